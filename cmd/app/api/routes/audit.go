@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoutes(r *gin.RouterGroup, s audit.Service) {
+	r.GET("", GetAuditLogs(s))
+}
+
+// @Summary Get Audit Logs
+// @Description Page through the changes to exchanges, symbols, signal intervals, users and api keys, newest first
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param entity_type query string false "exchanges, symbols, signal_intervals, users or api_keys"
+// @Param entity_id query string false "Entity ID"
+// @Param actor_id query string false "User or api key ID"
+// @Param action query string false "create, update or delete"
+// @Param from query string false "RFC3339 time, inclusive"
+// @Param to query string false "RFC3339 time, exclusive"
+// @Param page query int false "Page, defaults to 1"
+// @Param per_page query int false "Rows per page, defaults to 50, at most 500"
+// @Success 200 {object} dtos.PaginatedData
+// @Failure 400 {object} map[string]any
+// @Router /audit [GET]
+func GetAuditLogs(s audit.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.AuditLogReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.GetAuditLogs(c, req)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusInternalServerError,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
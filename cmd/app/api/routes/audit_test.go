@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) (dtos.PaginatedData, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.PaginatedData), args.Error(1)
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockAuditService)
+	router := gin.Default()
+	AuditRoutes(router.Group("/audit"), mockService)
+
+	t.Run("Filters", func(t *testing.T) {
+		req := dtos.AuditLogReq{
+			EntityType: "exchanges",
+			ActorID:    "user-1",
+			From:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Page:       2,
+		}
+		mockService.On("GetAuditLogs", mock.Anything, mock.MatchedBy(func(got dtos.AuditLogReq) bool {
+			return got.EntityType == req.EntityType && got.ActorID == req.ActorID && got.From.Equal(req.From) && got.Page == req.Page
+		})).Return(dtos.PaginatedData{Page: 2, PerPage: 50, Total: 51, TotalPages: 2, Rows: []dtos.AuditLogRes{{EntityType: "exchanges", Action: "update"}}}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/audit?entity_type=exchanges&actor_id=user-1&from=2025-01-01T00:00:00Z&page=2", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"action":"update"`)
+	})
+
+	t.Run("Invalid time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/audit?from=yesterday", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		mockService.On("GetAuditLogs", mock.Anything, dtos.AuditLogReq{}).Return(dtos.PaginatedData{}, errors.New("connection refused")).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/audit", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/auth"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func AuthRoutes(r *gin.RouterGroup, s auth.Service) {
+	r.POST("/login", Login(s))
+	r.POST("/refresh", RefreshToken(s))
+}
+
+// @Summary Login
+// @Description Check the credentials of a user and issue an access token and a refresh token
+// @Tags Auth Endpoints
+// @Accept json
+// @Produce json
+// @Param payload body dtos.LoginReq true "Login Request"
+// @Success 200 {object} dtos.TokenRes
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Router /auth/login [POST]
+func Login(s auth.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.LoginReq
+		if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  "username and password are required",
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		res, err := s.Login(c, req)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				status = http.StatusUnauthorized
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":   res,
+			"status": http.StatusOK,
+		})
+	}
+}
+
+// @Summary Refresh Token
+// @Description Trade a refresh token for a new access token and refresh token
+// @Tags Auth Endpoints
+// @Accept json
+// @Produce json
+// @Param payload body dtos.RefreshTokenReq true "Refresh Token Request"
+// @Success 200 {object} dtos.TokenRes
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Router /auth/refresh [POST]
+func RefreshToken(s auth.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.RefreshTokenReq
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  "refresh_token is required",
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		res, err := s.Refresh(c, req)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, utils.ErrTokenInvalid) || errors.Is(err, utils.ErrTokenExpired) {
+				status = http.StatusUnauthorized
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":   res,
+			"status": http.StatusOK,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/auth"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) Login(ctx context.Context, req dtos.LoginReq) (dtos.TokenRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.TokenRes), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, req dtos.RefreshTokenReq) (dtos.TokenRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.TokenRes), args.Error(1)
+}
+
+func TestLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockAuthService)
+	router := gin.Default()
+	AuthRoutes(router.Group("/auth"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("Login", mock.Anything, dtos.LoginReq{Username: "trader", Password: "pass"}).
+			Return(dtos.TokenRes{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 3600}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"trader","password":"pass"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"access_token":"access"`)
+		assert.Contains(t, w.Body.String(), `"refresh_token":"refresh"`)
+	})
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		mockService.On("Login", mock.Anything, dtos.LoginReq{Username: "trader", Password: "wrong"}).
+			Return(dtos.TokenRes{}, auth.ErrInvalidCredentials).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"trader","password":"wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing password", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"trader"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRefreshToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockAuthService)
+	router := gin.Default()
+	AuthRoutes(router.Group("/auth"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("Refresh", mock.Anything, dtos.RefreshTokenReq{RefreshToken: "refresh"}).
+			Return(dtos.TokenRes{AccessToken: "access-2", RefreshToken: "refresh-2"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{"refresh_token":"refresh"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"access_token":"access-2"`)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		mockService.On("Refresh", mock.Anything, dtos.RefreshTokenReq{RefreshToken: "old"}).
+			Return(dtos.TokenRes{}, utils.ErrTokenExpired).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{"refresh_token":"old"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
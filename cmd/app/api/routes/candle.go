@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	ctlog "github.com/SametAvcii/crypto-trade/pkg/ctlog"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func CandleRoutes(r *gin.RouterGroup, s candle.Service) {
+	r.POST("/backfill", StartBackfill(s))
+	r.GET("/backfill", GetBackfillJobs(s))
+	r.GET("/backfill/:id", GetBackfillJob(s))
+	r.POST("/rollup", RollupCandles(s))
+}
+
+// @Summary Start Candle Backfill
+// @Description Detect the missing candles of the symbol interval in the range and fetch them from the exchange in the background
+// @Tags Candle Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.BackfillReq true "Backfill Request"
+// @Success 202 {object} dtos.BackfillJobRes
+// @Failure 400 {object} map[string]any
+// @Router /candles/backfill [POST]
+func StartBackfill(s candle.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.BackfillReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		if req.ExchangeID == "" || req.Symbol == "" || req.Interval == "" {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  "exchange_id, symbol and interval are required",
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.StartBackfill(c, req)
+		if err != nil {
+			ctlog.For("candlestick").ErrorContext(c.Request.Context(), "Start Backfill Error", ctlog.Err(err))
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		ctlog.For("candlestick").InfoContext(c.Request.Context(), "Start Backfill", ctlog.Symbol(res.Symbol), slog.String("interval", res.Interval), slog.String("job_id", res.ID))
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"data":   res,
+			"status": http.StatusAccepted,
+		})
+	}
+}
+
+// @Summary Get Candle Backfill Jobs
+// @Description List the backfill jobs started since the app started with their progress
+// @Tags Candle Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Success 200 {array} dtos.BackfillJobRes
+// @Failure 400 {object} map[string]any
+// @Router /candles/backfill [GET]
+func GetBackfillJobs(s candle.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		jobs, err := s.GetJobs(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   jobs,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Get Candle Backfill Job
+// @Description Get the progress of a backfill job
+// @Tags Candle Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} dtos.BackfillJobRes
+// @Failure 404 {object} map[string]any
+// @Router /candles/backfill/{id} [GET]
+func GetBackfillJob(s candle.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		job, err := s.GetJob(c, c.Param("id"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, candle.ErrJobNotFound) {
+				status = http.StatusNotFound
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   job,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Roll Up Candles
+// @Description Recompute the derived interval bars of the exchange symbol from the stored 1m candles
+// @Tags Candle Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.RollupReq true "Rollup Request"
+// @Success 200 {object} dtos.RollupRes
+// @Failure 400 {object} map[string]any
+// @Router /candles/rollup [POST]
+func RollupCandles(s candle.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.RollupReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		if req.ExchangeID == "" || req.Symbol == "" {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  "exchange_id and symbol are required",
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.Rollup(c, req)
+		if err != nil {
+			ctlog.For("candlestick").ErrorContext(c.Request.Context(), "Rollup Candles Error", ctlog.Err(err))
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		ctlog.For("candlestick").InfoContext(c.Request.Context(), "Rollup Candles", ctlog.Exchange(res.ExchangeID), ctlog.Symbol(res.Symbol), slog.Any("bars", res.Bars))
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
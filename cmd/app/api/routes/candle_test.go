@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCandleService struct {
+	mock.Mock
+}
+
+func (m *MockCandleService) StartBackfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.BackfillJobRes), args.Error(1)
+}
+
+func (m *MockCandleService) Backfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.BackfillJobRes), args.Error(1)
+}
+
+func (m *MockCandleService) BackfillRecent(ctx context.Context, days int) error {
+	args := m.Called(ctx, days)
+	return args.Error(0)
+}
+
+func (m *MockCandleService) Rollup(ctx context.Context, req dtos.RollupReq) (dtos.RollupRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.RollupRes), args.Error(1)
+}
+
+func (m *MockCandleService) GetJob(ctx context.Context, id string) (dtos.BackfillJobRes, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(dtos.BackfillJobRes), args.Error(1)
+}
+
+func (m *MockCandleService) GetJobs(ctx context.Context) ([]dtos.BackfillJobRes, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dtos.BackfillJobRes), args.Error(1)
+}
+
+func TestStartBackfill(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockCandleService)
+	router := gin.Default()
+	CandleRoutes(router.Group("/candles"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		req := dtos.BackfillReq{
+			ExchangeID: "1",
+			Symbol:     "BTCUSDT",
+			Interval:   "1m",
+			From:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		mockService.On("StartBackfill", mock.Anything, req).Return(dtos.BackfillJobRes{ID: "job", Status: "pending"}, nil).Once()
+
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/candles/backfill", bytes.NewBuffer(body)))
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"job"`)
+	})
+
+	t.Run("MissingFields", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/candles/backfill", bytes.NewBufferString(`{"symbol":"BTCUSDT"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetBackfillJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockCandleService)
+	router := gin.Default()
+	CandleRoutes(router.Group("/candles"), mockService)
+
+	mockService.On("GetJob", mock.Anything, "job").Return(dtos.BackfillJobRes{ID: "job", Status: "done"}, nil)
+	mockService.On("GetJob", mock.Anything, "missing").Return(dtos.BackfillJobRes{}, candle.ErrJobNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/candles/backfill/job", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/candles/backfill/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRollupCandles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockCandleService)
+	router := gin.Default()
+	CandleRoutes(router.Group("/candles"), mockService)
+
+	req := dtos.RollupReq{ExchangeID: "1", Symbol: "BTCUSDT", Intervals: []string{"1h"}, From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	mockService.On("Rollup", mock.Anything, req).Return(dtos.RollupRes{ExchangeID: "1", Symbol: "BTCUSDT", Bars: map[string]int{"1h": 24}}, nil)
+
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/candles/rollup", bytes.NewBuffer(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"1h":24`)
+
+	// a symbol can be listed on several exchanges
+	body, _ = json.Marshal(dtos.RollupReq{Symbol: "BTCUSDT", From: req.From})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/candles/rollup", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
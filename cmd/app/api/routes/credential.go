@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/credential"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func CredentialRoutes(r *gin.RouterGroup, s credential.Service) {
+	r.POST("", AddCredential(s))
+	r.GET("", GetAllCredentials(s))
+	r.DELETE("/:id", DeleteCredential(s))
+	r.POST("/rotate", RotateCredentials(s))
+}
+
+// @Summary Add Exchange Credential
+// @Description Store the api key and secret of an exchange account, they are encrypted and never returned
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.AddCredentialReq true "Credential"
+// @Success 201 {object} dtos.CredentialRes
+// @Failure 400 {object} map[string]any
+// @Router /admin/credentials [POST]
+func AddCredential(s credential.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.AddCredentialReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.AddCredential(c, req)
+		if err != nil {
+			status := credentialStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(201, gin.H{
+			"data":   res,
+			"status": 201,
+		})
+	}
+}
+
+// @Summary Get All Exchange Credentials
+// @Description List the exchange credentials with a hint of their api key
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} dtos.CredentialRes
+// @Failure 500 {object} map[string]any
+// @Router /admin/credentials [GET]
+func GetAllCredentials(s credential.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		res, err := s.GetAllCredentials(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusInternalServerError,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Delete Exchange Credential
+// @Description Delete an exchange credential for good
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Success 200 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /admin/credentials/{id} [DELETE]
+func DeleteCredential(s credential.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if err := s.DeleteCredential(c, c.Param("id")); err != nil {
+			status := credentialStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Credential deleted successfully", "status": 200})
+	}
+}
+
+// @Summary Rotate Master Key
+// @Description Rewrap every credential with the active master key, run it after a new master key is made active
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} dtos.RotateCredentialsRes
+// @Failure 500 {object} map[string]any
+// @Router /admin/credentials/rotate [POST]
+func RotateCredentials(s credential.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		res, err := s.RotateCredentials(c)
+		if err != nil {
+			status := credentialStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+func credentialStatus(err error) int {
+	switch {
+	case errors.Is(err, credential.ErrInvalidCredentialReq):
+		return http.StatusBadRequest
+	case errors.Is(err, credential.ErrCredentialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, credential.ErrNoMasterKey):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/credential"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCredentialService struct {
+	mock.Mock
+}
+
+func (m *MockCredentialService) AddCredential(ctx context.Context, req dtos.AddCredentialReq) (dtos.CredentialRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.CredentialRes), args.Error(1)
+}
+
+func (m *MockCredentialService) GetAllCredentials(ctx context.Context) ([]dtos.CredentialRes, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dtos.CredentialRes), args.Error(1)
+}
+
+func (m *MockCredentialService) DeleteCredential(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCredentialService) RotateCredentials(ctx context.Context) (dtos.RotateCredentialsRes, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(dtos.RotateCredentialsRes), args.Error(1)
+}
+
+func (m *MockCredentialService) Signer(ctx context.Context, id string) (utils.Signer, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(utils.Signer), args.Error(1)
+}
+
+func TestAddCredential(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockCredentialService)
+	router := gin.Default()
+	CredentialRoutes(router.Group("/admin/credentials"), mockService)
+	body := `{"exchange_id":"6f1c2a5e-0d7a-4d59-9f3b-7c1f0c1f2b3a","label":"main","api_key":"key-0123456789","api_secret":"super-secret"}`
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("AddCredential", mock.Anything, mock.MatchedBy(func(req dtos.AddCredentialReq) bool {
+			return req.APISecret.Reveal() == "super-secret"
+		})).Return(dtos.CredentialRes{ID: "1", Label: "main", APIKeyHint: "****6789"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/admin/credentials", bytes.NewBufferString(body))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NotContains(t, w.Body.String(), "super-secret")
+		assert.NotContains(t, w.Body.String(), "key-0123456789")
+	})
+
+	t.Run("No master key", func(t *testing.T) {
+		mockService.On("AddCredential", mock.Anything, mock.Anything).Return(dtos.CredentialRes{}, credential.ErrNoMasterKey).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/admin/credentials", bytes.NewBufferString(body))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
+
+func TestRotateCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockCredentialService)
+	router := gin.Default()
+	CredentialRoutes(router.Group("/admin/credentials"), mockService)
+
+	mockService.On("RotateCredentials", mock.Anything).Return(dtos.RotateCredentialsRes{MasterKeyID: "k2", Rotated: 3}, nil).Once()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/admin/credentials/rotate", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"rotated":3`)
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/deadletter"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func DeadLetterRoutes(r *gin.RouterGroup, s deadletter.Service) {
+	r.GET("/:topic", ListDeadLetters(s))
+	r.POST("/:topic/replay", ReplayDeadLetters(s))
+}
+
+// @Summary List Dead Letters
+// @Description List the latest messages moved to the dead letter topic of a consumed topic with the error they failed with
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param topic path string true "Source topic"
+// @Param limit query int false "Messages returned, defaults to 50"
+// @Success 200 {array} dtos.DeadLetterRes
+// @Failure 400 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /admin/dlq/{topic} [GET]
+func ListDeadLetters(s deadletter.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.DeadLetterListReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.List(c, c.Param("topic"), req.Limit)
+		if err != nil {
+			status := deadLetterStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Replay Dead Letters
+// @Description Produce dead lettered messages back onto the topic they failed on, only the consumer group they failed in handles them again
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param topic path string true "Source topic"
+// @Param payload body dtos.DeadLetterReplayReq true "Messages to replay"
+// @Success 200 {object} dtos.DeadLetterReplayRes
+// @Failure 400 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /admin/dlq/{topic}/replay [POST]
+func ReplayDeadLetters(s deadletter.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.DeadLetterReplayReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.Replay(c, c.Param("topic"), req)
+		if err != nil {
+			status := deadLetterStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"data":   res,
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+func deadLetterStatus(err error) int {
+	switch {
+	case errors.Is(err, deadletter.ErrUnknownTopic), errors.Is(err, deadletter.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, deadletter.ErrInvalidReplay):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/deadletter"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeadLetterService struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterService) List(ctx context.Context, topic string, limit int) ([]dtos.DeadLetterRes, error) {
+	args := m.Called(ctx, topic, limit)
+	return args.Get(0).([]dtos.DeadLetterRes), args.Error(1)
+}
+
+func (m *MockDeadLetterService) Replay(ctx context.Context, topic string, req dtos.DeadLetterReplayReq) (dtos.DeadLetterReplayRes, error) {
+	args := m.Called(ctx, topic, req)
+	return args.Get(0).(dtos.DeadLetterReplayRes), args.Error(1)
+}
+
+func TestListDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockDeadLetterService)
+	router := gin.Default()
+	DeadLetterRoutes(router.Group("/admin/dlq"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("List", mock.Anything, "depth-data-pg", 10).Return([]dtos.DeadLetterRes{
+			{Topic: "depth-data-pg.dlq", Offset: 4, Error: "connection refused", ErrorType: "retryable", Attempts: 3},
+		}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin/dlq/depth-data-pg?limit=10", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"connection refused"`)
+		assert.Contains(t, w.Body.String(), `"attempts":3`)
+	})
+
+	t.Run("Unknown topic", func(t *testing.T) {
+		mockService.On("List", mock.Anything, "trades", 0).Return([]dtos.DeadLetterRes(nil), deadletter.ErrUnknownTopic).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin/dlq/trades", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockDeadLetterService)
+	router := gin.Default()
+	DeadLetterRoutes(router.Group("/admin/dlq"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		replayReq := dtos.DeadLetterReplayReq{Messages: []dtos.DeadLetterRef{{Partition: 1, Offset: 4}}}
+		mockService.On("Replay", mock.Anything, "depth-data-pg", replayReq).Return(dtos.DeadLetterReplayRes{
+			Replayed: []dtos.DeadLetterReplayed{{DeadLetterRef: dtos.DeadLetterRef{Partition: 1, Offset: 4}, SourceTopic: "depth-data-pg", ReplayOffset: 90}},
+		}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/admin/dlq/depth-data-pg/replay", bytes.NewBufferString(`{"messages":[{"partition":1,"offset":4}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"replay_offset":90`)
+	})
+
+	t.Run("Message not found", func(t *testing.T) {
+		replayReq := dtos.DeadLetterReplayReq{Messages: []dtos.DeadLetterRef{{Partition: 0, Offset: 99}}}
+		mockService.On("Replay", mock.Anything, "depth-data-pg", replayReq).Return(dtos.DeadLetterReplayRes{}, deadletter.ErrNotFound).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/admin/dlq/depth-data-pg/replay", bytes.NewBufferString(`{"messages":[{"partition":0,"offset":99}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/admin/dlq/depth-data-pg/replay", bytes.NewBufferString(`{`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
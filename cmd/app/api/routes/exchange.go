@@ -6,7 +6,6 @@ import (
 	ctlog "github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/exchange"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
 	"github.com/gin-gonic/gin"
 )
 
@@ -41,12 +40,7 @@ func AddExchange(s exchange.Service) func(c *gin.Context) {
 
 		res, err := s.AddExchange(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Add Exchange Error",
-				Message: "Add Exchange err: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			})
+			ctlog.For("exchange").ErrorContext(c.Request.Context(), "Add Exchange Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -54,12 +48,7 @@ func AddExchange(s exchange.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Add Exchange",
-			Message: "Add exchange success: " + res.Name,
-			Entity:  "exchange",
-			Type:    "success",
-		})
+		ctlog.For("exchange").InfoContext(c.Request.Context(), "Add Exchange", ctlog.Exchange(res.Name))
 
 		c.JSON(201, gin.H{
 			"data":   res,
@@ -89,21 +78,11 @@ func UpdateExchange(s exchange.Service) func(c *gin.Context) {
 		req.ID = c.Param("id")
 		res, err := s.Update(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Update Exchange Error",
-				Message: "Update exchange err: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			})
+			ctlog.For("exchange").ErrorContext(c.Request.Context(), "Update Exchange Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Update Exchange",
-			Message: "Update exchange success: " + res.Name,
-			Entity:  "exchange",
-			Type:    "success",
-		})
+		ctlog.For("exchange").InfoContext(c.Request.Context(), "Update Exchange", ctlog.Exchange(res.Name))
 
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
@@ -124,21 +103,11 @@ func GetExchangeById(s exchange.Service) func(c *gin.Context) {
 		id := c.Param("id")
 		res, err := s.GetById(c, id)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get Exchange Error",
-				Message: "Get exchange err: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			})
+			ctlog.For("exchange").ErrorContext(c.Request.Context(), "Get Exchange Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get Exchange",
-			Message: "Get exchange success: " + res.Name,
-			Entity:  "exchange",
-			Type:    "success",
-		})
+		ctlog.For("exchange").DebugContext(c.Request.Context(), "Get Exchange", ctlog.Exchange(res.Name))
 
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
@@ -158,21 +127,11 @@ func DeleteExchange(s exchange.Service) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		if err := s.Delete(c, id); err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Delete Exchange Error",
-				Message: "Delete exchange err: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			})
+			ctlog.For("exchange").ErrorContext(c.Request.Context(), "Delete Exchange Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Delete Exchange",
-			Message: "Delete exchange success",
-			Entity:  "exchange",
-			Type:    "success",
-		})
+		ctlog.For("exchange").InfoContext(c.Request.Context(), "Delete Exchange")
 
 		c.JSON(200, gin.H{"message": "Exchange deleted successfully", "status": 200})
 	}
@@ -191,23 +150,13 @@ func GetAllExchanges(s exchange.Service) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		res, err := s.GetAll(c)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get All Exchanges Error",
-				Message: "Get all exchanges err: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			})
+			ctlog.For("exchange").ErrorContext(c.Request.Context(), "Get All Exchanges Error", ctlog.Err(err))
 
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get All Exchanges",
-			Message: "Get all exchanges success",
-			Entity:  "exchange",
-			Type:    "success",
-		})
+		ctlog.For("exchange").DebugContext(c.Request.Context(), "Get All Exchanges")
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
 }
@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/logs"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func LogRoutes(r *gin.RouterGroup, s logs.Service) {
+	r.GET("", GetLogs(s))
+	r.GET("/error-rate", GetErrorRate(s))
+	r.GET("/top-errors", GetTopErrors(s))
+}
+
+// @Summary Get Logs
+// @Description Page through the logs of the services, newest first, q searches the words of the title and message, the last day by default
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "debug, info, warn or error"
+// @Param entity query string false "stream, candlestick, signal, symbol, exchange, etc"
+// @Param symbol query string false "Symbol the entry was logged for"
+// @Param q query string false "Full-text search over title and message"
+// @Param from query string false "RFC3339 time, inclusive, defaults to a day before to"
+// @Param to query string false "RFC3339 time, exclusive, defaults to now, at most 7 days after from"
+// @Param page query int false "Page, defaults to 1"
+// @Param per_page query int false "Rows per page, defaults to 50, at most 500"
+// @Success 200 {object} dtos.PaginatedData
+// @Failure 400 {object} map[string]any
+// @Router /logs [GET]
+func GetLogs(s logs.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.LogReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.GetLogs(c, req)
+		if err != nil {
+			status := logStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Get Error Rate
+// @Description Count the logs of each entity per minute, the errors of the last hour by default
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "Defaults to error"
+// @Param entity query string false "Entity"
+// @Param symbol query string false "Symbol the entry was logged for"
+// @Param from query string false "RFC3339 time, inclusive, defaults to an hour before to"
+// @Param to query string false "RFC3339 time, exclusive, defaults to now, at most 7 days after from"
+// @Success 200 {array} dtos.ErrorRateRes
+// @Failure 400 {object} map[string]any
+// @Router /logs/error-rate [GET]
+func GetErrorRate(s logs.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.LogStatsReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.GetErrorRate(c, req)
+		if err != nil {
+			status := logStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Get Top Errors
+// @Description Group the logs of each entity by message with the numbers, ids and quoted values replaced, most frequent first
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param type query string false "Defaults to error"
+// @Param entity query string false "Entity"
+// @Param symbol query string false "Symbol the entry was logged for"
+// @Param from query string false "RFC3339 time, inclusive, defaults to an hour before to"
+// @Param to query string false "RFC3339 time, exclusive, defaults to now, at most 7 days after from"
+// @Param limit query int false "Defaults to 20, at most 100"
+// @Success 200 {array} dtos.TopErrorRes
+// @Failure 400 {object} map[string]any
+// @Router /logs/top-errors [GET]
+func GetTopErrors(s logs.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.LogStatsReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.GetTopErrors(c, req)
+		if err != nil {
+			status := logStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+func logStatus(err error) int {
+	if errors.Is(err, logs.ErrInvalidRange) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/logs"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLogService struct {
+	mock.Mock
+}
+
+func (m *MockLogService) GetLogs(ctx context.Context, req dtos.LogReq) (dtos.PaginatedData, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.PaginatedData), args.Error(1)
+}
+
+func (m *MockLogService) GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]dtos.ErrorRateRes), args.Error(1)
+}
+
+func (m *MockLogService) GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]dtos.TopErrorRes), args.Error(1)
+}
+
+func TestGetLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockLogService)
+	router := gin.Default()
+	LogRoutes(router.Group("/logs"), mockService)
+
+	t.Run("Search", func(t *testing.T) {
+		mockService.On("GetLogs", mock.Anything, dtos.LogReq{Type: "error", Entity: "stream", Query: "read error", Page: 2}).
+			Return(dtos.PaginatedData{Page: 2, PerPage: 50, Total: 51, TotalPages: 2, Rows: []dtos.LogRes{{Title: "WebSocket read error"}}}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/logs?type=error&entity=stream&q=read+error&page=2", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"WebSocket read error"`)
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		mockService.On("GetLogs", mock.Anything, mock.Anything).Return(dtos.PaginatedData{}, logs.ErrInvalidRange).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/logs?from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetErrorRate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockLogService)
+	router := gin.Default()
+	LogRoutes(router.Group("/logs"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("GetErrorRate", mock.Anything, dtos.LogStatsReq{Entity: "stream", Symbol: "BTCUSDT"}).
+			Return([]dtos.ErrorRateRes{{Entity: "stream", Count: 12}}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/logs/error-rate?entity=stream&symbol=BTCUSDT", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"count":12`)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		mockService.On("GetErrorRate", mock.Anything, dtos.LogStatsReq{}).Return([]dtos.ErrorRateRes(nil), errors.New("connection refused")).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodGet, "/logs/error-rate", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestGetTopErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockLogService)
+	router := gin.Default()
+	LogRoutes(router.Group("/logs"), mockService)
+
+	mockService.On("GetTopErrors", mock.Anything, dtos.LogStatsReq{Limit: 5}).
+		Return([]dtos.TopErrorRes{{Entity: "stream", Pattern: "WebSocket read error: <value>", Count: 40}}, nil).Once()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodGet, "/logs/top-errors?limit=5", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":40`)
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/orderbook"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+)
+
+func OrderBookRoutes(r *gin.RouterGroup, s orderbook.Service) {
+	r.GET("/history", GetOrderBookAt(s))
+}
+
+// @Summary Get Order Book At
+// @Description Rebuild the order book of the symbol at a past time from the nearest snapshot and the level changes made since
+// @Tags Order Book Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param exchange_id query string true "Exchange ID"
+// @Param symbol query string true "Symbol"
+// @Param at query string false "Unix ms or RFC3339 time, defaults to now"
+// @Param depth query int false "Levels per side"
+// @Success 200 {object} dtos.OrderBookAtRes
+// @Failure 400 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /orderbook/history [GET]
+func GetOrderBookAt(s orderbook.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.OrderBookAtReq
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		if at := c.Query("at"); at != "" {
+			t, err := parseTimestamp(at)
+			if err != nil {
+				c.AbortWithStatusJSON(400, gin.H{
+					"error":  "at must be unix milliseconds or RFC3339",
+					"status": 400,
+				})
+				return
+			}
+			req.At = t
+		}
+
+		res, err := s.GetBookAt(c, req)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, orderbook.ErrBookNotFound) {
+				status = http.StatusNotFound
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/domains/orderbook"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOrderBookService struct {
+	mock.Mock
+}
+
+func (m *MockOrderBookService) Compact(ctx context.Context, now time.Time) (dtos.CompactionRes, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(dtos.CompactionRes), args.Error(1)
+}
+
+func (m *MockOrderBookService) GetBookAt(ctx context.Context, req dtos.OrderBookAtReq) (dtos.OrderBookAtRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.OrderBookAtRes), args.Error(1)
+}
+
+func TestGetOrderBookAt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockOrderBookService)
+	router := gin.Default()
+	OrderBookRoutes(router.Group("/orderbook"), mockService)
+
+	at := time.UnixMilli(1790812800000).UTC()
+
+	t.Run("Success", func(t *testing.T) {
+		req := dtos.OrderBookAtReq{ExchangeID: "ex-1", Symbol: "BTCUSDT", At: at, Depth: 10}
+		mockService.On("GetBookAt", mock.Anything, req).Return(dtos.OrderBookAtRes{
+			ExchangeID: "ex-1",
+			Symbol:     "BTCUSDT",
+			At:         at,
+			Bids:       [][]string{{"100.00", "1"}},
+		}, nil).Once()
+
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest(http.MethodGet, "/orderbook/history?exchange_id=ex-1&symbol=BTCUSDT&at=1790812800000&depth=10", nil)
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"bids":[["100.00","1"]]`)
+	})
+
+	t.Run("Invalid time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest(http.MethodGet, "/orderbook/history?exchange_id=ex-1&symbol=BTCUSDT&at=yesterday", nil)
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		req := dtos.OrderBookAtReq{ExchangeID: "ex-1", Symbol: "ETHUSDT", At: at}
+		mockService.On("GetBookAt", mock.Anything, req).Return(dtos.OrderBookAtRes{}, orderbook.ErrBookNotFound).Once()
+
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest(http.MethodGet, "/orderbook/history?exchange_id=ex-1&symbol=ETHUSDT&at=2026-10-01T00:00:00Z", nil)
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -6,7 +6,6 @@ import (
 	ctlog "github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/signal"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,12 +41,7 @@ func AddSignalInterval(s signal.Service) func(c *gin.Context) {
 
 		res, err := s.AddSignalIntervals(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Add Signal Interval Error",
-				Message: "Add Signal Interval err: " + err.Error(),
-				Entity:  "signal",
-				Type:    "error",
-			})
+			ctlog.For("signal").ErrorContext(c.Request.Context(), "Add Signal Interval Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -55,12 +49,7 @@ func AddSignalInterval(s signal.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Add Signal Interval",
-			Message: "Add Signal Interval success: " + res.Symbol,
-			Entity:  "signal",
-			Type:    "success",
-		})
+		ctlog.For("signal").InfoContext(c.Request.Context(), "Add Signal Interval", ctlog.Symbol(res.Symbol))
 
 		c.JSON(201, gin.H{
 			"data":   res,
@@ -92,22 +81,12 @@ func UpdateSignalInterval(s signal.Service) func(c *gin.Context) {
 
 		res, err := s.UpdateSignalIntervals(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Update Signal Interval Error",
-				Message: "Update Signal Interval err: " + err.Error(),
-				Entity:  "signal",
-				Type:    "error",
-			})
+			ctlog.For("signal").ErrorContext(c.Request.Context(), "Update Signal Interval Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Update Signal Interval",
-			Message: "Update Signal Interval success: " + res.Symbol,
-			Entity:  "signal",
-			Type:    "success",
-		})
+		ctlog.For("signal").InfoContext(c.Request.Context(), "Update Signal Interval", ctlog.Symbol(res.Symbol))
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
 }
@@ -127,22 +106,12 @@ func DeleteSignalInterval(s signal.Service) func(c *gin.Context) {
 		id := c.Param("id")
 		err := s.DeleteSignalIntervals(c, id)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Delete Signal Interval Error",
-				Message: "Delete Signal Interval err: " + err.Error(),
-				Entity:  "signal",
-				Type:    "error",
-			})
+			ctlog.For("signal").ErrorContext(c.Request.Context(), "Delete Signal Interval Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Delete Signal Interval",
-			Message: "Delete Signal Interval success",
-			Entity:  "signal",
-			Type:    "success",
-		})
+		ctlog.For("signal").InfoContext(c.Request.Context(), "Delete Signal Interval")
 		c.JSON(200, gin.H{"message": "Successfully deleted", "status": 200})
 	}
 }
@@ -162,22 +131,12 @@ func GetSignalIntervalByID(s signal.Service) func(c *gin.Context) {
 		id := c.Param("id")
 		res, err := s.GetSignalIntervalById(c, id)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get Signal Interval Error",
-				Message: "Get Signal Interval err: " + err.Error(),
-				Entity:  "signal",
-				Type:    "error",
-			})
+			ctlog.For("signal").ErrorContext(c.Request.Context(), "Get Signal Interval Error", ctlog.Err(err))
 
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get Signal Interval",
-			Message: "Get Signal Interval success: " + res.Symbol,
-			Entity:  "signal",
-			Type:    "success",
-		})
+		ctlog.For("signal").DebugContext(c.Request.Context(), "Get Signal Interval", ctlog.Symbol(res.Symbol))
 
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
@@ -196,23 +155,13 @@ func GetAllSignalIntervals(s signal.Service) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		res, err := s.GetAllSignalIntervals(c)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get All Signal Intervals Error",
-				Message: "Get All Signal Intervals err: " + err.Error(),
-				Entity:  "signal",
-				Type:    "error",
-			})
+			ctlog.For("signal").ErrorContext(c.Request.Context(), "Get All Signal Intervals Error", ctlog.Err(err))
 
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": http.StatusBadRequest})
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get All Signal Intervals",
-			Message: "Get All Signal Intervals success",
-			Entity:  "signal",
-			Type:    "success",
-		})
+		ctlog.For("signal").DebugContext(c.Request.Context(), "Get All Signal Intervals")
 
 		c.JSON(200, gin.H{"data": res, "status": 200})
 	}
@@ -1,17 +1,18 @@
 package routes
 
 import (
+	"log/slog"
 	"net/http"
 
 	ctlog "github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/symbol"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
 	"github.com/gin-gonic/gin"
 )
 
 func SymbolRoutes(r *gin.RouterGroup, s symbol.Service) {
 	r.POST("/", AddSymbol(s))
+	r.POST("/import", ImportSymbols(s))
 	r.PUT("/:id", UpdateSymbol(s))
 	r.DELETE("/:id", DeleteSymbol(s))
 	r.GET("/", GetAllSymbols(s))
@@ -42,12 +43,7 @@ func AddSymbol(s symbol.Service) func(c *gin.Context) {
 
 		res, err := s.AddSymbol(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Add Symbol Error",
-				Message: "Add symbol err: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			})
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Add Symbol Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -55,12 +51,55 @@ func AddSymbol(s symbol.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Add Symbol",
-			Message: "Add symbol success: " + res.Symbol,
-			Entity:  "symbol",
-			Type:    "success",
+		ctlog.For("symbol").InfoContext(c.Request.Context(), "Add Symbol", ctlog.Symbol(res.Symbol))
+
+		c.JSON(201, gin.H{
+			"data":   res,
+			"status": 201,
 		})
+	}
+}
+
+// @Summary Import Symbols
+// @Description Import every trading symbol of the exchange quoted in the given asset
+// @Tags Symbol Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.ImportSymbolsReq true "Import Symbols Request"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Router /symbol/import [POST]
+func ImportSymbols(s symbol.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.ImportSymbolsReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		if req.ExchangeID == "" || req.QuoteAsset == "" {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  "exchange_id and quote_asset are required",
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.ImportSymbols(c, req)
+		if err != nil {
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Import Symbols Error", ctlog.Err(err))
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusBadRequest,
+			})
+			return
+		}
+
+		ctlog.For("symbol").InfoContext(c.Request.Context(), "Import Symbols", slog.Int("imported", res.Imported), slog.String("quote_asset", req.QuoteAsset))
 
 		c.JSON(201, gin.H{
 			"data":   res,
@@ -96,12 +135,7 @@ func UpdateSymbol(s symbol.Service) func(c *gin.Context) {
 
 		res, err := s.UpdateSymbol(c, req)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Update Symbol Error",
-				Message: "Update symbol err: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			})
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Update Symbol Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -109,12 +143,7 @@ func UpdateSymbol(s symbol.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Update Symbol",
-			Message: "Update symbol success: " + res.Symbol,
-			Entity:  "symbol",
-			Type:    "success",
-		})
+		ctlog.For("symbol").InfoContext(c.Request.Context(), "Update Symbol", ctlog.Symbol(res.Symbol))
 
 		c.JSON(200, gin.H{
 			"data":   res,
@@ -138,12 +167,7 @@ func DeleteSymbol(s symbol.Service) func(c *gin.Context) {
 		id := c.Param("id")
 		err := s.DeleteSymbol(c, id)
 		if err != nil {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Delete Symbol Error",
-				Message: "Delete symbol err: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			})
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Delete Symbol Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -151,12 +175,7 @@ func DeleteSymbol(s symbol.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Delete Symbol",
-			Message: "Delete symbol success",
-			Entity:  "symbol",
-			Type:    "success",
-		})
+		ctlog.For("symbol").InfoContext(c.Request.Context(), "Delete Symbol")
 
 		c.JSON(200, gin.H{
 			"message": "Symbol deleted successfully",
@@ -179,12 +198,7 @@ func GetAllSymbols(s symbol.Service) func(c *gin.Context) {
 		symbols, err := s.GetAllSymbols(c)
 		if err != nil {
 
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get All Symbols Error",
-				Message: "Get all symbols err: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			})
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Get All Symbols Error", ctlog.Err(err))
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
 				"status": http.StatusBadRequest,
@@ -192,12 +206,7 @@ func GetAllSymbols(s symbol.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get All Symbols",
-			Message: "Get all symbols success",
-			Entity:  "symbol",
-			Type:    "success",
-		})
+		ctlog.For("symbol").DebugContext(c.Request.Context(), "Get All Symbols")
 
 		c.JSON(200, gin.H{
 			"data":   symbols,
@@ -222,12 +231,7 @@ func GetSymbolByID(s symbol.Service) func(c *gin.Context) {
 		symbol, err := s.GetSymbol(c, id)
 		if err != nil {
 
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Get Symbol Error",
-				Message: "Get symbol err: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			})
+			ctlog.For("symbol").ErrorContext(c.Request.Context(), "Get Symbol Error", ctlog.Err(err))
 
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 				"error":  err.Error(),
@@ -236,12 +240,7 @@ func GetSymbolByID(s symbol.Service) func(c *gin.Context) {
 			return
 		}
 
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get Symbol",
-			Message: "Get symbol success: " + symbol.Symbol,
-			Entity:  "symbol",
-			Type:    "success",
-		})
+		ctlog.For("symbol").DebugContext(c.Request.Context(), "Get Symbol", ctlog.Symbol(symbol.Symbol))
 
 		c.JSON(200, gin.H{
 			"data":   symbol,
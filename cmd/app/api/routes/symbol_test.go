@@ -27,6 +27,16 @@ func (m *MockSymbolService) AddSymbol(ctx context.Context, req dtos.AddSymbolReq
 	return args.Get(0).(dtos.AddSymbolRes), args.Error(1)
 }
 
+func (m *MockSymbolService) ImportSymbols(ctx context.Context, req dtos.ImportSymbolsReq) (dtos.ImportSymbolsRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.ImportSymbolsRes), args.Error(1)
+}
+
+func (m *MockSymbolService) SyncMetadata(ctx context.Context, exchangeID string) error {
+	args := m.Called(ctx, exchangeID)
+	return args.Error(0)
+}
+
 func (m *MockSymbolService) UpdateSymbol(ctx context.Context, req dtos.UpdateSymbolReq) (dtos.UpdateSymbolRes, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
@@ -102,6 +112,51 @@ func TestAddSymbol(t *testing.T) {
 	})
 }
 
+func TestImportSymbols(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockSymbolService)
+	router := gin.Default()
+	group := router.Group("/symbol")
+	SymbolRoutes(group, mockService)
+
+	t.Run("success", func(t *testing.T) {
+		req := dtos.ImportSymbolsReq{ExchangeID: "1", QuoteAsset: "USDT"}
+		expectedRes := dtos.ImportSymbolsRes{
+			Imported: 1,
+			Symbols:  []dtos.AddSymbolRes{{ID: "1", Symbol: "btcusdt", ExchangeID: "1"}},
+		}
+
+		mockService.On("ImportSymbols", mock.Anything, req).Return(expectedRes, nil)
+
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/symbol/import", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		router.ServeHTTP(w, r)
+		assert.Equal(t, 201, w.Code)
+
+		var response struct {
+			Data   dtos.ImportSymbolsRes `json:"data"`
+			Status int                   `json:"status"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedRes, response.Data)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("missing quote asset", func(t *testing.T) {
+		body, _ := json.Marshal(dtos.ImportSymbolsReq{ExchangeID: "1"})
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/symbol/import", bytes.NewBuffer(body))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
 func TestUpdateSymbol(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/user"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func UserRoutes(r *gin.RouterGroup, s user.Service) {
+	r.POST("/users", AddUser(s))
+	r.GET("/users", GetAllUsers(s))
+	r.POST("/api-keys", AddAPIKey(s))
+	r.GET("/api-keys", GetAllAPIKeys(s))
+	r.DELETE("/api-keys/:id", RevokeAPIKey(s))
+}
+
+// @Summary Add User
+// @Description Create an account that logs in with a password, the role is admin, trader or viewer
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.AddUserReq true "User"
+// @Success 201 {object} dtos.UserRes
+// @Failure 400 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Router /admin/users [POST]
+func AddUser(s user.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.AddUserReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+
+		res, err := s.AddUser(c, req)
+		if err != nil {
+			status := userStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(201, gin.H{
+			"data":   res,
+			"status": 201,
+		})
+	}
+}
+
+// @Summary Get All Users
+// @Description List the accounts and their roles
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} dtos.UserRes
+// @Failure 500 {object} map[string]any
+// @Router /admin/users [GET]
+func GetAllUsers(s user.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		res, err := s.GetAllUsers(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusInternalServerError,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Add API Key
+// @Description Create a key for service to service calls, it is sent in the data-api-key header and only returned once
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param payload body dtos.AddAPIKeyReq true "API Key"
+// @Success 201 {object} dtos.AddAPIKeyRes
+// @Failure 400 {object} map[string]any
+// @Router /admin/api-keys [POST]
+func AddAPIKey(s user.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var req dtos.AddAPIKeyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{
+				"error":  err.Error(),
+				"status": 400,
+			})
+			return
+		}
+		if p, ok := middleware.Caller(c); ok && p.Method == consts.AuthMethodToken {
+			req.UserID = p.ID
+		}
+
+		res, err := s.AddAPIKey(c, req)
+		if err != nil {
+			status := userStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(201, gin.H{
+			"data":   res,
+			"status": 201,
+		})
+	}
+}
+
+// @Summary Get All API Keys
+// @Description List the api keys without their secrets
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} dtos.APIKeyRes
+// @Failure 500 {object} map[string]any
+// @Router /admin/api-keys [GET]
+func GetAllAPIKeys(s user.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		res, err := s.GetAllAPIKeys(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":  err.Error(),
+				"status": http.StatusInternalServerError,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data":   res,
+			"status": 200,
+		})
+	}
+}
+
+// @Summary Revoke API Key
+// @Description Revoke an api key, calls with it are rejected from then on
+// @Tags Admin Endpoints
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "API Key ID"
+// @Success 200 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /admin/api-keys/{id} [DELETE]
+func RevokeAPIKey(s user.Service) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if err := s.RevokeAPIKey(c, c.Param("id")); err != nil {
+			status := userStatus(err)
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":  err.Error(),
+				"status": status,
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "API key revoked successfully", "status": 200})
+	}
+}
+
+func userStatus(err error) int {
+	switch {
+	case errors.Is(err, user.ErrInvalidUserReq), errors.Is(err, user.ErrInvalidAPIKeyReq):
+		return http.StatusBadRequest
+	case errors.Is(err, user.ErrUserExists):
+		return http.StatusConflict
+	case errors.Is(err, user.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/user"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) AddUser(ctx context.Context, req dtos.AddUserReq) (dtos.UserRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.UserRes), args.Error(1)
+}
+
+func (m *MockUserService) GetAllUsers(ctx context.Context) ([]dtos.UserRes, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dtos.UserRes), args.Error(1)
+}
+
+func (m *MockUserService) AddAPIKey(ctx context.Context, req dtos.AddAPIKeyReq) (dtos.AddAPIKeyRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(dtos.AddAPIKeyRes), args.Error(1)
+}
+
+func (m *MockUserService) GetAllAPIKeys(ctx context.Context) ([]dtos.APIKeyRes, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dtos.APIKeyRes), args.Error(1)
+}
+
+func (m *MockUserService) RevokeAPIKey(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) VerifyAPIKey(ctx context.Context, key string) (dtos.APIKeyRes, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(dtos.APIKeyRes), args.Error(1)
+}
+
+func TestAddUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockUserService)
+	router := gin.Default()
+	UserRoutes(router.Group("/admin"), mockService)
+
+	t.Run("Success", func(t *testing.T) {
+		req := dtos.AddUserReq{Username: "alice", Password: "secret", Role: consts.RoleTrader}
+		mockService.On("AddUser", mock.Anything, req).Return(dtos.UserRes{ID: "1", Username: "alice", Role: consts.RoleTrader}, nil).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/admin/users", bytes.NewBufferString(`{"username":"alice","password":"secret","role":"trader"}`))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NotContains(t, w.Body.String(), "secret")
+	})
+
+	t.Run("Taken username", func(t *testing.T) {
+		mockService.On("AddUser", mock.Anything, mock.Anything).Return(dtos.UserRes{}, user.ErrUserExists).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/admin/users", bytes.NewBufferString(`{"username":"alice","password":"secret","role":"trader"}`))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		mockService.On("AddUser", mock.Anything, mock.Anything).Return(dtos.UserRes{}, user.ErrInvalidUserReq).Once()
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(http.MethodPost, "/admin/users", bytes.NewBufferString(`{"username":"alice","password":"secret","role":"root"}`))
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestAddAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockUserService)
+	router := gin.Default()
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set(consts.ClaimsKey, middleware.Principal{ID: "user-1", Name: "admin", Role: consts.RoleAdmin, Method: consts.AuthMethodToken})
+	})
+	UserRoutes(admin, mockService)
+
+	req := dtos.AddAPIKeyReq{Name: "collector", Role: consts.RoleViewer, UserID: "user-1"}
+	mockService.On("AddAPIKey", mock.Anything, req).Return(dtos.AddAPIKeyRes{
+		APIKeyRes: dtos.APIKeyRes{ID: "key-1", Name: "collector", Prefix: "0011223344556677", Role: consts.RoleViewer},
+		Key:       "ct_0011223344556677_secret",
+	}, nil).Once()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(`{"name":"collector","role":"viewer"}`))
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"ct_0011223344556677_secret"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(MockUserService)
+	router := gin.Default()
+	UserRoutes(router.Group("/admin"), mockService)
+
+	mockService.On("RevokeAPIKey", mock.Anything, "key-1").Return(nil).Once()
+	mockService.On("RevokeAPIKey", mock.Anything, "missing").Return(user.ErrAPIKeyNotFound).Once()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodDelete, "/admin/api-keys/key-1", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodDelete, "/admin/api-keys/missing", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
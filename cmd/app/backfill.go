@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+// RunBackfill backfills the candles of one symbol interval from the command line
+//
+//	app backfill -exchange <id> -symbol BTCUSDT -interval 1m -from 2024-01-01 [-to 2024-02-01]
+//	app backfill -days 7
+func RunBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	exchangeID := fs.String("exchange", "", "exchange id")
+	symbol := fs.String("symbol", "", "symbol, e.g. BTCUSDT")
+	interval := fs.String("interval", "1m", "kline interval")
+	from := fs.String("from", "", "range start, RFC3339 or 2006-01-02")
+	to := fs.String("to", "", "range end, RFC3339 or 2006-01-02, defaults to now")
+	days := fs.Int("days", 0, "backfill the last days of every active signal interval instead of a single symbol")
+	fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	config := config.InitConfig()
+	database.InitDB(config.Database)
+
+	service := candle.NewService(candle.NewRepo(database.PgClient()), candlestick.NewFetcher(), audit.NewRecorder(database.PgClient()), config.Jobs.BackfillRequestsPerSecond)
+
+	if *days > 0 {
+		if err := service.BackfillRecent(ctx, *days); err != nil {
+			log.Fatalf("Backfill finished with errors: %v", err)
+		}
+		log.Println("Backfill finished")
+		return
+	}
+
+	req := dtos.BackfillReq{
+		ExchangeID: *exchangeID,
+		Symbol:     *symbol,
+		Interval:   *interval,
+	}
+	var err error
+	if req.From, err = parseTime(*from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if *to != "" {
+		if req.To, err = parseTime(*to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+	if req.ExchangeID == "" || req.Symbol == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	job, err := service.Backfill(ctx, req)
+	if err != nil {
+		log.Fatalf("Backfill %s %s failed: %v", job.Symbol, job.Interval, err)
+	}
+	log.Printf("Backfill %s %s done: %d gaps, %d missing, %d inserted, %d requests", job.Symbol, job.Interval, job.Gaps, job.Missing, job.Inserted, job.Requests)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
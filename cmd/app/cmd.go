@@ -6,16 +6,24 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
 	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
 	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/orderbook"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/symbol"
 	"github.com/SametAvcii/crypto-trade/pkg/events"
+	"github.com/SametAvcii/crypto-trade/pkg/exchangeinfo"
+	"github.com/SametAvcii/crypto-trade/pkg/health"
 	"github.com/SametAvcii/crypto-trade/pkg/server"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 )
 
 func StartApp() {
@@ -27,19 +35,68 @@ func StartApp() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	config := config.InitConfig()
+	shutdownTracing, err := tracing.Init(ctx, config.Tracing, config.App.Name)
+	if err != nil {
+		log.Printf("Error initializing tracing, spans are not exported: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
 	database.InitDB(config.Database)
-	go database.CheckPgAlive(ctx, config.Database)
+
+	partitions := database.NewPartitionManager(database.PgClient(), config.Retention)
+	if config.Retention.Interval > 0 {
+		go database.StartPartitionMaintenance(ctx, partitions, time.Duration(config.Retention.Interval)*time.Minute)
+	} else if err := partitions.Ensure(ctx, time.Now()); err != nil {
+		log.Printf("Error creating partitions: %v", err)
+	}
 
 	database.InitMongo(config.Mongo)
-	go database.CheckMongoAlive(ctx, config.Mongo)
+
+	waitLogs := ctlog.Init(ctx, config.Log, config.Mongo)
+
+	// seeding logs through the sinks, so it runs once they are up
+	if config.Database.Seed {
+		database.Seed()
+	}
+	if config.Auth.Bootstrap {
+		database.SeedUsers(config.Auth.Users)
+	}
 
 	cache.InitRedis(config.Redis)
-	go cache.RedisAlive(ctx, config.Redis)
 
 	kafka.InitKafka(config.Kafka)
-	go kafka.CheckKafkaAlive(ctx, config.Kafka)
+
+	// the stream is produced to kafka and configured from postgres, without them the service is down, mongo and
+	// redis only back some of the routes and stale feeds degrade it
+	feeds := health.NewFeeds(health.FeedMaxAge(config.Health))
+	registry := health.NewRegistry(health.Timeout(config.Health))
+	registry.Register("postgres", health.CheckFunc(database.PingPostgres), health.Options{Critical: true})
+	registry.Register("kafka", health.CheckFunc(kafka.Ping), health.Options{Critical: true})
+	registry.Register("mongo", health.CheckFunc(database.PingMongo), health.Options{})
+	registry.Register("redis", health.CheckFunc(cache.Ping), health.Options{})
+	registry.Register("feeds", feeds, health.Options{})
 
 	stream := events.NewStream(database.PgClient(), kafka.KafkaClientNew())
+	stream.Feeds = feeds
+
+	if config.Jobs.SymbolSyncInterval > 0 {
+		symbolService := symbol.NewService(symbol.NewRepo(database.PgClient()), exchangeinfo.NewProvider(database.PgClient()))
+		go exchangeinfo.StartSync(ctx, database.PgClient(), symbolService, time.Duration(config.Jobs.SymbolSyncInterval)*time.Minute)
+	}
+
+	if config.Compaction.Interval > 0 {
+		orderBookService := orderbook.NewService(orderbook.NewRepo(database.PgClient()), config.Compaction)
+		go orderbook.StartCompaction(ctx, orderBookService, time.Duration(config.Compaction.Interval)*time.Second)
+	}
+
+	// one service for the startup backfill and the api so every backfill shares the exchange request budget
+	candleService := candle.NewService(candle.NewRepo(database.PgClient()), candlestick.NewFetcher(), audit.NewRecorder(database.PgClient()), config.Jobs.BackfillRequestsPerSecond)
+	if config.Jobs.BackfillDays > 0 {
+		go func() {
+			if err := candleService.BackfillRecent(ctx, config.Jobs.BackfillDays); err != nil {
+				log.Printf("Startup backfill finished with errors: %v", err)
+			}
+		}()
+	}
 
 	//running all streams
 	/*go func() {
@@ -49,12 +106,7 @@ func StartApp() {
 
 			err := stream.StartAllStreams(exchange.ID.String(), consts.AggTradeTopic)
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Stream Error For Exchange: " + exchange.Name,
-					Message: "Error starting stream: " + err.Error(),
-					Type:    "error",
-					Entity:  "exchange",
-				})
+				ctlog.For("exchange").Error("Error starting stream", ctlog.Exchange(exchange.Name), ctlog.Topic(consts.AggTradeTopic), ctlog.Err(err))
 				continue
 			}
 		}
@@ -67,12 +119,7 @@ func StartApp() {
 
 			err := stream.StartAllStreams(exchange.ID.String(), consts.OrderBookTopic)
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Stream Error For Exchange: " + exchange.Name,
-					Message: "Error starting stream: " + err.Error(),
-					Type:    "error",
-					Entity:  "exchange",
-				})
+				ctlog.For("exchange").Error("Error starting stream", ctlog.Exchange(exchange.Name), ctlog.Topic(consts.OrderBookTopic), ctlog.Err(err))
 				continue
 			}
 		}
@@ -85,12 +132,7 @@ func StartApp() {
 
 			err := stream.StartAllStreams(exchange.ID.String(), consts.CandleStickTopic)
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Stream Error For Exchange: " + exchange.Name,
-					Message: "Error starting stream: " + err.Error(),
-					Type:    "error",
-					Entity:  "exchange",
-				})
+				ctlog.For("exchange").Error("Error starting stream", ctlog.Exchange(exchange.Name), ctlog.Topic(consts.CandleStickTopic), ctlog.Err(err))
 				continue
 			}
 		}
@@ -98,11 +140,23 @@ func StartApp() {
 
 	log.Println("All streams started successfully.")
 
-	server.LaunchHttpServer(config.App, config.Allows)
+	server.LaunchHttpServer(config.App, config.Allows, registry, candleService)
 
 	<-quit
 	log.Println("Shutdown signal received. Cleaning up...")
 
 	cancel()
 
+	// flush the frames still buffered for Kafka
+	if err := kafka.KafkaClientNew().CloseAsync(); err != nil {
+		log.Printf("Error closing Kafka async producer: %v", err)
+	}
+	waitLogs()
+
+	// export the spans still queued
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Printf("Error flushing spans: %v", err)
+	}
 }
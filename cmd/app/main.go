@@ -1,5 +1,7 @@
 package main
 
+import "os"
+
 // @title Crypto Trade API
 // @version 1.0
 // @description Crypto Trade API Documentation
@@ -8,7 +10,27 @@ package main
 // @BasePath /api/v1
 // @schemes http https
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name data-api-key
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			RunBackfill(os.Args[2:])
+			return
+		case "rollup":
+			RunRollup(os.Args[2:])
+			return
+		case "migrate":
+			RunMigrate(os.Args[2:])
+			return
+		}
+	}
 
 	StartApp()
 }
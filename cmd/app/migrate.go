@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+)
+
+const migrateUsage = `usage: app migrate <command>
+
+commands:
+  up             apply every pending migration
+  down [steps]   roll back the last steps migrations, defaults to 1
+  to <version>   migrate up or down to the version, 0 rolls back everything
+  status         list the migrations and when they were applied
+  partitions     create the upcoming partitions and expire the old ones
+  seed           insert the default exchange and symbol`
+
+// RunMigrate manages the schema version from the command line
+func RunMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	config := config.InitConfig()
+	if err := database.Connect(config.Database); err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(database.PgClient())
+	if err != nil {
+		log.Fatalf("Error loading migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Invalid steps: %s", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			fmt.Println(migrateUsage)
+			os.Exit(2)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			log.Fatalf("Invalid version: %s", args[1])
+		}
+		err = migrator.To(ctx, version)
+	case "status":
+		var statuses []database.MigrationStatus
+		statuses, err = migrator.Status(ctx)
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-32s %s\n", status.Version, status.Name, appliedAt)
+		}
+	case "partitions":
+		err = database.NewPartitionManager(database.PgClient(), config.Retention).Run(ctx, time.Now())
+	case "seed":
+		database.Seed()
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("Migrate %s failed: %v", args[0], err)
+	}
+}
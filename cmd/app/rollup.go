@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+// RunRollup recomputes the derived interval bars of an exchange symbol from the stored 1m candles
+//
+//	app rollup -exchange <id> -symbol BTCUSDT -from 2024-01-01 [-to 2024-02-01] [-intervals 1h,4h]
+func RunRollup(args []string) {
+	fs := flag.NewFlagSet("rollup", flag.ExitOnError)
+	exchange := fs.String("exchange", "", "exchange id")
+	symbol := fs.String("symbol", "", "symbol, e.g. BTCUSDT")
+	intervals := fs.String("intervals", "", "comma separated intervals, defaults to every derived interval")
+	from := fs.String("from", "", "range start, RFC3339 or 2006-01-02")
+	to := fs.String("to", "", "range end, RFC3339 or 2006-01-02, defaults to now")
+	fs.Parse(args)
+
+	req := dtos.RollupReq{ExchangeID: *exchange, Symbol: *symbol}
+	if *intervals != "" {
+		req.Intervals = strings.Split(*intervals, ",")
+	}
+	var err error
+	if req.From, err = parseTime(*from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if *to != "" {
+		if req.To, err = parseTime(*to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+	if req.ExchangeID == "" || req.Symbol == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	config := config.InitConfig()
+	database.InitDB(config.Database)
+
+	service := candle.NewService(candle.NewRepo(database.PgClient()), candlestick.NewFetcher(), audit.NewRecorder(database.PgClient()), config.Jobs.BackfillRequestsPerSecond)
+	res, err := service.Rollup(ctx, req)
+	if err != nil {
+		log.Fatalf("Rollup %s failed: %v", req.Symbol, err)
+	}
+	log.Printf("Rollup %s done: %v", res.Symbol, res.Bars)
+}
@@ -6,41 +6,24 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
 	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
 	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/events"
+	"github.com/SametAvcii/crypto-trade/pkg/health"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
 	"github.com/SametAvcii/crypto-trade/pkg/server"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 )
 
-var (
-	consumerSuccessCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "consumer_success_count",
-			Help: "Total number of successful Kafka consumer messages",
-		},
-		[]string{"consumer", "topic"},
-	)
-	consumerFailureCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "consumer_failure_count",
-			Help: "Total number of failed Kafka consumer messages",
-		},
-		[]string{"consumer", "topic"},
-	)
-)
-
-func init() {
-	prometheus.MustRegister(consumerSuccessCounter)
-	prometheus.MustRegister(consumerFailureCounter)
-}
-
 func StartConsumer() {
 
+	// ctx stops the writers and health checks, the consumers run on a child context stopped first on shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -48,76 +31,145 @@ func StartConsumer() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	config := config.InitConfig()
+	shutdownTracing, err := tracing.Init(ctx, config.Tracing, config.Consumer.Name)
+	if err != nil {
+		log.Printf("Error initializing tracing, spans are not exported: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+	metrics.RegisterConsumer()
 	database.InitDB(config.Database)
-	go database.CheckPgAlive(ctx, config.Database)
 
 	database.InitMongo(config.Mongo)
-	go database.CheckMongoAlive(ctx, config.Mongo)
+
+	waitLogs := ctlog.Init(ctx, config.Log, config.Mongo)
 
 	cache.InitRedis(config.Redis)
-	go cache.RedisAlive(ctx, config.Redis)
 
 	kafka.InitKafka(config.Kafka)
-	go kafka.CheckKafkaAlive(ctx, config.Kafka)
 
-	mongoDbConsumerOrderBook := kafka.Consumer{
-		Brokers: config.Kafka.Brokers,
-		GroupID: consts.DbOrderBookGroup,
-		Topic:   consts.OrderBookTopic,
-		Handler: &events.MongoHandler{},
-	}
+	// handlers buffer their rows, offsets are marked once the rows are flushed
+	batch := database.ConsumerBatchConfig(config.Consumer)
+	writers := events.NewWriters(batch)
+	writers.Run(ctx)
 
-	dbConsumerOrderBook := kafka.Consumer{
-		Brokers: config.Kafka.Brokers,
-		GroupID: consts.PgOrderBookGroup,
-		Topic:   consts.PgOrderBookTopic,
-		Handler: &events.PgOrderBookHandler{},
+	// the frames are stored with their postgres fan-out, the relay produces it once the frame is in mongo
+	store := events.NewMongoStore()
+	if err := store.EnsureIndexes(ctx, consts.CollectionNameOrder, consts.CollectionNameCandleStick); err != nil {
+		log.Printf("Error creating outbox indexes: %v", err)
+	}
+	relay := &events.OutboxRelay{
+		Store:       store,
+		Producer:    kafka.KafkaClientNew(),
+		Collections: []string{consts.CollectionNameOrder, consts.CollectionNameCandleStick},
+		BatchSize:   config.Outbox.BatchSize,
+	}
+	go events.StartOutboxRelay(ctx, relay, events.OutboxInterval(config.Outbox))
+
+	// failing messages are retried, then moved to the dead letter topic of their source
+	retry := kafka.NewRetryPolicy(config.Consumer)
+	deadLetter := kafka.KafkaClientNew()
+	drainTimeout := kafka.DrainTimeout(config.Consumer)
+	deps := events.Deps{DB: database.PgClient, Cache: cache.DefaultStore(), Store: store}
+
+	mongoDbConsumerOrderBook := &kafka.Consumer{
+		Brokers:      config.Kafka.Brokers,
+		GroupID:      consts.DbOrderBookGroup,
+		Topic:        consts.OrderBookTopic,
+		Handler:      &events.MongoHandler{Outbox: store},
+		Retry:        retry,
+		DeadLetter:   deadLetter,
+		Workers:      kafka.GroupWorkers(config.Consumer, consts.DbOrderBookGroup),
+		DrainTimeout: drainTimeout,
 	}
 
-	mongoDbConsumerCandleStick := kafka.Consumer{
-		Brokers: config.Kafka.Brokers,
-		GroupID: consts.MongoCandleStickGroup,
-		Topic:   consts.CandleStickTopic,
-		Handler: &events.MongoHandler{},
+	dbConsumerOrderBook := &kafka.Consumer{
+		Brokers:      config.Kafka.Brokers,
+		GroupID:      consts.PgOrderBookGroup,
+		Topic:        consts.PgOrderBookTopic,
+		Handler:      &events.PgOrderBookHandler{Deps: deps, Levels: writers.OrderBooks, History: writers.OrderBookHistory},
+		Retry:        retry,
+		DeadLetter:   deadLetter,
+		Workers:      kafka.GroupWorkers(config.Consumer, consts.PgOrderBookGroup),
+		DrainTimeout: drainTimeout,
 	}
 
-	dbConsumerCandlestick := kafka.Consumer{
-		Brokers: config.Kafka.Brokers,
-		GroupID: consts.PgCandleStickGroup,
-		Topic:   consts.PgCandleStickTopic,
-		Handler: &events.PgCandleStickHandler{},
+	mongoDbConsumerCandleStick := &kafka.Consumer{
+		Brokers:      config.Kafka.Brokers,
+		GroupID:      consts.MongoCandleStickGroup,
+		Topic:        consts.CandleStickTopic,
+		Handler:      &events.MongoHandler{Outbox: store},
+		Retry:        retry,
+		DeadLetter:   deadLetter,
+		Workers:      kafka.GroupWorkers(config.Consumer, consts.MongoCandleStickGroup),
+		DrainTimeout: drainTimeout,
 	}
 
-	signalCandlesticks := kafka.Consumer{
-		Brokers: config.Kafka.Brokers,
-		GroupID: consts.SignalCandleStickGroup,
-		Topic:   consts.CandleStickTopic,
-		Handler: &events.SignalHandlerCandleStick{},
+	dbConsumerCandlestick := &kafka.Consumer{
+		Brokers:      config.Kafka.Brokers,
+		GroupID:      consts.PgCandleStickGroup,
+		Topic:        consts.PgCandleStickTopic,
+		Handler:      &events.PgCandleStickHandler{Deps: deps, Writer: writers.Candles},
+		Retry:        retry,
+		DeadLetter:   deadLetter,
+		Workers:      kafka.GroupWorkers(config.Consumer, consts.PgCandleStickGroup),
+		DrainTimeout: drainTimeout,
 	}
 
-	mongoDbConsumerOrderBook.Start()
-	dbConsumerOrderBook.Start()
+	signalCandlesticks := &kafka.Consumer{
+		Brokers:      config.Kafka.Brokers,
+		GroupID:      consts.SignalCandleStickGroup,
+		Topic:        consts.CandleStickTopic,
+		Handler:      &events.SignalHandlerCandleStick{Deps: deps},
+		Retry:        retry,
+		DeadLetter:   deadLetter,
+		Workers:      kafka.GroupWorkers(config.Consumer, consts.SignalCandleStickGroup),
+		DrainTimeout: drainTimeout,
+	}
 
-	mongoDbConsumerCandleStick.Start()
-	dbConsumerCandlestick.Start()
-	signalCandlesticks.Start()
+	consumerList := []*kafka.Consumer{
+		mongoDbConsumerOrderBook,
+		dbConsumerOrderBook,
+		mongoDbConsumerCandleStick,
+		dbConsumerCandlestick,
+		signalCandlesticks,
+	}
+	consumers := kafka.NewRuntime(consumerList...)
+	if err := consumers.Start(ctx); err != nil {
+		log.Printf("Error starting consumers: %v", err)
+	}
 
-	go func() {
-		consumerSuccessCounter.WithLabelValues("mongoDbConsumerOrderBook", consts.OrderBookTopic).Inc()
-		consumerFailureCounter.WithLabelValues("mongoDbConsumerOrderBook", consts.OrderBookTopic).Inc()
-	}()
+	if lag, err := kafka.KafkaClientNew().NewLagMonitor(consumerList...); err != nil {
+		log.Printf("Error creating consumer lag monitor: %v", err)
+	} else {
+		go kafka.StartLagMonitor(ctx, lag, kafka.LagInterval(config.Consumer))
+	}
 
-	go func() {
-		consumerSuccessCounter.WithLabelValues("dbConsumerOrderBook", consts.OrderBookTopic).Inc()
-		consumerFailureCounter.WithLabelValues("dbConsumerOrderBook", consts.OrderBookTopic).Inc()
-	}()
+	log.Println("All consumers started successfully.")
 
-	go func() {
-		consumerSuccessCounter.WithLabelValues("signalCandlesticks", consts.CandleStickTopic).Inc()
-		consumerFailureCounter.WithLabelValues("signalCandlesticks", consts.CandleStickTopic).Inc()
-	}()
+	// every group writes to postgres or mongo, only the signals need redis
+	registry := health.NewRegistry(health.Timeout(config.Health))
+	registry.Register("kafka", health.CheckFunc(kafka.Ping), health.Options{Critical: true})
+	registry.Register("postgres", health.CheckFunc(database.PingPostgres), health.Options{Critical: true})
+	registry.Register("mongo", health.CheckFunc(database.PingMongo), health.Options{Critical: true})
+	registry.Register("redis", health.CheckFunc(cache.Ping), health.Options{})
+	go server.LaunchConsumerServer(config.Consumer, registry)
 
-	log.Println("All consumers started successfully.")
+	<-quit
+	log.Println("Shutdown signal received, draining consumers...")
 
-	server.LaunchConsumerServer(config.Consumer)
+	// the writers keep flushing while the claims drain so the buffered messages can still be committed
+	if err := consumers.Shutdown(kafka.ShutdownTimeout(config.Consumer)); err != nil {
+		log.Printf("Consumer shutdown: %v, uncommitted messages will be redelivered", err)
+	}
+	cancel()
+	writers.Wait()
+	log.Println("Consumers stopped.")
+	waitLogs()
+
+	// export the spans still queued
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Printf("Error flushing spans: %v", err)
+	}
 }
@@ -2,12 +2,14 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -24,6 +26,7 @@ func InitRedis(rds config.Redis) {
 			Password: rds.Pass,
 			DB:       0, // use default DB
 		})
+		rdc.AddHook(tracing.RedisHook{})
 		// -----> control
 		var ctx = context.Background()
 		_, err := rdc.Ping(ctx).Result()
@@ -41,25 +44,12 @@ func RedisClient() *redis.Client {
 	return client
 }
 
-func RedisAlive(ctx context.Context, rds config.Redis) {
-
-	timeTicker := time.NewTicker(15 * time.Second)
-
-	for range timeTicker.C {
-		rdc := redis.NewClient(&redis.Options{
-			Addr:     net.JoinHostPort(rds.Host, rds.Port),
-			Password: rds.Pass,
-			DB:       0, // use default DB
-		})
-		var ctx = context.Background()
-		_, err := rdc.Ping(ctx).Result()
-		if err != nil {
-			log.Println("Error connecting to Redis: ", err.Error())
-			continue
-		}
-		client = rdc
+// Ping checks the redis server, it is the readiness check of the cache
+func Ping(ctx context.Context) error {
+	if client == nil {
+		return errors.New("redis is not initialized")
 	}
-
+	return client.Ping(ctx).Err()
 }
 
 func Set(ctx context.Context, key string, value interface{}, ex_time time.Duration) error {
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LimitResult is the outcome of a request against a rate limit
+type LimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the current window ends
+}
+
+// Limiter counts the requests of a key in a sliding window, RedisLimiter and MemoryLimiter implement it
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (LimitResult, error)
+}
+
+// slidingWindow splits now into the fixed window it falls in, the share of the previous window still inside the
+// sliding window and the time left of the current one
+func slidingWindow(now time.Time, window time.Duration) (index int64, weight float64, reset time.Duration) {
+	index = now.UnixNano() / int64(window)
+	elapsed := time.Duration(now.UnixNano() - index*int64(window))
+	return index, 1 - float64(elapsed)/float64(window), window - elapsed
+}
+
+func limitResult(allowed bool, count, limit int, reset time.Duration) LimitResult {
+	return LimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(limit-count, 0),
+		Reset:     reset,
+	}
+}
+
+// allowScript counts a request in the current window unless the weighted count of both windows reached the limit,
+// it returns whether the request is allowed and the count
+var allowScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit = tonumber(ARGV[1])
+local count = math.floor(previous * tonumber(ARGV[2])) + current
+if count >= limit then
+	return {0, count}
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, count + 1}
+`)
+
+// RedisLimiter keeps the counters in redis so every api instance shares them, the client is looked up on every call
+type RedisLimiter struct {
+	client func() *redis.Client
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client func() *redis.Client) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (LimitResult, error) {
+	index, weight, reset := slidingWindow(l.now(), window)
+	keys := []string{key + ":" + strconv.FormatInt(index, 10), key + ":" + strconv.FormatInt(index-1, 10)}
+	// the current window is read as the previous one during the next window
+	ttl := (2 * window).Milliseconds()
+
+	res, err := allowScript.Run(ctx, l.client(), keys, limit, weight, ttl).Int64Slice()
+	if err != nil {
+		return LimitResult{}, err
+	}
+	return limitResult(res[0] == 1, int(res[1]), limit, reset), nil
+}
+
+type windowCount struct {
+	index    int64
+	current  int
+	previous int
+	expires  time.Time
+}
+
+// MemoryLimiter keeps the counters of one process, it is the fallback while redis is unreachable
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	counts    map[string]*windowCount
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		counts: map[string]*windowCount{},
+		now:    time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (LimitResult, error) {
+	now := l.now()
+	index, weight, reset := slidingWindow(now, window)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	c, ok := l.counts[key]
+	switch {
+	case !ok:
+		c = &windowCount{index: index}
+		l.counts[key] = c
+	case c.index == index-1:
+		c.index, c.previous, c.current = index, c.current, 0
+	case c.index != index:
+		c.index, c.previous, c.current = index, 0, 0
+	}
+	c.expires = now.Add(reset + window)
+
+	count := int(math.Floor(float64(c.previous)*weight)) + c.current
+	if count >= limit {
+		return limitResult(false, count, limit, reset), nil
+	}
+	c.current++
+	return limitResult(true, count+1, limit, reset), nil
+}
+
+// sweep drops the counters of keys that went quiet for a whole window, at most once a minute
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, c := range l.counts {
+		if now.After(c.expires) {
+			delete(l.counts, key)
+		}
+	}
+}
+
+// FallbackLimiter asks the primary limiter and the fallback when the primary fails, so an unreachable redis does not
+// turn into failed requests
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	degraded atomic.Bool
+}
+
+func NewFallbackLimiter(primary, fallback Limiter) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (l *FallbackLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (LimitResult, error) {
+	res, err := l.primary.Allow(ctx, key, limit, window)
+	if err == nil {
+		if l.degraded.CompareAndSwap(true, false) {
+			log.Println("Rate limiter is back on redis")
+		}
+		return res, nil
+	}
+	if l.degraded.CompareAndSwap(false, true) {
+		log.Println("Rate limiter falls back to memory: ", err.Error())
+	}
+	return l.fallback.Allow(ctx, key, limit, window)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+	var _ Limiter = l
+
+	for i := 0; i < 3; i++ {
+		res, err := l.Allow(ctx, "ip:1", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	res, err := l.Allow(ctx, "ip:1", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Minute, res.Reset)
+
+	// other keys have their own counters
+	res, _ = l.Allow(ctx, "ip:2", 3, time.Minute)
+	assert.True(t, res.Allowed)
+
+	// a third into the next window two thirds of the previous one still count
+	now = now.Add(time.Minute + 20*time.Second)
+	res, _ = l.Allow(ctx, "ip:1", 3, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 40*time.Second, res.Reset)
+	res, _ = l.Allow(ctx, "ip:1", 3, time.Minute)
+	assert.False(t, res.Allowed)
+
+	// quiet keys are swept
+	now = now.Add(5 * time.Minute)
+	res, _ = l.Allow(ctx, "ip:1", 3, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+	assert.NotContains(t, l.counts, "ip:2")
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	ctx := context.Background()
+	// nothing listens on the port, every redis call fails
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer unreachable.Close()
+
+	_, err := NewRedisLimiter(func() *redis.Client { return unreachable }).Allow(ctx, "ip:1", 1, time.Minute)
+	assert.Error(t, err)
+
+	l := NewFallbackLimiter(NewRedisLimiter(func() *redis.Client { return unreachable }), NewMemoryLimiter())
+	res, err := l.Allow(ctx, "ip:1", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.True(t, l.degraded.Load())
+
+	res, err = l.Allow(ctx, "ip:1", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
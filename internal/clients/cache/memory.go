@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MemoryStore is a cache kept in memory for tests and local runs, it has no expiry
+type MemoryStore struct {
+	mu      sync.Mutex
+	strings map[string]string
+	lists   map[string][]string
+	hashes  map[string]map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		strings: map[string]string{},
+		lists:   map[string][]string{},
+		hashes:  map[string]map[string]string{},
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.strings[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return val, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strings[key] = value
+	return nil
+}
+
+func (s *MemoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, isString := s.strings[key]
+	return isString || len(s.lists[key]) > 0 || len(s.hashes[key]) > 0, nil
+}
+
+func (s *MemoryStore) Del(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.strings, key)
+		delete(s.lists, key)
+		delete(s.hashes, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) PushList(ctx context.Context, key string, size int64, values ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append(s.lists[key], values...)
+	if size > 0 && int64(len(list)) > size {
+		list = slices.Clone(list[int64(len(list))-size:])
+	}
+	s.lists[key] = list
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.lists[key]), nil
+}
+
+func (s *MemoryStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := maps.Clone(s.hashes[key])
+	if values == nil {
+		values = map[string]string{}
+	}
+	return values, nil
+}
+
+func (s *MemoryStore) HSet(ctx context.Context, key string, values map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hashes[key] == nil {
+		s.hashes[key] = map[string]string{}
+	}
+	maps.Copy(s.hashes[key], values)
+	return nil
+}
+
+func (s *MemoryStore) HDel(ctx context.Context, key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, field := range fields {
+		delete(s.hashes[key], field)
+	}
+	if len(s.hashes[key]) == 0 {
+		delete(s.hashes, key)
+	}
+	return nil
+}
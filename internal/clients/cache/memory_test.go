@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	var _ Store = s
+
+	_, err := s.Get(ctx, "lastSignal")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, s.Set(ctx, "lastSignal", "BUY"))
+	val, err := s.Get(ctx, "lastSignal")
+	assert.NoError(t, err)
+	assert.Equal(t, "BUY", val)
+
+	assert.NoError(t, s.PushList(ctx, "ma", 3, "1", "2"))
+	assert.NoError(t, s.PushList(ctx, "ma", 3, "3", "4"))
+	list, err := s.List(ctx, "ma")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3", "4"}, list)
+
+	assert.NoError(t, s.HSet(ctx, "bids", map[string]string{"100": "1", "99": "2"}))
+	assert.NoError(t, s.HDel(ctx, "bids", "99"))
+	hash, err := s.HGetAll(ctx, "bids")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"100": "1"}, hash)
+
+	assert.NoError(t, s.Del(ctx, "ma", "bids"))
+	exists, err := s.Exists(ctx, "ma")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = s.Exists(ctx, "lastSignal")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Store is the cache the consumers keep their state in, RedisStore and MemoryStore implement it
+type Store interface {
+	// Get returns ErrNotFound for a missing key
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	// PushList appends the values to the list and keeps its last size values, a size of 0 keeps them all
+	PushList(ctx context.Context, key string, size int64, values ...string) error
+	List(ctx context.Context, key string) ([]string, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HSet(ctx context.Context, key string, values map[string]string) error
+	HDel(ctx context.Context, key string, fields ...string) error
+}
+
+// RedisStore is the cache kept in redis, the client is looked up on every call so a reconnected client is used
+type RedisStore struct {
+	client func() *redis.Client
+}
+
+func NewRedisStore(client func() *redis.Client) *RedisStore {
+	return &RedisStore{
+		client: client,
+	}
+}
+
+// DefaultStore returns the store of the initialized redis client
+func DefaultStore() Store {
+	return NewRedisStore(RedisClient)
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	val, err := s.client().Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return val, err
+}
+
+func (s *RedisStore) Set(ctx context.Context, key, value string) error {
+	return s.client().Set(ctx, key, value, 0).Err()
+}
+
+func (s *RedisStore) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.client().Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.client().Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) PushList(ctx context.Context, key string, size int64, values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	pipe := s.client().Pipeline()
+	pipe.RPush(ctx, key, args...)
+	if size > 0 {
+		pipe.LTrim(ctx, key, -size, -1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) List(ctx context.Context, key string) ([]string, error) {
+	return s.client().LRange(ctx, key, 0, -1).Result()
+}
+
+func (s *RedisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.client().HGetAll(ctx, key).Result()
+}
+
+func (s *RedisStore) HSet(ctx context.Context, key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.client().HSet(ctx, key, values).Err()
+}
+
+func (s *RedisStore) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.client().HDel(ctx, key, fields...).Err()
+}
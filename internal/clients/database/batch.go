@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"gorm.io/gorm"
+)
+
+var errNotInitialized = errors.New("postgres is not initialized")
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = 200 * time.Millisecond
+)
+
+type BatchConfig struct {
+	Size          int           // rows that trigger a flush
+	FlushInterval time.Duration // longest time a row waits in the buffer
+	MaxPending    int           // Add blocks while this many rows wait, defaults to 10 batches
+}
+
+// BatchWriter buffers the rows of a table and writes them with multi-row statements. Done callbacks run once
+// the row is stored or failed on its own, failed batches stay buffered while the database is unreachable so no row
+// is acknowledged early.
+type BatchWriter[T any] struct {
+	name   string
+	db     func() *gorm.DB
+	write  func(db *gorm.DB, rows []T) error
+	key    func(T) string
+	config BatchConfig
+
+	flushMu sync.Mutex // one flush at a time
+	mu      sync.Mutex
+	space   *sync.Cond
+	rows    []T
+	dones   []func(error)
+	flushCh chan struct{}
+}
+
+// NewBatchWriter returns a writer storing the rows with write, when key is set rows sharing a key within a batch
+// collapse into the last one so upserts never touch the same row twice in one statement
+func NewBatchWriter[T any](name string, cfg BatchConfig, db func() *gorm.DB, write func(db *gorm.DB, rows []T) error, key func(T) string) *BatchWriter[T] {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 10 * cfg.Size
+	}
+
+	w := &BatchWriter[T]{
+		name:    name,
+		db:      db,
+		write:   write,
+		key:     key,
+		config:  cfg,
+		flushCh: make(chan struct{}, 1),
+	}
+	w.space = sync.NewCond(&w.mu)
+	return w
+}
+
+// Add buffers the row, done gets the error of a row the database rejects and may be nil
+func (w *BatchWriter[T]) Add(row T, done func(error)) {
+	w.mu.Lock()
+	for len(w.rows) >= w.config.MaxPending {
+		w.space.Wait()
+	}
+
+	w.rows = append(w.rows, row)
+	w.dones = append(w.dones, done)
+	full := len(w.rows) >= w.config.Size
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run flushes the buffer on size or time until ctx is done, then flushes what is left
+func (w *BatchWriter[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.Flush(context.Background()); err != nil {
+				log.Printf("[%s] Final flush failed, %d rows are not written: %v", w.name, w.Len(), err)
+			}
+			return
+		case <-ticker.C:
+		case <-w.flushCh:
+		}
+
+		if err := w.Flush(ctx); err != nil {
+			log.Printf("[%s] Flush failed, retrying on the next tick: %v", w.name, err)
+		}
+	}
+}
+
+// Len returns the number of buffered rows
+func (w *BatchWriter[T]) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.rows)
+}
+
+// Flush writes the buffered rows in batches of the configured size
+func (w *BatchWriter[T]) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	for {
+		w.mu.Lock()
+		n := min(len(w.rows), w.config.Size)
+		rows, dones := w.rows[:n:n], w.dones[:n:n]
+		w.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		errs, err := w.flush(ctx, rows)
+		if err != nil {
+			return err
+		}
+
+		w.mu.Lock()
+		w.rows, w.dones = w.rows[n:], w.dones[n:]
+		w.space.Broadcast()
+		w.mu.Unlock()
+
+		for i, done := range dones {
+			if done != nil {
+				done(errs[i])
+			}
+		}
+	}
+}
+
+// flush writes the rows, the batch error keeps them buffered while the per row errors fail the rows the database
+// rejects on their own
+func (w *BatchWriter[T]) flush(ctx context.Context, rows []T) ([]error, error) {
+	db := w.db()
+	if db == nil {
+		return nil, errNotInitialized
+	}
+	db = db.WithContext(ctx)
+
+	errs := make([]error, len(rows))
+	batch, owners := w.collapse(rows)
+	err := w.write(db, batch)
+	if err == nil {
+		return errs, nil
+	}
+
+	// keep the batch while the database is unreachable, otherwise a bad row fails it and the rows are written one by one
+	if sqlDB, dbErr := db.DB(); dbErr != nil || sqlDB.PingContext(ctx) != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] Batch of %d rows failed, writing them one by one: %v", w.name, len(batch), err)
+	for i := range batch {
+		err := w.write(db, batch[i:i+1])
+		if err == nil {
+			continue
+		}
+		log.Printf("[%s] Row failed: %v", w.name, err)
+		// a collapsed row fails every row it stands for
+		for j, owner := range owners {
+			if owner == i {
+				errs[j] = err
+			}
+		}
+	}
+	return errs, nil
+}
+
+// collapse returns the rows to write and, for every buffered row, the index of the row it is written as
+func (w *BatchWriter[T]) collapse(rows []T) ([]T, []int) {
+	owners := make([]int, len(rows))
+	if w.key == nil {
+		for i := range owners {
+			owners[i] = i
+		}
+		return rows, owners
+	}
+
+	index := make(map[string]int, len(rows))
+	batch := make([]T, 0, len(rows))
+	for j, row := range rows {
+		k := w.key(row)
+		if i, ok := index[k]; ok {
+			batch[i] = row
+			owners[j] = i
+			continue
+		}
+		index[k] = len(batch)
+		owners[j] = len(batch)
+		batch = append(batch, row)
+	}
+	return batch, owners
+}
+
+// Completion calls fn once every callback handed out by Add has run and the owner released it, fn gets the first
+// error a callback reported
+type Completion struct {
+	pending atomic.Int64
+	fn      func(error)
+
+	errOnce sync.Once
+	err     error
+}
+
+func NewCompletion(fn func(error)) *Completion {
+	c := &Completion{fn: fn}
+	c.pending.Store(1)
+	return c
+}
+
+// Add returns a callback to hand to a writer
+func (c *Completion) Add() func(error) {
+	c.pending.Add(1)
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			if err != nil {
+				c.errOnce.Do(func() { c.err = err })
+			}
+			c.release()
+		})
+	}
+}
+
+// Release drops the owner reference, after it fn runs as soon as the last callback does
+func (c *Completion) Release() {
+	c.release()
+}
+
+func (c *Completion) release() {
+	if c.pending.Add(-1) == 0 && c.fn != nil {
+		c.fn(c.err)
+	}
+}
+
+// ConsumerBatchConfig returns the batch settings of the consumer handlers
+func ConsumerBatchConfig(cfg config.Consumer) BatchConfig {
+	return BatchConfig{
+		Size:          cfg.BatchSize,
+		FlushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		MaxPending:    cfg.MaxPending,
+	}
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type batchRow struct {
+	Key   string
+	Value int
+}
+
+// recordingWrite stores the batches it is handed, failing while fail returns an error
+type recordingWrite struct {
+	batches [][]batchRow
+	fail    func(rows []batchRow) error
+}
+
+func (r *recordingWrite) write(db *gorm.DB, rows []batchRow) error {
+	if r.fail != nil {
+		if err := r.fail(rows); err != nil {
+			return err
+		}
+	}
+	r.batches = append(r.batches, append([]batchRow(nil), rows...))
+	return nil
+}
+
+func setupBatchDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	mock.ExpectPing() // gorm pings on open
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB, DriverName: "postgres"}), &gorm.Config{
+		Logger:                 logger.Default.LogMode(logger.Silent),
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+func rowKey(r batchRow) string { return r.Key }
+
+func TestBatchWriterFlush(t *testing.T) {
+	db, _ := setupBatchDB(t)
+	rec := &recordingWrite{}
+	w := NewBatchWriter("test", BatchConfig{Size: 10}, func() *gorm.DB { return db }, rec.write, rowKey)
+
+	done := 0
+	w.Add(batchRow{Key: "a", Value: 1}, func(error) { done++ })
+	w.Add(batchRow{Key: "b", Value: 1}, func(error) { done++ })
+	w.Add(batchRow{Key: "a", Value: 2}, nil)
+
+	err := w.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, w.Len())
+	assert.Equal(t, 2, done)
+	// rows sharing a key collapse into the last one
+	assert.Equal(t, [][]batchRow{{{Key: "a", Value: 2}, {Key: "b", Value: 1}}}, rec.batches)
+}
+
+func TestBatchWriterFlushInBatches(t *testing.T) {
+	db, _ := setupBatchDB(t)
+	rec := &recordingWrite{}
+	w := NewBatchWriter("test", BatchConfig{Size: 2}, func() *gorm.DB { return db }, rec.write, nil)
+
+	for i := 0; i < 5; i++ {
+		w.Add(batchRow{Key: "a", Value: i}, nil)
+	}
+
+	assert.NoError(t, w.Flush(context.Background()))
+	assert.Len(t, rec.batches, 3)
+	assert.Len(t, rec.batches[2], 1)
+}
+
+func TestBatchWriterKeepsRowsWhileDown(t *testing.T) {
+	db, mock := setupBatchDB(t)
+	down := true
+	rec := &recordingWrite{fail: func(rows []batchRow) error {
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}
+	w := NewBatchWriter("test", BatchConfig{Size: 10}, func() *gorm.DB { return db }, rec.write, nil)
+
+	done := 0
+	w.Add(batchRow{Key: "a"}, func(error) { done++ })
+	w.Add(batchRow{Key: "b"}, func(error) { done++ })
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	err := w.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, w.Len())
+	assert.Equal(t, 0, done)
+
+	down = false
+	err = w.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, w.Len())
+	assert.Equal(t, 2, done)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchWriterFailsBadRows(t *testing.T) {
+	db, mock := setupBatchDB(t)
+	rec := &recordingWrite{fail: func(rows []batchRow) error {
+		for _, row := range rows {
+			if row.Key == "bad" {
+				return errors.New("invalid input syntax")
+			}
+		}
+		return nil
+	}}
+	w := NewBatchWriter("test", BatchConfig{Size: 10}, func() *gorm.DB { return db }, rec.write, rowKey)
+
+	results := map[int]error{}
+	for i, key := range []string{"a", "bad", "b", "bad"} {
+		w.Add(batchRow{Key: key, Value: i}, func(err error) { results[i] = err })
+	}
+
+	mock.ExpectPing()
+	err := w.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, w.Len())
+	assert.Len(t, results, 4)
+	assert.NoError(t, results[0])
+	assert.NoError(t, results[2])
+	// the rejected row fails every row collapsed into it
+	assert.EqualError(t, results[1], "invalid input syntax")
+	assert.EqualError(t, results[3], "invalid input syntax")
+	assert.Equal(t, [][]batchRow{{{Key: "a", Value: 0}}, {{Key: "b", Value: 2}}}, rec.batches)
+}
+
+func TestBatchWriterRunFlushesOnSize(t *testing.T) {
+	db, _ := setupBatchDB(t)
+	rec := &recordingWrite{}
+	w := NewBatchWriter("test", BatchConfig{Size: 2, FlushInterval: time.Hour}, func() *gorm.DB { return db }, rec.write, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(stopped)
+	}()
+
+	flushed := make(chan struct{}, 2)
+	w.Add(batchRow{Key: "a"}, func(error) { flushed <- struct{}{} })
+	w.Add(batchRow{Key: "b"}, func(error) { flushed <- struct{}{} })
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-flushed:
+		case <-time.After(time.Second):
+			t.Fatal("full batch was not flushed")
+		}
+	}
+
+	// rows left when the context ends are flushed before Run returns
+	w.Add(batchRow{Key: "c"}, nil)
+	cancel()
+	<-stopped
+	assert.Equal(t, 0, w.Len())
+}
+
+func TestCompletion(t *testing.T) {
+	calls := 0
+	var result error
+	c := NewCompletion(func(err error) {
+		calls++
+		result = err
+	})
+
+	first, second, third := c.Add(), c.Add(), c.Add()
+	first(nil)
+	first(errors.New("ignored"))
+	c.Release()
+	second(errors.New("duplicate key"))
+	assert.Equal(t, 0, calls)
+
+	third(errors.New("later"))
+	assert.Equal(t, 1, calls)
+	// the first failure is reported
+	assert.EqualError(t, result, "duplicate key")
+
+	calls = 0
+	NewCompletion(func(err error) {
+		calls++
+		result = err
+	}).Release()
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, result)
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/internal/clients/database/migrations"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := LoadMigrations(migrations.FS)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, list)
+
+	for i, m := range list {
+		assert.Equal(t, i+1, m.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoadMigrationsMissingDown(t *testing.T) {
+	_, err := LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql": {Data: []byte("CREATE TABLE a (id int);")},
+	})
+	assert.Error(t, err)
+}
+
+func setupMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB, DriverName: "postgres"}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+
+	list, err := LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql":     {Data: []byte("CREATE TABLE a (id int);")},
+		"0001_init.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	return NewMigratorWith(gormDB, list), mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, applied ...int) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlmock.NewRows([]string{"version", "name", "applied_at"})
+	for _, version := range applied {
+		rows.AddRow(version, "init", time.Now())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "schema_migrations" ORDER BY version`)).WillReturnRows(rows)
+}
+
+func TestMigratorUp(t *testing.T) {
+	migrator, mock := setupMigrator(t)
+
+	expectLocked(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE b (id int);")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "schema_migrations"`)).
+		WithArgs(2, "second", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := migrator.Up(context.Background())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDown(t *testing.T) {
+	migrator, mock := setupMigrator(t)
+
+	expectLocked(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE b;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "schema_migrations" WHERE version = $1`)).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := migrator.Down(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorToUnknownVersion(t *testing.T) {
+	migrator, _ := setupMigrator(t)
+
+	err := migrator.To(context.Background(), 7)
+	assert.Error(t, err)
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS order_books;
+DROP TABLE IF EXISTS candlesticks;
+DROP TABLE IF EXISTS signals;
+DROP TABLE IF EXISTS signal_intervals;
+DROP TABLE IF EXISTS symbol_prices;
+DROP TABLE IF EXISTS symbols;
+DROP TABLE IF EXISTS exchanges;
+DROP TABLE IF EXISTS logs;
@@ -0,0 +1,118 @@
+-- Schema as created by AutoMigrate before versioned migrations, existing databases adopt it as is.
+
+CREATE TABLE IF NOT EXISTS logs (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    title text,
+    message text,
+    entity text,
+    type text,
+    proto text,
+    ip text,
+    data text
+);
+CREATE INDEX IF NOT EXISTS idx_logs_deleted_at ON logs (deleted_at);
+
+CREATE TABLE IF NOT EXISTS exchanges (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    ws_url text,
+    rest_url text,
+    is_active bigint
+);
+CREATE INDEX IF NOT EXISTS idx_exchanges_deleted_at ON exchanges (deleted_at);
+
+CREATE TABLE IF NOT EXISTS symbols (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    exchange_id uuid,
+    is_active bigint
+);
+CREATE INDEX IF NOT EXISTS idx_symbols_deleted_at ON symbols (deleted_at);
+
+CREATE TABLE IF NOT EXISTS symbol_prices (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    price text,
+    quantity text,
+    trade_id bigint,
+    trade_time bigint,
+    is_buyer_maker boolean,
+    event_time bigint,
+    event_type text,
+    mongo_id text
+);
+CREATE INDEX IF NOT EXISTS idx_symbol_prices_deleted_at ON symbol_prices (deleted_at);
+
+CREATE TABLE IF NOT EXISTS signal_intervals (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    interval text,
+    exchange_id uuid,
+    is_active bigint
+);
+CREATE INDEX IF NOT EXISTS idx_signal_intervals_deleted_at ON signal_intervals (deleted_at);
+
+CREATE TABLE IF NOT EXISTS signals (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    timeframe text,
+    signal text,
+    indicator jsonb,
+    last_trade jsonb
+);
+CREATE INDEX IF NOT EXISTS idx_signals_deleted_at ON signals (deleted_at);
+
+CREATE TABLE IF NOT EXISTS candlesticks (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    exchange_id text,
+    interval text,
+    open_time bigint,
+    open text,
+    high text,
+    low text,
+    close text,
+    volume text,
+    close_time bigint,
+    quote_volume text,
+    number_of_trades bigint,
+    taker_buy_base_volume text,
+    taker_buy_quote_volume text,
+    ignore text
+);
+CREATE INDEX IF NOT EXISTS idx_candlesticks_deleted_at ON candlesticks (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_books (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    exchange_id text,
+    price text,
+    amount text,
+    side text,
+    status text
+);
+CREATE INDEX IF NOT EXISTS idx_order_books_deleted_at ON order_books (deleted_at);
@@ -0,0 +1,9 @@
+ALTER TABLE symbols
+    DROP COLUMN IF EXISTS base_asset,
+    DROP COLUMN IF EXISTS quote_asset,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS tick_size,
+    DROP COLUMN IF EXISTS step_size,
+    DROP COLUMN IF EXISTS min_notional,
+    DROP COLUMN IF EXISTS base_asset_precision,
+    DROP COLUMN IF EXISTS quote_asset_precision;
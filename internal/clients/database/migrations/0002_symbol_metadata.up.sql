@@ -0,0 +1,9 @@
+ALTER TABLE symbols
+    ADD COLUMN IF NOT EXISTS base_asset text,
+    ADD COLUMN IF NOT EXISTS quote_asset text,
+    ADD COLUMN IF NOT EXISTS status text,
+    ADD COLUMN IF NOT EXISTS tick_size text,
+    ADD COLUMN IF NOT EXISTS step_size text,
+    ADD COLUMN IF NOT EXISTS min_notional text,
+    ADD COLUMN IF NOT EXISTS base_asset_precision bigint,
+    ADD COLUMN IF NOT EXISTS quote_asset_precision bigint;
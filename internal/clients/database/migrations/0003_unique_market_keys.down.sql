@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_order_book_key;
+DROP INDEX IF EXISTS idx_candlestick_key;
+
+ALTER TABLE order_books ALTER COLUMN exchange_id DROP NOT NULL;
+ALTER TABLE candlesticks ALTER COLUMN exchange_id DROP NOT NULL;
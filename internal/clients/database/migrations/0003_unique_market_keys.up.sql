@@ -0,0 +1,31 @@
+-- Rows written before the exchange was tracked take it from the only exchange listing their symbol.
+UPDATE candlesticks t SET exchange_id = s.exchange_id
+FROM (SELECT lower(symbol) AS symbol, min(exchange_id::text) AS exchange_id FROM symbols
+      WHERE exchange_id IS NOT NULL GROUP BY lower(symbol) HAVING count(DISTINCT exchange_id) = 1) s
+WHERE (t.exchange_id IS NULL OR t.exchange_id = '') AND lower(t.symbol) = s.symbol;
+
+UPDATE order_books t SET exchange_id = s.exchange_id
+FROM (SELECT lower(symbol) AS symbol, min(exchange_id::text) AS exchange_id FROM symbols
+      WHERE exchange_id IS NOT NULL GROUP BY lower(symbol) HAVING count(DISTINCT exchange_id) = 1) s
+WHERE (t.exchange_id IS NULL OR t.exchange_id = '') AND lower(t.symbol) = s.symbol;
+
+-- the rest cannot be attributed to an exchange, candles are backfilled again and books rebuilt by the stream
+DELETE FROM candlesticks WHERE exchange_id IS NULL OR exchange_id = '';
+DELETE FROM order_books WHERE exchange_id IS NULL OR exchange_id = '';
+
+ALTER TABLE candlesticks ALTER COLUMN exchange_id SET NOT NULL;
+ALTER TABLE order_books ALTER COLUMN exchange_id SET NOT NULL;
+
+-- keep the most recently updated row of every key
+DELETE FROM candlesticks a USING candlesticks b
+WHERE a.exchange_id = b.exchange_id AND a.symbol = b.symbol
+  AND a.interval = b.interval AND a.open_time = b.open_time
+  AND (a.updated_at, a.id) < (b.updated_at, b.id);
+
+DELETE FROM order_books a USING order_books b
+WHERE a.exchange_id = b.exchange_id AND a.symbol = b.symbol
+  AND a.side = b.side AND a.price = b.price
+  AND (a.updated_at, a.id) < (b.updated_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_candlestick_key ON candlesticks (exchange_id, symbol, interval, open_time);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_book_key ON order_books (exchange_id, symbol, side, price);
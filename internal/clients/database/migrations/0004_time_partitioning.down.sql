@@ -0,0 +1,59 @@
+-- Partitions archived by the retention manager are left in the archive schema.
+
+ALTER TABLE candlesticks RENAME TO candlesticks_partitioned;
+ALTER INDEX idx_candlestick_key RENAME TO idx_candlestick_key_partitioned;
+ALTER INDEX idx_candlesticks_deleted_at RENAME TO idx_candlesticks_deleted_at_partitioned;
+
+CREATE TABLE candlesticks (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    exchange_id text NOT NULL,
+    interval text,
+    open_time bigint,
+    open text,
+    high text,
+    low text,
+    close text,
+    volume text,
+    close_time bigint,
+    quote_volume text,
+    number_of_trades bigint,
+    taker_buy_base_volume text,
+    taker_buy_quote_volume text,
+    ignore text
+);
+INSERT INTO candlesticks (id, created_at, updated_at, deleted_at, symbol, exchange_id, interval, open_time, open, high, low,
+    close, volume, close_time, quote_volume, number_of_trades, taker_buy_base_volume, taker_buy_quote_volume, ignore)
+SELECT id, created_at, updated_at, deleted_at, symbol, exchange_id, interval, open_time, open, high, low,
+    close, volume, close_time, quote_volume, number_of_trades, taker_buy_base_volume, taker_buy_quote_volume, ignore
+FROM candlesticks_partitioned;
+DROP TABLE candlesticks_partitioned;
+CREATE UNIQUE INDEX idx_candlestick_key ON candlesticks (exchange_id, symbol, interval, open_time);
+CREATE INDEX idx_candlesticks_deleted_at ON candlesticks (deleted_at);
+
+DROP TABLE order_book_history;
+
+ALTER TABLE logs RENAME TO logs_partitioned;
+ALTER INDEX idx_logs_deleted_at RENAME TO idx_logs_deleted_at_partitioned;
+
+CREATE TABLE logs (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    title text,
+    message text,
+    entity text,
+    type text,
+    proto text,
+    ip text,
+    data text
+);
+INSERT INTO logs (id, created_at, updated_at, deleted_at, title, message, entity, type, proto, ip, data)
+SELECT id, created_at, updated_at, deleted_at, title, message, entity, type, proto, ip, data
+FROM logs_partitioned;
+DROP TABLE logs_partitioned;
+CREATE INDEX idx_logs_deleted_at ON logs (deleted_at);
@@ -0,0 +1,84 @@
+-- Market data and logs become range partitioned by time. Every table gets a default partition holding
+-- the copied rows, the partition manager splits it into monthly or daily partitions on startup.
+
+-- candlesticks: monthly on open_time (ms)
+ALTER TABLE candlesticks RENAME TO candlesticks_unpartitioned;
+ALTER INDEX idx_candlestick_key RENAME TO idx_candlestick_key_unpartitioned;
+ALTER INDEX idx_candlesticks_deleted_at RENAME TO idx_candlesticks_deleted_at_unpartitioned;
+
+CREATE TABLE candlesticks (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    symbol text,
+    exchange_id text NOT NULL,
+    interval text,
+    open_time bigint NOT NULL,
+    open text,
+    high text,
+    low text,
+    close text,
+    volume text,
+    close_time bigint,
+    quote_volume text,
+    number_of_trades bigint,
+    taker_buy_base_volume text,
+    taker_buy_quote_volume text,
+    ignore text,
+    PRIMARY KEY (id, open_time)
+) PARTITION BY RANGE (open_time);
+CREATE TABLE candlesticks_default PARTITION OF candlesticks DEFAULT;
+CREATE UNIQUE INDEX idx_candlestick_key ON candlesticks (exchange_id, symbol, interval, open_time);
+CREATE INDEX idx_candlesticks_deleted_at ON candlesticks (deleted_at);
+
+INSERT INTO candlesticks (id, created_at, updated_at, deleted_at, symbol, exchange_id, interval, open_time, open, high, low,
+    close, volume, close_time, quote_volume, number_of_trades, taker_buy_base_volume, taker_buy_quote_volume, ignore)
+SELECT id, created_at, updated_at, deleted_at, symbol, exchange_id, interval, open_time, open, high, low,
+    close, volume, close_time, quote_volume, number_of_trades, taker_buy_base_volume, taker_buy_quote_volume, ignore
+FROM candlesticks_unpartitioned WHERE open_time IS NOT NULL;
+DROP TABLE candlesticks_unpartitioned;
+
+-- order_books keeps the live book, every level change is appended to the history: daily on created_at
+CREATE TABLE order_book_history (
+    id uuid NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    exchange_id text NOT NULL,
+    symbol text,
+    side text,
+    price text,
+    amount text,
+    status text,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+CREATE TABLE order_book_history_default PARTITION OF order_book_history DEFAULT;
+CREATE INDEX idx_order_book_history_symbol ON order_book_history (exchange_id, symbol, created_at);
+CREATE INDEX idx_order_book_history_deleted_at ON order_book_history (deleted_at);
+
+-- logs: daily on created_at
+ALTER TABLE logs RENAME TO logs_unpartitioned;
+ALTER INDEX idx_logs_deleted_at RENAME TO idx_logs_deleted_at_unpartitioned;
+
+CREATE TABLE logs (
+    id uuid NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    title text,
+    message text,
+    entity text,
+    type text,
+    proto text,
+    ip text,
+    data text,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+CREATE TABLE logs_default PARTITION OF logs DEFAULT;
+CREATE INDEX idx_logs_deleted_at ON logs (deleted_at);
+
+INSERT INTO logs (id, created_at, updated_at, deleted_at, title, message, entity, type, proto, ip, data)
+SELECT id, COALESCE(created_at, updated_at, now()), updated_at, deleted_at, title, message, entity, type, proto, ip, data
+FROM logs_unpartitioned;
+DROP TABLE logs_unpartitioned;
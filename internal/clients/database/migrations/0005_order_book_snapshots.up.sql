@@ -0,0 +1,16 @@
+-- Fixed interval snapshots of the top levels compacted from order_book_history, pruned per resolution.
+CREATE TABLE IF NOT EXISTS order_book_snapshots (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    exchange_id text,
+    symbol text,
+    resolution text,
+    taken_at timestamptz NOT NULL,
+    bids jsonb,
+    asks jsonb
+);
+CREATE INDEX IF NOT EXISTS idx_order_book_snapshots_deleted_at ON order_book_snapshots (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_book_snapshot_key ON order_book_snapshots (exchange_id, symbol, resolution, taken_at);
+CREATE INDEX IF NOT EXISTS idx_order_book_snapshots_taken_at ON order_book_snapshots (exchange_id, symbol, taken_at);
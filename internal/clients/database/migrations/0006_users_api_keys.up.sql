@@ -0,0 +1,29 @@
+-- Accounts that log in with a password and api keys of the services calling the api, secrets are stored hashed.
+CREATE TABLE IF NOT EXISTS users (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username text NOT NULL,
+    password_hash text NOT NULL,
+    role text NOT NULL,
+    is_active bigint
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    secret_hash text NOT NULL,
+    role text NOT NULL,
+    user_id uuid REFERENCES users (id),
+    expires_at timestamptz,
+    revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
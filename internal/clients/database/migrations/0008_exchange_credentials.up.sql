@@ -0,0 +1,15 @@
+-- Api keys and secrets of the exchange accounts, sealed with envelope encryption, only a hint of the key is in clear.
+CREATE TABLE IF NOT EXISTS exchange_credentials (
+    id uuid PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    exchange_id uuid NOT NULL REFERENCES exchanges (id),
+    label text NOT NULL,
+    api_key_hint text NOT NULL,
+    master_key_id text NOT NULL,
+    wrapped_key bytea NOT NULL,
+    ciphertext bytea NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_exchange_credentials_deleted_at ON exchange_credentials (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_exchange_credentials_master_key_id ON exchange_credentials (master_key_id);
@@ -0,0 +1,2 @@
+ALTER TABLE logs DROP COLUMN IF EXISTS fields;
+ALTER TABLE logs DROP COLUMN IF EXISTS trace_id;
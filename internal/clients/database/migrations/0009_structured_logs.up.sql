@@ -0,0 +1,3 @@
+-- Logs carry the trace of the request or message they belong to and their structured fields.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id text;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS fields jsonb;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_search;
+DROP INDEX IF EXISTS idx_logs_type_entity;
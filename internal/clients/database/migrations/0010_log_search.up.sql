@@ -0,0 +1,3 @@
+-- Logs are filtered by type and entity and searched in their title and message.
+CREATE INDEX IF NOT EXISTS idx_logs_type_entity ON logs (type, entity, created_at);
+CREATE INDEX IF NOT EXISTS idx_logs_search ON logs USING gin (to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(message, '')));
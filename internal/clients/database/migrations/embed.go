@@ -0,0 +1,9 @@
+// Package migrations holds the versioned schema migrations of the Postgres database.
+//
+// Every version has a NNNN_name.up.sql and a NNNN_name.down.sql file, versions are applied in order.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
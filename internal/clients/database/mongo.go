@@ -2,20 +2,20 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var (
-	mongoClient *mongo.Client
-	MongoAlive  bool
-)
+var mongoClient *mongo.Client
 
 func InitMongo(cfg config.Mongo) {
 
@@ -28,7 +28,7 @@ func InitMongo(cfg config.Mongo) {
 	defer cancel()
 
 	uri := buildMongoURI(cfg)
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().ApplyURI(uri).SetMonitor(tracing.MongoMonitor())
 
 	var err error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
@@ -85,31 +85,10 @@ func MongoDatabase(cfg config.Mongo) *mongo.Database {
 	return MongoClient().Database(cfg.Database)
 }
 
-func CheckMongoAlive(ctx context.Context, cfg config.Mongo) {
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		if mongoClient == nil {
-			log.Println("MongoDB client is nil. Trying to establish a connection...")
-
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-
-			uri := buildMongoURI(cfg)
-			clientOptions := options.Client().ApplyURI(uri)
-
-			err := connectToMongo(ctx, clientOptions)
-			if err != nil {
-				MongoAlive = false
-				log.Println("Failed to establish MongoDB connection:", err)
-				continue
-			}
-		} else {
-			MongoAlive = true
-		}
-
-		log.Println("MongoDB connection alive:", MongoAlive)
-
+// PingMongo checks the primary, it is the readiness check of mongo
+func PingMongo(ctx context.Context) error {
+	if mongoClient == nil {
+		return errors.New("mongo is not initialized")
 	}
+	return mongoClient.Ping(ctx, readpref.Primary())
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -52,23 +53,16 @@ func TestConnectToMongo(t *testing.T) {
 	assert.Error(t, err)
 }
 
-func TestCheckMongoAlive(t *testing.T) {
-	cfg := config.Mongo{
-		User:     "testuser",
-		Pass:     "testpass",
-		Host:     "localhost",
-		Port:     "27017",
-		Database: "testdb",
-	}
-
-	done := make(chan bool)
-	go func() {
-		time.Sleep(20 * time.Second)
-		done <- true
-	}()
+func TestPingMongo(t *testing.T) {
+	previous := mongoClient
+	defer func() { mongoClient = previous }()
 
-	go CheckMongoAlive(context.Background(), cfg)
+	mongoClient = nil
+	assert.Error(t, PingMongo(context.Background()))
 
-	<-done
-	assert.False(t, MongoAlive)
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://invalid:27017").SetServerSelectionTimeout(100*time.Millisecond))
+	assert.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	mongoClient = client
+	assert.Error(t, PingMongo(context.Background()))
 }
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"gorm.io/gorm"
+)
+
+type PartitionPeriod int
+
+const (
+	Daily PartitionPeriod = iota
+	Monthly
+)
+
+// PartitionedTable is a table range partitioned by time, the partitions are named <table>_p<period start>
+type PartitionedTable struct {
+	Name   string
+	Column string
+	Period PartitionPeriod
+	Millis bool // the column holds unix milliseconds instead of a timestamptz
+}
+
+var PartitionedTables = []PartitionedTable{
+	{Name: "candlesticks", Column: "open_time", Period: Monthly, Millis: true},
+	{Name: "order_book_history", Column: "created_at", Period: Daily},
+	{Name: "logs", Column: "created_at", Period: Daily},
+}
+
+// Start returns the start of the partition holding at
+func (t PartitionedTable) Start(at time.Time) time.Time {
+	at = at.UTC()
+	if t.Period == Monthly {
+		return time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Add moves start by n partitions
+func (t PartitionedTable) Add(start time.Time, n int) time.Time {
+	if t.Period == Monthly {
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// Partition returns the name of the partition starting at start
+func (t PartitionedTable) Partition(start time.Time) string {
+	return t.Name + "_p" + start.Format(t.layout())
+}
+
+func (t PartitionedTable) layout() string {
+	if t.Period == Monthly {
+		return "2006_01"
+	}
+	return "2006_01_02"
+}
+
+func (t PartitionedTable) parse(partition string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(partition, t.Name+"_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	start, err := time.ParseInLocation(t.layout(), suffix, time.UTC)
+	return start, err == nil
+}
+
+func (t PartitionedTable) bound(at time.Time) string {
+	if t.Millis {
+		return strconv.FormatInt(at.UnixMilli(), 10)
+	}
+	return "'" + at.UTC().Format("2006-01-02 15:04:05") + "+00'"
+}
+
+func (t PartitionedTable) timeExpr() string {
+	if t.Millis {
+		return fmt.Sprintf("to_timestamp(%s / 1000.0)", t.Column)
+	}
+	return t.Column
+}
+
+// PartitionManager creates the partitions ahead of time and applies the retention policy of every table
+type PartitionManager struct {
+	db       *gorm.DB
+	tables   []PartitionedTable
+	premake  int
+	policies map[string]config.RetentionPolicy
+}
+
+func NewPartitionManager(db *gorm.DB, cfg config.Retention) *PartitionManager {
+	return &PartitionManager{
+		db:      db,
+		tables:  PartitionedTables,
+		premake: cfg.Premake,
+		policies: map[string]config.RetentionPolicy{
+			"candlesticks":       cfg.Candlesticks,
+			"order_book_history": cfg.OrderBookHistory,
+			"logs":               cfg.Logs,
+		},
+	}
+}
+
+// StartPartitionMaintenance runs the partition manager until ctx is done
+func StartPartitionMaintenance(ctx context.Context, m *PartitionManager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Run(ctx, time.Now()); err != nil {
+			log.Printf("Partition maintenance finished with errors: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run creates the missing partitions then expires the old ones
+func (m *PartitionManager) Run(ctx context.Context, now time.Time) error {
+	return errors.Join(m.Ensure(ctx, now), m.Retain(ctx, now))
+}
+
+// Ensure creates the current and premade partitions of every table, rows that landed in the default partition are moved to their own
+func (m *PartitionManager) Ensure(ctx context.Context, now time.Time) error {
+	var errs []error
+	for _, table := range m.tables {
+		if err := m.ensure(ctx, table, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", table.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *PartitionManager) ensure(ctx context.Context, table PartitionedTable, now time.Time) error {
+	existing, err := m.partitions(ctx, table)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		have[name] = true
+	}
+
+	var starts []time.Time
+	first, last, err := m.defaultRange(ctx, table)
+	if err != nil {
+		return err
+	}
+	if first.Valid && last.Valid {
+		for start := table.Start(first.Time); !start.After(last.Time); start = table.Add(start, 1) {
+			starts = append(starts, start)
+		}
+	}
+	current := table.Start(now)
+	for i := 0; i <= m.premake; i++ {
+		starts = append(starts, table.Add(current, i))
+	}
+
+	for _, start := range starts {
+		name := table.Partition(start)
+		if have[name] {
+			continue
+		}
+		if err := m.create(ctx, table, start); err != nil {
+			return fmt.Errorf("creating partition %s: %w", name, err)
+		}
+		have[name] = true
+		log.Printf("Created partition %s", name)
+	}
+	return nil
+}
+
+// create attaches the partition starting at start, moving its rows out of the default partition first
+func (m *PartitionManager) create(ctx context.Context, table PartitionedTable, start time.Time) error {
+	var (
+		name = table.Partition(start)
+		from = table.bound(start)
+		to   = table.bound(table.Add(start, 1))
+	)
+
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", name, table.Name),
+			fmt.Sprintf("WITH moved AS (DELETE FROM %s_default WHERE %s >= %s AND %s < %s RETURNING *) INSERT INTO %s SELECT * FROM moved",
+				table.Name, table.Column, from, table.Column, to, name),
+			fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM (%s) TO (%s)", table.Name, name, from, to),
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Retain drops or archives the partitions older than the number of partitions the table policy keeps
+func (m *PartitionManager) Retain(ctx context.Context, now time.Time) error {
+	var errs []error
+	for _, table := range m.tables {
+		policy := m.policies[table.Name]
+		if policy.Keep <= 0 {
+			continue
+		}
+
+		names, err := m.partitions(ctx, table)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", table.Name, err))
+			continue
+		}
+
+		cutoff := table.Add(table.Start(now), -policy.Keep)
+		for _, name := range names {
+			start, ok := table.parse(name)
+			if !ok || !start.Before(cutoff) {
+				continue
+			}
+			if err := m.expire(ctx, table, name, policy.Action); err != nil {
+				errs = append(errs, fmt.Errorf("expiring partition %s: %w", name, err))
+				continue
+			}
+			log.Printf("Expired partition %s (%s)", name, policy.Action)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *PartitionManager) expire(ctx context.Context, table PartitionedTable, name, action string) error {
+	switch action {
+	case "", consts.RetentionDrop:
+		return m.db.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE %s", name)).Error
+	case consts.RetentionArchive:
+		// archived partitions leave the hot table but stay queryable until they are dumped
+		return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			statements := []string{
+				fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", consts.ArchiveSchema),
+				fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", table.Name, name),
+				fmt.Sprintf("ALTER TABLE %s SET SCHEMA %s", name, consts.ArchiveSchema),
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown retention action %q", action)
+	}
+}
+
+// partitions lists the partitions attached to the table
+func (m *PartitionManager) partitions(ctx context.Context, table PartitionedTable) ([]string, error) {
+	var names []string
+	err := m.db.WithContext(ctx).Raw(`SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ? ORDER BY c.relname`, table.Name).Scan(&names).Error
+	return names, err
+}
+
+// defaultRange returns the time span of the rows in the default partition
+func (m *PartitionManager) defaultRange(ctx context.Context, table PartitionedTable) (sql.NullTime, sql.NullTime, error) {
+	var first, last sql.NullTime
+	err := m.db.WithContext(ctx).
+		Raw(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s_default", table.timeExpr(), table.timeExpr(), table.Name)).
+		Row().Scan(&first, &last)
+	return first, last, err
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupPartitionManager(t *testing.T, table PartitionedTable, cfg config.Retention) (*PartitionManager, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB, DriverName: "postgres"}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+
+	m := NewPartitionManager(gormDB, cfg)
+	m.tables = []PartitionedTable{table}
+	return m, mock
+}
+
+func expectPartitions(mock sqlmock.Sqlmock, names ...string) {
+	rows := sqlmock.NewRows([]string{"relname"})
+	for _, name := range names {
+		rows.AddRow(name)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT c.relname FROM pg_inherits")).WillReturnRows(rows)
+}
+
+func TestPartitionNames(t *testing.T) {
+	at := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+
+	candles := PartitionedTables[0]
+	start := candles.Start(at)
+	assert.Equal(t, "candlesticks_p2026_10", candles.Partition(start))
+	assert.Equal(t, "candlesticks_p2027_01", candles.Partition(candles.Add(start, 3)))
+	assert.Equal(t, "1790812800000", candles.bound(start))
+
+	logs := PartitionedTables[2]
+	start = logs.Start(at)
+	assert.Equal(t, "logs_p2026_10_19", logs.Partition(start))
+	assert.Equal(t, "'2026-10-20 00:00:00+00'", logs.bound(logs.Add(start, 1)))
+
+	parsed, ok := logs.parse("logs_p2026_10_19")
+	assert.True(t, ok)
+	assert.Equal(t, start, parsed)
+
+	_, ok = logs.parse("logs_default")
+	assert.False(t, ok)
+}
+
+func TestPartitionManagerEnsure(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+	m, mock := setupPartitionManager(t, PartitionedTables[2], config.Retention{Premake: 1})
+
+	expectPartitions(mock, "logs_default", "logs_p2026_10_19")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MIN(created_at), MAX(created_at) FROM logs_default")).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max"}).AddRow(nil, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE logs_p2026_10_20 (LIKE logs INCLUDING DEFAULTS INCLUDING CONSTRAINTS)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("WITH moved AS (DELETE FROM logs_default WHERE created_at >= '2026-10-20 00:00:00+00' AND created_at < '2026-10-21 00:00:00+00' RETURNING *) INSERT INTO logs_p2026_10_20")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE logs ATTACH PARTITION logs_p2026_10_20 FOR VALUES FROM ('2026-10-20 00:00:00+00') TO ('2026-10-21 00:00:00+00')")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := m.Ensure(context.Background(), now)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionManagerEnsureSplitsDefault(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+	m, mock := setupPartitionManager(t, PartitionedTables[0], config.Retention{})
+
+	// backfilled candles of september landed in the default partition
+	expectPartitions(mock, "candlesticks_default", "candlesticks_p2026_10")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MIN(to_timestamp(open_time / 1000.0)), MAX(to_timestamp(open_time / 1000.0)) FROM candlesticks_default")).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max"}).
+			AddRow(time.Date(2026, 9, 3, 0, 0, 0, 0, time.UTC), time.Date(2026, 9, 28, 0, 0, 0, 0, time.UTC)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE candlesticks_p2026_09")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("WITH moved AS (DELETE FROM candlesticks_default WHERE open_time >= 1788220800000 AND open_time < 1790812800000")).
+		WillReturnResult(sqlmock.NewResult(0, 120))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE candlesticks ATTACH PARTITION candlesticks_p2026_09")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := m.Ensure(context.Background(), now)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionManagerRetain(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+	m, mock := setupPartitionManager(t, PartitionedTables[0], config.Retention{
+		Candlesticks: config.RetentionPolicy{Keep: 2, Action: "archive"},
+	})
+
+	expectPartitions(mock, "candlesticks_default", "candlesticks_p2026_06", "candlesticks_p2026_08", "candlesticks_p2026_10")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE SCHEMA IF NOT EXISTS archive")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE candlesticks DETACH PARTITION candlesticks_p2026_06")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE candlesticks_p2026_06 SET SCHEMA archive")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := m.Retain(context.Background(), now)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionManagerRetainDrop(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+	m, mock := setupPartitionManager(t, PartitionedTables[2], config.Retention{
+		Logs: config.RetentionPolicy{Keep: 1, Action: "drop"},
+	})
+
+	expectPartitions(mock, "logs_default", "logs_p2026_10_17", "logs_p2026_10_18", "logs_p2026_10_19")
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE logs_p2026_10_17")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := m.Retain(context.Background(), now)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
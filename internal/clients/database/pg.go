@@ -2,25 +2,39 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var (
-	db    *gorm.DB
-	err   error
-	Alive bool
-	dsn   string
+	db  *gorm.DB
+	err error
+	dsn string
 )
 
 func InitDB(cfg config.Database) error {
+	if err := Connect(cfg); err != nil {
+		return err
+	}
+
+	if err := runMigrations(); err != nil {
+		return fmt.Errorf("database migration failed: %w", err)
+	}
+
+	log.Println("Database initialized and migrations completed successfully.")
+	return nil
+}
+
+// Connect opens the connection pool without migrating the schema
+func Connect(cfg config.Database) error {
 	const (
 		maxRetries    = 5
 		retryInterval = 5 * time.Second
@@ -53,14 +67,6 @@ func InitDB(cfg config.Database) error {
 	sqldb.SetMaxOpenConns(consts.MaxOpenConn)
 	sqldb.SetConnMaxLifetime(consts.MaxLifetime)
 	sqldb.SetConnMaxIdleTime(consts.MaxIdleTime)
-
-	if err := runMigrations(); err != nil {
-		return fmt.Errorf("database migration failed: %w", err)
-	}
-
-	Seed()
-
-	log.Println("Database initialized and migrations completed successfully.")
 	return nil
 }
 
@@ -72,26 +78,31 @@ func buildDSN(cfg config.Database) string {
 }
 
 func openDB(dsn string) (*gorm.DB, error) {
-	return gorm.Open(
+	db, err := gorm.Open(
 		postgres.New(postgres.Config{
 			DSN:                  dsn,
 			PreferSimpleProtocol: true,
 		}),
 		&gorm.Config{},
 	)
+	if err != nil {
+		return nil, err
+	}
+	return db, db.Use(tracing.GormPlugin{})
 }
 
 func runMigrations() error {
-	return db.AutoMigrate(
-		&entities.Log{},
-		&entities.Symbol{},
-		&entities.Exchange{},
-		&entities.SymbolPrice{},
-		&entities.SignalInterval{},
-		&entities.Signal{},
-		&entities.Candlestick{},
-		&entities.OrderBook{},
-	)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	return migrator.Up(context.Background())
+}
+
+// PgPool returns the connection pool without checking it, a caller writing on every tick relies on the error of its
+// write instead of a ping per call
+func PgPool() *gorm.DB {
+	return db
 }
 
 func PgClient() *gorm.DB {
@@ -125,33 +136,15 @@ func PgClient() *gorm.DB {
 
 	return db
 }
-func CheckPgAlive(ctx context.Context, dbc config.Database) {
-	timeTicker := time.NewTicker(15 * time.Second)
-
-	var (
-		dsn = buildDSN(dbc)
-		err error
-	)
-
-	for range timeTicker.C {
-		db, err = openDB(dsn)
-		if err != nil {
-			Alive = false
-		}
 
-		sqldb, err := db.DB()
-		if err != nil {
-			Alive = false
-		}
-
-		err = sqldb.Ping()
-		if err != nil {
-			Alive = false
-		} else {
-			Alive = true
-		}
-
-		sqldb.Close()
-		log.Println("Postgres connection alive: ", Alive)
+// PingPostgres checks the connection pool, it is the readiness check of postgres
+func PingPostgres(ctx context.Context) error {
+	if db == nil {
+		return errors.New("postgres is not initialized")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.PingContext(ctx)
 }
@@ -69,7 +69,7 @@ func TestPgClient(t *testing.T) {
 	assert.NotNil(t, client)
 }
 
-func TestCheckPgAlive(t *testing.T) {
+func TestPingPostgres(t *testing.T) {
 	cfg := config.Database{
 		Host:    "localhost",
 		Port:    "5432",
@@ -79,14 +79,10 @@ func TestCheckPgAlive(t *testing.T) {
 		SslMode: "disable",
 	}
 
-	done := make(chan bool)
-	go func() {
-		time.Sleep(20 * time.Second)
-		done <- true
-	}()
-
-	go CheckPgAlive(context.Background(), cfg)
+	err := InitDB(cfg)
+	assert.NoError(t, err)
 
-	<-done
-	assert.True(t, Alive)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, PingPostgres(ctx))
 }
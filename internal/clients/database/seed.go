@@ -2,11 +2,19 @@ package database
 
 import (
 	"log"
+	"log/slog"
+	"os"
 
+	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"golang.org/x/crypto/bcrypt"
 )
 
+const defaultBootstrapAdmin = "admin"
+
+// Seed creates the default exchange, symbol and signal interval, call it after ctlog.Init so its entries reach the
+// log sinks
 func Seed() {
 	log.Println("Seeding database...")
 	db := PgClient()
@@ -21,15 +29,7 @@ func Seed() {
 	if err != nil {
 		err = db.Create(exchange).Error
 		if err != nil {
-			errLog := &entities.Log{
-				Title:   "Error creating exchange from seed",
-				Message: "Error creating exchange from seed: " + err.Error(),
-				Entity:  "exchange",
-				Type:    "error",
-			}
-			db.Model(&entities.Log{}).Create(errLog)
-
-			log.Println("Error creating exchange:", err)
+			seedLogger("exchange").Error("Error creating exchange from seed", "error", err)
 			return
 		}
 	}
@@ -43,15 +43,7 @@ func Seed() {
 	if err != nil {
 		err = db.Create(symbol).Error
 		if err != nil {
-			errLog := &entities.Log{
-				Title:   "Error creating symbol from seed",
-				Message: "Error creating symbol from seed: " + err.Error(),
-				Entity:  "symbol",
-				Type:    "error",
-			}
-			db.Model(&entities.Log{}).Create(errLog)
-
-			log.Println("Error creating symbol:", err)
+			seedLogger("symbol").Error("Error creating symbol from seed", "error", err)
 			return
 		}
 	}
@@ -66,27 +58,67 @@ func Seed() {
 	if err != nil {
 		err = db.Create(signalInterval).Error
 		if err != nil {
-			errLog := &entities.Log{
-				Title:   "Error creating signal interval from seed",
-				Message: "Error creating signal interval from seed: " + err.Error(),
-				Entity:  "signal_interval",
-				Type:    "error",
-			}
-
-			db.Model(&entities.Log{}).Create(errLog)
-			log.Println("Error creating signal interval:", err)
+			seedLogger("signal_interval").Error("Error creating signal interval from seed", "error", err)
 			return
 		}
 	}
 
-	infoLog := &entities.Log{
-		Title:   "Dummy data created",
-		Message: "Dummy data created successfully",
-		Entity:  "seed",
-		Type:    "info",
+	seedLogger("seed").Info("Dummy data created successfully")
+}
+
+// seedLogger returns the slog logger of an entity, ctlog imports this package so its keys are spelled out here
+func seedLogger(entity string) *slog.Logger {
+	return slog.Default().With("entity", entity)
+}
+
+// SeedUsers creates the accounts of the auth config and the bootstrap admin that are not in the users table yet,
+// existing accounts are left as they are so a password or role changed through the api is not overwritten
+func SeedUsers(users []config.AuthUser) {
+	admin, err := bootstrapAdmin()
+	if err != nil {
+		log.Println("Error creating the bootstrap admin:", err)
+	} else if admin != nil {
+		users = append(users, *admin)
+	}
+	if len(users) == 0 {
+		log.Println("No accounts to bootstrap, set BOOTSTRAP_ADMIN_PASSWORD to create an admin")
+		return
+	}
+
+	db := PgClient()
+	for _, u := range users {
+		role := u.Role
+		if role == "" {
+			role = consts.RoleViewer
+		}
+		user := &entities.User{
+			Username:     u.Username,
+			PasswordHash: u.PasswordHash,
+			Role:         role,
+			IsActive:     consts.Active,
+		}
+		err := db.Where("username = ?", user.Username).FirstOrCreate(user).Error
+		if err != nil {
+			log.Println("Error creating user "+u.Username+":", err)
+		}
 	}
-	db.Model(&entities.Log{}).Create(infoLog)
+}
 
-	log.Println("Dummy data created successfully")
+// bootstrapAdmin returns the admin account of the BOOTSTRAP_ADMIN_USER and BOOTSTRAP_ADMIN_PASSWORD envs, none when
+// the password is not set
+func bootstrapAdmin() (*config.AuthUser, error) {
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if password == "" {
+		return nil, nil
+	}
+	username := os.Getenv("BOOTSTRAP_ADMIN_USER")
+	if username == "" {
+		username = defaultBootstrapAdmin
+	}
 
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &config.AuthUser{Username: username, PasswordHash: string(hash), Role: consts.RoleAdmin}, nil
 }
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	t.Setenv("BOOTSTRAP_ADMIN_USER", "")
+	t.Setenv("BOOTSTRAP_ADMIN_PASSWORD", "")
+	admin, err := bootstrapAdmin()
+	assert.NoError(t, err)
+	assert.Nil(t, admin, "no admin without a password")
+
+	t.Setenv("BOOTSTRAP_ADMIN_PASSWORD", "s3cret-pass")
+	admin, err = bootstrapAdmin()
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", admin.Username)
+	assert.Equal(t, consts.RoleAdmin, admin.Role)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte("s3cret-pass")))
+
+	t.Setenv("BOOTSTRAP_ADMIN_USER", "ops")
+	admin, err = bootstrapAdmin()
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", admin.Username)
+}
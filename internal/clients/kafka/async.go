@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+)
+
+const (
+	BufferPolicyDrop  = "drop"
+	BufferPolicyBlock = "block"
+
+	ProducerStatusDelivered = "delivered"
+	ProducerStatusFailed    = "failed"
+	ProducerStatusDropped   = "dropped"
+
+	defaultBufferSize = 10000
+)
+
+var (
+	ErrBufferFull     = errors.New("producer buffer is full")
+	ErrProducerClosed = errors.New("producer is closed")
+)
+
+// AsyncProducerConfig returns the sarama config of the stream producer, batches are compressed and the producer is
+// idempotent so broker retries do not duplicate frames
+func AsyncProducerConfig(cfg config.Kafka) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_1_0_0 // zstd and idempotence need 2.1 and 0.11
+
+	saramaConfig.Producer.Idempotent = true
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Net.MaxOpenRequests = 1
+	saramaConfig.Producer.Retry.Max = max(cfg.MaxRetry, 1)
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Producer.Return.Successes = true
+	if cfg.MaxMessageSize > 0 {
+		saramaConfig.Producer.MaxMessageBytes = cfg.MaxMessageSize
+	}
+
+	switch strings.ToLower(cfg.Producer.Compression) {
+	case "", "zstd":
+		saramaConfig.Producer.Compression = sarama.CompressionZSTD
+	case "lz4":
+		saramaConfig.Producer.Compression = sarama.CompressionLZ4
+	case "none":
+		saramaConfig.Producer.Compression = sarama.CompressionNone
+	default:
+		return nil, fmt.Errorf("unknown producer compression: %s", cfg.Producer.Compression)
+	}
+
+	saramaConfig.Producer.Flush.Frequency = time.Duration(cfg.Producer.FlushFrequency) * time.Millisecond
+	saramaConfig.Producer.Flush.Messages = cfg.Producer.FlushMessages
+	saramaConfig.Producer.Flush.Bytes = cfg.Producer.FlushBytes
+
+	return saramaConfig, saramaConfig.Validate()
+}
+
+// AsyncProducer queues messages in a bounded buffer and hands them to a sarama async producer, a send never waits
+// for the broker. Once the buffer is full the drop policy rejects the message, the block policy waits for room
+// until the block timeout passes. Deliveries, failures and drops are counted in the producer metrics.
+type AsyncProducer struct {
+	producer     sarama.AsyncProducer
+	buffer       chan *sarama.ProducerMessage
+	policy       string
+	blockTimeout time.Duration
+
+	mu        sync.RWMutex // held while sending, so the buffer is not closed under a send
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewAsyncProducer(producer sarama.AsyncProducer, cfg config.Producer) (*AsyncProducer, error) {
+	policy := strings.ToLower(cfg.BufferPolicy)
+	switch policy {
+	case "":
+		policy = BufferPolicyDrop
+	case BufferPolicyDrop, BufferPolicyBlock:
+	default:
+		return nil, fmt.Errorf("unknown producer buffer policy: %s", cfg.BufferPolicy)
+	}
+
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	p := &AsyncProducer{
+		producer:     producer,
+		buffer:       make(chan *sarama.ProducerMessage, size),
+		policy:       policy,
+		blockTimeout: time.Duration(cfg.BlockTimeout) * time.Millisecond,
+		closing:      make(chan struct{}),
+	}
+
+	p.wg.Add(3)
+	go p.forward()
+	go p.successes()
+	go p.failures()
+	return p, nil
+}
+
+// Send queues the message, it returns ErrBufferFull when the message is dropped
+func (p *AsyncProducer) Send(topic, key string, value []byte, headers ...sarama.RecordHeader) error {
+	msg := &sarama.ProducerMessage{
+		Topic:    topic,
+		Key:      sarama.StringEncoder(key),
+		Value:    sarama.ByteEncoder(value),
+		Headers:  headers,
+		Metadata: time.Now(),
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	select {
+	case p.buffer <- msg:
+		metrics.ProducerBuffered.Set(float64(len(p.buffer)))
+		return nil
+	default:
+	}
+
+	if p.policy == BufferPolicyBlock {
+		var timeout <-chan time.Time
+		if p.blockTimeout > 0 {
+			timer := time.NewTimer(p.blockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case p.buffer <- msg:
+			metrics.ProducerBuffered.Set(float64(len(p.buffer)))
+			return nil
+		case <-p.closing:
+			return ErrProducerClosed
+		case <-timeout:
+		}
+	}
+
+	metrics.ProducerMessages.WithLabelValues(topic, ProducerStatusDropped).Inc()
+	return ErrBufferFull
+}
+
+// Close stops accepting messages, hands the buffered ones to the producer and waits until every batch is delivered
+// or failed
+func (p *AsyncProducer) Close() error {
+	p.closeOnce.Do(func() {
+		// blocked sends give up before the buffer is closed
+		close(p.closing)
+		p.mu.Lock()
+		p.closed = true
+		close(p.buffer)
+		p.mu.Unlock()
+	})
+
+	p.wg.Wait()
+	return nil
+}
+
+// forward moves the buffered messages to the producer, the producer is closed once the buffer is drained
+func (p *AsyncProducer) forward() {
+	defer p.wg.Done()
+	for msg := range p.buffer {
+		metrics.ProducerBuffered.Set(float64(len(p.buffer)))
+		p.producer.Input() <- msg
+	}
+	p.producer.AsyncClose()
+}
+
+func (p *AsyncProducer) successes() {
+	defer p.wg.Done()
+	for msg := range p.producer.Successes() {
+		metrics.ProducerMessages.WithLabelValues(msg.Topic, ProducerStatusDelivered).Inc()
+		if sent, ok := msg.Metadata.(time.Time); ok {
+			metrics.ProducerLatency.WithLabelValues(msg.Topic).Observe(time.Since(sent).Seconds())
+		}
+	}
+}
+
+func (p *AsyncProducer) failures() {
+	defer p.wg.Done()
+	for err := range p.producer.Errors() {
+		topic := ""
+		if err.Msg != nil {
+			topic = err.Msg.Topic
+		}
+		metrics.ProducerMessages.WithLabelValues(topic, ProducerStatusFailed).Inc()
+		log.Printf("Kafka delivery error for %s: %v", topic, err.Err)
+	}
+}
@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// stalledProducer takes a message only when the test reads it from input, like a broker that stopped acknowledging
+type stalledProducer struct {
+	sarama.AsyncProducer
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func newStalledProducer() *stalledProducer {
+	return &stalledProducer{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+	}
+}
+
+func (p *stalledProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *stalledProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *stalledProducer) Errors() <-chan *sarama.ProducerError      { return p.errors }
+func (p *stalledProducer) AsyncClose() {
+	close(p.successes)
+	close(p.errors)
+}
+
+func producerCount(topic, status string) float64 {
+	return testutil.ToFloat64(metrics.ProducerMessages.WithLabelValues(topic, status))
+}
+
+func TestAsyncProducerConfig(t *testing.T) {
+	cfg, err := AsyncProducerConfig(config.Kafka{MaxRetry: 5, Producer: config.Producer{Compression: "lz4", FlushFrequency: 50, FlushMessages: 500}})
+	assert.NoError(t, err)
+	assert.True(t, cfg.Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, cfg.Producer.RequiredAcks)
+	assert.Equal(t, 1, cfg.Net.MaxOpenRequests)
+	assert.Equal(t, sarama.CompressionLZ4, cfg.Producer.Compression)
+	assert.Equal(t, 50*time.Millisecond, cfg.Producer.Flush.Frequency)
+	assert.Equal(t, 500, cfg.Producer.Flush.Messages)
+
+	cfg, err = AsyncProducerConfig(config.Kafka{})
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.CompressionZSTD, cfg.Producer.Compression)
+
+	_, err = AsyncProducerConfig(config.Kafka{Producer: config.Producer{Compression: "brotli"}})
+	assert.Error(t, err)
+
+	_, err = NewAsyncProducer(newStalledProducer(), config.Producer{BufferPolicy: "spill"})
+	assert.Error(t, err)
+}
+
+func TestAsyncProducerDelivery(t *testing.T) {
+	delivered := producerCount("delivery-topic", ProducerStatusDelivered)
+	failed := producerCount("delivery-topic", ProducerStatusFailed)
+
+	mockConfig := mocks.NewTestConfig()
+	mockConfig.Producer.Return.Successes = true
+	mockProducer := mocks.NewAsyncProducer(t, mockConfig)
+	mockProducer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if key, _ := msg.Key.Encode(); string(key) != "BTCUSDT" {
+			return errors.New("unexpected key")
+		}
+		return nil
+	})
+	mockProducer.ExpectInputAndFail(errors.New("not enough replicas"))
+
+	p, err := NewAsyncProducer(mockProducer, config.Producer{BufferSize: 10})
+	assert.NoError(t, err)
+	assert.NoError(t, p.Send("delivery-topic", "BTCUSDT", []byte("frame-1")))
+	assert.NoError(t, p.Send("delivery-topic", "BTCUSDT", []byte("frame-2")))
+
+	// close flushes the buffer and waits for both outcomes
+	assert.NoError(t, p.Close())
+	assert.Equal(t, delivered+1, producerCount("delivery-topic", ProducerStatusDelivered))
+	assert.Equal(t, failed+1, producerCount("delivery-topic", ProducerStatusFailed))
+	assert.ErrorIs(t, p.Send("delivery-topic", "BTCUSDT", []byte("frame-3")), ErrProducerClosed)
+}
+
+func TestAsyncProducerBufferPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{name: "Drop", policy: BufferPolicyDrop},
+		{name: "Block", policy: BufferPolicyBlock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := "buffer-" + tt.policy
+			dropped := producerCount(topic, ProducerStatusDropped)
+
+			stalled := newStalledProducer()
+			p, err := NewAsyncProducer(stalled, config.Producer{BufferSize: 1, BufferPolicy: tt.policy, BlockTimeout: 20})
+			assert.NoError(t, err)
+
+			// the first frame is in hand of the stalled producer, the second one fills the buffer
+			assert.NoError(t, p.Send(topic, "BTCUSDT", []byte("frame-1")))
+			assert.Eventually(t, func() bool { return len(p.buffer) == 0 }, time.Second, time.Millisecond)
+			assert.NoError(t, p.Send(topic, "BTCUSDT", []byte("frame-2")))
+
+			start := time.Now()
+			assert.ErrorIs(t, p.Send(topic, "BTCUSDT", []byte("frame-3")), ErrBufferFull)
+			if tt.policy == BufferPolicyBlock {
+				assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+			}
+			assert.Equal(t, dropped+1, producerCount(topic, ProducerStatusDropped))
+
+			// the broker catches up, the buffered frames go out in order
+			var values []string
+			done := make(chan struct{})
+			go func() {
+				for msg := range stalled.input {
+					value, _ := msg.Value.Encode()
+					values = append(values, string(value))
+					if len(values) == 2 {
+						close(done)
+					}
+				}
+			}()
+			<-done
+			assert.NoError(t, p.Close())
+			assert.Equal(t, []string{"frame-1", "frame-2"}, values)
+		})
+	}
+}
+
+func TestAsyncProducerBlockWaitsForRoom(t *testing.T) {
+	stalled := newStalledProducer()
+	p, err := NewAsyncProducer(stalled, config.Producer{BufferSize: 1, BufferPolicy: BufferPolicyBlock})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Send("block-topic", "BTCUSDT", []byte("frame-1")))
+	assert.Eventually(t, func() bool { return len(p.buffer) == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, p.Send("block-topic", "BTCUSDT", []byte("frame-2")))
+
+	// without a block timeout the send waits until the producer takes a message
+	sent := make(chan error)
+	go func() {
+		sent <- p.Send("block-topic", "BTCUSDT", []byte("frame-3"))
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("send returned before there was room: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-stalled.input
+	assert.NoError(t, <-sent)
+
+	go func() {
+		for range stalled.input {
+		}
+	}()
+	assert.NoError(t, p.Close())
+}
@@ -0,0 +1,17 @@
+package kafka
+
+import "github.com/IBM/sarama"
+
+// Broker produces to the topics, KafkaClient and MemoryBroker implement it
+type Broker interface {
+	Producer
+	// ProduceMessages sends the messages as one batch and returns once every message is acknowledged
+	ProduceMessages(msgs []*sarama.ProducerMessage) error
+	// ProduceAsync queues the message without waiting for the broker
+	ProduceAsync(topic, key string, message []byte, headers ...sarama.RecordHeader) error
+}
+
+var (
+	_ Broker = (*KafkaClient)(nil)
+	_ Broker = (*MemoryBroker)(nil)
+)
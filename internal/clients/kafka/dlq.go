@@ -0,0 +1,211 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+)
+
+var ErrMessageNotFound = errors.New(consts.DeadLetterNotFound)
+
+// Producer sends prepared messages, the consumers use it to dead letter messages
+type Producer interface {
+	ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error)
+}
+
+// DeadLetterTopic returns the dead letter topic of a source topic
+func DeadLetterTopic(topic string) string {
+	return topic + consts.DLQSuffix
+}
+
+// NewDeadLetter returns msg addressed to the dead letter topic of its source, the headers tell why, where and when it
+// failed and the original headers are kept
+func NewDeadLetter(msg *sarama.ConsumerMessage, group string, attempts int, err error, at time.Time) *sarama.ProducerMessage {
+	headers := WithoutDeadLetterHeaders(msg.Headers)
+	headers = append(headers,
+		header(consts.DLQErrorHeader, err.Error()),
+		header(consts.DLQErrorTypeHeader, ErrorType(err)),
+		header(consts.DLQAttemptsHeader, strconv.Itoa(attempts)),
+		header(consts.DLQGroupHeader, group),
+		header(consts.DLQSourceTopicHeader, msg.Topic),
+		header(consts.DLQSourcePartitionHeader, strconv.FormatInt(int64(msg.Partition), 10)),
+		header(consts.DLQSourceOffsetHeader, strconv.FormatInt(msg.Offset, 10)),
+		header(consts.DLQFailedAtHeader, at.UTC().Format(time.RFC3339Nano)),
+	)
+
+	dlq := &sarama.ProducerMessage{
+		Topic:   DeadLetterTopic(msg.Topic),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		dlq.Key = sarama.ByteEncoder(msg.Key)
+	}
+	return dlq
+}
+
+// NewReplay returns the dead lettered msg addressed back to its source topic with its original key and headers, only
+// the group it failed in handles it again. Messages dead lettered without their group are handled by every group.
+func NewReplay(msg *sarama.ConsumerMessage, source string) *sarama.ProducerMessage {
+	headers := WithoutDeadLetterHeaders(msg.Headers)
+	if group := Header(msg, consts.DLQGroupHeader); group != "" {
+		headers = append(headers, header(consts.DLQReplayGroupHeader, group))
+	}
+
+	replay := &sarama.ProducerMessage{
+		Topic:   source,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		replay.Key = sarama.ByteEncoder(msg.Key)
+	}
+	return replay
+}
+
+// replayedForOther reports whether msg is a replay meant for another group than group
+func replayedForOther(msg *sarama.ConsumerMessage, group string) bool {
+	replayGroup := Header(msg, consts.DLQReplayGroupHeader)
+	return replayGroup != "" && replayGroup != group
+}
+
+// WithoutDeadLetterHeaders copies the headers leaving out the ones added by NewDeadLetter
+func WithoutDeadLetterHeaders(headers []*sarama.RecordHeader) []sarama.RecordHeader {
+	var res []sarama.RecordHeader
+	for _, h := range headers {
+		if h == nil || strings.HasPrefix(string(h.Key), "dlq-") {
+			continue
+		}
+		res = append(res, *h)
+	}
+	return res
+}
+
+// Header returns the value of the named header of msg
+func Header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func header(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
+
+// ProduceMessage sends a prepared message, headers included
+func (k *KafkaClient) ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return k.producer.SendMessage(msg)
+}
+
+// ReadMessages returns up to limit of the latest messages of every partition of the topic, newest first
+func (k *KafkaClient) ReadMessages(ctx context.Context, topic string, limit int) ([]*sarama.ConsumerMessage, error) {
+	partitions, err := k.client.Partitions(topic)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// admin reads get their own consumer, a partition is consumed once per consumer
+	consumer, err := sarama.NewConsumerFromClient(k.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	var msgs []*sarama.ConsumerMessage
+	for _, partition := range partitions {
+		oldest, err := k.client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := k.client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+
+		from := max(oldest, newest-int64(limit))
+		if from >= newest {
+			continue
+		}
+		read, err := readPartition(ctx, consumer, topic, partition, from, newest)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, read...)
+	}
+
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Timestamp.After(msgs[j].Timestamp) })
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+// ReadMessage returns the message stored at the offset of the partition
+func (k *KafkaClient) ReadMessage(ctx context.Context, topic string, partition int32, offset int64) (*sarama.ConsumerMessage, error) {
+	oldest, err := k.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	newest, err := k.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+	if offset < oldest || offset >= newest {
+		return nil, ErrMessageNotFound
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(k.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	msgs, err := readPartition(ctx, consumer, topic, partition, offset, offset+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || msgs[0].Offset != offset {
+		return nil, ErrMessageNotFound
+	}
+	return msgs[0], nil
+}
+
+// readPartition reads the messages in [from, to) of the partition, offsets missing from the log are skipped
+func readPartition(ctx context.Context, consumer sarama.Consumer, topic string, partition int32, from, to int64) ([]*sarama.ConsumerMessage, error) {
+	pc, err := consumer.ConsumePartition(topic, partition, from)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+
+	var msgs []*sarama.ConsumerMessage
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case msg, ok := <-pc.Messages():
+			if !ok || msg.Offset >= to {
+				return msgs, nil
+			}
+			msgs = append(msgs, msg)
+			if msg.Offset == to-1 {
+				return msgs, nil
+			}
+		}
+	}
+}
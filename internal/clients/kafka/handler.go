@@ -2,54 +2,345 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// MessageHandler handles a consumed message, errors are retried unless they are marked Permanent and the message is
+// dead lettered once the retries are exhausted
 type MessageHandler interface {
-	HandleMessage(msg *sarama.ConsumerMessage)
+	HandleMessage(msg *sarama.ConsumerMessage) error
 }
 
+// DeferredHandler is a handler whose writes are flushed after it returns, it calls done once the message is
+// stored or skipped and only then its offset is marked. A write failing after the handler returned is passed to
+// done and dead letters the message. done is never called when an error is returned.
+type DeferredHandler interface {
+	HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error
+}
+
+// defaultDrainTimeout bounds how long a finished claim waits for the deferred messages to be flushed
+const defaultDrainTimeout = 10 * time.Second
+
+// consumeRetryDelay is the wait before joining the group again after a failed session
+const consumeRetryDelay = time.Second
+
 type Consumer struct {
 	Brokers []string
 	GroupID string
 	Topic   string
 	Handler MessageHandler
+	Retry   RetryPolicy
+	// DeadLetter receives the messages failing permanently or after the last retry, without it they are dropped
+	DeadLetter Producer
+	// Workers handle the messages of a claim in parallel, messages sharing a key always go to the same worker
+	Workers int
+	// DrainTimeout bounds how long a released claim waits for its deferred messages before committing
+	DrainTimeout time.Duration
+	// Group is the consumer group to consume through, nil joins GroupID on the Brokers
+	Group sarama.ConsumerGroup
+
+	done chan struct{}
 }
 
-func (c *Consumer) Start() error {
-	log.Printf("Starting Kafka consumer for group %s on topic %s", c.GroupID, c.Topic)
-	config := sarama.NewConfig()
-	config.Version = sarama.V2_1_0_0
+// Start joins the group and consumes until ctx is done, then the claims drain, commit their offsets and the
+// consumer leaves the group, Done is closed once it has
+func (c *Consumer) Start(ctx context.Context) error {
+	log.Printf("Starting Kafka consumer for group %s on topic %s with %d workers", c.GroupID, c.Topic, max(c.Workers, 1))
+	consumerGroup := c.Group
+	if consumerGroup == nil {
+		config := sarama.NewConfig()
+		config.Version = sarama.V2_1_0_0
 
-	consumerGroup, err := sarama.NewConsumerGroup(c.Brokers, c.GroupID, config)
-	if err != nil {
-		return err
+		group, err := sarama.NewConsumerGroup(c.Brokers, c.GroupID, config)
+		if err != nil {
+			return err
+		}
+		consumerGroup = group
 	}
 
+	handler := &consumerGroupHandler{
+		handler:      c.Handler,
+		group:        c.GroupID,
+		retry:        c.Retry,
+		deadLetter:   c.DeadLetter,
+		workers:      c.Workers,
+		drainTimeout: c.DrainTimeout,
+	}
+	c.done = make(chan struct{})
 	go func() {
-		for {
-			err := consumerGroup.Consume(context.Background(), []string{c.Topic}, &consumerGroupHandler{handler: c.Handler})
-			if err != nil {
-				log.Printf("[%s] Consume error: %v", c.GroupID, err)
+		defer close(c.done)
+		for ctx.Err() == nil {
+			err := consumerGroup.Consume(ctx, []string{c.Topic}, handler)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("[%s] Consume error: %v", c.GroupID, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(consumeRetryDelay):
 			}
 		}
+
+		if err := consumerGroup.Close(); err != nil {
+			log.Printf("[%s] Error leaving the consumer group: %v", c.GroupID, err)
+		}
+		log.Printf("[%s] Consumer stopped", c.GroupID)
 	}()
 
 	return nil
 }
 
+// Done is closed once the consumer stopped, it is nil before Start
+func (c *Consumer) Done() <-chan struct{} {
+	return c.done
+}
+
 type consumerGroupHandler struct {
-	handler MessageHandler
+	handler      MessageHandler
+	group        string
+	retry        RetryPolicy
+	deadLetter   Producer
+	workers      int
+	drainTimeout time.Duration
 }
 
 func (h *consumerGroupHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
 func (h *consumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim hands the messages to the workers until the claim is released or a message can neither be handled
+// nor dead lettered, then waits for the handled messages to be marked and commits their offsets
 func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		h.handler.HandleMessage(msg)
-		sess.MarkMessage(msg, "")
+	ctx, cancel := context.WithCancel(sess.Context())
+	defer cancel()
+
+	tracker := newOffsetTracker(sess)
+	var pool *keyedPool
+	pool = newKeyedPool(ctx, cancel, max(h.workers, 1), func(ctx context.Context, msg *sarama.ConsumerMessage, done func()) error {
+		return h.process(ctx, msg, done, func(err error) {
+			tracker.release(msg)
+			pool.fail(err)
+		})
+	}, tracker.release)
+
+consume:
+	for {
+		select {
+		case <-ctx.Done():
+			break consume
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				break consume
+			}
+			if !pool.dispatch(msg, tracker.track(msg)) {
+				tracker.release(msg)
+				break consume
+			}
+		}
 	}
+
+	err := pool.close()
+	drainTimeout := h.drainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	if !tracker.wait(drainTimeout) {
+		log.Printf("Claim %s/%d released with unflushed messages, they will be redelivered", claim.Topic(), claim.Partition())
+	}
+	sess.Commit()
+
+	// a released session stops the claim, it is not a failure
+	if err != nil && sess.Context().Err() != nil && errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// process handles a message under the retry policy, done runs once the message is stored, skipped or dead lettered.
+// A deferred write that fails and cannot be dead lettered calls fail so the message is redelivered. The span of the message continues the trace of its producer and is put back in the headers, the handler reads it
+// with tracing.MessageContext.
+func (h *consumerGroupHandler) process(ctx context.Context, msg *sarama.ConsumerMessage, done func(), fail func(error)) (err error) {
+	// the other groups handled the message the first time it was delivered
+	if replayedForOther(msg, h.group) {
+		done()
+		return nil
+	}
+
+	spanCtx, span := tracing.Tracer().Start(tracing.MessageContext(msg), msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(int(msg.Partition))),
+			semconv.MessagingKafkaConsumerGroup(h.group),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	tracing.SetMessageContext(spanCtx, msg)
+
+	deferred, isDeferred := h.handler.(DeferredHandler)
+
+	// the message counts as processed once its offset can be marked
+	partition := strconv.Itoa(int(msg.Partition))
+	finished := func() {
+		metrics.ConsumerProcessed.WithLabelValues(h.group, msg.Topic, partition).Inc()
+		done()
+	}
+	// the rows were already written one by one, retrying the message cannot store them so it goes to the dead letters
+	flushed := func(writeErr error) {
+		if writeErr == nil {
+			finished()
+			return
+		}
+		if err := h.handle(ctx, msg, func() error { return Permanent(writeErr) }); err != nil {
+			fail(err)
+			return
+		}
+		finished()
+	}
+
+	deferredDone := false
+	err = h.handle(ctx, msg, func() error {
+		if !isDeferred {
+			return h.handler.HandleMessage(msg)
+		}
+		err := deferred.HandleMessageDeferred(msg, flushed)
+		deferredDone = err == nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// the deferred handler calls done once its writes are flushed
+	if !deferredDone {
+		finished()
+	}
+	return nil
+}
+
+// handle runs fn under the retry policy and dead letters the message when it keeps failing, the returned error
+// means the message is neither handled nor dead lettered and the claim has to stop so it is redelivered
+func (h *consumerGroupHandler) handle(ctx context.Context, msg *sarama.ConsumerMessage, fn func() error) error {
+	attempts, err := h.retry.Do(ctx, func() error {
+		start := time.Now()
+		err := fn()
+		metrics.ConsumerHandleDuration.WithLabelValues(h.group, msg.Topic).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ConsumerErrors.WithLabelValues(h.group, msg.Topic, ErrorType(err)).Inc()
+		}
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	log.Printf("[%s] Message %s/%d/%d failed after %d attempts (%s): %v", h.group, msg.Topic, msg.Partition, msg.Offset, attempts, ErrorType(err), err)
+	if h.deadLetter == nil {
+		return nil
+	}
+	if _, _, dlqErr := h.deadLetter.ProduceMessage(NewDeadLetter(msg, h.group, attempts, err, time.Now())); dlqErr != nil {
+		return fmt.Errorf("dead letter %s/%d/%d: %w", msg.Topic, msg.Partition, msg.Offset, dlqErr)
+	}
+	metrics.ConsumerDeadLettered.WithLabelValues(h.group, msg.Topic).Inc()
 	return nil
 }
+
+// offsetTracker marks the messages of a claim in order, a message is marked once it and every earlier one is done
+type offsetTracker struct {
+	mu      sync.Mutex
+	sess    sarama.ConsumerGroupSession
+	pending []*trackedMessage
+	drained chan struct{}
+}
+
+type trackedMessage struct {
+	msg  *sarama.ConsumerMessage
+	done bool
+}
+
+func newOffsetTracker(sess sarama.ConsumerGroupSession) *offsetTracker {
+	return &offsetTracker{sess: sess}
+}
+
+// track returns the done callback of the message
+func (t *offsetTracker) track(msg *sarama.ConsumerMessage) func() {
+	entry := &trackedMessage{msg: msg}
+	t.mu.Lock()
+	t.pending = append(t.pending, entry)
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if entry.done {
+			return
+		}
+		entry.done = true
+
+		for len(t.pending) > 0 && t.pending[0].done {
+			t.sess.MarkMessage(t.pending[0].msg, "")
+			t.pending = t.pending[1:]
+		}
+		if len(t.pending) == 0 && t.drained != nil {
+			close(t.drained)
+			t.drained = nil
+		}
+	}
+}
+
+// release stops tracking the message and every later one, they are left unmarked to be redelivered
+func (t *offsetTracker) release(msg *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, entry := range t.pending {
+		if entry.msg.Offset >= msg.Offset {
+			t.pending = t.pending[:i]
+			break
+		}
+	}
+	if len(t.pending) == 0 && t.drained != nil {
+		close(t.drained)
+		t.drained = nil
+	}
+}
+
+// wait blocks until every tracked message is marked or the timeout passes
+func (t *offsetTracker) wait(timeout time.Duration) bool {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return true
+	}
+	drained := make(chan struct{})
+	t.drained = drained
+	t.mu.Unlock()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -14,8 +14,8 @@ type MockMessageHandler struct {
 	mock.Mock
 }
 
-func (m *MockMessageHandler) HandleMessage(msg *sarama.ConsumerMessage) {
-	m.Called(msg)
+func (m *MockMessageHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	return m.Called(msg).Error(0)
 }
 
 func TestConsumer_Start(t *testing.T) {
@@ -24,6 +24,7 @@ func TestConsumer_Start(t *testing.T) {
 		brokers []string
 		groupID string
 		topic   string
+		group   sarama.ConsumerGroup
 		wantErr bool
 	}{
 		{
@@ -31,6 +32,7 @@ func TestConsumer_Start(t *testing.T) {
 			brokers: []string{"localhost:9092"},
 			groupID: "test-group",
 			topic:   "test-topic",
+			group:   NewMemoryBroker().Group("test-group"),
 			wantErr: false,
 		},
 		{
@@ -50,9 +52,12 @@ func TestConsumer_Start(t *testing.T) {
 				GroupID: tt.groupID,
 				Topic:   tt.topic,
 				Handler: mockHandler,
+				Group:   tt.group,
 			}
 
-			err := consumer.Start()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err := consumer.Start(ctx)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -76,8 +81,9 @@ func TestConsumerGroupHandler_ConsumeClaim(t *testing.T) {
 		Value: []byte("test message"),
 	}
 
-	mockHandler.On("HandleMessage", testMsg).Return()
+	mockHandler.On("HandleMessage", testMsg).Return(nil)
 	mockSession.On("MarkMessage", testMsg, "").Return()
+	mockSession.On("Commit").Return()
 
 	go func() {
 		time.Sleep(100 * time.Millisecond)
@@ -157,3 +163,68 @@ func (m *MockConsumerGroupClaim) HighWaterMarkOffset() int64 {
 func (m *MockConsumerGroupClaim) InitialOffset() int64 {
 	return m.Called().Get(0).(int64)
 }
+
+// deferredHandler keeps the done callbacks so the test decides when messages are flushed
+type deferredHandler struct {
+	dones chan func(error)
+}
+
+func (h *deferredHandler) HandleMessage(msg *sarama.ConsumerMessage) error { return nil }
+
+func (h *deferredHandler) HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error {
+	h.dones <- done
+	return nil
+}
+
+func TestConsumerGroupHandler_ConsumeClaimDeferred(t *testing.T) {
+	handler := &deferredHandler{dones: make(chan func(error), 3)}
+	cgh := &consumerGroupHandler{handler: handler}
+
+	msgs := []*sarama.ConsumerMessage{
+		{Topic: "test-topic", Offset: 1},
+		{Topic: "test-topic", Offset: 2},
+		{Topic: "test-topic", Offset: 3},
+	}
+	mockClaim := &MockConsumerGroupClaim{messagesChan: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		mockClaim.messagesChan <- msg
+	}
+	close(mockClaim.messagesChan)
+
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", mock.Anything, "").Return()
+	mockSession.On("Commit").Return()
+
+	finished := make(chan error)
+	go func() {
+		finished <- cgh.ConsumeClaim(mockSession, mockClaim)
+	}()
+
+	var dones []func(error)
+	for range msgs {
+		dones = append(dones, <-handler.dones)
+	}
+
+	// the later messages are flushed first, nothing is marked until the first one is
+	dones[2](nil)
+	dones[1](nil)
+	mockSession.AssertNotCalled(t, "MarkMessage", mock.Anything, "")
+
+	dones[0](nil)
+	assert.NoError(t, <-finished)
+
+	mockSession.AssertNumberOfCalls(t, "MarkMessage", 3)
+	for i, call := range mockSession.Calls[:3] {
+		assert.Equal(t, msgs[i], call.Arguments.Get(0))
+	}
+	mockSession.AssertCalled(t, "Commit")
+}
+
+func TestOffsetTrackerWaitTimeout(t *testing.T) {
+	mockSession := &MockConsumerGroupSession{}
+	tracker := newOffsetTracker(mockSession)
+	tracker.track(&sarama.ConsumerMessage{Offset: 1})
+
+	assert.False(t, tracker.wait(10*time.Millisecond))
+	mockSession.AssertNotCalled(t, "MarkMessage", mock.Anything, "")
+}
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -11,15 +12,14 @@ import (
 )
 
 type KafkaClient struct {
+	client   sarama.Client
 	producer sarama.SyncProducer
+	async    *AsyncProducer
 	topics   []string
 	consumer sarama.Consumer
 }
 
-var (
-	kafka_client *KafkaClient
-	KafkaAlive   bool
-)
+var kafka_client *KafkaClient
 
 func KafkaClientNew() *KafkaClient {
 	return kafka_client
@@ -74,6 +74,32 @@ func InitKafka(cfg config.Kafka) {
 		log.Fatalf("Failed to create Kafka sync producer after %d attempts: %v", maxRetries, err)
 	}
 
+	// Async producer of the exchange streams, it runs on its own client since it needs an idempotent config
+	asyncConfig, err := AsyncProducerConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid Kafka async producer config: %v", err)
+	}
+
+	var async_producer sarama.AsyncProducer
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		async_producer, err = sarama.NewAsyncProducer(config.ReadValue().Kafka.Brokers, asyncConfig)
+		if err == nil {
+			break
+		}
+
+		log.Printf("Failed to create Kafka async producer (attempt %d/%d): %v", attempt, maxRetries, err)
+		time.Sleep(retryInterval)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to create Kafka async producer after %d attempts: %v", maxRetries, err)
+	}
+
+	async, err := NewAsyncProducer(async_producer, cfg.Producer)
+	if err != nil {
+		log.Fatalf("Invalid Kafka async producer config: %v", err)
+	}
+
 	// Get topics from Kafka with retries
 	var topics []string
 	for attempt := 1; attempt <= maxRetries; attempt++ {
@@ -108,8 +134,10 @@ func InitKafka(cfg config.Kafka) {
 
 	// Kafka client object setup
 	c := KafkaClient{
+		client:   client,
 		topics:   topics,
 		producer: sync_producer,
+		async:    async,
 		consumer: consumer,
 	}
 
@@ -118,33 +146,11 @@ func InitKafka(cfg config.Kafka) {
 
 }
 
-func CheckKafkaAlive(ctx context.Context, cfg config.Kafka) {
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		saramaConfig := sarama.NewConfig()
-		saramaConfig.Producer.Return.Errors = cfg.ReturnErrors
-		saramaConfig.Producer.Return.Successes = cfg.ReturnSucces
-		saramaConfig.Producer.Retry.Max = cfg.MaxRetry
-		saramaConfig.Producer.MaxMessageBytes = cfg.MaxMessageSize
-
-		client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
-		if err != nil {
-			log.Println("Kafka client check failed:", err)
-			KafkaAlive = false
-			continue
-		}
-
-		_, err = client.Topics()
-		if err != nil {
-			log.Println("Kafka topic fetch failed:", err)
-			KafkaAlive = false
-		} else {
-			KafkaAlive = true
-			log.Println("Kafka is alive.")
-		}
-
-		_ = client.Close()
+// Ping refreshes the cluster metadata from the brokers, it is the readiness check of kafka
+func Ping(ctx context.Context) error {
+	if kafka_client == nil {
+		return errors.New("kafka is not initialized")
 	}
+	// sarama does not take a context, the registry gives up on the check at its timeout
+	return kafka_client.client.RefreshMetadata()
 }
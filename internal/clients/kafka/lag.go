@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+)
+
+const defaultLagInterval = 15 * time.Second
+
+// offsetReader reads the offsets of a partition, sarama.Client implements it
+type offsetReader interface {
+	Partitions(topic string) ([]int32, error)
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+}
+
+// groupOffsetReader reads the offsets a group committed, sarama.ClusterAdmin implements it
+type groupOffsetReader interface {
+	ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error)
+}
+
+// LagMonitor reports the lag of the consumer groups, the high water mark of each partition minus the offset the group
+// committed. It reads the committed offsets from the brokers, so the lag of a stuck or stopped group keeps growing.
+type LagMonitor struct {
+	offsets   offsetReader
+	committed groupOffsetReader
+	groups    map[string]string // group -> topic
+}
+
+// NewLagMonitor returns a monitor of the consumers reading the offsets through the kafka client
+func (k *KafkaClient) NewLagMonitor(consumers ...*Consumer) (*LagMonitor, error) {
+	if k == nil || k.client == nil {
+		return nil, errors.New("kafka is not initialized")
+	}
+	// the admin shares the client, it is never closed so the client stays open
+	admin, err := sarama.NewClusterAdminFromClient(k.client)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]string, len(consumers))
+	for _, c := range consumers {
+		groups[c.GroupID] = c.Topic
+	}
+	return &LagMonitor{
+		offsets:   k.client,
+		committed: admin,
+		groups:    groups,
+	}, nil
+}
+
+// Collect updates the lag of every partition consumed by the groups
+func (m *LagMonitor) Collect() error {
+	var errs []error
+	for group, topic := range m.groups {
+		if err := m.collect(group, topic); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", group, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *LagMonitor) collect(group, topic string) error {
+	partitions, err := m.offsets.Partitions(topic)
+	if err != nil {
+		return err
+	}
+	res, err := m.committed.ListConsumerGroupOffsets(group, map[string][]int32{topic: partitions})
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		highWaterMark, err := m.offsets.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+
+		// a group that never committed on the partition has the whole retained log ahead of it
+		committed := int64(-1)
+		if block := res.GetBlock(topic, partition); block != nil {
+			committed = block.Offset
+		}
+		if committed < 0 {
+			if committed, err = m.offsets.GetOffset(topic, partition, sarama.OffsetOldest); err != nil {
+				return err
+			}
+		}
+
+		metrics.ConsumerLag.WithLabelValues(group, topic, strconv.Itoa(int(partition))).Set(float64(max(highWaterMark-committed, 0)))
+	}
+	return nil
+}
+
+// StartLagMonitor collects the lag every interval until ctx is done
+func StartLagMonitor(ctx context.Context, m *LagMonitor, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Collect(); err != nil {
+			log.Printf("Error collecting consumer lag: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LagInterval returns the time between lag collections
+func LagInterval(cfg config.Consumer) time.Duration {
+	if cfg.LagInterval > 0 {
+		return time.Duration(cfg.LagInterval) * time.Second
+	}
+	return defaultLagInterval
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOffsets serves the oldest and newest offset of every partition and the offsets a group committed
+type fakeOffsets struct {
+	oldest    map[int32]int64
+	newest    map[int32]int64
+	committed map[int32]int64
+}
+
+func (f *fakeOffsets) Partitions(topic string) ([]int32, error) {
+	var partitions []int32
+	for partition := range f.newest {
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+func (f *fakeOffsets) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	if time == sarama.OffsetOldest {
+		return f.oldest[partition], nil
+	}
+	return f.newest[partition], nil
+}
+
+func (f *fakeOffsets) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	res := &sarama.OffsetFetchResponse{}
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			offset, ok := f.committed[partition]
+			if !ok {
+				offset = -1
+			}
+			res.AddBlock(topic, partition, &sarama.OffsetFetchResponseBlock{Offset: offset})
+		}
+	}
+	return res, nil
+}
+
+func TestLagMonitorCollect(t *testing.T) {
+	offsets := &fakeOffsets{
+		oldest:    map[int32]int64{0: 0, 1: 20},
+		newest:    map[int32]int64{0: 120, 1: 50},
+		committed: map[int32]int64{0: 100},
+	}
+	m := &LagMonitor{offsets: offsets, committed: offsets, groups: map[string]string{"lag-group": "depth-data"}}
+
+	assert.NoError(t, m.Collect())
+	assert.Equal(t, float64(20), testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues("lag-group", "depth-data", "0")))
+	// nothing committed yet, the group is behind by the whole retained log
+	assert.Equal(t, float64(30), testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues("lag-group", "depth-data", "1")))
+
+	offsets.committed[1] = 50
+	assert.NoError(t, m.Collect())
+	assert.Zero(t, testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues("lag-group", "depth-data", "1")))
+}
@@ -0,0 +1,238 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// MemoryBroker keeps every topic as a single partition log in memory, it stands in for the brokers in tests. The
+// groups returned by Group consume it through the same handlers as a sarama consumer group, a marked message counts as
+// committed.
+type MemoryBroker struct {
+	mu        sync.Mutex
+	logs      map[string][]*sarama.ConsumerMessage
+	committed map[string]map[string]int64 // group -> topic -> next offset
+	appended  chan struct{}               // closed and replaced on every append
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		logs:      map[string][]*sarama.ConsumerMessage{},
+		committed: map[string]map[string]int64{},
+		appended:  make(chan struct{}),
+	}
+}
+
+func (b *MemoryBroker) ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	offset, err := b.append(msg)
+	return 0, offset, err
+}
+
+func (b *MemoryBroker) ProduceMessages(msgs []*sarama.ProducerMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		if _, err := b.append(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProduceAsync appends the message right away, the memory broker has no buffer to fill
+func (b *MemoryBroker) ProduceAsync(topic, key string, message []byte, headers ...sarama.RecordHeader) error {
+	_, _, err := b.ProduceMessage(&sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.StringEncoder(key),
+		Value:   sarama.ByteEncoder(message),
+		Headers: headers,
+	})
+	return err
+}
+
+func (b *MemoryBroker) append(msg *sarama.ProducerMessage) (int64, error) {
+	consumed := &sarama.ConsumerMessage{
+		Topic:     msg.Topic,
+		Offset:    int64(len(b.logs[msg.Topic])),
+		Timestamp: time.Now(),
+	}
+	var err error
+	if msg.Key != nil {
+		if consumed.Key, err = msg.Key.Encode(); err != nil {
+			return 0, err
+		}
+	}
+	if msg.Value != nil {
+		if consumed.Value, err = msg.Value.Encode(); err != nil {
+			return 0, err
+		}
+	}
+	for _, h := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+
+	b.logs[msg.Topic] = append(b.logs[msg.Topic], consumed)
+	close(b.appended)
+	b.appended = make(chan struct{})
+	return consumed.Offset, nil
+}
+
+// Messages returns the messages produced to the topic so far
+func (b *MemoryBroker) Messages(topic string) []*sarama.ConsumerMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*sarama.ConsumerMessage(nil), b.logs[topic]...)
+}
+
+// Committed returns the next offset the group consumes from the topic
+func (b *MemoryBroker) Committed(group, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed[group][topic]
+}
+
+// Group returns a consumer group reading the broker, set it as the Group of a Consumer
+func (b *MemoryBroker) Group(group string) sarama.ConsumerGroup {
+	return &memoryGroup{
+		broker: b,
+		group:  group,
+		errors: make(chan error),
+	}
+}
+
+// next returns the messages of the topic from offset on, or a channel closed once there are some
+func (b *MemoryBroker) next(topic string, offset int64) ([]*sarama.ConsumerMessage, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if log := b.logs[topic]; offset < int64(len(log)) {
+		return log[offset:], nil
+	}
+	return nil, b.appended
+}
+
+func (b *MemoryBroker) mark(group, topic string, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.committed[group] == nil {
+		b.committed[group] = map[string]int64{}
+	}
+	b.committed[group][topic] = max(b.committed[group][topic], offset)
+}
+
+type memoryGroup struct {
+	broker *MemoryBroker
+	group  string
+	errors chan error
+}
+
+// Consume claims the single partition of every topic and hands the messages to the handler until ctx is done
+func (g *memoryGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	sess := &memorySession{ctx: ctx, group: g}
+	if err := handler.Setup(sess); err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, topic := range topics {
+		claim := &memoryClaim{
+			broker:   g.broker,
+			topic:    topic,
+			offset:   g.broker.Committed(g.group, topic),
+			messages: make(chan *sarama.ConsumerMessage),
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			claim.feed(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			if err := handler.ConsumeClaim(sess, claim); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := handler.Cleanup(sess); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (g *memoryGroup) Errors() <-chan error      { return g.errors }
+func (g *memoryGroup) Close() error              { return nil }
+func (g *memoryGroup) Pause(map[string][]int32)  {}
+func (g *memoryGroup) Resume(map[string][]int32) {}
+func (g *memoryGroup) PauseAll()                 {}
+func (g *memoryGroup) ResumeAll()                {}
+
+type memorySession struct {
+	ctx   context.Context
+	group *memoryGroup
+}
+
+func (s *memorySession) Claims() map[string][]int32 { return nil }
+func (s *memorySession) MemberID() string           { return s.group.group }
+func (s *memorySession) GenerationID() int32        { return 1 }
+func (s *memorySession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.group.broker.mark(s.group.group, topic, offset)
+}
+func (s *memorySession) Commit()                                                                  {}
+func (s *memorySession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+func (s *memorySession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+func (s *memorySession) Context() context.Context { return s.ctx }
+
+type memoryClaim struct {
+	broker   *MemoryBroker
+	topic    string
+	offset   int64
+	messages chan *sarama.ConsumerMessage
+}
+
+// feed sends the messages of the topic as they are produced and closes the claim once ctx is done
+func (c *memoryClaim) feed(ctx context.Context) {
+	defer close(c.messages)
+	offset := c.offset
+	for {
+		msgs, appended := c.broker.next(c.topic, offset)
+		for _, msg := range msgs {
+			select {
+			case c.messages <- msg:
+				offset++
+			case <-ctx.Done():
+				return
+			}
+		}
+		if appended == nil {
+			continue
+		}
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *memoryClaim) Topic() string                            { return c.topic }
+func (c *memoryClaim) Partition() int32                         { return 0 }
+func (c *memoryClaim) InitialOffset() int64                     { return c.offset }
+func (c *memoryClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *memoryClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
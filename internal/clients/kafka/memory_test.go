@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler keeps the values it handled, failures fails the first calls
+type recordingHandler struct {
+	mu       sync.Mutex
+	values   []string
+	failures int
+}
+
+func (h *recordingHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failures > 0 {
+		h.failures--
+		return errors.New("database is down")
+	}
+	h.values = append(h.values, string(msg.Value))
+	return nil
+}
+
+func (h *recordingHandler) handled() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.values...)
+}
+
+func TestMemoryBrokerConsumer(t *testing.T) {
+	broker := NewMemoryBroker()
+	assert.NoError(t, broker.ProduceAsync("test-topic", "BTCUSDT", []byte("1")))
+
+	handler := &recordingHandler{failures: 1}
+	consumer := &Consumer{GroupID: "test-group", Topic: "test-topic", Handler: handler, Retry: testRetry, Group: broker.Group("test-group")}
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, consumer.Start(ctx))
+
+	// messages produced while the group consumes are delivered too
+	assert.NoError(t, broker.ProduceMessages([]*sarama.ProducerMessage{{Topic: "test-topic", Value: sarama.StringEncoder("2")}}))
+	assert.Eventually(t, func() bool { return len(handler.handled()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "2"}, handler.handled())
+
+	cancel()
+	<-consumer.Done()
+	assert.Equal(t, int64(2), broker.Committed("test-group", "test-topic"))
+
+	// a restarted group continues after its committed offset
+	_, _, err := broker.ProduceMessage(&sarama.ProducerMessage{Topic: "test-topic", Value: sarama.StringEncoder("3")})
+	assert.NoError(t, err)
+	restarted := &Consumer{GroupID: "test-group", Topic: "test-topic", Handler: handler, Group: broker.Group("test-group")}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, restarted.Start(ctx))
+	assert.Eventually(t, func() bool { return len(handler.handled()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "2", "3"}, handler.handled())
+}
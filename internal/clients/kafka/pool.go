@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// workerQueueSize is how many messages wait for a busy worker before the claim stops fetching
+const workerQueueSize = 64
+
+// handleFunc handles a message, done runs once the message can be marked
+type handleFunc func(ctx context.Context, msg *sarama.ConsumerMessage, done func()) error
+
+type job struct {
+	msg  *sarama.ConsumerMessage
+	done func()
+}
+
+// keyedPool handles the messages of a claim on a fixed set of workers. Messages sharing a key go to the same
+// worker, so a symbol is handled in order while the symbols run in parallel. The first failure stops the pool.
+type keyedPool struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	handle  handleFunc
+	abandon func(msg *sarama.ConsumerMessage) // called for the messages left unhandled
+	queues  []chan job
+	wg      sync.WaitGroup
+
+	errMu sync.Mutex
+	err   error
+}
+
+func newKeyedPool(ctx context.Context, cancel context.CancelFunc, workers int, handle handleFunc, abandon func(msg *sarama.ConsumerMessage)) *keyedPool {
+	p := &keyedPool{
+		ctx:     ctx,
+		cancel:  cancel,
+		handle:  handle,
+		abandon: abandon,
+		queues:  make([]chan job, workers),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan job, workerQueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// dispatch queues the message on the worker of its key, it returns false once the pool is stopped
+func (p *keyedPool) dispatch(msg *sarama.ConsumerMessage, done func()) bool {
+	select {
+	case p.queues[p.worker(msg.Key)] <- job{msg: msg, done: done}:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// worker picks the worker of a key, messages without a key share the first worker and keep their order
+func (p *keyedPool) worker(key []byte) int {
+	if len(p.queues) == 1 || len(key) == 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// close waits for the workers to finish the messages in hand and returns the failure stopping the pool
+func (p *keyedPool) close() error {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	return p.err
+}
+
+func (p *keyedPool) work(queue <-chan job) {
+	defer p.wg.Done()
+	for j := range queue {
+		// once stopped the queued messages are left unmarked and redelivered
+		if p.ctx.Err() != nil {
+			p.abandon(j.msg)
+			continue
+		}
+		if err := p.handle(p.ctx, j.msg, j.done); err != nil {
+			p.abandon(j.msg)
+			p.fail(err)
+		}
+	}
+}
+
+// fail stops the pool, the first failure is the one returned by close. A deferred write may fail after close.
+func (p *keyedPool) fail(err error) {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	if p.err == nil {
+		p.err = err
+		p.cancel()
+	}
+}
@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// cancellableSession is a session whose context the test cancels, as sarama does on shutdown and rebalance
+type cancellableSession struct {
+	MockConsumerGroupSession
+	ctx context.Context
+}
+
+func (s *cancellableSession) Context() context.Context {
+	return s.ctx
+}
+
+// blockingHandler records the handled messages, a message whose key has a gate waits for it to be closed
+type blockingHandler struct {
+	mu      sync.Mutex
+	handled []string
+	started chan string
+	gates   map[string]chan struct{}
+}
+
+func (h *blockingHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	h.started <- string(msg.Value)
+	if gate, ok := h.gates[string(msg.Value)]; ok {
+		<-gate
+	}
+	h.mu.Lock()
+	h.handled = append(h.handled, string(msg.Value))
+	h.mu.Unlock()
+	return nil
+}
+
+func TestKeyedPoolWorker(t *testing.T) {
+	p := &keyedPool{queues: make([]chan job, 4)}
+	assert.Equal(t, p.worker([]byte("BTCUSDT")), p.worker([]byte("BTCUSDT")))
+	assert.Equal(t, 0, p.worker(nil))
+	assert.Less(t, p.worker([]byte("ETHUSDT")), 4)
+}
+
+func TestConsumeClaimKeyedWorkers(t *testing.T) {
+	h := &blockingHandler{
+		started: make(chan string, 3),
+		gates:   map[string]chan struct{}{"btc-1": make(chan struct{})},
+	}
+	cgh := &consumerGroupHandler{handler: h, workers: 2}
+
+	// the keys land on different workers
+	pool := &keyedPool{queues: make([]chan job, 2)}
+	btc, sol := []byte("BTCUSDT"), []byte("SOLUSDT")
+	assert.NotEqual(t, pool.worker(btc), pool.worker(sol))
+
+	msgs := []*sarama.ConsumerMessage{
+		{Topic: "test-topic", Offset: 0, Key: btc, Value: []byte("btc-1")},
+		{Topic: "test-topic", Offset: 1, Key: sol, Value: []byte("sol-1")},
+		{Topic: "test-topic", Offset: 2, Key: btc, Value: []byte("btc-2")},
+	}
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", mock.Anything, "").Return()
+	mockSession.On("Commit").Return()
+
+	finished := make(chan error)
+	go func() {
+		finished <- cgh.ConsumeClaim(mockSession, claimOf(msgs...))
+	}()
+
+	// sol runs while the first btc update is blocked, the second btc update waits for the first one
+	assert.ElementsMatch(t, []string{"btc-1", "sol-1"}, []string{<-h.started, <-h.started})
+	select {
+	case value := <-h.started:
+		t.Fatalf("%s started before btc-1 finished", value)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(h.gates["btc-1"])
+	assert.Equal(t, "btc-2", <-h.started)
+	assert.NoError(t, <-finished)
+
+	assert.Equal(t, []string{"sol-1", "btc-1", "btc-2"}, h.handled)
+	mockSession.AssertNumberOfCalls(t, "MarkMessage", 3)
+	for i, call := range mockSession.Calls[:3] {
+		assert.Equal(t, msgs[i], call.Arguments.Get(0))
+	}
+	mockSession.AssertCalled(t, "Commit")
+}
+
+func TestConsumeClaimDrainsOnShutdown(t *testing.T) {
+	h := &blockingHandler{
+		started: make(chan string, 3),
+		gates:   map[string]chan struct{}{"first": make(chan struct{})},
+	}
+	cgh := &consumerGroupHandler{handler: h, workers: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess := &cancellableSession{ctx: ctx}
+	sess.On("MarkMessage", mock.Anything, "").Return()
+	sess.On("Commit").Return()
+
+	first := &sarama.ConsumerMessage{Topic: "test-topic", Offset: 0, Value: []byte("first")}
+	claim := &MockConsumerGroupClaim{messagesChan: make(chan *sarama.ConsumerMessage, 2)}
+	claim.messagesChan <- first
+	claim.messagesChan <- &sarama.ConsumerMessage{Topic: "test-topic", Offset: 1, Value: []byte("second")}
+
+	finished := make(chan error)
+	go func() {
+		finished <- cgh.ConsumeClaim(sess, claim)
+	}()
+
+	// the session ends while the first message is in hand, it is finished and committed, the queued one is left
+	assert.Equal(t, "first", <-h.started)
+	cancel()
+	close(h.gates["first"])
+
+	assert.NoError(t, <-finished)
+	assert.Equal(t, []string{"first"}, h.handled)
+	sess.AssertNumberOfCalls(t, "MarkMessage", 1)
+	sess.AssertCalled(t, "MarkMessage", first, "")
+	sess.AssertCalled(t, "Commit")
+}
+
+func TestRuntimeShutdown(t *testing.T) {
+	stopped := &Consumer{GroupID: "stopped", done: make(chan struct{})}
+	close(stopped.done)
+	stuck := &Consumer{GroupID: "stuck", done: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runtime{consumers: []*Consumer{stopped, {GroupID: "not started"}}, cancel: cancel}
+	assert.NoError(t, r.Shutdown(time.Second))
+	assert.Error(t, ctx.Err())
+
+	r = &Runtime{consumers: []*Consumer{stopped, stuck}}
+	assert.ErrorIs(t, r.Shutdown(10*time.Millisecond), ErrShutdownTimeout)
+}
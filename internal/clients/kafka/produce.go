@@ -4,13 +4,36 @@ import (
 	"github.com/IBM/sarama"
 )
 
-func (k *KafkaClient) Produce(topic, key string, message []byte) (int32, int64, error) {
+func (k *KafkaClient) Produce(topic, key string, message []byte, headers ...sarama.RecordHeader) (int32, int64, error) {
 
 	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(key),
-		Value: sarama.StringEncoder(message),
+		Topic:   topic,
+		Key:     sarama.StringEncoder(key),
+		Value:   sarama.StringEncoder(message),
+		Headers: headers,
 	}
 
 	return k.producer.SendMessage(msg)
 }
+
+// ProduceMessages sends the messages as one batch and returns once every message is acknowledged
+func (k *KafkaClient) ProduceMessages(msgs []*sarama.ProducerMessage) error {
+	return k.producer.SendMessages(msgs)
+}
+
+// ProduceAsync queues the message on the async producer without waiting for the broker, it returns ErrBufferFull
+// when the buffer is full and the message is dropped
+func (k *KafkaClient) ProduceAsync(topic, key string, message []byte, headers ...sarama.RecordHeader) error {
+	if k.async == nil {
+		return ErrProducerClosed
+	}
+	return k.async.Send(topic, key, message, headers...)
+}
+
+// CloseAsync flushes the buffered messages of the async producer and closes it
+func (k *KafkaClient) CloseAsync() error {
+	if k.async == nil {
+		return nil
+	}
+	return k.async.Close()
+}
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+)
+
+const (
+	ErrorTypePermanent = "permanent"
+	ErrorTypeRetryable = "retryable"
+)
+
+const (
+	defaultMaxAttempts  = 3
+	defaultRetryBackoff = 200 * time.Millisecond
+	defaultMaxBackoff   = 5 * time.Second
+)
+
+// PermanentError wraps a failure retrying cannot fix, such as a message that does not decode
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent marks err as not retryable, the message goes to the dead letter topic on the first failure
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err is marked as not retryable, every other error is retried
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// ErrorType returns the class of a handler error
+func ErrorType(err error) string {
+	if IsPermanent(err) {
+		return ErrorTypePermanent
+	}
+	return ErrorTypeRetryable
+}
+
+// RetryPolicy bounds how often a failed message is handled again before it is dead lettered
+type RetryPolicy struct {
+	MaxAttempts int           // handler calls per message, including the first one
+	Backoff     time.Duration // wait before the second attempt, doubled on every further one
+	MaxBackoff  time.Duration
+}
+
+// NewRetryPolicy returns the retry settings of the consumer handlers
+func NewRetryPolicy(cfg config.Consumer) RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     time.Duration(cfg.RetryBackoff) * time.Millisecond,
+		MaxBackoff:  time.Duration(cfg.MaxRetryBackoff) * time.Millisecond,
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaultRetryBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	return p
+}
+
+// delay returns the wait before the given attempt, attempts start at 1
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 2; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// Do calls fn until it succeeds, fails permanently or runs out of attempts and returns the attempts made with the
+// last error, ctx being done stops the retries with its error
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || IsPermanent(err) || attempt >= maxAttempts {
+			return attempt, err
+		}
+
+		timer := time.NewTimer(p.delay(attempt + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingProducer keeps the produced messages, err fails every send
+type recordingProducer struct {
+	msgs []*sarama.ProducerMessage
+	err  error
+}
+
+func (p *recordingProducer) ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if p.err != nil {
+		return 0, 0, p.err
+	}
+	p.msgs = append(p.msgs, msg)
+	return 0, int64(len(p.msgs) - 1), nil
+}
+
+func headerOf(msg *sarama.ProducerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func claimOf(msgs ...*sarama.ConsumerMessage) *MockConsumerGroupClaim {
+	claim := &MockConsumerGroupClaim{messagesChan: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.messagesChan <- msg
+	}
+	close(claim.messagesChan)
+	return claim
+}
+
+var testRetry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, p.delay(2))
+	assert.Equal(t, 200*time.Millisecond, p.delay(3))
+	assert.Equal(t, 300*time.Millisecond, p.delay(4))
+	assert.Equal(t, 300*time.Millisecond, p.delay(5))
+}
+
+func TestErrorType(t *testing.T) {
+	err := Permanent(errors.New("bad payload"))
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsPermanent(errors.Join(errors.New("decode"), err)))
+	assert.Equal(t, ErrorTypePermanent, ErrorType(err))
+	assert.Equal(t, ErrorTypeRetryable, ErrorType(errors.New("connection refused")))
+	assert.Nil(t, Permanent(nil))
+}
+
+func TestConsumeClaimRetriesUntilHandled(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Topic: "test-topic", Offset: 7}
+	mockHandler := &MockMessageHandler{}
+	mockHandler.On("HandleMessage", msg).Return(errors.New("database is down")).Twice()
+	mockHandler.On("HandleMessage", msg).Return(nil).Once()
+
+	dlq := &recordingProducer{}
+	h := &consumerGroupHandler{handler: mockHandler, group: "retry-group", retry: testRetry, deadLetter: dlq}
+
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", msg, "").Return()
+	mockSession.On("Commit").Return()
+
+	assert.NoError(t, h.ConsumeClaim(mockSession, claimOf(msg)))
+	mockHandler.AssertNumberOfCalls(t, "HandleMessage", 3)
+	mockSession.AssertCalled(t, "MarkMessage", msg, "")
+	assert.Empty(t, dlq.msgs)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.ConsumerErrors.WithLabelValues("retry-group", "test-topic", ErrorTypeRetryable)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.ConsumerProcessed.WithLabelValues("retry-group", "test-topic", "0")))
+	assert.Zero(t, testutil.ToFloat64(metrics.ConsumerDeadLettered.WithLabelValues("retry-group", "test-topic")))
+}
+
+func TestConsumeClaimDeadLetters(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		calls     int
+		errorType string
+	}{
+		{name: "Permanent", err: Permanent(errors.New("invalid character")), calls: 1, errorType: ErrorTypePermanent},
+		{name: "Retries exhausted", err: errors.New("database is down"), calls: 3, errorType: ErrorTypeRetryable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &sarama.ConsumerMessage{
+				Topic:     "depth-data",
+				Partition: 2,
+				Offset:    41,
+				Key:       []byte("BTCUSDT"),
+				Value:     []byte("{"),
+				Headers:   []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+			}
+			mockHandler := &MockMessageHandler{}
+			mockHandler.On("HandleMessage", msg).Return(tt.err)
+
+			dlq := &recordingProducer{}
+			h := &consumerGroupHandler{handler: mockHandler, group: "test-group", retry: testRetry, deadLetter: dlq}
+
+			mockSession := &MockConsumerGroupSession{}
+			mockSession.On("MarkMessage", msg, "").Return()
+			mockSession.On("Commit").Return()
+
+			assert.NoError(t, h.ConsumeClaim(mockSession, claimOf(msg)))
+			mockHandler.AssertNumberOfCalls(t, "HandleMessage", tt.calls)
+			mockSession.AssertCalled(t, "MarkMessage", msg, "")
+
+			assert.Len(t, dlq.msgs, 1)
+			dead := dlq.msgs[0]
+			assert.Equal(t, "depth-data.dlq", dead.Topic)
+			key, _ := dead.Key.Encode()
+			assert.Equal(t, "BTCUSDT", string(key))
+			assert.Equal(t, tt.err.Error(), headerOf(dead, consts.DLQErrorHeader))
+			assert.Equal(t, tt.errorType, headerOf(dead, consts.DLQErrorTypeHeader))
+			assert.Equal(t, "test-group", headerOf(dead, consts.DLQGroupHeader))
+			assert.Equal(t, "depth-data", headerOf(dead, consts.DLQSourceTopicHeader))
+			assert.Equal(t, "2", headerOf(dead, consts.DLQSourcePartitionHeader))
+			assert.Equal(t, "41", headerOf(dead, consts.DLQSourceOffsetHeader))
+			assert.Equal(t, "abc", headerOf(dead, "trace"))
+			assert.NotEmpty(t, headerOf(dead, consts.DLQFailedAtHeader))
+		})
+	}
+}
+
+func TestConsumeClaimDeadLetterFails(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Topic: "test-topic", Offset: 3}
+	mockHandler := &MockMessageHandler{}
+	mockHandler.On("HandleMessage", msg).Return(Permanent(errors.New("bad payload")))
+
+	h := &consumerGroupHandler{handler: mockHandler, retry: testRetry, deadLetter: &recordingProducer{err: errors.New("broker down")}}
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("Commit").Return()
+
+	// the message is neither handled nor dead lettered, it stays unmarked to be redelivered
+	assert.Error(t, h.ConsumeClaim(mockSession, claimOf(msg)))
+	mockSession.AssertNotCalled(t, "MarkMessage", mock.Anything, "")
+}
+
+func TestConsumeClaimReplayOnlyInFailedGroup(t *testing.T) {
+	source := &sarama.ConsumerMessage{Topic: "depth-data", Offset: 41, Key: []byte("BTCUSDT"), Value: []byte("{}")}
+	dead := NewDeadLetter(source, "test-group", 3, errors.New("database is down"), time.Now())
+	value, _ := dead.Value.Encode()
+	deadMsg := &sarama.ConsumerMessage{Topic: dead.Topic, Value: value}
+	for i := range dead.Headers {
+		deadMsg.Headers = append(deadMsg.Headers, &dead.Headers[i])
+	}
+
+	replay := NewReplay(deadMsg, source.Topic)
+	assert.Equal(t, "depth-data", replay.Topic)
+	assert.Equal(t, "test-group", headerOf(replay, consts.DLQReplayGroupHeader))
+	assert.Empty(t, headerOf(replay, consts.DLQErrorHeader))
+
+	msg := &sarama.ConsumerMessage{Topic: replay.Topic, Offset: 42, Value: value}
+	for i := range replay.Headers {
+		msg.Headers = append(msg.Headers, &replay.Headers[i])
+	}
+
+	for _, tt := range []struct {
+		group string
+		calls int
+	}{
+		{group: "test-group", calls: 1},
+		// the other groups handled the message when it was first delivered
+		{group: "other-group", calls: 0},
+	} {
+		t.Run(tt.group, func(t *testing.T) {
+			mockHandler := &MockMessageHandler{}
+			mockHandler.On("HandleMessage", msg).Return(nil)
+			h := &consumerGroupHandler{handler: mockHandler, group: tt.group, retry: testRetry}
+
+			mockSession := &MockConsumerGroupSession{}
+			mockSession.On("MarkMessage", msg, "").Return()
+			mockSession.On("Commit").Return()
+
+			assert.NoError(t, h.ConsumeClaim(mockSession, claimOf(msg)))
+			mockHandler.AssertNumberOfCalls(t, "HandleMessage", tt.calls)
+			mockSession.AssertCalled(t, "MarkMessage", msg, "")
+		})
+	}
+}
+
+// failingDeferredHandler fails the messages with an odd offset and calls done of the others at once
+type failingDeferredHandler struct{}
+
+func (h *failingDeferredHandler) HandleMessage(msg *sarama.ConsumerMessage) error { return nil }
+
+func (h *failingDeferredHandler) HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error {
+	if msg.Offset%2 == 1 {
+		return Permanent(errors.New("bad payload"))
+	}
+	done(nil)
+	return nil
+}
+
+func TestConsumeClaimDeferredDeadLetters(t *testing.T) {
+	msgs := []*sarama.ConsumerMessage{
+		{Topic: "test-topic", Offset: 0},
+		{Topic: "test-topic", Offset: 1},
+		{Topic: "test-topic", Offset: 2},
+	}
+	dlq := &recordingProducer{}
+	h := &consumerGroupHandler{handler: &failingDeferredHandler{}, retry: testRetry, deadLetter: dlq}
+
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", mock.Anything, "").Return()
+	mockSession.On("Commit").Return()
+
+	assert.NoError(t, h.ConsumeClaim(mockSession, claimOf(msgs...)))
+	mockSession.AssertNumberOfCalls(t, "MarkMessage", 3)
+	assert.Len(t, dlq.msgs, 1)
+	assert.Equal(t, "1", headerOf(dlq.msgs[0], consts.DLQSourceOffsetHeader))
+}
+
+// rejectingDeferredHandler buffers every message and the flush rejects the rows of the odd offsets
+type rejectingDeferredHandler struct{}
+
+func (h *rejectingDeferredHandler) HandleMessage(msg *sarama.ConsumerMessage) error { return nil }
+
+func (h *rejectingDeferredHandler) HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error {
+	if msg.Offset%2 == 1 {
+		done(errors.New("value too long"))
+		return nil
+	}
+	done(nil)
+	return nil
+}
+
+func TestConsumeClaimDeferredWriteFailureDeadLetters(t *testing.T) {
+	msgs := []*sarama.ConsumerMessage{
+		{Topic: "test-topic", Offset: 0},
+		{Topic: "test-topic", Offset: 1},
+		{Topic: "test-topic", Offset: 2},
+	}
+	dlq := &recordingProducer{}
+	h := &consumerGroupHandler{handler: &rejectingDeferredHandler{}, retry: testRetry, deadLetter: dlq}
+
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", mock.Anything, "").Return()
+	mockSession.On("Commit").Return()
+
+	assert.NoError(t, h.ConsumeClaim(mockSession, claimOf(msgs...)))
+	mockSession.AssertNumberOfCalls(t, "MarkMessage", 3)
+	assert.Len(t, dlq.msgs, 1)
+	assert.Equal(t, "1", headerOf(dlq.msgs[0], consts.DLQSourceOffsetHeader))
+	assert.Equal(t, "value too long", headerOf(dlq.msgs[0], consts.DLQErrorHeader))
+	assert.Equal(t, ErrorTypePermanent, headerOf(dlq.msgs[0], consts.DLQErrorTypeHeader))
+}
+
+func TestConsumeClaimDeferredWriteFailureUnmarked(t *testing.T) {
+	msgs := []*sarama.ConsumerMessage{
+		{Topic: "test-topic", Offset: 0},
+		{Topic: "test-topic", Offset: 1},
+		{Topic: "test-topic", Offset: 2},
+	}
+	h := &consumerGroupHandler{handler: &rejectingDeferredHandler{}, retry: testRetry, deadLetter: &recordingProducer{err: errors.New("broker down")}}
+
+	mockSession := &MockConsumerGroupSession{}
+	mockSession.On("MarkMessage", mock.Anything, "").Return()
+	mockSession.On("Commit").Return()
+
+	// the rejected message is neither stored nor dead lettered, it and the later ones are redelivered
+	assert.Error(t, h.ConsumeClaim(mockSession, claimOf(msgs...)))
+	mockSession.AssertNumberOfCalls(t, "MarkMessage", 1)
+	mockSession.AssertCalled(t, "MarkMessage", msgs[0], "")
+}
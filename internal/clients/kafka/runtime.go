@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+)
+
+const (
+	defaultWorkers         = 1
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+var ErrShutdownTimeout = errors.New("consumers did not stop before the shutdown deadline")
+
+// Runtime runs the consumers of a process on one cancellable context and stops them together
+type Runtime struct {
+	consumers []*Consumer
+	cancel    context.CancelFunc
+}
+
+func NewRuntime(consumers ...*Consumer) *Runtime {
+	return &Runtime{
+		consumers: consumers,
+	}
+}
+
+// Start starts every consumer, they consume until ctx is done or Shutdown is called
+func (r *Runtime) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	var errs []error
+	for _, c := range r.consumers {
+		if err := c.Start(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.GroupID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown stops fetching and waits until every consumer drained its in-flight messages, committed their offsets
+// and left its group, it gives up once the timeout passes
+func (r *Runtime) Shutdown(timeout time.Duration) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	deadline := time.After(timeout)
+	for _, c := range r.consumers {
+		// consumers failing to start have nothing to drain
+		if c.Done() == nil {
+			continue
+		}
+		select {
+		case <-c.Done():
+		case <-deadline:
+			return ErrShutdownTimeout
+		}
+	}
+	return nil
+}
+
+// GroupWorkers returns the workers of the consumer group, the group setting overrides the default one
+func GroupWorkers(cfg config.Consumer, group string) int {
+	if workers, ok := cfg.GroupWorkers[group]; ok && workers > 0 {
+		return workers
+	}
+	if cfg.Workers > 0 {
+		return cfg.Workers
+	}
+	return defaultWorkers
+}
+
+// ShutdownTimeout returns the deadline of a consumer shutdown
+func ShutdownTimeout(cfg config.Consumer) time.Duration {
+	if cfg.ShutdownTimeout > 0 {
+		return time.Duration(cfg.ShutdownTimeout) * time.Second
+	}
+	return defaultShutdownTimeout
+}
+
+// DrainTimeout returns how long a released claim waits for its deferred messages
+func DrainTimeout(cfg config.Consumer) time.Duration {
+	if cfg.DrainTimeout > 0 {
+		return time.Duration(cfg.DrainTimeout) * time.Second
+	}
+	return defaultDrainTimeout
+}
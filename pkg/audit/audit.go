@@ -0,0 +1,160 @@
+// Package audit records who changed a configuration entity and how, the changes are written next to the change itself
+// so a rolled back change leaves no audit row.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+const SystemActor = "system"
+
+// ignored are the fields that change on every save and say nothing about the change
+var ignored = []string{"CreatedAt", "UpdatedAt", "DeletedAt"}
+
+// Actor is who made a change, the api keeps the caller of a request in its context
+type Actor struct {
+	ID     string // user or api key id
+	Name   string // username or api key name
+	Method string // token or api_key
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of the context, changes made outside a request are made by the system
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: SystemActor, Method: SystemActor}
+}
+
+// Change is the state of an entity before and after a mutation, Before is nil for a create and After for a delete
+type Change struct {
+	EntityType string
+	EntityID   string
+	Action     string
+	Before     any
+	After      any
+}
+
+// Record appends the change to the audit log through tx, updates that change nothing but timestamps are skipped
+func Record(ctx context.Context, tx *gorm.DB, change Change) error {
+	before, err := fields(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := fields(change.After)
+	if err != nil {
+		return err
+	}
+	diff := Diff(before, after)
+	if change.Action == entities.AuditUpdate && len(diff) == 0 {
+		return nil
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	actor := ActorFrom(ctx)
+	row := &entities.AuditLog{
+		ActorID:    actor.ID,
+		ActorName:  actor.Name,
+		AuthMethod: actor.Method,
+		EntityType: change.EntityType,
+		EntityID:   change.EntityID,
+		Action:     change.Action,
+		Diff:       string(diffJSON),
+	}
+	if row.Before, err = marshal(before); err != nil {
+		return err
+	}
+	if row.After, err = marshal(after); err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Create(row).Error
+}
+
+// Recorder records the changes made outside Postgres, e.g. a replayed dead letter, there is no transaction to write
+// them in so they are recorded once they are made
+type Recorder interface {
+	Record(ctx context.Context, change Change) error
+}
+
+type recorder struct {
+	db *gorm.DB
+}
+
+func NewRecorder(db *gorm.DB) Recorder {
+	return &recorder{
+		db: db,
+	}
+}
+
+func (r *recorder) Record(ctx context.Context, change Change) error {
+	return Record(ctx, r.db, change)
+}
+
+// FieldChange is the value of a field before and after a change
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff returns the fields whose value differs between the two states, a missing state has no fields
+func Diff(before, after map[string]any) map[string]FieldChange {
+	diff := map[string]FieldChange{}
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			diff[key] = FieldChange{Before: before[key], After: value}
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			diff[key] = FieldChange{Before: value}
+		}
+	}
+	return diff
+}
+
+// fields flattens the json of an entity into its top level fields without the timestamps
+func fields(v any) (map[string]any, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	maps.DeleteFunc(m, func(key string, _ any) bool {
+		return slices.Contains(ignored, key)
+	})
+	return m, nil
+}
+
+func marshal(m map[string]any) (*string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+// jsonArg matches a json column equal to the value
+type jsonArg string
+
+func (j jsonArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var got, want any
+	return json.Unmarshal([]byte(s), &got) == nil && json.Unmarshal([]byte(j), &want) == nil && assert.ObjectsAreEqual(want, got)
+}
+
+func TestActorFrom(t *testing.T) {
+	assert.Equal(t, Actor{Name: SystemActor, Method: SystemActor}, ActorFrom(context.Background()))
+
+	actor := Actor{ID: "user-1", Name: "alice", Method: "token"}
+	assert.Equal(t, actor, ActorFrom(WithActor(context.Background(), actor)))
+}
+
+func TestDiff(t *testing.T) {
+	before := map[string]any{"name": "Binance", "ws_url": "wss://old", "is_active": 1.0}
+	after := map[string]any{"name": "Binance", "ws_url": "wss://new", "rest_url": "https://api"}
+
+	assert.Equal(t, map[string]FieldChange{
+		"ws_url":    {Before: "wss://old", After: "wss://new"},
+		"rest_url":  {After: "https://api"},
+		"is_active": {Before: 1.0},
+	}, Diff(before, after))
+	assert.Empty(t, Diff(before, before))
+}
+
+func TestRecord(t *testing.T) {
+	ctx := WithActor(context.Background(), Actor{ID: "user-1", Name: "alice", Method: "token"})
+	id := uuid.New()
+	before := entities.Exchange{Base: entities.Base{ID: id}, Name: "Binance", WsUrl: "wss://old", IsActive: entities.ExchangeActive}
+	after := before
+	after.WsUrl = "wss://new"
+
+	t.Run("Update", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "audit_logs"`).
+			WithArgs(
+				sqlmock.AnyArg(), sqlmock.AnyArg(), "user-1", "alice", "token", entities.AuditExchange, id.String(), entities.AuditUpdate,
+				sqlmock.AnyArg(), sqlmock.AnyArg(),
+				jsonArg(`{"ws_url":{"before":"wss://old","after":"wss://new"}}`),
+			).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := Record(ctx, db, Change{EntityType: entities.AuditExchange, EntityID: id.String(), Action: entities.AuditUpdate, Before: before, After: after})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Delete keeps the last state", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "audit_logs"`).
+			WithArgs(
+				sqlmock.AnyArg(), sqlmock.AnyArg(), "user-1", "alice", "token", entities.AuditExchange, id.String(), entities.AuditDelete,
+				jsonArg(`{"id":"`+id.String()+`","name":"Binance","ws_url":"wss://old","rest_url":"","is_active":1}`),
+				nil,
+				sqlmock.AnyArg(),
+			).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := Record(ctx, db, Change{EntityType: entities.AuditExchange, EntityID: id.String(), Action: entities.AuditDelete, Before: &before})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unchanged update is skipped", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		err := Record(ctx, db, Change{EntityType: entities.AuditExchange, EntityID: id.String(), Action: entities.AuditUpdate, Before: before, After: before})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := WithActor(context.Background(), Actor{ID: "user-1", Name: "alice", Method: "token"})
+	db, mock := setupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "audit_logs"`).
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), "user-1", "alice", "token", entities.AuditDeadLetter, "depth-data-pg.dlq/0/3", entities.AuditReplay,
+			nil,
+			jsonArg(`{"source_topic":"depth-data-pg"}`),
+			jsonArg(`{"source_topic":{"before":null,"after":"depth-data-pg"}}`),
+		).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := NewRecorder(db).Record(ctx, Change{EntityType: entities.AuditDeadLetter, EntityID: "depth-data-pg.dlq/0/3", Action: entities.AuditReplay,
+		After: map[string]string{"source_topic": "depth-data-pg"}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+// Package audittest expects the audit rows a repository writes next to its change in sqlmock.
+package audittest
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+)
+
+// Expect expects the audit row of a change the system made, its before, after and diff match anything
+func Expect(mock sqlmock.Sqlmock, entityType, action string, entityID any) {
+	ExpectColumns(mock, entityType, action, entityID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg())
+}
+
+// ExpectColumns expects the audit row of a change the system made with the before, after and diff columns, a nil
+// column is NULL
+func ExpectColumns(mock sqlmock.Sqlmock, entityType, action string, entityID, before, after, diff any) {
+	mock.ExpectExec(`INSERT INTO "audit_logs"`).
+		WithArgs(
+			sqlmock.AnyArg(), // ID
+			sqlmock.AnyArg(), // CreatedAt
+			"",               // ActorID
+			audit.SystemActor,
+			audit.SystemActor,
+			entityType,
+			entityID,
+			action,
+			before,
+			after,
+			diff,
+		).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// DiffOf matches a diff that changed exactly the field
+type DiffOf string
+
+func (d DiffOf) Match(v driver.Value) bool {
+	var diff map[string]any
+	s, ok := v.(string)
+	if !ok || json.Unmarshal([]byte(s), &diff) != nil {
+		return false
+	}
+	_, changed := diff[string(d)]
+	return changed && len(diff) == 1
+}
@@ -0,0 +1,229 @@
+package candlestick
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/shopspring/decimal"
+)
+
+// bucketStart returns the open time of the bar containing openTime, bars are aligned to the epoch like the exchange aligns them
+func bucketStart(openTime, step int64) int64 {
+	return openTime - openTime%step
+}
+
+// merge folds the next candle of the bar into it, candles must be merged in open time order
+func merge(bar *entities.Candlestick, c entities.Candlestick) {
+	bar.High = decimal.Max(bar.High, c.High)
+	bar.Low = decimal.Min(bar.Low, c.Low)
+	bar.Close = c.Close
+	bar.Volume = bar.Volume.Add(c.Volume)
+	bar.QuoteVolume = bar.QuoteVolume.Add(c.QuoteVolume)
+	bar.NumberOfTrades += c.NumberOfTrades
+	bar.TakerBuyBaseVolume = bar.TakerBuyBaseVolume.Add(c.TakerBuyBaseVolume)
+	bar.TakerBuyQuoteVolume = bar.TakerBuyQuoteVolume.Add(c.TakerBuyQuoteVolume)
+}
+
+// open starts a bar of the interval from its first candle
+func open(c entities.Candlestick, interval string, step int64) entities.Candlestick {
+	start := bucketStart(c.OpenTime, step)
+	return entities.Candlestick{
+		Symbol:              c.Symbol,
+		ExchangeId:          c.ExchangeId,
+		Interval:            interval,
+		OpenTime:            start,
+		CloseTime:           start + step - 1,
+		Open:                c.Open,
+		High:                c.High,
+		Low:                 c.Low,
+		Close:               c.Close,
+		Volume:              c.Volume,
+		QuoteVolume:         c.QuoteVolume,
+		NumberOfTrades:      c.NumberOfTrades,
+		TakerBuyBaseVolume:  c.TakerBuyBaseVolume,
+		TakerBuyQuoteVolume: c.TakerBuyQuoteVolume,
+		Ignore:              c.Ignore,
+	}
+}
+
+// Rollup aggregates base interval candles into bars of the interval, bars missing any base candle are skipped
+func Rollup(candles []entities.Candlestick, interval string) ([]entities.Candlestick, error) {
+	step, base, err := rollupSteps(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]entities.Candlestick, len(candles))
+	copy(sorted, candles)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].OpenTime < sorted[j].OpenTime })
+
+	var (
+		bars  []entities.Candlestick
+		bar   entities.Candlestick
+		count int64
+	)
+	flush := func() {
+		if count == step/base {
+			bars = append(bars, bar)
+		}
+		count = 0
+	}
+
+	for i, c := range sorted {
+		if i > 0 && c.OpenTime == sorted[i-1].OpenTime {
+			continue
+		}
+		if count > 0 && bucketStart(c.OpenTime, step) != bar.OpenTime {
+			flush()
+		}
+		if count == 0 {
+			bar = open(c, interval, step)
+		} else {
+			merge(&bar, c)
+		}
+		count++
+	}
+	if count > 0 {
+		flush()
+	}
+
+	return bars, nil
+}
+
+func rollupSteps(interval string) (int64, int64, error) {
+	duration, err := IntervalDuration(interval)
+	if err != nil {
+		return 0, 0, err
+	}
+	baseDuration, _ := IntervalDuration(consts.BaseInterval)
+
+	step, base := duration.Milliseconds(), baseDuration.Milliseconds()
+	if step <= base || step%base != 0 {
+		return 0, 0, fmt.Errorf("interval %s can not be derived from %s", interval, consts.BaseInterval)
+	}
+	return step, base, nil
+}
+
+type pendingBar struct {
+	candle       entities.Candlestick
+	count        int64 // base klines folded in, the bar is complete at step/base
+	lastOpen     int64 // open time of the last folded kline, a repeated kline is not folded twice
+	firstTradeID int64
+	lastTradeID  int64
+	eventTime    int64
+}
+
+// Aggregator derives bars of higher intervals from the closed base interval klines of a stream
+type Aggregator struct {
+	mu        sync.Mutex
+	intervals []string
+	steps     map[string]int64
+	base      int64
+	pending   map[string]*pendingBar
+	emitted   map[string]int64 // open time of the last emitted bar
+}
+
+func NewAggregator(intervals []string) (*Aggregator, error) {
+	steps := make(map[string]int64, len(intervals))
+	var base int64
+	for _, interval := range intervals {
+		step, baseStep, err := rollupSteps(interval)
+		if err != nil {
+			return nil, err
+		}
+		steps[interval], base = step, baseStep
+	}
+
+	return &Aggregator{
+		intervals: intervals,
+		steps:     steps,
+		base:      base,
+		pending:   make(map[string]*pendingBar),
+		emitted:   make(map[string]int64),
+	}, nil
+}
+
+// Add folds a closed base interval kline into the pending bars and returns the bars it completes. Only bars built from
+// every base kline of their bucket are emitted, a bar missing one, like the first bar after a restart or a stream gap,
+// is dropped and left to the rollup of the stored klines.
+func (a *Aggregator) Add(payload dtos.CandlestickWs) []dtos.CandlestickWs {
+	if !payload.Kline.IsKlineClosed || payload.Kline.Interval != consts.BaseInterval {
+		return nil
+	}
+
+	var c entities.Candlestick
+	c.FromDtoWs(&payload)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var closed []dtos.CandlestickWs
+	for _, interval := range a.intervals {
+		step := a.steps[interval]
+		key := c.Symbol + ":" + interval
+		start := bucketStart(c.OpenTime, step)
+
+		if last, ok := a.emitted[key]; ok && start <= last {
+			// a late kline of a bar that was already emitted
+			continue
+		}
+
+		bar, ok := a.pending[key]
+		if ok && bar.candle.OpenTime < start {
+			a.drop(key, bar, step)
+			ok = false
+		}
+		if ok && c.OpenTime <= bar.lastOpen {
+			continue
+		}
+
+		if !ok {
+			bar = &pendingBar{
+				candle:       open(c, interval, step),
+				firstTradeID: payload.Kline.FirstTradeID,
+			}
+			a.pending[key] = bar
+		} else {
+			merge(&bar.candle, c)
+		}
+		bar.count++
+		bar.lastOpen = c.OpenTime
+		bar.lastTradeID = payload.Kline.LastTradeID
+		bar.eventTime = payload.EventTime
+
+		if c.CloseTime >= bar.candle.CloseTime {
+			if bar.count == step/a.base {
+				closed = append(closed, a.emit(key, bar))
+			} else {
+				a.drop(key, bar, step)
+			}
+		}
+	}
+	return closed
+}
+
+// drop forgets a bar missing base klines, emitting it would overwrite the stored bar with a wrong open and volume
+func (a *Aggregator) drop(key string, bar *pendingBar, step int64) {
+	log.Printf("[%s] %s bar at %d has %d of its %d %s klines, it is not emitted", bar.candle.Symbol, bar.candle.Interval, bar.candle.OpenTime, bar.count, step/a.base, consts.BaseInterval)
+	delete(a.pending, key)
+	a.emitted[key] = bar.candle.OpenTime
+}
+
+func (a *Aggregator) emit(key string, bar *pendingBar) dtos.CandlestickWs {
+	delete(a.pending, key)
+	a.emitted[key] = bar.candle.OpenTime
+	return bar.toWs()
+}
+
+func (b *pendingBar) toWs() dtos.CandlestickWs {
+	ws := b.candle.ToWs(true)
+	ws.EventTime = b.eventTime
+	ws.Kline.FirstTradeID = b.firstTradeID
+	ws.Kline.LastTradeID = b.lastTradeID
+	return ws
+}
@@ -0,0 +1,137 @@
+package candlestick
+
+import (
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const minute = int64(60_000)
+
+func minuteCandle(openTime int64, open, high, low, close string, trades int64) entities.Candlestick {
+	return entities.Candlestick{
+		Symbol:             "BTCUSDT",
+		Interval:           "1m",
+		OpenTime:           openTime,
+		CloseTime:          openTime + minute - 1,
+		Open:               decimal.RequireFromString(open),
+		High:               decimal.RequireFromString(high),
+		Low:                decimal.RequireFromString(low),
+		Close:              decimal.RequireFromString(close),
+		Volume:             decimal.NewFromInt(2),
+		QuoteVolume:        decimal.NewFromInt(20),
+		NumberOfTrades:     trades,
+		TakerBuyBaseVolume: decimal.NewFromInt(1),
+	}
+}
+
+func minuteKline(c entities.Candlestick, firstTrade, lastTrade int64) dtos.CandlestickWs {
+	ws := c.ToWs(true)
+	ws.EventTime = c.CloseTime + 1
+	ws.Kline.FirstTradeID = firstTrade
+	ws.Kline.LastTradeID = lastTrade
+	return ws
+}
+
+func TestRollup(t *testing.T) {
+	candles := []entities.Candlestick{
+		minuteCandle(2*minute, "12", "13", "11", "12.5", 4),
+		minuteCandle(0, "10", "11", "9", "10.5", 1),
+		minuteCandle(minute, "10.5", "14", "10", "12", 2),
+		// the second bar misses a minute and is skipped
+		minuteCandle(3*minute, "12.5", "13", "12", "12", 1),
+		minuteCandle(5*minute, "12", "13", "12", "12", 1),
+	}
+
+	bars, err := Rollup(candles, "3m")
+	assert.NoError(t, err)
+	assert.Len(t, bars, 1)
+
+	bar := bars[0]
+	assert.Equal(t, "3m", bar.Interval)
+	assert.Equal(t, int64(0), bar.OpenTime)
+	assert.Equal(t, 3*minute-1, bar.CloseTime)
+	assert.Equal(t, "10", bar.Open.String())
+	assert.Equal(t, "14", bar.High.String())
+	assert.Equal(t, "9", bar.Low.String())
+	assert.Equal(t, "12.5", bar.Close.String())
+	assert.Equal(t, "6", bar.Volume.String())
+	assert.Equal(t, "60", bar.QuoteVolume.String())
+	assert.Equal(t, "3", bar.TakerBuyBaseVolume.String())
+	assert.Equal(t, int64(7), bar.NumberOfTrades)
+
+	_, err = Rollup(candles, "1m")
+	assert.Error(t, err)
+}
+
+func TestAggregator(t *testing.T) {
+	aggregator, err := NewAggregator([]string{"3m", "5m"})
+	assert.NoError(t, err)
+
+	var closed []dtos.CandlestickWs
+	for i := int64(0); i < 6; i++ {
+		closed = append(closed, aggregator.Add(minuteKline(minuteCandle(i*minute, "10", "11", "9", "10", 1), i*10, i*10+9))...)
+	}
+
+	assert.Len(t, closed, 3)
+	assert.Equal(t, "3m", closed[0].Kline.Interval)
+	assert.Equal(t, int64(0), closed[0].Kline.StartTime)
+	assert.Equal(t, int64(0), closed[0].Kline.FirstTradeID)
+	assert.Equal(t, int64(29), closed[0].Kline.LastTradeID)
+	assert.Equal(t, "6", closed[0].Kline.BaseAssetVolume)
+	assert.True(t, closed[0].Kline.IsKlineClosed)
+
+	assert.Equal(t, "5m", closed[1].Kline.Interval)
+	assert.Equal(t, int64(5), closed[1].Kline.NumberOfTrades)
+	assert.Equal(t, 5*minute-1, closed[1].Kline.CloseTime)
+
+	assert.Equal(t, "3m", closed[2].Kline.Interval)
+	assert.Equal(t, 3*minute, closed[2].Kline.StartTime)
+
+	// open klines and other intervals are ignored
+	open := minuteKline(minuteCandle(6*minute, "10", "11", "9", "10", 1), 0, 0)
+	open.Kline.IsKlineClosed = false
+	assert.Empty(t, aggregator.Add(open))
+}
+
+func TestAggregatorMissingKline(t *testing.T) {
+	aggregator, err := NewAggregator([]string{"3m"})
+	assert.NoError(t, err)
+
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(0, "10", "11", "9", "10", 1), 0, 0)))
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(minute, "10", "12", "9", "11", 1), 0, 0)))
+
+	// the last minute of the first bar never arrived, the bar is dropped
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(3*minute, "11", "11", "10", "10", 1), 0, 0)))
+
+	// a late kline of the dropped bar is dropped too
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(2*minute, "10", "11", "9", "10", 1), 0, 0)))
+
+	// a repeated kline is folded once and the bar completes with its last one
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(4*minute, "10", "11", "9", "10", 1), 0, 0)))
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(4*minute, "10", "11", "9", "10", 1), 0, 0)))
+	closed := aggregator.Add(minuteKline(minuteCandle(5*minute, "10", "11", "9", "12", 1), 0, 0))
+	assert.Len(t, closed, 1)
+	assert.Equal(t, 3*minute, closed[0].Kline.StartTime)
+	assert.Equal(t, "12", closed[0].Kline.ClosePrice)
+	assert.Equal(t, int64(3), closed[0].Kline.NumberOfTrades)
+}
+
+func TestAggregatorStartsMidBucket(t *testing.T) {
+	aggregator, err := NewAggregator([]string{"3m"})
+	assert.NoError(t, err)
+
+	// started after the first minute of the bar, like after a restart, the partial bar is not emitted
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(minute, "10", "12", "9", "11", 1), 0, 0)))
+	assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(2*minute, "11", "11", "10", "10", 1), 0, 0)))
+
+	for i := int64(3); i < 5; i++ {
+		assert.Empty(t, aggregator.Add(minuteKline(minuteCandle(i*minute, "10", "11", "9", "10", 1), 0, 0)))
+	}
+	closed := aggregator.Add(minuteKline(minuteCandle(5*minute, "10", "11", "9", "10", 1), 0, 0))
+	assert.Len(t, closed, 1)
+	assert.Equal(t, 3*minute, closed[0].Kline.StartTime)
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
 	"github.com/SametAvcii/crypto-trade/pkg/config"
@@ -17,8 +21,118 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// MaxKlineLimit is the most klines the exchange returns for a single request
+const MaxKlineLimit = 1000
+
+const saveBatchSize = 500
+
+type RestFetcher struct{}
+
+func NewFetcher() *RestFetcher {
+	return &RestFetcher{}
+}
+
+// FetchKlines fetches up to limit klines of the symbol from the exchange REST api, start and end are open times in ms and ignored when zero
+func (f *RestFetcher) FetchKlines(ctx context.Context, exchange entities.Exchange, symbol, interval string, start, end int64, limit int) ([]dtos.CandlestickRest, error) {
+	api := utils.NewAPI(exchange.RestUrl)
+
+	var params url.Values
+	switch exchange.Name {
+	case consts.Binance:
+		params = url.Values{
+			"symbol":   {strings.ToUpper(symbol)},
+			"interval": {interval},
+			"limit":    {strconv.Itoa(limit)},
+		}
+		if start > 0 {
+			params.Set("startTime", strconv.FormatInt(start, 10))
+		}
+		if end > 0 {
+			params.Set("endTime", strconv.FormatInt(end, 10))
+		}
+	default:
+		ctlog.For("candlestick").WarnContext(ctx, "Exchange not supported", ctlog.Exchange(exchange.Name))
+		return nil, fmt.Errorf("exchange %s not supported", exchange.Name)
+	}
+
+	var klines [][]interface{}
+	if err := api.Get(ctx, "/klines", params, &klines); err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error getting candlestick data", ctlog.Exchange(exchange.Name), ctlog.Symbol(symbol), slog.String("interval", interval), ctlog.Data("/klines?"+params.Encode()), ctlog.Err(err))
+		return nil, err
+	}
+
+	return parseKlines(klines, exchange.ID.String(), symbol, interval), nil
+}
+
+func parseKlines(klines [][]interface{}, exchangeId, symbol, interval string) []dtos.CandlestickRest {
+	candles := make([]dtos.CandlestickRest, 0, len(klines))
+	for _, k := range klines {
+		if len(k) < 12 {
+			continue
+		}
+
+		candles = append(candles, dtos.CandlestickRest{
+			Symbol:              symbol,
+			Interval:            interval,
+			ExchangeId:          exchangeId,
+			OpenTime:            toInt64(k[0]),
+			Open:                toDecimal(k[1]),
+			High:                toDecimal(k[2]),
+			Low:                 toDecimal(k[3]),
+			Close:               toDecimal(k[4]),
+			Volume:              toDecimal(k[5]),
+			CloseTime:           toInt64(k[6]),
+			QuoteVolume:         toDecimal(k[7]),
+			NumberOfTrades:      toInt64(k[8]),
+			TakerBuyBaseVolume:  toDecimal(k[9]),
+			TakerBuyQuoteVolume: toDecimal(k[10]),
+			Ignore:              toDecimal(k[11]),
+		})
+	}
+	return candles
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+func toDecimal(v interface{}) decimal.Decimal {
+	switch s := v.(type) {
+	case string:
+		d, _ := decimal.NewFromString(s)
+		return d
+	case json.Number:
+		d, _ := decimal.NewFromString(s.String())
+		return d
+	default:
+		return decimal.Zero
+	}
+}
+
+// SaveCandles bulk inserts the candles skipping the ones already stored and returns the inserted count
+func SaveCandles(ctx context.Context, db *gorm.DB, candles []entities.Candlestick) (int64, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+
+	res := db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: entities.CandlestickKey, DoNothing: true}).
+		CreateInBatches(&candles, saveBatchSize)
+	return res.RowsAffected, res.Error
+}
+
 func GetCandleSticksAndUpdate(ctx context.Context, exchangeId, symbol string, interval string, limit int) ([]entities.Candlestick, error) {
 	var (
 		mongoClient = database.MongoClient()
@@ -29,152 +143,58 @@ func GetCandleSticksAndUpdate(ctx context.Context, exchangeId, symbol string, in
 
 	err := database.Where("id= ?", exchangeId).First(&exchange).Error
 	if err != nil {
-		log.Printf("Error fetching exchange from PostgreSQL: %v", err)
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching exchange from PostgreSQL",
-			Message: "Error fetching exchange from PostgreSQL: " + err.Error(),
-			Type:    "error",
-			Entity:  "exchange",
-			Data:    fmt.Sprintf("Exchange ID: %s, Error: %s", exchangeId, err.Error()),
-		})
+		ctlog.For("candlestick").ErrorContext(ctx, "Error fetching exchange from PostgreSQL", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), ctlog.Err(err))
 		return nil, err
 	}
 
-	api := utils.NewAPI(exchange.RestUrl)
-
-	var url string
-	switch exchange.Name {
-	case consts.Binance:
-		url = fmt.Sprintf("/klines?symbol=%s"+"&interval=%s&limit=%d", symbol, interval, limit)
-	default:
-		log.Printf("Exchange %s not supported", exchange.Name)
-		return nil, fmt.Errorf("exchange %s not supported", exchange.Name)
+	klines, err := NewFetcher().FetchKlines(ctx, exchange, symbol, interval, 0, 0, limit)
+	if err != nil {
+		return nil, err
 	}
-	var klines [][]interface{}
 
-	err = api.Get(url, nil, &klines)
-	if err != nil {
-		log.Printf("Error getting candlestick data: %v", err)
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error getting candlestick data",
-			Message: "Error getting candlestick data: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    url,
-		})
+	var lastCandlestick dtos.CandlestickRest
 
+	filter := bson.M{"symbol": symbol, "interval": interval}
+	opts := options.FindOne().SetSort(bson.D{{Key: "openTime", Value: -1}})
+	err = collection.FindOne(ctx, filter, opts).Decode(&lastCandlestick)
+	if err != nil && err != mongo.ErrNoDocuments {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error fetching last candlestick", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), slog.String("interval", interval), ctlog.Err(err))
 		return nil, err
 	}
 
-	for _, k := range klines {
-		openTime, _ := k[0].(json.Number).Int64()
-		open, _ := decimal.NewFromString(k[1].(string))
-		high, _ := decimal.NewFromString(k[2].(string))
-		low, _ := decimal.NewFromString(k[3].(string))
-		close, _ := decimal.NewFromString(k[4].(string))
-		volume, _ := decimal.NewFromString(k[5].(string))
-		quoteVolume, _ := decimal.NewFromString(k[7].(string))
-		takerBuyBaseVolume, _ := decimal.NewFromString(k[9].(string))
-		takerBuyQuoteVolume, _ := decimal.NewFromString(k[10].(string))
-		ignore, _ := decimal.NewFromString(k[11].(string))
-		closeTime, _ := k[6].(json.Number).Int64()
-		numberOfTrades, _ := k[8].(json.Number).Int64()
-
-		kline := dtos.CandlestickRest{
-			Symbol:              symbol,
-			Interval:            interval,
-			ExchangeId:          exchange.ID.String(),
-			OpenTime:            openTime,
-			Open:                open,
-			High:                high,
-			Low:                 low,
-			Close:               close,
-			Volume:              volume,
-			CloseTime:           closeTime,
-			QuoteVolume:         quoteVolume,
-			NumberOfTrades:      numberOfTrades,
-			TakerBuyBaseVolume:  takerBuyBaseVolume,
-			TakerBuyQuoteVolume: takerBuyQuoteVolume,
-			Ignore:              ignore,
+	var (
+		docs         []interface{}
+		candlesticks = make([]entities.Candlestick, 0, len(klines))
+	)
+	for i := range klines {
+		if lastCandlestick.OpenTime < klines[i].OpenTime {
+			docs = append(docs, klines[i])
 		}
 
-		var lastCandlestick dtos.CandlestickRest
-
-		filter := bson.M{"symbol": symbol, "interval": interval}
-		opts := options.FindOne().SetSort(bson.D{{Key: "openTime", Value: -1}})
-		err := collection.FindOne(ctx, filter, opts).Decode(&lastCandlestick)
-
-		if err != nil && err != mongo.ErrNoDocuments {
-			log.Printf("Error fetching last candlestick: %v", err)
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Error fetching last candlestick",
-				Message: "Error fetching last candlestick: " + err.Error(),
-				Type:    "error",
-				Entity:  "candlestick",
-				Data:    fmt.Sprintf("Symbol: %s, Error: %s", symbol, err.Error()),
-			})
+		var candlestick entities.Candlestick
+		candlestick.FromDto(&klines[i])
+		candlesticks = append(candlesticks, candlestick)
+	}
+
+	if len(docs) > 0 {
+		if _, err := collection.InsertMany(ctx, docs); err != nil {
+			ctlog.For("candlestick").ErrorContext(ctx, "Error inserting candlesticks into MongoDB", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), slog.String("interval", interval), ctlog.Err(err))
 			return nil, err
 		}
+	}
 
-		if lastCandlestick.OpenTime < kline.OpenTime {
-			_, err := collection.InsertOne(ctx, kline)
-			if err != nil {
-				log.Printf("Error inserting candlestick into MongoDB: %v", err)
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error inserting candlestick into MongoDB",
-					Message: "Error inserting candlestick into MongoDB: " + err.Error(),
-					Type:    "error",
-					Entity:  "candlestick",
-					Data:    fmt.Sprintf("Symbol: %s, Candlestick: %+v", symbol, kline),
-				})
-				return nil, err
-			}
-			var candlestick entities.Candlestick
-			candlestick.FromDto(&kline)
-			err = database.Create(&candlestick).Error
-			if err != nil {
-				log.Printf("Error inserting candlestick into PostgreSQL: %v", err)
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error inserting candlestick into PostgreSQL",
-					Message: "Error inserting candlestick into PostgreSQL: " + err.Error(),
-					Type:    "error",
-					Entity:  "candlestick",
-					Data:    fmt.Sprintf("Symbol: %s, Candlestick: %+v", symbol, kline),
-				})
-				return nil, err
-			}
-			log.Printf("Inserted candlestick into PostgreSQL: %+v", kline)
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Inserted candlestick into PostgreSQL",
-				Message: fmt.Sprintf("Inserted candlestick into PostgreSQL: %+v", kline),
-				Type:    "info",
-				Entity:  "candlestick",
-				Data:    fmt.Sprintf("Symbol: %s, Candlestick: %+v", symbol, kline),
-			})
-		} else {
-			log.Printf("Candlestick already exists in MongoDB: %+v", kline)
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Candlestick already exists in MongoDB",
-				Message: fmt.Sprintf("Candlestick already exists in MongoDB: %+v", kline),
-				Type:    "info",
-				Entity:  "candlestick",
-				Data:    fmt.Sprintf("Symbol: %s, Candlestick: %+v", symbol, kline),
-			})
-
-		}
+	inserted, err := SaveCandles(ctx, database, candlesticks)
+	if err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error inserting candlesticks into PostgreSQL", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), slog.String("interval", interval), ctlog.Err(err))
+		return nil, err
 	}
+	ctlog.For("candlestick").InfoContext(ctx, "Inserted candlesticks into PostgreSQL", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), slog.String("interval", interval), slog.Int64("inserted", inserted))
 
-	var candlesticks []entities.Candlestick
-	err = database.Where("symbol = ? AND interval = ?", symbol, interval).Limit(limit).Order("open_time desc").Find(&candlesticks).Error
+	end := time.Now().UnixMilli()
+	err = database.Where("symbol = ? AND interval = ? AND open_time BETWEEN ? AND ?", symbol, interval, WindowStart(interval, limit, end), end).
+		Limit(limit).Order("open_time desc").Find(&candlesticks).Error
 	if err != nil {
-		log.Printf("Error fetching candlesticks from PostgreSQL: %v", err)
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching candlesticks from PostgreSQL",
-			Message: "Error fetching candlesticks from PostgreSQL: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    fmt.Sprintf("Symbol: %s, Interval: %s", symbol, interval),
-		})
+		ctlog.For("candlestick").ErrorContext(ctx, "Error fetching candlesticks from PostgreSQL", ctlog.Exchange(exchangeId), ctlog.Symbol(symbol), slog.String("interval", interval), ctlog.Err(err))
 		return nil, err
 	}
 
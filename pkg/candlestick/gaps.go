@@ -0,0 +1,47 @@
+package candlestick
+
+import "github.com/SametAvcii/crypto-trade/pkg/dtos"
+
+// DetectGaps returns the ranges of open times missing between from and to, openTimes must be sorted ascending.
+// Open times are aligned to the step like the exchange aligns them to the epoch.
+func DetectGaps(openTimes []int64, from, to, step int64) []dtos.CandleGap {
+	var gaps []dtos.CandleGap
+	if step <= 0 || from > to {
+		return gaps
+	}
+
+	expected := alignUp(from, step)
+	last := to - to%step
+
+	i := 0
+	for expected <= last {
+		for i < len(openTimes) && openTimes[i] < expected {
+			i++
+		}
+
+		if i < len(openTimes) && openTimes[i] == expected {
+			expected += step
+			continue
+		}
+
+		end := last
+		if i < len(openTimes) && openTimes[i] <= last {
+			end = openTimes[i] - step
+		}
+		gaps = append(gaps, dtos.CandleGap{
+			From:  expected,
+			To:    end,
+			Count: (end-expected)/step + 1,
+		})
+		expected = end + step
+	}
+
+	return gaps
+}
+
+func alignUp(ts, step int64) int64 {
+	if rem := ts % step; rem != 0 {
+		return ts + step - rem
+	}
+	return ts
+}
@@ -0,0 +1,47 @@
+package candlestick
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectGaps(t *testing.T) {
+	const step = int64(60_000)
+
+	t.Run("NoCandles", func(t *testing.T) {
+		gaps := DetectGaps(nil, 0, 4*step, step)
+		assert.Equal(t, []dtos.CandleGap{{From: 0, To: 4 * step, Count: 5}}, gaps)
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		gaps := DetectGaps([]int64{0, step, 2 * step}, 0, 2*step, step)
+		assert.Empty(t, gaps)
+	})
+
+	t.Run("HolesAndEdges", func(t *testing.T) {
+		openTimes := []int64{step, 2 * step, 5 * step, 6 * step}
+		gaps := DetectGaps(openTimes, 0, 9*step, step)
+		assert.Equal(t, []dtos.CandleGap{
+			{From: 0, To: 0, Count: 1},
+			{From: 3 * step, To: 4 * step, Count: 2},
+			{From: 7 * step, To: 9 * step, Count: 3},
+		}, gaps)
+	})
+
+	t.Run("UnalignedRange", func(t *testing.T) {
+		gaps := DetectGaps([]int64{step}, 100, 2*step+100, step)
+		assert.Equal(t, []dtos.CandleGap{{From: 2 * step, To: 2 * step, Count: 1}}, gaps)
+	})
+}
+
+func TestIntervalDuration(t *testing.T) {
+	d, err := IntervalDuration("4h")
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Hour, d)
+
+	_, err = IntervalDuration("1M")
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package candlestick
+
+import (
+	"fmt"
+	"time"
+)
+
+var intervals = map[string]time.Duration{
+	"1s":  time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// IntervalDuration returns the length of a kline interval, month klines are not fixed length so they are not supported
+func IntervalDuration(interval string) (time.Duration, error) {
+	d, ok := intervals[interval]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval %s", interval)
+	}
+	return d, nil
+}
+
+// WindowStart returns the open time of the oldest of the last bars candles opened up to end, candle queries bound
+// open_time with it so only the partitions holding the window are scanned. Unsupported intervals return 0.
+func WindowStart(interval string, bars int, end int64) int64 {
+	d, err := IntervalDuration(interval)
+	if err != nil {
+		return 0
+	}
+	return end - int64(bars-1)*d.Milliseconds()
+}
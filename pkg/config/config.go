@@ -10,30 +10,168 @@ import (
 )
 
 type Config struct {
-	App      App      `yaml:"app"`
-	Redis    Redis    `yaml:"redis"`
-	Database Database `yaml:"database"`
-	Allows   Allows   `yaml:"allows"`
-	Kafka    Kafka    `yaml:"kafka"`
-	Mongo    Mongo    `yaml:"mongo"`
-	Consumer Consumer `yaml:"consumer"`
+	App        App        `yaml:"app"`
+	Redis      Redis      `yaml:"redis"`
+	Database   Database   `yaml:"database"`
+	Allows     Allows     `yaml:"allows"`
+	Kafka      Kafka      `yaml:"kafka"`
+	Mongo      Mongo      `yaml:"mongo"`
+	Consumer   Consumer   `yaml:"consumer"`
+	Jobs       Jobs       `yaml:"jobs"`
+	Retention  Retention  `yaml:"retention"`
+	Compaction Compaction `yaml:"compaction"`
+	Outbox     Outbox     `yaml:"outbox"`
+	Auth       Auth       `yaml:"auth"`
+	RateLimit  RateLimit  `yaml:"rate_limit"`
+	Secrets    Secrets    `yaml:"secrets"`
+	Log        Log        `yaml:"log"`
+	Tracing    Tracing    `yaml:"tracing"`
+	Health     Health     `yaml:"health"`
 }
 
 type App struct {
-	Name      string `yaml:"name"`
-	Port      string `yaml:"port"`
-	Host      string `yaml:"host"`
-	BaseUrl   string `yaml:"base_url"`
-	JwtIssuer string `yaml:"jwt_issuer"`
-	JwtSecret string `yaml:"jwt_secret"`
-	JwtExpire int    `yaml:"jwt_expire"`
-	ClientID  string `yaml:"client_id"`
+	Name             string `yaml:"name"`
+	Port             string `yaml:"port"`
+	Host             string `yaml:"host"`
+	BaseUrl          string `yaml:"base_url"`
+	JwtIssuer        string `yaml:"jwt_issuer"`
+	JwtSecret        string `yaml:"jwt_secret"`         // the JWT_SECRET env overrides it, keep it blank in committed configs
+	JwtExpire        int    `yaml:"jwt_expire"`         // hours an access token is valid
+	JwtRefreshExpire int    `yaml:"jwt_refresh_expire"` // hours a refresh token is valid
+	ClientID         string `yaml:"client_id"`
+	Development      bool   `yaml:"development"` // accepts the default jwt secret, never set it in production
 }
 
 type Consumer struct {
-	Name string `yaml:"name"`
-	Port string `yaml:"port"`
-	Host string `yaml:"host"`
+	Name          string `yaml:"name"`
+	Port          string `yaml:"port"`
+	Host          string `yaml:"host"`
+	BatchSize     int    `yaml:"batch_size"`     // rows per Postgres write
+	FlushInterval int    `yaml:"flush_interval"` // milliseconds a row waits before it is written
+	MaxPending    int    `yaml:"max_pending"`    // buffered rows per table before consuming blocks
+	// retries of a failing message before it goes to the dead letter topic
+	MaxAttempts     int `yaml:"max_attempts"`      // handler calls per message
+	RetryBackoff    int `yaml:"retry_backoff"`     // milliseconds before the first retry, doubled on every next one
+	MaxRetryBackoff int `yaml:"max_retry_backoff"` // milliseconds
+	// messages of a claim handled at once, messages sharing a key are never handled in parallel
+	Workers         int            `yaml:"workers"`
+	GroupWorkers    map[string]int `yaml:"group_workers"`    // workers per consumer group, overrides workers
+	DrainTimeout    int            `yaml:"drain_timeout"`    // seconds a released claim waits for buffered messages
+	ShutdownTimeout int            `yaml:"shutdown_timeout"` // seconds the consumers get to drain and commit on shutdown
+	LagInterval     int            `yaml:"lag_interval"`     // seconds between consumer lag collections
+}
+
+type Jobs struct {
+	SymbolSyncInterval        int `yaml:"symbol_sync_interval"`         // minutes
+	BackfillDays              int `yaml:"backfill_days"`                // days backfilled on startup, 0 disables
+	BackfillRequestsPerSecond int `yaml:"backfill_requests_per_second"` // exchange requests per second while backfilling
+}
+
+type Retention struct {
+	Interval         int             `yaml:"interval"` // minutes between partition maintenance runs, 0 disables
+	Premake          int             `yaml:"premake"`  // partitions created ahead of the current one
+	Candlesticks     RetentionPolicy `yaml:"candlesticks"`
+	OrderBookHistory RetentionPolicy `yaml:"order_book_history"`
+	Logs             RetentionPolicy `yaml:"logs"`
+}
+
+type RetentionPolicy struct {
+	Keep   int    `yaml:"keep"`   // past partitions kept besides the current one, 0 keeps everything
+	Action string `yaml:"action"` // drop or archive
+}
+
+type Compaction struct {
+	Interval    int                  `yaml:"interval"` // seconds between order book compaction runs, 0 disables
+	Depth       int                  `yaml:"depth"`    // levels per side a book request returns by default, snapshots keep the whole book
+	Resolutions []SnapshotResolution `yaml:"resolutions"`
+}
+
+type Outbox struct {
+	Interval  int `yaml:"interval"`   // milliseconds between outbox relay runs
+	BatchSize int `yaml:"batch_size"` // entries produced per batch
+}
+
+type Auth struct {
+	Bootstrap bool       `yaml:"bootstrap"` // create the accounts and the BOOTSTRAP_ADMIN_PASSWORD admin at startup
+	Users     []AuthUser `yaml:"users"`     // accounts created at startup when they do not exist
+}
+
+type AuthUser struct {
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password_hash"` // bcrypt hash of the password
+	Role         string `yaml:"role"`          // admin, trader, viewer
+}
+
+type RateLimit struct {
+	Enabled bool                     `yaml:"enabled"`
+	Default RateLimitRule            `yaml:"default"`
+	Groups  map[string]RateLimitRule `yaml:"groups"` // per route group, overrides default
+}
+
+type RateLimitRule struct {
+	Limit  int `yaml:"limit"`  // requests per window, 0 does not limit
+	Window int `yaml:"window"` // seconds
+}
+
+// Rule returns the limit of the route group, nothing is limited while rate limiting is disabled
+func (r RateLimit) Rule(group string) RateLimitRule {
+	if !r.Enabled {
+		return RateLimitRule{}
+	}
+	if rule, ok := r.Groups[group]; ok {
+		return rule
+	}
+	return r.Default
+}
+
+// Secrets are the master keys exchange credentials are sealed with, the MASTER_KEY env overrides them
+type Secrets struct {
+	ActiveKey   string      `yaml:"active_key"` // id of the key new credentials are sealed with
+	MasterKeys  []MasterKey `yaml:"master_keys"`
+	Development bool        `yaml:"development"` // lets a key of the config be the active one, never set it in production
+}
+
+type MasterKey struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"` // base64 of 32 random bytes, openssl rand -base64 32
+}
+
+// Log configures the structured logger, stdout always gets the entries, postgres and mongo when enabled
+type Log struct {
+	Level    string      `yaml:"level"` // debug, info, warn, error
+	Postgres LogSink     `yaml:"postgres"`
+	Mongo    LogSink     `yaml:"mongo"`
+	Sampling LogSampling `yaml:"sampling"`
+}
+
+type LogSink struct {
+	Enabled       bool   `yaml:"enabled"`
+	Level         string `yaml:"level"`          // entries below it are not sent to the sink
+	BatchSize     int    `yaml:"batch_size"`     // entries written at once
+	FlushInterval int    `yaml:"flush_interval"` // ms an entry waits at most
+	Buffer        int    `yaml:"buffer"`         // entries queued for the sink, new ones are dropped while it is full
+}
+
+// LogSampling keeps the first Initial entries with the same message and level every Tick, then every Thereafter-th,
+// warnings and errors are never sampled
+type LogSampling struct {
+	Tick       int `yaml:"tick"` // seconds, 0 disables sampling
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
+}
+
+// Tracing exports the spans of both services over OTLP/HTTP, the trace context is propagated through http and kafka
+// headers even when exporting is disabled
+type Tracing struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`     // host:port of the collector, OTEL_EXPORTER_OTLP_ENDPOINT overrides it
+	Insecure    bool    `yaml:"insecure"`     // plain http to the collector
+	SampleRatio float64 `yaml:"sample_ratio"` // share of the new traces recorded, 0 records none, traces started upstream follow their parent
+}
+
+type SnapshotResolution struct {
+	Resolution string `yaml:"resolution"` // 1s, 1m, 1h
+	Keep       int    `yaml:"keep"`       // hours the snapshots are kept, 0 keeps everything
 }
 
 type Kafka struct {
@@ -42,6 +180,18 @@ type Kafka struct {
 	MaxMessageSize int      `yaml:"max_message_size"`
 	ReturnErrors   bool     `yaml:"return_errors"`
 	ReturnSucces   bool     `yaml:"return_succes"`
+	Producer       Producer `yaml:"producer"`
+}
+
+// Producer configures the async producer of the exchange streams
+type Producer struct {
+	Compression    string `yaml:"compression"`     // zstd, lz4 or none
+	FlushFrequency int    `yaml:"flush_frequency"` // milliseconds a batch waits for more messages
+	FlushMessages  int    `yaml:"flush_messages"`  // messages that trigger a flush
+	FlushBytes     int    `yaml:"flush_bytes"`     // bytes that trigger a flush
+	BufferSize     int    `yaml:"buffer_size"`     // messages held in memory while the broker is slow
+	BufferPolicy   string `yaml:"buffer_policy"`   // drop or block once the buffer is full
+	BlockTimeout   int    `yaml:"block_timeout"`   // milliseconds a blocked send waits before dropping, 0 waits until there is room
 }
 
 type Redis struct {
@@ -50,6 +200,12 @@ type Redis struct {
 	Pass string `yaml:"pass"`
 }
 
+// Health tunes the readiness checks of both services
+type Health struct {
+	Timeout    int `yaml:"timeout"`      // milliseconds a dependency check may take before it fails
+	FeedMaxAge int `yaml:"feed_max_age"` // seconds a subscribed stream may go without a frame before it is stale
+}
+
 type Database struct {
 	Host    string `yaml:"host"`
 	Port    string `yaml:"port"`
@@ -57,6 +213,7 @@ type Database struct {
 	Pass    string `yaml:"pass"`
 	Name    string `yaml:"name"`
 	SslMode string `yaml:"sslmode"`
+	Seed    bool   `yaml:"seed"` // opt in to seed the default exchange and symbol on startup, app migrate seed does it once
 }
 
 type Mongo struct {
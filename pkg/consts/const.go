@@ -2,6 +2,28 @@ package consts
 
 const ( // User
 	UserName = "username"
+	// ClaimsKey is the gin context key of the caller of an authenticated request
+	ClaimsKey = "claims"
+)
+
+const ( // Auth
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	BearerScheme     = "Bearer"
+	// APIKeyHeader carries the api key of service to service calls instead of a bearer token
+	APIKeyHeader = "data-api-key"
+	APIKeyPrefix = "ct"
+)
+
+const ( // Roles, every role can do what the roles before it can
+	RoleViewer = "viewer"
+	RoleTrader = "trader"
+	RoleAdmin  = "admin"
+)
+
+const ( // How a request was authenticated
+	AuthMethodToken  = "token"
+	AuthMethodAPIKey = "api_key"
 )
 
 const ( //Error
@@ -27,3 +49,21 @@ const (
 	MaxRetries = 5
 	RetryDelay = 5
 )
+
+const ( // Health of a service or of one of its dependencies
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+const ( // Symbol status reported by the exchange
+	SymbolStatusTrading = "TRADING"
+	SymbolStatusUnknown = "UNKNOWN"
+)
+
+const ( // Candle backfill job status
+	BackfillPending = "pending"
+	BackfillRunning = "running"
+	BackfillDone    = "done"
+	BackfillFailed  = "failed"
+)
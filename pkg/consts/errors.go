@@ -5,3 +5,55 @@ const (
 	AlreadyInSell = "Already in sell signal"
 	AlreadyInHold = "Already in hold signal"
 )
+
+const ( // Symbol
+	UnknownSymbol    = "symbol is not listed on the exchange"
+	SymbolNotTrading = "symbol is not trading on the exchange"
+)
+
+const ( // Candle backfill
+	BackfillJobNotFound  = "backfill job not found"
+	BackfillInvalidRange = "backfill range is invalid"
+)
+
+const ( // Order book
+	OrderBookNotFound   = "no order book history at the requested time"
+	OrderBookInvalidReq = "exchange_id and symbol are required"
+)
+
+const ( // Dead letters
+	DeadLetterNotFound      = "dead letter message not found"
+	DeadLetterUnknownTopic  = "topic has no dead letter topic"
+	DeadLetterInvalidReplay = "messages to replay are required"
+)
+
+const ( // Auth
+	AuthMissingToken       = "bearer token is required"
+	AuthInvalidToken       = "token is invalid"
+	AuthExpiredToken       = "token is expired"
+	AuthInvalidCredentials = "username or password is invalid"
+	AuthInvalidAPIKey      = "api key is invalid"
+	AuthForbidden          = "role is not allowed to access this resource"
+)
+
+const ( // Users
+	UserNotFound     = "user not found"
+	UserInvalidReq   = "username, password and a valid role are required"
+	UserExists       = "username is already taken"
+	APIKeyNotFound   = "api key not found"
+	APIKeyInvalidReq = "name and a valid role are required"
+)
+
+const ( // Rate limit
+	RateLimitExceeded = "rate limit exceeded, retry later"
+)
+
+const ( // Exchange credentials
+	CredentialNotFound    = "exchange credential not found"
+	CredentialInvalidReq  = "exchange_id, label, api_key and api_secret are required"
+	CredentialNoMasterKey = "no master key is configured to seal credentials"
+)
+
+const ( // Logs
+	LogInvalidRange = "log range is invalid, to must be after from and at most 7 days later"
+)
@@ -26,3 +26,27 @@ const ( // DB Group
 	PgOrderBookGroup   = "pg-order-book-group"
 	PgCandleStickGroup = "pg-candlestick-group"
 )
+
+// DLQSuffix names the dead letter topic of a source topic
+const DLQSuffix = ".dlq"
+
+const ( // Dead letter headers
+	DLQErrorHeader           = "dlq-error"
+	DLQErrorTypeHeader       = "dlq-error-type"
+	DLQAttemptsHeader        = "dlq-attempts"
+	DLQGroupHeader           = "dlq-group"
+	DLQSourceTopicHeader     = "dlq-source-topic"
+	DLQSourcePartitionHeader = "dlq-source-partition"
+	DLQSourceOffsetHeader    = "dlq-source-offset"
+	DLQFailedAtHeader        = "dlq-failed-at"
+	// a replayed message is meant for the group it failed in, the other groups consuming its topic skip it
+	DLQReplayGroupHeader = "dlq-replay-group"
+)
+
+const ( // Envelope headers
+	SchemaSubjectHeader = "schema-subject"
+	SchemaVersionHeader = "schema-version"
+)
+
+// DLQSourceTopics are the consumed topics having a dead letter topic
+var DLQSourceTopics = []string{OrderBookTopic, PgOrderBookTopic, CandleStickTopic, PgCandleStickTopic}
@@ -6,4 +6,5 @@ const (
 	CollectionNameUpdatedOrder = "updated-order-book-data"
 	CollectionNameCandleStick  = "candle-stick-data"
 	CollectionNameSignal       = "signal-data"
+	CollectionNameLog          = "logs"
 )
@@ -8,3 +8,10 @@ const (
 	MaxLifetime = 1 * time.Hour
 	MaxIdleTime = 2 * time.Second
 )
+
+// retention actions applied to expired partitions
+const (
+	RetentionDrop    = "drop"
+	RetentionArchive = "archive"
+	ArchiveSchema    = "archive"
+)
@@ -3,9 +3,15 @@ package consts
 const (
 	StreamAggTrade  = "aggTrade"
 	StreamOrderBook = "depth"
+	StreamKline     = "kline"
 )
 
 // can define candlestick with fmt.Sprintf("%s@kline_%s", symbol, interval)
 const (
 	StreamCandleStick = "kline_%s"
 )
+
+// candlesticks are streamed once at BaseInterval and the derived intervals are aggregated locally
+const BaseInterval = "1m"
+
+var DerivedIntervals = []string{"3m", "5m", "15m", "1h", "4h", "1d"}
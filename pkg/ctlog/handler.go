@@ -0,0 +1,177 @@
+package ctlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fanout hands every record to each handler that is enabled for its level
+type Fanout struct {
+	handlers []slog.Handler
+}
+
+func NewFanout(handlers ...slog.Handler) *Fanout {
+	return &Fanout{handlers: handlers}
+}
+
+func (f *Fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f.handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f.handlers {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *Fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(f.handlers))
+	for i, h := range f.handlers {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return &Fanout{handlers: handlers}
+}
+
+func (f *Fanout) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(f.handlers))
+	for i, h := range f.handlers {
+		handlers[i] = h.WithGroup(name)
+	}
+	return &Fanout{handlers: handlers}
+}
+
+// ContextHandler adds the fields of the context and the trace id of its span to the record, at the top level even
+// when the logger has open groups
+type ContextHandler struct {
+	root    slog.Handler
+	derived slog.Handler                      // root with the attrs and groups of the logger
+	ops     []func(slog.Handler) slog.Handler // WithAttrs and WithGroup calls, replayed over the context fields
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{root: next, derived: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.derived.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	var attrs []slog.Attr
+	if ctx != nil {
+		attrs = fieldsFrom(ctx)
+		if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+			attrs = append(attrs[:len(attrs):len(attrs)], slog.String(KeyTraceID, span.TraceID().String()))
+		}
+	}
+	if len(attrs) == 0 {
+		return h.derived.Handle(ctx, r)
+	}
+	if len(h.ops) == 0 {
+		r.AddAttrs(attrs...)
+		return h.derived.Handle(ctx, r)
+	}
+
+	next := h.root.WithAttrs(attrs)
+	for _, op := range h.ops {
+		next = op(next)
+	}
+	return next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *ContextHandler) with(op func(slog.Handler) slog.Handler) *ContextHandler {
+	return &ContextHandler{
+		root:    h.root,
+		derived: op(h.derived),
+		ops:     append(h.ops[:len(h.ops):len(h.ops)], op),
+	}
+}
+
+// Sampler lets through the first initial records with the same level and message every tick and then every
+// thereafter-th one, so a message logged for each frame can not flood the sinks. Warnings and errors always pass.
+type Sampler struct {
+	next       slog.Handler
+	tick       time.Duration
+	initial    uint64
+	thereafter uint64
+	counts     *sync.Map // level and message to *sampleCount, shared by the derived handlers
+	now        func() time.Time
+}
+
+type sampleCount struct {
+	window atomic.Int64 // tick the count belongs to
+	n      atomic.Uint64
+}
+
+func NewSampler(next slog.Handler, tick time.Duration, initial, thereafter int) *Sampler {
+	return &Sampler{
+		next:       next,
+		tick:       tick,
+		initial:    uint64(max(initial, 0)),
+		thereafter: uint64(max(thereafter, 0)),
+		counts:     &sync.Map{},
+		now:        time.Now,
+	}
+}
+
+func (s *Sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *Sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn || s.keep(r) {
+		return s.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (s *Sampler) keep(r slog.Record) bool {
+	value, _ := s.counts.LoadOrStore(r.Level.String()+"|"+r.Message, &sampleCount{})
+	count := value.(*sampleCount)
+
+	window := s.now().UnixNano() / int64(s.tick)
+	if old := count.window.Load(); old != window && count.window.CompareAndSwap(old, window) {
+		count.n.Store(0)
+	}
+
+	n := count.n.Add(1)
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+func (s *Sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *s
+	derived.next = s.next.WithAttrs(attrs)
+	return &derived
+}
+
+func (s *Sampler) WithGroup(name string) slog.Handler {
+	derived := *s
+	derived.next = s.next.WithGroup(name)
+	return &derived
+}
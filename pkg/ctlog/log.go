@@ -1,14 +1,123 @@
+// Package ctlog is the structured logger of the services, entries go through log/slog to stdout as json and to the
+// postgres and mongo sinks in batches off the hot path.
 package ctlog
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+)
+
+// keys of the fields shared by the entries of every package
+const (
+	KeyEntity   = "entity"
+	KeySymbol   = "symbol"
+	KeyTopic    = "topic"
+	KeyExchange = "exchange"
+	KeyTraceID  = "trace_id"
+	KeyError    = "error"
+	KeyData     = "data"
 )
 
-func CreateLog(payload *entities.Log) {
-	db := database.PgClient()
-	if db == nil {
-		return
+// Init makes the configured logger the slog default, log.Printf lines go through it too, the sinks flush what they
+// hold when ctx is done and wait returns once they did. Call it once postgres and mongo are initialized.
+func Init(ctx context.Context, cfg config.Log, mongo config.Mongo) (wait func()) {
+	var sinks []*AsyncSink
+	handlers := []slog.Handler{
+		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: ParseLevel(cfg.Level)}),
+	}
+	if cfg.Postgres.Enabled {
+		sink := NewAsyncSink("postgres", cfg.Postgres, writePostgres)
+		sinks = append(sinks, sink)
+		handlers = append(handlers, NewSinkHandler(sink, ParseLevel(cfg.Postgres.Level)))
+	}
+	if cfg.Mongo.Enabled {
+		if client := database.MongoClient(); client != nil {
+			sink := NewAsyncSink("mongo", cfg.Mongo, writeMongo(client.Database(mongo.Database).Collection(consts.CollectionNameLog)))
+			sinks = append(sinks, sink)
+			handlers = append(handlers, NewSinkHandler(sink, ParseLevel(cfg.Mongo.Level)))
+		}
+	}
+
+	var handler slog.Handler = NewContextHandler(NewFanout(handlers...))
+	if cfg.Sampling.Tick > 0 {
+		handler = NewSampler(handler, time.Duration(cfg.Sampling.Tick)*time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+	slog.SetDefault(slog.New(handler))
+
+	for _, sink := range sinks {
+		go sink.Run(ctx)
+	}
+	return func() {
+		for _, sink := range sinks {
+			sink.Wait()
+		}
+	}
+}
+
+// For returns the logger of an entity, stream, candlestick, signal, etc
+func For(entity string) *slog.Logger {
+	return slog.Default().With(KeyEntity, entity)
+}
+
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
 	}
-	db.Model(&entities.Log{}).Create(payload)
 }
+
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String(KeyError, err.Error())
+}
+
+func Symbol(symbol string) slog.Attr {
+	return slog.String(KeySymbol, symbol)
+}
+
+func Topic(topic string) slog.Attr {
+	return slog.String(KeyTopic, topic)
+}
+
+func Exchange(exchange string) slog.Attr {
+	return slog.String(KeyExchange, exchange)
+}
+
+// Data is free form detail of the entry, the url of a failed request, the payload of a bad message, etc
+func Data(data string) slog.Attr {
+	return slog.String(KeyData, data)
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a context whose entries carry the attrs, a consumer adds the topic of the message it handles
+func WithFields(ctx context.Context, attrs ...slog.Attr) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, fieldsKey{}, append(fields[:len(fields):len(fields)], attrs...))
+}
+
+func fieldsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+// pgClient is swapped in tests, every flush would ping the database through database.PgClient
+var pgClient = database.PgPool
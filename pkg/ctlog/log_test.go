@@ -0,0 +1,158 @@
+package ctlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recorder is a sink writer keeping the batches it was given
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]Entry
+}
+
+func (r *recorder) write(_ context.Context, entries []Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]Entry(nil), entries...))
+	return nil
+}
+
+func (r *recorder) entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []Entry
+	for _, b := range r.batches {
+		all = append(all, b...)
+	}
+	return all
+}
+
+func TestSinkHandlerEntry(t *testing.T) {
+	sink := NewAsyncSink("test", config.LogSink{Buffer: 10}, nil)
+	logger := slog.New(NewContextHandler(NewSinkHandler(sink, slog.LevelInfo)))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = WithFields(ctx, Topic("candlestick"))
+
+	logger.With(KeyEntity, "stream").WithGroup("kline").ErrorContext(ctx, "Error reading frame",
+		Symbol("BTCUSDT"), Err(errors.New("eof")), Err(nil), slog.Int("interval", 60))
+	logger.Debug("below the level")
+
+	require.Len(t, sink.entries, 1)
+	e := <-sink.entries
+	assert.Equal(t, "error", e.Level)
+	assert.Equal(t, "Error reading frame", e.Message)
+	assert.Equal(t, "stream", e.Entity)
+	assert.Equal(t, traceID.String(), e.TraceID)
+	assert.Equal(t, map[string]any{
+		"kline.symbol":   "BTCUSDT",
+		"kline.error":    "eof",
+		"kline.interval": int64(60),
+		KeyTopic:         "candlestick",
+	}, e.Fields, "attrs logged inside a group are keyed by it, the context fields stay on top")
+}
+
+func TestEntryToLog(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := Entry{
+		Time:    at,
+		Level:   "error",
+		Message: "Error inserting signal",
+		Entity:  "signal",
+		TraceID: "abc",
+		Error:   "duplicate key",
+		Data:    "BTCUSDT",
+		Fields:  map[string]any{KeySymbol: "BTCUSDT"},
+	}.ToLog()
+
+	assert.Equal(t, "Error inserting signal", l.Title)
+	assert.Equal(t, "Error inserting signal: duplicate key", l.Message)
+	assert.Equal(t, "error", l.Type)
+	assert.Equal(t, "signal", l.Entity)
+	assert.Equal(t, "abc", l.TraceID)
+	assert.Equal(t, at, l.CreatedAt)
+	require.NotNil(t, l.Fields)
+	assert.JSONEq(t, `{"symbol":"BTCUSDT"}`, *l.Fields)
+
+	assert.Nil(t, Entry{Message: "no fields"}.ToLog().Fields)
+}
+
+func TestSampler(t *testing.T) {
+	sink := NewAsyncSink("test", config.LogSink{Buffer: 100}, nil)
+	sampler := NewSampler(NewSinkHandler(sink, slog.LevelDebug), time.Second, 2, 3)
+	now := time.Unix(100, 0)
+	sampler.now = func() time.Time { return now }
+	logger := slog.New(sampler).With(KeyEntity, "stream")
+
+	for range 10 {
+		logger.Debug("Frame received")
+	}
+	assert.Len(t, sink.entries, 4, "the first 2 and then every 3rd of the tick")
+
+	logger.Debug("Other message")
+	for range 3 {
+		logger.Warn("Frame received")
+	}
+	assert.Len(t, sink.entries, 8, "messages are counted apart and warnings always pass")
+
+	now = now.Add(time.Second)
+	logger.Debug("Frame received")
+	assert.Len(t, sink.entries, 9, "counts start over on the next tick")
+}
+
+func TestFanout(t *testing.T) {
+	info := NewAsyncSink("info", config.LogSink{Buffer: 10}, nil)
+	warn := NewAsyncSink("warn", config.LogSink{Buffer: 10}, nil)
+	fanout := NewFanout(NewSinkHandler(info, slog.LevelInfo), NewSinkHandler(warn, slog.LevelWarn))
+	logger := slog.New(fanout)
+
+	assert.False(t, fanout.Enabled(context.Background(), slog.LevelDebug))
+	logger.Debug("dropped")
+	logger.Info("info")
+	logger.Warn("warn")
+
+	assert.Len(t, info.entries, 2)
+	assert.Len(t, warn.entries, 1)
+}
+
+func TestAsyncSinkFlush(t *testing.T) {
+	var rec recorder
+	sink := NewAsyncSink("test", config.LogSink{BatchSize: 2, FlushInterval: 60000, Buffer: 10}, rec.write)
+	ctx, cancel := context.WithCancel(context.Background())
+	go sink.Run(ctx)
+
+	sink.Add(Entry{Message: "1"})
+	sink.Add(Entry{Message: "2"})
+	assert.Eventually(t, func() bool { return len(rec.entries()) == 2 }, time.Second, 5*time.Millisecond, "a full batch is written")
+
+	sink.Add(Entry{Message: "3"})
+	cancel()
+	sink.Wait()
+	assert.Len(t, rec.entries(), 3, "the rest is written on shutdown")
+}
+
+func TestAsyncSinkDropsWhenFull(t *testing.T) {
+	sink := NewAsyncSink("test", config.LogSink{Buffer: 1}, nil)
+
+	assert.True(t, sink.Add(Entry{Message: "kept"}))
+	assert.False(t, sink.Add(Entry{Message: "dropped"}), "logging never waits for the sink")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("DEBUG"))
+	assert.Equal(t, slog.LevelWarn, ParseLevel("warning"))
+	assert.Equal(t, slog.LevelError, ParseLevel("error"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel(""))
+}
@@ -0,0 +1,275 @@
+package ctlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultSinkBatch    = 200
+	defaultSinkInterval = time.Second
+	defaultSinkBuffer   = 10000
+	finalFlushTimeout   = 5 * time.Second
+)
+
+// Entry is a record as the sinks store it, attrs of groups are keyed group.key
+type Entry struct {
+	Time    time.Time      `bson:"created_at"`
+	Level   string         `bson:"level"`
+	Message string         `bson:"message"`
+	Entity  string         `bson:"entity,omitempty"`
+	TraceID string         `bson:"trace_id,omitempty"`
+	Error   string         `bson:"error,omitempty"`
+	Data    string         `bson:"data,omitempty"`
+	Fields  map[string]any `bson:"fields,omitempty"`
+}
+
+// ToLog maps the entry on the logs table, the title is the message and the message carries the error
+func (e Entry) ToLog() entities.Log {
+	l := entities.Log{
+		Title:   e.Message,
+		Message: e.Message,
+		Entity:  e.Entity,
+		Type:    e.Level,
+		Data:    e.Data,
+		TraceID: e.TraceID,
+	}
+	l.CreatedAt = e.Time
+	if e.Error != "" {
+		l.Message = e.Message + ": " + e.Error
+	}
+	if len(e.Fields) > 0 {
+		if b, err := json.Marshal(e.Fields); err == nil {
+			fields := string(b)
+			l.Fields = &fields
+		}
+	}
+	return l
+}
+
+// SinkHandler turns the records into entries for an async sink
+type SinkHandler struct {
+	sink   *AsyncSink
+	level  slog.Level
+	attrs  []slog.Attr
+	prefix string // of the open groups, ends with a dot
+}
+
+func NewSinkHandler(sink *AsyncSink, level slog.Level) *SinkHandler {
+	return &SinkHandler{sink: sink, level: level}
+}
+
+func (h *SinkHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *SinkHandler) Handle(_ context.Context, r slog.Record) error {
+	e := Entry{
+		Time:    r.Time,
+		Level:   strings.ToLower(r.Level.String()),
+		Message: r.Message,
+		Fields:  map[string]any{},
+	}
+	for _, a := range h.attrs {
+		e.add("", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		e.add(h.prefix, a)
+		return true
+	})
+	if len(e.Fields) == 0 {
+		e.Fields = nil
+	}
+	h.sink.Add(e)
+	return nil
+}
+
+func (h *SinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], prefixed(h.prefix, attrs)...)
+	return &derived
+}
+
+func (h *SinkHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	derived := *h
+	derived.prefix = h.prefix + name + "."
+	return &derived
+}
+
+func prefixed(prefix string, attrs []slog.Attr) []slog.Attr {
+	if prefix == "" {
+		return attrs
+	}
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = slog.Attr{Key: prefix + a.Key, Value: a.Value}
+	}
+	return out
+}
+
+func (e *Entry) add(prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			e.add(p, ga)
+		}
+		return
+	}
+
+	key := prefix + a.Key
+	switch key {
+	case KeyEntity:
+		e.Entity = a.Value.String()
+	case KeyTraceID:
+		e.TraceID = a.Value.String()
+	case KeyError:
+		e.Error = a.Value.String()
+	case KeyData:
+		e.Data = a.Value.String()
+	default:
+		value := a.Value.Any()
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		e.Fields[key] = value
+	}
+}
+
+// AsyncSink queues entries and writes them in batches on its own goroutine, logging never waits for a sink, entries
+// are dropped while its queue is full
+type AsyncSink struct {
+	name     string
+	entries  chan Entry
+	size     int
+	interval time.Duration
+	write    func(ctx context.Context, entries []Entry) error
+	done     chan struct{}
+}
+
+func NewAsyncSink(name string, cfg config.LogSink, write func(ctx context.Context, entries []Entry) error) *AsyncSink {
+	s := &AsyncSink{
+		name:     name,
+		size:     cfg.BatchSize,
+		interval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		write:    write,
+		done:     make(chan struct{}),
+	}
+	if s.size <= 0 {
+		s.size = defaultSinkBatch
+	}
+	if s.interval <= 0 {
+		s.interval = defaultSinkInterval
+	}
+	buffer := cfg.Buffer
+	if buffer <= 0 {
+		buffer = defaultSinkBuffer
+	}
+	s.entries = make(chan Entry, buffer)
+	return s
+}
+
+// Add queues the entry, false when the queue is full and the entry is dropped
+func (s *AsyncSink) Add(e Entry) bool {
+	select {
+	case s.entries <- e:
+		return true
+	default:
+		metrics.LogEntriesDropped.WithLabelValues(s.name, "full").Inc()
+		return false
+	}
+}
+
+// Run writes the queued entries on size or time until ctx is done, then writes what is left
+func (s *AsyncSink) Run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, s.size)
+	for {
+		select {
+		case e := <-s.entries:
+			if batch = append(batch, e); len(batch) >= s.size {
+				batch = s.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(ctx, batch)
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			defer cancel()
+			for {
+				select {
+				case e := <-s.entries:
+					if batch = append(batch, e); len(batch) >= s.size {
+						batch = s.flush(final, batch)
+					}
+				default:
+					s.flush(final, batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Wait blocks until Run has written the entries left at shutdown
+func (s *AsyncSink) Wait() {
+	<-s.done
+}
+
+// flush writes the batch and returns it emptied, a failed batch is dropped, logs are not worth holding the queue
+func (s *AsyncSink) flush(ctx context.Context, batch []Entry) []Entry {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := s.write(ctx, batch); err != nil {
+		// not through slog, the entry would come back to this sink
+		fmt.Fprintf(os.Stderr, "[log %s] dropping %d entries: %v\n", s.name, len(batch), err)
+		metrics.LogEntriesDropped.WithLabelValues(s.name, "write").Add(float64(len(batch)))
+	}
+	return batch[:0]
+}
+
+func writePostgres(ctx context.Context, entries []Entry) error {
+	db := pgClient()
+	if db == nil {
+		return errors.New("postgres is not initialized")
+	}
+	logs := make([]entities.Log, len(entries))
+	for i, e := range entries {
+		logs[i] = e.ToLog()
+	}
+	return db.WithContext(ctx).Create(&logs).Error
+}
+
+func writeMongo(collection *mongo.Collection) func(ctx context.Context, entries []Entry) error {
+	return func(ctx context.Context, entries []Entry) error {
+		docs := make([]any, len(entries))
+		for i, e := range entries {
+			docs[i] = e
+		}
+		_, err := collection.InsertMany(ctx, docs)
+		return err
+	}
+}
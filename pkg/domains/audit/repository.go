@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+// Repository only reads the audit log, rows are written by the repositories of the audited entities
+type Repository interface {
+	GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) ([]entities.AuditLog, int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetAuditLogs returns a page of the matching rows, newest first, and the count of all matching rows
+func (r *repository) GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) ([]entities.AuditLog, int64, error) {
+	query := r.db.WithContext(ctx).Model(&entities.AuditLog{})
+	if req.EntityType != "" {
+		query = query.Where("entity_type = ?", req.EntityType)
+	}
+	if req.EntityID != "" {
+		query = query.Where("entity_id = ?", req.EntityID)
+	}
+	if req.ActorID != "" {
+		query = query.Where("actor_id = ?", req.ActorID)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if !req.From.IsZero() {
+		query = query.Where("created_at >= ?", req.From)
+	}
+	if !req.To.IsZero() {
+		query = query.Where("created_at < ?", req.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []entities.AuditLog
+	err := query.Order("created_at DESC").Offset(int((req.Page - 1) * req.PerPage)).Limit(int(req.PerPage)).Find(&logs).Error
+	return logs, total, err
+}
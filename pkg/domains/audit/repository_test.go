@@ -0,0 +1,64 @@
+package audit_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+func TestGetAuditLogsRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := audit.NewRepo(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "audit_logs" WHERE entity_type = $1 AND action = $2 AND created_at >= $3`)).
+		WithArgs(entities.AuditSymbol, entities.AuditDelete, from).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_logs" WHERE entity_type = $1 AND action = $2 AND created_at >= $3 ORDER BY created_at DESC LIMIT $4 OFFSET $5`)).
+		WithArgs(entities.AuditSymbol, entities.AuditDelete, from, 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "entity_type", "entity_id", "action", "actor_name"}).
+			AddRow(id, entities.AuditSymbol, "1", entities.AuditDelete, "alice"))
+
+	logs, total, err := repo.GetAuditLogs(context.Background(), dtos.AuditLogReq{
+		EntityType: entities.AuditSymbol,
+		Action:     entities.AuditDelete,
+		From:       from,
+		Page:       2,
+		PerPage:    10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), total)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, "alice", logs[0].ActorName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
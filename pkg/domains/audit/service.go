@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+type Service interface {
+	GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) (dtos.PaginatedData, error)
+}
+
+type service struct {
+	repository Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{
+		repository: r,
+	}
+}
+
+func (s *service) GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) (dtos.PaginatedData, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PerPage < 1 {
+		req.PerPage = defaultPerPage
+	}
+	req.PerPage = min(req.PerPage, maxPerPage)
+
+	logs, total, err := s.repository.GetAuditLogs(ctx, req)
+	if err != nil {
+		return dtos.PaginatedData{}, err
+	}
+	rows := make([]dtos.AuditLogRes, 0, len(logs))
+	for _, l := range logs {
+		rows = append(rows, l.ToDto())
+	}
+	return dtos.PaginatedData{
+		Page:       req.Page,
+		PerPage:    req.PerPage,
+		Total:      total,
+		TotalPages: int((total + req.PerPage - 1) / req.PerPage),
+		Rows:       rows,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetAuditLogs(ctx context.Context, req dtos.AuditLogReq) ([]entities.AuditLog, int64, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]entities.AuditLog), args.Get(1).(int64), args.Error(2)
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	diff := `{"ws_url":{"before":"wss://old","after":"wss://new"}}`
+	logs := []entities.AuditLog{{EntityType: entities.AuditExchange, EntityID: "1", Action: entities.AuditUpdate, ActorName: "alice", Diff: diff}}
+
+	t.Run("Defaults", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+		mockRepo.On("GetAuditLogs", mock.Anything, dtos.AuditLogReq{EntityType: entities.AuditExchange, Page: 1, PerPage: 50}).Return(logs, int64(120), nil)
+
+		res, err := service.GetAuditLogs(context.Background(), dtos.AuditLogReq{EntityType: entities.AuditExchange})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), res.Page)
+		assert.Equal(t, int64(120), res.Total)
+		assert.Equal(t, 3, res.TotalPages)
+
+		rows := res.Rows.([]dtos.AuditLogRes)
+		assert.Len(t, rows, 1)
+		assert.JSONEq(t, diff, string(rows[0].Diff))
+		assert.Nil(t, rows[0].Before)
+	})
+
+	t.Run("Page size is capped", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+		mockRepo.On("GetAuditLogs", mock.Anything, dtos.AuditLogReq{Page: 2, PerPage: 500}).Return([]entities.AuditLog{}, int64(0), nil)
+
+		res, err := service.GetAuditLogs(context.Background(), dtos.AuditLogReq{Page: 2, PerPage: 10000})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(500), res.PerPage)
+		mockRepo.AssertExpectations(t)
+	})
+}
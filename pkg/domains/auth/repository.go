@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+var ErrUserNotFound = errors.New(consts.UserNotFound)
+
+// User is an account that can log in
+type User struct {
+	ID           string
+	Username     string
+	PasswordHash string // bcrypt
+	Role         string // admin, trader, viewer
+}
+
+type Repository interface {
+	GetUser(ctx context.Context, username string) (User, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepo returns the active accounts of the users table
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetUser(ctx context.Context, username string) (User, error) {
+	var user entities.User
+	err := r.db.WithContext(ctx).Where("username = ? AND is_active = ?", username, consts.Active).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return User{
+		ID:           user.ID.String(),
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		Role:         user.Role,
+	}, nil
+}
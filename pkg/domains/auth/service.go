@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultAccessExpire  = time.Hour
+	defaultRefreshExpire = 7 * 24 * time.Hour
+)
+
+var ErrInvalidCredentials = errors.New(consts.AuthInvalidCredentials)
+
+// dummyHash is compared when the user does not exist, so a wrong username takes as long as a wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("crypto-trade"), bcrypt.DefaultCost)
+
+type Service interface {
+	Login(ctx context.Context, req dtos.LoginReq) (dtos.TokenRes, error)
+	Refresh(ctx context.Context, req dtos.RefreshTokenReq) (dtos.TokenRes, error)
+}
+
+type service struct {
+	repository    Repository
+	jwt           *utils.JwtWrapper
+	accessExpire  time.Duration
+	refreshExpire time.Duration
+}
+
+func NewService(r Repository, j *utils.JwtWrapper, app config.App) Service {
+	s := &service{
+		repository:    r,
+		jwt:           j,
+		accessExpire:  defaultAccessExpire,
+		refreshExpire: defaultRefreshExpire,
+	}
+	if app.JwtExpire > 0 {
+		s.accessExpire = time.Duration(app.JwtExpire) * time.Hour
+	}
+	if app.JwtRefreshExpire > 0 {
+		s.refreshExpire = time.Duration(app.JwtRefreshExpire) * time.Hour
+	}
+	return s
+}
+
+// Login checks the password of the user and issues an access and a refresh token
+func (s *service) Login(ctx context.Context, req dtos.LoginReq) (dtos.TokenRes, error) {
+	user, err := s.repository.GetUser(ctx, req.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return dtos.TokenRes{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return dtos.TokenRes{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return dtos.TokenRes{}, ErrInvalidCredentials
+	}
+	return s.issue(user)
+}
+
+// Refresh trades a refresh token of a user that still exists for a new pair of tokens, the role is read again so
+// a changed role shows up in the new tokens
+func (s *service) Refresh(ctx context.Context, req dtos.RefreshTokenReq) (dtos.TokenRes, error) {
+	claims, err := s.jwt.ParseClaims(req.RefreshToken)
+	if err != nil {
+		return dtos.TokenRes{}, err
+	}
+	if claims.TokenType != consts.TokenTypeRefresh {
+		return dtos.TokenRes{}, utils.ErrTokenInvalid
+	}
+
+	user, err := s.repository.GetUser(ctx, claims.UserName)
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user.ID != claims.UserId) {
+		return dtos.TokenRes{}, utils.ErrTokenInvalid
+	}
+	if err != nil {
+		return dtos.TokenRes{}, err
+	}
+	return s.issue(user)
+}
+
+func (s *service) issue(user User) (dtos.TokenRes, error) {
+	access, err := s.jwt.GenerateToken(user.Username, user.ID, user.Role, consts.TokenTypeAccess, s.accessExpire)
+	if err != nil {
+		return dtos.TokenRes{}, err
+	}
+	refresh, err := s.jwt.GenerateToken(user.Username, user.ID, user.Role, consts.TokenTypeRefresh, s.refreshExpire)
+	if err != nil {
+		return dtos.TokenRes{}, err
+	}
+	return dtos.TokenRes{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    consts.BearerScheme,
+		ExpiresIn:    int64(s.accessExpire.Seconds()),
+	}, nil
+}
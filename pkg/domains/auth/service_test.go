@@ -0,0 +1,99 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/auth"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type memoryRepository map[string]auth.User
+
+func (r memoryRepository) GetUser(ctx context.Context, username string) (auth.User, error) {
+	user, ok := r[username]
+	if !ok {
+		return auth.User{}, auth.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func newTestService(t *testing.T) (auth.Service, *utils.JwtWrapper) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	jwt := &utils.JwtWrapper{SecretKey: "secret", Issuer: "crypto-trade"}
+	repo := memoryRepository{"trader": {ID: "trader", Username: "trader", PasswordHash: string(hash), Role: consts.RoleTrader}}
+	return auth.NewService(repo, jwt, config.App{JwtExpire: 2}), jwt
+}
+
+func TestLogin(t *testing.T) {
+	s, jwt := newTestService(t)
+
+	t.Run("Success", func(t *testing.T) {
+		res, err := s.Login(context.Background(), dtos.LoginReq{Username: "trader", Password: "pass"})
+		assert.NoError(t, err)
+		assert.Equal(t, consts.BearerScheme, res.TokenType)
+		assert.Equal(t, int64(2*time.Hour/time.Second), res.ExpiresIn)
+
+		access, err := jwt.ParseClaims(res.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, consts.TokenTypeAccess, access.TokenType)
+		assert.Equal(t, "trader", access.UserName)
+		assert.Equal(t, consts.RoleTrader, access.Role)
+
+		refresh, err := jwt.ParseClaims(res.RefreshToken)
+		assert.NoError(t, err)
+		assert.Equal(t, consts.TokenTypeRefresh, refresh.TokenType)
+		assert.True(t, refresh.ExpiresAt.After(access.ExpiresAt.Time))
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		_, err := s.Login(context.Background(), dtos.LoginReq{Username: "trader", Password: "wrong"})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		_, err := s.Login(context.Background(), dtos.LoginReq{Username: "nobody", Password: "pass"})
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	s, jwt := newTestService(t)
+	login, err := s.Login(context.Background(), dtos.LoginReq{Username: "trader", Password: "pass"})
+	assert.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		res, err := s.Refresh(context.Background(), dtos.RefreshTokenReq{RefreshToken: login.RefreshToken})
+		assert.NoError(t, err)
+		claims, err := jwt.ParseClaims(res.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "trader", claims.UserId)
+	})
+
+	t.Run("Access token", func(t *testing.T) {
+		_, err := s.Refresh(context.Background(), dtos.RefreshTokenReq{RefreshToken: login.AccessToken})
+		assert.ErrorIs(t, err, utils.ErrTokenInvalid)
+	})
+
+	t.Run("Removed user", func(t *testing.T) {
+		token, err := jwt.GenerateToken("nobody", "nobody", consts.RoleTrader, consts.TokenTypeRefresh, time.Hour)
+		assert.NoError(t, err)
+		_, err = s.Refresh(context.Background(), dtos.RefreshTokenReq{RefreshToken: token})
+		assert.ErrorIs(t, err, utils.ErrTokenInvalid)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, err := jwt.GenerateToken("trader", "trader", consts.RoleTrader, consts.TokenTypeRefresh, -time.Minute)
+		assert.NoError(t, err)
+		_, err = s.Refresh(context.Background(), dtos.RefreshTokenReq{RefreshToken: token})
+		assert.ErrorIs(t, err, utils.ErrTokenExpired)
+	})
+}
@@ -0,0 +1,73 @@
+package candle
+
+import (
+	"context"
+
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetExchange(ctx context.Context, id string) (entities.Exchange, error)
+	GetOpenTimes(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]int64, error)
+	SaveCandles(ctx context.Context, candles []entities.Candlestick) (int64, error)
+	GetActiveIntervals(ctx context.Context) ([]entities.SignalInterval, error)
+	GetCandles(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]entities.Candlestick, error)
+	ReplaceCandles(ctx context.Context, candles []entities.Candlestick) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) GetExchange(ctx context.Context, id string) (entities.Exchange, error) {
+	var exchange entities.Exchange
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&exchange).Error
+	return exchange, err
+}
+
+// GetOpenTimes returns the stored open times of the exchange symbol interval between from and to in ascending order
+func (r *repository) GetOpenTimes(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]int64, error) {
+	var openTimes []int64
+	err := r.db.WithContext(ctx).Model(&entities.Candlestick{}).
+		Where("exchange_id = ? AND symbol = ? AND interval = ? AND open_time BETWEEN ? AND ?", exchangeID, symbol, interval, from, to).
+		Order("open_time asc").
+		Pluck("open_time", &openTimes).Error
+	return openTimes, err
+}
+
+// SaveCandles inserts the candles missing from the store, the key includes the exchange of every candle
+func (r *repository) SaveCandles(ctx context.Context, candles []entities.Candlestick) (int64, error) {
+	return candlestick.SaveCandles(ctx, r.db, candles)
+}
+
+func (r *repository) GetActiveIntervals(ctx context.Context) ([]entities.SignalInterval, error) {
+	var intervals []entities.SignalInterval
+	err := r.db.WithContext(ctx).Where("is_active = ?", entities.SignalIntervalActive).Find(&intervals).Error
+	return intervals, err
+}
+
+// GetCandles returns the stored candles of the exchange symbol interval opened between from and to in ascending order
+func (r *repository) GetCandles(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]entities.Candlestick, error) {
+	var candles []entities.Candlestick
+	err := r.db.WithContext(ctx).
+		Where("exchange_id = ? AND symbol = ? AND interval = ? AND open_time BETWEEN ? AND ?", exchangeID, symbol, interval, from, to).
+		Order("open_time asc").
+		Find(&candles).Error
+	return candles, err
+}
+
+// ReplaceCandles overwrites the stored candles having the same exchange, symbol, interval and open time
+func (r *repository) ReplaceCandles(ctx context.Context, candles []entities.Candlestick) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(entities.CandlestickUpsert()).CreateInBatches(&candles, 500).Error
+}
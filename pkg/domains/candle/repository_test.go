@@ -0,0 +1,104 @@
+package candle_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	dialector := postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	})
+
+	gormDB, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+
+	return gormDB, mock
+}
+
+var testCandles = []entities.Candlestick{
+	{ExchangeId: "1", Symbol: "BTCUSDT", Interval: "1h", OpenTime: 0, CloseTime: 3599999},
+	{ExchangeId: "1", Symbol: "BTCUSDT", Interval: "1h", OpenTime: 3600000, CloseTime: 7199999},
+}
+
+func TestSaveCandlesRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := candle.NewRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "candlesticks"`) + `.*` +
+		regexp.QuoteMeta(`ON CONFLICT ("exchange_id","symbol","interval","open_time") DO NOTHING`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	inserted, err := repo.SaveCandles(t.Context(), testCandles)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), inserted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceCandlesRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := candle.NewRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "candlesticks"`) + `.*` +
+		regexp.QuoteMeta(`ON CONFLICT ("exchange_id","symbol","interval","open_time") DO UPDATE SET "updated_at"="excluded"."updated_at"`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err := repo.ReplaceCandles(t.Context(), testCandles)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOpenTimesRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := candle.NewRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "open_time" FROM "candlesticks" WHERE (exchange_id = $1 AND symbol = $2 AND interval = $3 AND open_time BETWEEN $4 AND $5)`)).
+		WithArgs("1", "BTCUSDT", "1h", int64(0), int64(3600000)).
+		WillReturnRows(sqlmock.NewRows([]string{"open_time"}).AddRow(0).AddRow(3600000))
+
+	openTimes, err := repo.GetOpenTimes(t.Context(), "1", "BTCUSDT", "1h", 0, 3600000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 3600000}, openTimes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetCandlesRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := candle.NewRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "candlesticks" WHERE (exchange_id = $1 AND symbol = $2 AND interval = $3 AND open_time BETWEEN $4 AND $5)`)).
+		WithArgs("1", "BTCUSDT", "1m", int64(0), int64(59999)).
+		WillReturnRows(sqlmock.NewRows([]string{"exchange_id", "symbol", "interval", "open_time"}).AddRow("1", "BTCUSDT", "1m", 0))
+
+	candles, err := repo.GetCandles(t.Context(), "1", "BTCUSDT", "1m", 0, 59999)
+
+	assert.NoError(t, err)
+	assert.Len(t, candles, 1)
+	assert.Equal(t, "1", candles[0].ExchangeId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,423 @@
+package candle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+)
+
+// maxFinishedJobs is how many finished jobs are kept for the job endpoints, the oldest are forgotten first
+const maxFinishedJobs = 100
+
+var (
+	ErrJobNotFound  = errors.New(consts.BackfillJobNotFound)
+	ErrInvalidRange = errors.New(consts.BackfillInvalidRange)
+)
+
+// KlineFetcher pages klines from the exchange REST api
+type KlineFetcher interface {
+	FetchKlines(ctx context.Context, exchange entities.Exchange, symbol, interval string, start, end int64, limit int) ([]dtos.CandlestickRest, error)
+}
+
+type Service interface {
+	// StartBackfill validates the request and runs the backfill in the background
+	StartBackfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error)
+	// Backfill runs the backfill and returns when it is finished
+	Backfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error)
+	// BackfillRecent backfills the last days of every active signal interval
+	BackfillRecent(ctx context.Context, days int) error
+	// Rollup recomputes the derived interval bars of the exchange symbol from the stored base interval candles
+	Rollup(ctx context.Context, req dtos.RollupReq) (dtos.RollupRes, error)
+	GetJob(ctx context.Context, id string) (dtos.BackfillJobRes, error)
+	GetJobs(ctx context.Context) ([]dtos.BackfillJobRes, error)
+}
+
+type service struct {
+	repository Repository
+	fetcher    KlineFetcher
+	audit      audit.Recorder
+
+	mu    sync.Mutex
+	jobs  map[string]*dtos.BackfillJobRes
+	order []string // job ids oldest first, finished jobs beyond maxFinishedJobs are dropped
+
+	// requests to the exchange are spaced evenly across every running job
+	throttleMu sync.Mutex
+	spacing    time.Duration
+	next       time.Time
+}
+
+func NewService(r Repository, f KlineFetcher, a audit.Recorder, requestsPerSecond int) Service {
+	var spacing time.Duration
+	if requestsPerSecond > 0 {
+		spacing = time.Second / time.Duration(requestsPerSecond)
+	}
+	return &service{
+		repository: r,
+		fetcher:    f,
+		audit:      a,
+		jobs:       make(map[string]*dtos.BackfillJobRes),
+		spacing:    spacing,
+	}
+}
+
+type backfillRun struct {
+	job      *dtos.BackfillJobRes
+	exchange entities.Exchange
+	step     int64
+	from     int64
+	to       int64
+}
+
+func (s *service) StartBackfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error) {
+	run, err := s.prepare(ctx, req)
+	if err != nil {
+		return dtos.BackfillJobRes{}, err
+	}
+
+	// the job outlives the request that started it
+	go s.run(context.Background(), run)
+
+	return s.snapshot(run.job), nil
+}
+
+func (s *service) Backfill(ctx context.Context, req dtos.BackfillReq) (dtos.BackfillJobRes, error) {
+	run, err := s.prepare(ctx, req)
+	if err != nil {
+		return dtos.BackfillJobRes{}, err
+	}
+
+	err = s.run(ctx, run)
+	return s.snapshot(run.job), err
+}
+
+func (s *service) BackfillRecent(ctx context.Context, days int) error {
+	intervals, err := s.repository.GetActiveIntervals(ctx)
+	if err != nil {
+		return err
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+
+	var errs []error
+	for _, interval := range intervals {
+		_, err := s.Backfill(ctx, dtos.BackfillReq{
+			ExchangeID: interval.ExchangeID.String(),
+			Symbol:     interval.Symbol,
+			Interval:   interval.Interval,
+			From:       from,
+			To:         to,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", interval.Symbol, interval.Interval, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *service) Rollup(ctx context.Context, req dtos.RollupReq) (dtos.RollupRes, error) {
+	if len(req.Intervals) == 0 {
+		req.Intervals = consts.DerivedIntervals
+	}
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if req.From.IsZero() || !req.From.Before(req.To) {
+		return dtos.RollupRes{}, ErrInvalidRange
+	}
+
+	// rolling up one window of the longest interval at a time keeps every bar inside a single window
+	var window int64
+	for _, interval := range req.Intervals {
+		duration, err := candlestick.IntervalDuration(interval)
+		if err != nil {
+			return dtos.RollupRes{}, err
+		}
+		window = max(window, duration.Milliseconds())
+	}
+
+	res := dtos.RollupRes{
+		ExchangeID: req.ExchangeID,
+		Symbol:     strings.ToUpper(req.Symbol),
+		From:       req.From.UTC(),
+		To:         req.To.UTC(),
+		Bars:       make(map[string]int, len(req.Intervals)),
+	}
+
+	from := req.From.UnixMilli()
+	from -= from % window
+	for start := from; start < req.To.UnixMilli(); start += window {
+		candles, err := s.repository.GetCandles(ctx, res.ExchangeID, res.Symbol, consts.BaseInterval, start, start+window-1)
+		if err != nil {
+			return res, err
+		}
+
+		for _, interval := range req.Intervals {
+			bars, err := candlestick.Rollup(candles, interval)
+			if err != nil {
+				return res, err
+			}
+			if err := s.repository.ReplaceCandles(ctx, bars); err != nil {
+				return res, err
+			}
+			res.Bars[interval] += len(bars)
+		}
+	}
+
+	ctlog.For("candlestick").InfoContext(ctx, "Rollup done", ctlog.Exchange(res.ExchangeID), ctlog.Symbol(res.Symbol), slog.Any("bars", res.Bars))
+	return res, nil
+}
+
+func (s *service) GetJob(ctx context.Context, id string) (dtos.BackfillJobRes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return dtos.BackfillJobRes{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+func (s *service) GetJobs(ctx context.Context) ([]dtos.BackfillJobRes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// newest first
+	jobs := make([]dtos.BackfillJobRes, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *s.jobs[s.order[i]])
+	}
+	return jobs, nil
+}
+
+// prepare validates the request and registers a pending job, only closed candles are backfilled
+func (s *service) prepare(ctx context.Context, req dtos.BackfillReq) (backfillRun, error) {
+	duration, err := candlestick.IntervalDuration(req.Interval)
+	if err != nil {
+		return backfillRun{}, err
+	}
+	step := duration.Milliseconds()
+
+	now := time.Now()
+	if req.To.IsZero() || req.To.After(now) {
+		req.To = now
+	}
+	if req.From.IsZero() || !req.From.Before(req.To) {
+		return backfillRun{}, ErrInvalidRange
+	}
+
+	exchange, err := s.repository.GetExchange(ctx, req.ExchangeID)
+	if err != nil {
+		return backfillRun{}, fmt.Errorf("exchange %s not found: %w", req.ExchangeID, err)
+	}
+
+	from := req.From.UnixMilli()
+	to := req.To.UnixMilli()
+	to -= to % step
+	if to+step > now.UnixMilli() {
+		to -= step
+	}
+	if to < from {
+		return backfillRun{}, ErrInvalidRange
+	}
+
+	job := &dtos.BackfillJobRes{
+		ID:         uuid.NewString(),
+		ExchangeID: req.ExchangeID,
+		// candles from the streams are stored with the exchange symbol
+		Symbol:   strings.ToUpper(req.Symbol),
+		Interval: req.Interval,
+		From:     req.From.UTC(),
+		To:       req.To.UTC(),
+		Status:   consts.BackfillPending,
+	}
+	// the job is started by the caller of the request, scheduled ones by the system
+	if err := s.audit.Record(ctx, audit.Change{EntityType: entities.AuditBackfillJob, EntityID: job.ID, Action: entities.AuditCreate, After: *job}); err != nil {
+		return backfillRun{}, fmt.Errorf("audit backfill job: %w", err)
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.prune()
+	s.mu.Unlock()
+
+	return backfillRun{
+		job:      job,
+		exchange: exchange,
+		step:     step,
+		from:     from,
+		to:       to,
+	}, nil
+}
+
+func (s *service) run(ctx context.Context, run backfillRun) error {
+	startedAt := time.Now()
+	s.update(run.job, func(j *dtos.BackfillJobRes) {
+		j.Status = consts.BackfillRunning
+		j.StartedAt = &startedAt
+	})
+
+	err := s.fill(ctx, run)
+
+	finishedAt := time.Now()
+	s.update(run.job, func(j *dtos.BackfillJobRes) {
+		j.FinishedAt = &finishedAt
+		if err != nil {
+			j.Status = consts.BackfillFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = consts.BackfillDone
+	})
+
+	job := s.snapshot(run.job)
+	if err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Candle backfill failed", ctlog.Symbol(job.Symbol), slog.String("interval", job.Interval),
+			slog.String("job_id", job.ID), slog.Int64("inserted", job.Inserted), slog.Int64("missing", job.Missing), ctlog.Err(err))
+		return err
+	}
+
+	ctlog.For("candlestick").InfoContext(ctx, "Candle backfill done", ctlog.Symbol(job.Symbol), slog.String("interval", job.Interval),
+		slog.String("job_id", job.ID), slog.Int("gaps", job.Gaps), slog.Int64("inserted", job.Inserted), slog.Int64("missing", job.Missing),
+		slog.Int64("requests", job.Requests))
+	return nil
+}
+
+func (s *service) fill(ctx context.Context, run backfillRun) error {
+	exchangeID, symbol, interval := run.job.ExchangeID, run.job.Symbol, run.job.Interval
+
+	openTimes, err := s.repository.GetOpenTimes(ctx, exchangeID, symbol, interval, run.from, run.to)
+	if err != nil {
+		return err
+	}
+
+	gaps := candlestick.DetectGaps(openTimes, run.from, run.to, run.step)
+	var missing int64
+	for _, gap := range gaps {
+		missing += gap.Count
+	}
+	s.update(run.job, func(j *dtos.BackfillJobRes) {
+		j.Gaps = len(gaps)
+		j.Missing = missing
+	})
+
+	for _, gap := range gaps {
+		start := gap.From
+		for start <= gap.To {
+			if err := s.wait(ctx); err != nil {
+				return err
+			}
+
+			limit := int(min((gap.To-start)/run.step+1, candlestick.MaxKlineLimit))
+			klines, err := s.fetcher.FetchKlines(ctx, run.exchange, symbol, interval, start, gap.To, limit)
+			s.update(run.job, func(j *dtos.BackfillJobRes) { j.Requests++ })
+			if err != nil {
+				return err
+			}
+
+			candles := make([]entities.Candlestick, 0, len(klines))
+			for i := range klines {
+				if klines[i].OpenTime < start || klines[i].OpenTime > gap.To {
+					continue
+				}
+				var candle entities.Candlestick
+				candle.FromDto(&klines[i])
+				candle.ExchangeId = exchangeID
+				candles = append(candles, candle)
+			}
+			// the exchange has no candles left in the gap, e.g. before the symbol was listed
+			if len(candles) == 0 {
+				break
+			}
+
+			inserted, err := s.repository.SaveCandles(ctx, candles)
+			if err != nil {
+				return err
+			}
+			s.update(run.job, func(j *dtos.BackfillJobRes) {
+				j.Fetched += int64(len(candles))
+				j.Inserted += inserted
+			})
+
+			start = candles[len(candles)-1].OpenTime + run.step
+		}
+	}
+	return nil
+}
+
+// wait blocks until the next exchange request is allowed
+func (s *service) wait(ctx context.Context) error {
+	if s.spacing == 0 {
+		return ctx.Err()
+	}
+
+	s.throttleMu.Lock()
+	now := time.Now()
+	at := s.next
+	if at.Before(now) {
+		at = now
+	}
+	s.next = at.Add(s.spacing)
+	s.throttleMu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs, pending and running jobs are kept, s.mu is held
+func (s *service) prune() {
+	finished := 0
+	for _, id := range s.order {
+		if isFinished(s.jobs[id]) {
+			finished++
+		}
+	}
+
+	order := s.order[:0]
+	for _, id := range s.order {
+		if finished > maxFinishedJobs && isFinished(s.jobs[id]) {
+			delete(s.jobs, id)
+			finished--
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+}
+
+func isFinished(job *dtos.BackfillJobRes) bool {
+	return job.Status == consts.BackfillDone || job.Status == consts.BackfillFailed
+}
+
+func (s *service) update(job *dtos.BackfillJobRes, fn func(j *dtos.BackfillJobRes)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(job)
+}
+
+func (s *service) snapshot(job *dtos.BackfillJobRes) dtos.BackfillJobRes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *job
+}
@@ -0,0 +1,322 @@
+package candle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetExchange(ctx context.Context, id string) (entities.Exchange, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(entities.Exchange), args.Error(1)
+}
+
+func (m *MockRepository) GetOpenTimes(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]int64, error) {
+	args := m.Called(ctx, exchangeID, symbol, interval, from, to)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) SaveCandles(ctx context.Context, candles []entities.Candlestick) (int64, error) {
+	args := m.Called(ctx, candles)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetActiveIntervals(ctx context.Context) ([]entities.SignalInterval, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entities.SignalInterval), args.Error(1)
+}
+
+func (m *MockRepository) GetCandles(ctx context.Context, exchangeID, symbol, interval string, from, to int64) ([]entities.Candlestick, error) {
+	args := m.Called(ctx, exchangeID, symbol, interval, from, to)
+	return args.Get(0).([]entities.Candlestick), args.Error(1)
+}
+
+func (m *MockRepository) ReplaceCandles(ctx context.Context, candles []entities.Candlestick) error {
+	args := m.Called(ctx, candles)
+	return args.Error(0)
+}
+
+// recorder keeps the recorded audit changes, err fails every record
+type recorder struct {
+	mu      sync.Mutex
+	changes []audit.Change
+	err     error
+}
+
+func (r *recorder) Record(ctx context.Context, change audit.Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+// fakeFetcher serves every requested kline but at most pageSize per request
+type fakeFetcher struct {
+	mu       sync.Mutex
+	pageSize int
+	until    int64 // no klines after this open time when set
+	requests [][2]int64
+}
+
+func (f *fakeFetcher) FetchKlines(ctx context.Context, exchange entities.Exchange, symbol, interval string, start, end int64, limit int) ([]dtos.CandlestickRest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, [2]int64{start, end})
+
+	duration, err := candlestick.IntervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	step := duration.Milliseconds()
+
+	var klines []dtos.CandlestickRest
+	for t := start; t <= end && len(klines) < min(limit, f.pageSize); t += step {
+		if f.until > 0 && t > f.until {
+			break
+		}
+		klines = append(klines, dtos.CandlestickRest{Symbol: symbol, Interval: interval, OpenTime: t, CloseTime: t + step - 1})
+	}
+	return klines, nil
+}
+
+var (
+	testExchange = entities.Exchange{Name: consts.Binance}
+	rangeStart   = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestBackfill(t *testing.T) {
+	step := time.Minute.Milliseconds()
+	from := rangeStart.UnixMilli()
+	to := from + 10*step
+
+	mockRepo := new(MockRepository)
+	fetcher := &fakeFetcher{pageSize: 3}
+	service := NewService(mockRepo, fetcher, &recorder{}, 0)
+
+	mockRepo.On("GetExchange", mock.Anything, "1").Return(testExchange, nil)
+	mockRepo.On("GetOpenTimes", mock.Anything, "1", "BTCUSDT", "1m", from, to).Return([]int64{from + 2*step, from + 3*step}, nil)
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(2), nil).Once()
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(3), nil).Twice()
+	// the last candle was stored by the stream meanwhile
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(0), nil).Once()
+
+	job, err := service.Backfill(t.Context(), dtos.BackfillReq{
+		ExchangeID: "1",
+		Symbol:     "btcusdt",
+		Interval:   "1m",
+		From:       rangeStart,
+		To:         rangeStart.Add(10 * time.Minute),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, consts.BackfillDone, job.Status)
+	assert.Equal(t, "BTCUSDT", job.Symbol)
+	assert.Equal(t, 2, job.Gaps)
+	assert.Equal(t, int64(9), job.Missing)
+	assert.Equal(t, int64(9), job.Fetched)
+	assert.Equal(t, int64(8), job.Inserted)
+	assert.Equal(t, int64(4), job.Requests)
+	assert.Equal(t, [][2]int64{
+		{from, from + step},
+		{from + 4*step, to},
+		{from + 7*step, to},
+		{from + 10*step, to},
+	}, fetcher.requests)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillBeforeListing(t *testing.T) {
+	step := time.Hour.Milliseconds()
+	from := rangeStart.UnixMilli()
+
+	mockRepo := new(MockRepository)
+	// the symbol has no klines after the first 2 hours
+	fetcher := &fakeFetcher{pageSize: 1000, until: from + step}
+	service := NewService(mockRepo, fetcher, &recorder{}, 0)
+
+	mockRepo.On("GetExchange", mock.Anything, "1").Return(testExchange, nil)
+	mockRepo.On("GetOpenTimes", mock.Anything, "1", "BTCUSDT", "1h", mock.Anything, mock.Anything).Return([]int64{}, nil)
+	mockRepo.On("SaveCandles", mock.Anything, mock.MatchedBy(func(candles []entities.Candlestick) bool {
+		return len(candles) == 2 && candles[0].ExchangeId == "1" && candles[1].ExchangeId == "1"
+	})).Return(int64(2), nil).Once()
+
+	job, err := service.Backfill(t.Context(), dtos.BackfillReq{
+		ExchangeID: "1",
+		Symbol:     "BTCUSDT",
+		Interval:   "1h",
+		From:       rangeStart,
+		To:         rangeStart.Add(24 * time.Hour),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25), job.Missing)
+	assert.Equal(t, int64(2), job.Inserted)
+	// the second page comes back empty and ends the gap
+	assert.Equal(t, int64(2), job.Requests)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBackfillInvalidRequest(t *testing.T) {
+	mockRepo := new(MockRepository)
+	audits := &recorder{}
+	service := NewService(mockRepo, &fakeFetcher{}, audits, 0)
+
+	_, err := service.Backfill(t.Context(), dtos.BackfillReq{ExchangeID: "1", Symbol: "BTCUSDT", Interval: "7m", From: rangeStart})
+	assert.Error(t, err)
+
+	_, err = service.Backfill(t.Context(), dtos.BackfillReq{ExchangeID: "1", Symbol: "BTCUSDT", Interval: "1m", From: rangeStart, To: rangeStart.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	jobs, err := service.GetJobs(t.Context())
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+	assert.Empty(t, audits.changes)
+	mockRepo.AssertNotCalled(t, "GetExchange", mock.Anything, mock.Anything)
+}
+
+func TestBackfillNotAudited(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, &fakeFetcher{}, &recorder{err: errors.New("connection refused")}, 0)
+
+	mockRepo.On("GetExchange", mock.Anything, "1").Return(testExchange, nil)
+
+	// a job nobody can trace back is not started
+	_, err := service.StartBackfill(t.Context(), dtos.BackfillReq{ExchangeID: "1", Symbol: "BTCUSDT", Interval: "1m", From: rangeStart, To: rangeStart.Add(time.Hour)})
+	assert.Error(t, err)
+
+	jobs, _ := service.GetJobs(t.Context())
+	assert.Empty(t, jobs)
+	mockRepo.AssertNotCalled(t, "GetOpenTimes", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStartBackfill(t *testing.T) {
+	mockRepo := new(MockRepository)
+	audits := &recorder{}
+	service := NewService(mockRepo, &fakeFetcher{pageSize: 1000}, audits, 100)
+
+	mockRepo.On("GetExchange", mock.Anything, "1").Return(testExchange, nil)
+	mockRepo.On("GetOpenTimes", mock.Anything, "1", "BTCUSDT", "1m", mock.Anything, mock.Anything).Return([]int64{}, nil)
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(61), nil)
+
+	job, err := service.StartBackfill(t.Context(), dtos.BackfillReq{
+		ExchangeID: "1",
+		Symbol:     "BTCUSDT",
+		Interval:   "1m",
+		From:       rangeStart,
+		To:         rangeStart.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, consts.BackfillPending, job.Status)
+	assert.Len(t, audits.changes, 1)
+	assert.Equal(t, audit.Change{EntityType: entities.AuditBackfillJob, EntityID: job.ID, Action: entities.AuditCreate, After: job}, audits.changes[0])
+
+	assert.Eventually(t, func() bool {
+		job, err := service.GetJob(t.Context(), job.ID)
+		return err == nil && job.Status == consts.BackfillDone && job.Inserted == 61
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = service.GetJob(t.Context(), uuid.NewString())
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestFinishedJobsAreCapped(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, &fakeFetcher{pageSize: 1000}, &recorder{}, 0)
+
+	mockRepo.On("GetExchange", mock.Anything, "1").Return(testExchange, nil)
+	mockRepo.On("GetOpenTimes", mock.Anything, "1", "BTCUSDT", "1h", mock.Anything, mock.Anything).Return([]int64{}, nil)
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(4), nil)
+
+	var first string
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		job, err := service.Backfill(t.Context(), dtos.BackfillReq{
+			ExchangeID: "1",
+			Symbol:     "BTCUSDT",
+			Interval:   "1h",
+			From:       rangeStart,
+			To:         rangeStart.Add(3 * time.Hour),
+		})
+		assert.NoError(t, err)
+		if i == 0 {
+			first = job.ID
+		}
+	}
+
+	jobs, err := service.GetJobs(t.Context())
+	assert.NoError(t, err)
+	assert.Len(t, jobs, maxFinishedJobs+1)
+	_, err = service.GetJob(t.Context(), first)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestBackfillRecent(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, &fakeFetcher{pageSize: 1000}, &recorder{}, 0)
+
+	exchangeID := uuid.New()
+	mockRepo.On("GetActiveIntervals", mock.Anything).Return([]entities.SignalInterval{
+		{Symbol: "btcusdt", Interval: "1h", ExchangeID: exchangeID},
+		{Symbol: "ethusdt", Interval: "4h", ExchangeID: exchangeID},
+	}, nil)
+	mockRepo.On("GetExchange", mock.Anything, exchangeID.String()).Return(testExchange, nil)
+	mockRepo.On("GetOpenTimes", mock.Anything, exchangeID.String(), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]int64{}, nil)
+	mockRepo.On("SaveCandles", mock.Anything, mock.Anything).Return(int64(24), nil)
+
+	err := service.BackfillRecent(t.Context(), 1)
+	assert.NoError(t, err)
+
+	jobs, _ := service.GetJobs(t.Context())
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, "ETHUSDT", jobs[0].Symbol)
+	assert.Equal(t, consts.BackfillDone, jobs[1].Status)
+	mockRepo.AssertNumberOfCalls(t, "GetOpenTimes", 2)
+}
+
+func TestRollup(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, &fakeFetcher{}, &recorder{}, 0)
+
+	from := rangeStart.UnixMilli()
+	hour := time.Hour.Milliseconds()
+	var candles []entities.Candlestick
+	for i := int64(0); i < 120; i++ {
+		openTime := from + i*time.Minute.Milliseconds()
+		candles = append(candles, entities.Candlestick{ExchangeId: "1", Symbol: "BTCUSDT", Interval: "1m", OpenTime: openTime, CloseTime: openTime + time.Minute.Milliseconds() - 1})
+	}
+
+	mockRepo.On("GetCandles", mock.Anything, "1", "BTCUSDT", "1m", from, from+hour-1).Return(candles[:60], nil)
+	mockRepo.On("GetCandles", mock.Anything, "1", "BTCUSDT", "1m", from+hour, from+2*hour-1).Return(candles[60:], nil)
+	mockRepo.On("ReplaceCandles", mock.Anything, mock.MatchedBy(func(bars []entities.Candlestick) bool {
+		return len(bars) > 0 && bars[0].ExchangeId == "1"
+	})).Return(nil)
+
+	res, err := service.Rollup(t.Context(), dtos.RollupReq{
+		ExchangeID: "1",
+		Symbol:     "btcusdt",
+		Intervals:  []string{"15m", "1h"},
+		From:       rangeStart.Add(10 * time.Minute),
+		To:         rangeStart.Add(2 * time.Hour),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"15m": 8, "1h": 2}, res.Bars)
+	mockRepo.AssertNumberOfCalls(t, "ReplaceCandles", 4)
+}
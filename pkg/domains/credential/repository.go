@@ -0,0 +1,97 @@
+package credential
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+var ErrCredentialNotFound = errors.New(consts.CredentialNotFound)
+
+type Repository interface {
+	AddCredential(ctx context.Context, cred *entities.ExchangeCredential) error
+	GetAllCredentials(ctx context.Context) ([]entities.ExchangeCredential, error)
+	GetCredential(ctx context.Context, id string) (entities.ExchangeCredential, error)
+	GetCredentialsNotWrappedWith(ctx context.Context, masterKeyID string) ([]entities.ExchangeCredential, error)
+	UpdateEnvelope(ctx context.Context, cred *entities.ExchangeCredential) error
+	DeleteCredential(ctx context.Context, id string) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) AddCredential(ctx context.Context, cred *entities.ExchangeCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(cred).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditCredential, EntityID: cred.ID.String(), Action: entities.AuditCreate, After: cred})
+	})
+}
+
+func (r *repository) GetAllCredentials(ctx context.Context) ([]entities.ExchangeCredential, error) {
+	var creds []entities.ExchangeCredential
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&creds).Error
+	return creds, err
+}
+
+func (r *repository) GetCredential(ctx context.Context, id string) (entities.ExchangeCredential, error) {
+	var cred entities.ExchangeCredential
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&cred).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cred, ErrCredentialNotFound
+	}
+	return cred, err
+}
+
+// GetCredentialsNotWrappedWith returns the credentials whose data key is wrapped by another master key
+func (r *repository) GetCredentialsNotWrappedWith(ctx context.Context, masterKeyID string) ([]entities.ExchangeCredential, error) {
+	var creds []entities.ExchangeCredential
+	err := r.db.WithContext(ctx).Where("master_key_id <> ?", masterKeyID).Find(&creds).Error
+	return creds, err
+}
+
+// UpdateEnvelope saves a rewrapped data key, the ciphertext does not change
+func (r *repository) UpdateEnvelope(ctx context.Context, cred *entities.ExchangeCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before entities.ExchangeCredential
+		if err := tx.Where("id = ?", cred.ID).First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(cred).Updates(map[string]any{"master_key_id": cred.MasterKeyID, "wrapped_key": cred.WrappedKey}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditCredential, EntityID: cred.ID.String(), Action: entities.AuditUpdate, Before: before, After: cred})
+	})
+}
+
+// DeleteCredential removes the row for good so no sealed secret is left behind, deleting a missing credential is a
+// no-op
+func (r *repository) DeleteCredential(ctx context.Context, id string) error {
+	var cred entities.ExchangeCredential
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&cred).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("id = ?", id).Delete(&entities.ExchangeCredential{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditCredential, EntityID: id, Action: entities.AuditDelete, Before: cred})
+	})
+}
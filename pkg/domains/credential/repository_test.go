@@ -0,0 +1,127 @@
+package credential_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit/audittest"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/credential"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+func TestAddCredential(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := credential.NewRepo(db)
+	cred := &entities.ExchangeCredential{
+		ExchangeID:  uuid.New(),
+		Label:       "main",
+		APIKeyHint:  "****6789",
+		MasterKeyID: "k1",
+		WrappedKey:  []byte("wrapped"),
+		Ciphertext:  []byte("sealed"),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "exchange_credentials"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), cred.ExchangeID, "main", "****6789", "k1", []byte("wrapped"), []byte("sealed")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.ExpectColumns(mock, entities.AuditCredential, entities.AuditCreate, sqlmock.AnyArg(), nil, withoutSealed{}, withoutSealed{})
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.AddCredential(context.Background(), cred))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateEnvelope(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := credential.NewRepo(db)
+	id := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "exchange_credentials" WHERE id = \$1`).
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "master_key_id", "wrapped_key"}).AddRow(id, "main", "k1", []byte("old")))
+	mock.ExpectExec(`UPDATE "exchange_credentials" SET "master_key_id"=\$1,"wrapped_key"=\$2,"updated_at"=\$3 WHERE "exchange_credentials"."deleted_at" IS NULL AND "id" = \$4`).
+		WithArgs("k2", []byte("new"), sqlmock.AnyArg(), id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.ExpectColumns(mock, entities.AuditCredential, entities.AuditUpdate, id.String(), sqlmock.AnyArg(), sqlmock.AnyArg(), audittest.DiffOf("master_key_id"))
+	mock.ExpectCommit()
+
+	cred := &entities.ExchangeCredential{Label: "main", MasterKeyID: "k2", WrappedKey: []byte("new")}
+	cred.ID = id
+	assert.NoError(t, repo.UpdateEnvelope(context.Background(), cred))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteCredential(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := credential.NewRepo(db)
+		id := uuid.New()
+
+		mock.ExpectQuery(`SELECT \* FROM "exchange_credentials" WHERE id = \$1`).
+			WithArgs(id.String(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "label"}).AddRow(id, "main"))
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "exchange_credentials" WHERE id = \$1`).
+			WithArgs(id.String()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		audittest.ExpectColumns(mock, entities.AuditCredential, entities.AuditDelete, id.String(), sqlmock.AnyArg(), nil, sqlmock.AnyArg())
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.DeleteCredential(context.Background(), id.String()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := credential.NewRepo(db)
+
+		mock.ExpectQuery(`SELECT \* FROM "exchange_credentials" WHERE id = \$1`).
+			WithArgs("missing", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		assert.NoError(t, repo.DeleteCredential(context.Background(), "missing"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// withoutSealed matches audit json that has no sealed key material
+type withoutSealed struct{}
+
+func (withoutSealed) Match(v driver.Value) bool {
+	var m map[string]any
+	s, ok := v.(string)
+	if !ok || json.Unmarshal([]byte(s), &m) != nil {
+		return false
+	}
+	_, wrapped := m["wrapped_key"]
+	_, sealed := m["ciphertext"]
+	return !wrapped && !sealed
+}
@@ -0,0 +1,138 @@
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCredentialReq = errors.New(consts.CredentialInvalidReq)
+	ErrNoMasterKey          = errors.New(consts.CredentialNoMasterKey)
+)
+
+// payload is what is sealed in the ciphertext of a credential, it only lives in this package while it is in clear
+type payload struct {
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+}
+
+type Service interface {
+	AddCredential(ctx context.Context, req dtos.AddCredentialReq) (dtos.CredentialRes, error)
+	GetAllCredentials(ctx context.Context) ([]dtos.CredentialRes, error)
+	DeleteCredential(ctx context.Context, id string) error
+	RotateCredentials(ctx context.Context) (dtos.RotateCredentialsRes, error)
+	Signer(ctx context.Context, id string) (utils.Signer, error)
+}
+
+type service struct {
+	repository Repository
+	keys       *secrets.Keyring
+}
+
+// NewService seals credentials with keys, without a keyring credentials can only be listed and deleted
+func NewService(r Repository, keys *secrets.Keyring) Service {
+	return &service{
+		repository: r,
+		keys:       keys,
+	}
+}
+
+func (s *service) AddCredential(ctx context.Context, req dtos.AddCredentialReq) (dtos.CredentialRes, error) {
+	exchangeID, err := uuid.Parse(req.ExchangeID)
+	if err != nil || req.Label == "" || req.APIKey == "" || req.APISecret == "" {
+		return dtos.CredentialRes{}, ErrInvalidCredentialReq
+	}
+	if s.keys == nil {
+		return dtos.CredentialRes{}, ErrNoMasterKey
+	}
+
+	plain, err := json.Marshal(payload{APIKey: req.APIKey.Reveal(), APISecret: req.APISecret.Reveal()})
+	if err != nil {
+		return dtos.CredentialRes{}, err
+	}
+	envelope, err := s.keys.Seal(plain)
+	if err != nil {
+		return dtos.CredentialRes{}, err
+	}
+
+	cred := entities.ExchangeCredential{
+		ExchangeID: exchangeID,
+		Label:      req.Label,
+		APIKeyHint: secrets.Hint(req.APIKey.Reveal()),
+	}
+	cred.SetEnvelope(envelope)
+	if err := s.repository.AddCredential(ctx, &cred); err != nil {
+		return dtos.CredentialRes{}, err
+	}
+	return cred.ToDto(), nil
+}
+
+func (s *service) GetAllCredentials(ctx context.Context) ([]dtos.CredentialRes, error) {
+	creds, err := s.repository.GetAllCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dtos.CredentialRes, 0, len(creds))
+	for _, c := range creds {
+		res = append(res, c.ToDto())
+	}
+	return res, nil
+}
+
+func (s *service) DeleteCredential(ctx context.Context, id string) error {
+	return s.repository.DeleteCredential(ctx, id)
+}
+
+// RotateCredentials rewraps the data keys that are not wrapped by the active master key, once it is done the old
+// master key can be removed from the config
+func (s *service) RotateCredentials(ctx context.Context) (dtos.RotateCredentialsRes, error) {
+	if s.keys == nil {
+		return dtos.RotateCredentialsRes{}, ErrNoMasterKey
+	}
+	creds, err := s.repository.GetCredentialsNotWrappedWith(ctx, s.keys.ActiveKeyID())
+	if err != nil {
+		return dtos.RotateCredentialsRes{}, err
+	}
+
+	res := dtos.RotateCredentialsRes{MasterKeyID: s.keys.ActiveKeyID()}
+	for _, cred := range creds {
+		envelope, err := s.keys.Rewrap(cred.Envelope())
+		if err != nil {
+			return res, err
+		}
+		cred.SetEnvelope(envelope)
+		if err := s.repository.UpdateEnvelope(ctx, &cred); err != nil {
+			return res, err
+		}
+		res.Rotated++
+	}
+	return res, nil
+}
+
+// Signer opens the credential and returns a signer for the signed endpoints of its exchange
+func (s *service) Signer(ctx context.Context, id string) (utils.Signer, error) {
+	if s.keys == nil {
+		return nil, ErrNoMasterKey
+	}
+	cred, err := s.repository.GetCredential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := s.keys.Open(cred.Envelope())
+	if err != nil {
+		return nil, err
+	}
+	var p payload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return nil, err
+	}
+	return utils.NewHMACSigner(secrets.Secret(p.APIKey), secrets.Secret(p.APISecret)), nil
+}
@@ -0,0 +1,158 @@
+package credential
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) AddCredential(ctx context.Context, cred *entities.ExchangeCredential) error {
+	args := m.Called(ctx, cred)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAllCredentials(ctx context.Context) ([]entities.ExchangeCredential, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entities.ExchangeCredential), args.Error(1)
+}
+
+func (m *MockRepository) GetCredential(ctx context.Context, id string) (entities.ExchangeCredential, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(entities.ExchangeCredential), args.Error(1)
+}
+
+func (m *MockRepository) GetCredentialsNotWrappedWith(ctx context.Context, masterKeyID string) ([]entities.ExchangeCredential, error) {
+	args := m.Called(ctx, masterKeyID)
+	return args.Get(0).([]entities.ExchangeCredential), args.Error(1)
+}
+
+func (m *MockRepository) UpdateEnvelope(ctx context.Context, cred *entities.ExchangeCredential) error {
+	args := m.Called(ctx, cred)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteCredential(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func addReq() dtos.AddCredentialReq {
+	return dtos.AddCredentialReq{
+		ExchangeID: uuid.NewString(),
+		Label:      "main",
+		APIKey:     "key-0123456789",
+		APISecret:  "super-secret",
+	}
+}
+
+func TestAddCredential(t *testing.T) {
+	t.Run("Seals the secrets", func(t *testing.T) {
+		keys, err := secrets.NewKeyring("k1", map[string]string{"k1": newKey(t)})
+		require.NoError(t, err)
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, keys)
+
+		var saved *entities.ExchangeCredential
+		mockRepo.On("AddCredential", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(1).(*entities.ExchangeCredential)
+		}).Return(nil)
+
+		res, err := service.AddCredential(context.Background(), addReq())
+		require.NoError(t, err)
+		assert.Equal(t, "****6789", res.APIKeyHint)
+		assert.Equal(t, "k1", res.MasterKeyID)
+		assert.NotContains(t, string(saved.Ciphertext), "super-secret")
+
+		plain, err := keys.Open(saved.Envelope())
+		require.NoError(t, err)
+		assert.Contains(t, string(plain), "super-secret")
+	})
+
+	t.Run("Missing secret", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, nil)
+
+		req := addReq()
+		req.APISecret = ""
+		_, err := service.AddCredential(context.Background(), req)
+		assert.ErrorIs(t, err, ErrInvalidCredentialReq)
+	})
+
+	t.Run("No master key", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo, nil)
+
+		_, err := service.AddCredential(context.Background(), addReq())
+		assert.ErrorIs(t, err, ErrNoMasterKey)
+		mockRepo.AssertNotCalled(t, "AddCredential", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRotateCredentials(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+	old, err := secrets.NewKeyring("k1", map[string]string{"k1": k1})
+	require.NoError(t, err)
+	envelope, err := old.Seal([]byte(`{"api_key":"key","api_secret":"secret"}`))
+	require.NoError(t, err)
+	cred := entities.ExchangeCredential{Label: "main"}
+	cred.SetEnvelope(envelope)
+
+	keys, err := secrets.NewKeyring("k2", map[string]string{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, keys)
+
+	mockRepo.On("GetCredentialsNotWrappedWith", mock.Anything, "k2").Return([]entities.ExchangeCredential{cred}, nil)
+	mockRepo.On("UpdateEnvelope", mock.Anything, mock.MatchedBy(func(c *entities.ExchangeCredential) bool {
+		return c.MasterKeyID == "k2" && string(c.Ciphertext) == string(envelope.Ciphertext)
+	})).Return(nil)
+
+	res, err := service.RotateCredentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, dtos.RotateCredentialsRes{MasterKeyID: "k2", Rotated: 1}, res)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSigner(t *testing.T) {
+	keys, err := secrets.NewKeyring("k1", map[string]string{"k1": newKey(t)})
+	require.NoError(t, err)
+	envelope, err := keys.Seal([]byte(`{"api_key":"key-0123456789","api_secret":"super-secret"}`))
+	require.NoError(t, err)
+	cred := entities.ExchangeCredential{}
+	cred.SetEnvelope(envelope)
+
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo, keys)
+	mockRepo.On("GetCredential", mock.Anything, "1").Return(cred, nil)
+
+	signer, err := service.Signer(context.Background(), "1")
+	require.NoError(t, err)
+	query, headers := signer.Sign(url.Values{"symbol": {"BTCUSDT"}})
+	assert.Equal(t, "key-0123456789", headers[utils.BinanceAPIKeyHeader])
+
+	unsigned, signature, _ := strings.Cut(query, "&signature=")
+	assert.Equal(t, utils.SignHMAC("super-secret", unsigned), signature)
+}
@@ -0,0 +1,145 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+var (
+	ErrUnknownTopic  = errors.New(consts.DeadLetterUnknownTopic)
+	ErrInvalidReplay = errors.New(consts.DeadLetterInvalidReplay)
+	ErrNotFound      = kafka.ErrMessageNotFound
+)
+
+// Broker reads the dead letter topics and produces the replayed messages, the Kafka client implements it
+type Broker interface {
+	ReadMessages(ctx context.Context, topic string, limit int) ([]*sarama.ConsumerMessage, error)
+	ReadMessage(ctx context.Context, topic string, partition int32, offset int64) (*sarama.ConsumerMessage, error)
+	ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error)
+}
+
+type Service interface {
+	List(ctx context.Context, topic string, limit int) ([]dtos.DeadLetterRes, error)
+	Replay(ctx context.Context, topic string, req dtos.DeadLetterReplayReq) (dtos.DeadLetterReplayRes, error)
+}
+
+type service struct {
+	broker Broker
+	audit  audit.Recorder
+}
+
+func NewService(b Broker, r audit.Recorder) Service {
+	return &service{
+		broker: b,
+		audit:  r,
+	}
+}
+
+// List returns the latest dead lettered messages of the source topic, newest first
+func (s *service) List(ctx context.Context, topic string, limit int) ([]dtos.DeadLetterRes, error) {
+	if !slices.Contains(consts.DLQSourceTopics, topic) {
+		return nil, ErrUnknownTopic
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+
+	msgs, err := s.broker.ReadMessages(ctx, kafka.DeadLetterTopic(topic), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dtos.DeadLetterRes, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, deadLetterRes(msg))
+	}
+	return res, nil
+}
+
+// Replay produces the dead lettered messages back onto their source topic with their original key and headers for
+// the group they failed in, it stops at the first message failing and returns the ones replayed before it
+func (s *service) Replay(ctx context.Context, topic string, req dtos.DeadLetterReplayReq) (dtos.DeadLetterReplayRes, error) {
+	res := dtos.DeadLetterReplayRes{Replayed: []dtos.DeadLetterReplayed{}}
+	if !slices.Contains(consts.DLQSourceTopics, topic) {
+		return res, ErrUnknownTopic
+	}
+	if len(req.Messages) == 0 {
+		return res, ErrInvalidReplay
+	}
+
+	for _, ref := range req.Messages {
+		msg, err := s.broker.ReadMessage(ctx, kafka.DeadLetterTopic(topic), ref.Partition, ref.Offset)
+		if err != nil {
+			return res, fmt.Errorf("partition %d offset %d: %w", ref.Partition, ref.Offset, err)
+		}
+
+		source := kafka.Header(msg, consts.DLQSourceTopicHeader)
+		if source == "" {
+			source = topic
+		}
+
+		partition, offset, err := s.broker.ProduceMessage(kafka.NewReplay(msg, source))
+		if err != nil {
+			return res, fmt.Errorf("partition %d offset %d: %w", ref.Partition, ref.Offset, err)
+		}
+		replayed := dtos.DeadLetterReplayed{
+			DeadLetterRef:   ref,
+			SourceTopic:     source,
+			Group:           kafka.Header(msg, consts.DLQGroupHeader),
+			ReplayPartition: partition,
+			ReplayOffset:    offset,
+		}
+		res.Replayed = append(res.Replayed, replayed)
+
+		// the message is replayed already, an unaudited replay stops the ones after it
+		id := fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+		if err := s.audit.Record(ctx, audit.Change{EntityType: entities.AuditDeadLetter, EntityID: id, Action: entities.AuditReplay, After: replayed}); err != nil {
+			return res, fmt.Errorf("audit replay of partition %d offset %d: %w", ref.Partition, ref.Offset, err)
+		}
+	}
+	return res, nil
+}
+
+func deadLetterRes(msg *sarama.ConsumerMessage) dtos.DeadLetterRes {
+	res := dtos.DeadLetterRes{
+		Topic:       msg.Topic,
+		Partition:   msg.Partition,
+		Offset:      msg.Offset,
+		Key:         string(msg.Key),
+		Value:       string(msg.Value),
+		Timestamp:   msg.Timestamp,
+		Error:       kafka.Header(msg, consts.DLQErrorHeader),
+		ErrorType:   kafka.Header(msg, consts.DLQErrorTypeHeader),
+		Group:       kafka.Header(msg, consts.DLQGroupHeader),
+		SourceTopic: kafka.Header(msg, consts.DLQSourceTopicHeader),
+		Headers:     make(map[string]string),
+	}
+	res.Attempts, _ = strconv.Atoi(kafka.Header(msg, consts.DLQAttemptsHeader))
+	if partition, err := strconv.ParseInt(kafka.Header(msg, consts.DLQSourcePartitionHeader), 10, 32); err == nil {
+		res.SourcePartition = int32(partition)
+	}
+	res.SourceOffset, _ = strconv.ParseInt(kafka.Header(msg, consts.DLQSourceOffsetHeader), 10, 64)
+	res.FailedAt, _ = time.Parse(time.RFC3339Nano, kafka.Header(msg, consts.DLQFailedAtHeader))
+
+	for _, h := range kafka.WithoutDeadLetterHeaders(msg.Headers) {
+		res.Headers[string(h.Key)] = string(h.Value)
+	}
+	return res
+}
@@ -0,0 +1,165 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryBroker keeps the topics of a single test in memory, a message offset is its index in the topic
+type memoryBroker struct {
+	topics map[string][]*sarama.ConsumerMessage
+}
+
+func (b *memoryBroker) ReadMessages(ctx context.Context, topic string, limit int) ([]*sarama.ConsumerMessage, error) {
+	msgs := b.topics[topic]
+	res := make([]*sarama.ConsumerMessage, 0, limit)
+	for i := len(msgs) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, msgs[i])
+	}
+	return res, nil
+}
+
+func (b *memoryBroker) ReadMessage(ctx context.Context, topic string, partition int32, offset int64) (*sarama.ConsumerMessage, error) {
+	msgs := b.topics[topic]
+	if partition != 0 || offset < 0 || offset >= int64(len(msgs)) {
+		return nil, kafka.ErrMessageNotFound
+	}
+	return msgs[offset], nil
+}
+
+func (b *memoryBroker) ProduceMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	value, _ := msg.Value.Encode()
+	consumed := &sarama.ConsumerMessage{Topic: msg.Topic, Value: value, Offset: int64(len(b.topics[msg.Topic]))}
+	if msg.Key != nil {
+		consumed.Key, _ = msg.Key.Encode()
+	}
+	for i := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+	}
+	b.topics[msg.Topic] = append(b.topics[msg.Topic], consumed)
+	return 0, consumed.Offset, nil
+}
+
+// recorder keeps the recorded audit changes, err fails every record
+type recorder struct {
+	changes []audit.Change
+	err     error
+}
+
+func (r *recorder) Record(ctx context.Context, change audit.Change) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+// deadLetter dead letters a message of the source topic the way a consumer does
+func deadLetter(b *memoryBroker, source *sarama.ConsumerMessage, err error) {
+	b.ProduceMessage(kafka.NewDeadLetter(source, consts.PgOrderBookGroup, 3, err, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestList(t *testing.T) {
+	broker := &memoryBroker{topics: make(map[string][]*sarama.ConsumerMessage)}
+	deadLetter(broker, &sarama.ConsumerMessage{Topic: consts.PgOrderBookTopic, Partition: 1, Offset: 10, Value: []byte("{")}, kafka.Permanent(errors.New("unexpected end of JSON input")))
+	deadLetter(broker, &sarama.ConsumerMessage{Topic: consts.PgOrderBookTopic, Partition: 0, Offset: 12, Value: []byte("{}")}, errors.New("connection refused"))
+
+	s := NewService(broker, &recorder{})
+
+	res, err := s.List(context.Background(), consts.PgOrderBookTopic, 0)
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+
+	// newest first
+	assert.Equal(t, "connection refused", res[0].Error)
+	assert.Equal(t, kafka.ErrorTypeRetryable, res[0].ErrorType)
+	assert.Equal(t, "unexpected end of JSON input", res[1].Error)
+	assert.Equal(t, kafka.ErrorTypePermanent, res[1].ErrorType)
+	assert.Equal(t, 3, res[1].Attempts)
+	assert.Equal(t, consts.PgOrderBookGroup, res[1].Group)
+	assert.Equal(t, consts.PgOrderBookTopic, res[1].SourceTopic)
+	assert.Equal(t, int32(1), res[1].SourcePartition)
+	assert.Equal(t, int64(10), res[1].SourceOffset)
+	assert.Equal(t, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), res[1].FailedAt)
+
+	res, err = s.List(context.Background(), consts.PgOrderBookTopic, 1)
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+
+	_, err = s.List(context.Background(), "unknown-topic", 0)
+	assert.ErrorIs(t, err, ErrUnknownTopic)
+}
+
+func TestReplay(t *testing.T) {
+	broker := &memoryBroker{topics: make(map[string][]*sarama.ConsumerMessage)}
+	deadLetter(broker, &sarama.ConsumerMessage{
+		Topic:   consts.PgOrderBookTopic,
+		Offset:  12,
+		Key:     []byte("BTCUSDT"),
+		Value:   []byte(`{"id":"1"}`),
+		Headers: []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+	}, errors.New("connection refused"))
+
+	audits := &recorder{}
+	s := NewService(broker, audits)
+
+	res, err := s.Replay(context.Background(), consts.PgOrderBookTopic, dtos.DeadLetterReplayReq{
+		Messages: []dtos.DeadLetterRef{{Partition: 0, Offset: 0}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, res.Replayed, 1)
+	assert.Equal(t, consts.PgOrderBookTopic, res.Replayed[0].SourceTopic)
+
+	replayed := broker.topics[consts.PgOrderBookTopic]
+	assert.Len(t, replayed, 1)
+	assert.Equal(t, "BTCUSDT", string(replayed[0].Key))
+	assert.Equal(t, `{"id":"1"}`, string(replayed[0].Value))
+	assert.Equal(t, "abc", kafka.Header(replayed[0], "trace"))
+	// the replayed message fails afresh, it carries none of the dead letter headers
+	assert.Empty(t, kafka.Header(replayed[0], consts.DLQErrorHeader))
+	// only the group it failed in handles it again
+	assert.Equal(t, consts.PgOrderBookGroup, res.Replayed[0].Group)
+	assert.Equal(t, consts.PgOrderBookGroup, kafka.Header(replayed[0], consts.DLQReplayGroupHeader))
+	assert.Len(t, replayed[0].Headers, 2)
+
+	assert.Len(t, audits.changes, 1)
+	assert.Equal(t, audit.Change{
+		EntityType: entities.AuditDeadLetter,
+		EntityID:   consts.PgOrderBookTopic + consts.DLQSuffix + "/0/0",
+		Action:     entities.AuditReplay,
+		After:      res.Replayed[0],
+	}, audits.changes[0])
+
+	t.Run("Not found", func(t *testing.T) {
+		res, err := s.Replay(context.Background(), consts.PgOrderBookTopic, dtos.DeadLetterReplayReq{
+			Messages: []dtos.DeadLetterRef{{Partition: 0, Offset: 0}, {Partition: 0, Offset: 5}},
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Len(t, res.Replayed, 1)
+	})
+
+	t.Run("Not audited", func(t *testing.T) {
+		s := NewService(broker, &recorder{err: errors.New("connection refused")})
+		res, err := s.Replay(context.Background(), consts.PgOrderBookTopic, dtos.DeadLetterReplayReq{
+			Messages: []dtos.DeadLetterRef{{Partition: 0, Offset: 0}, {Partition: 0, Offset: 0}},
+		})
+		// the first one is replayed already, the second one is not
+		assert.Error(t, err)
+		assert.Len(t, res.Replayed, 1)
+	})
+
+	t.Run("Nothing to replay", func(t *testing.T) {
+		_, err := s.Replay(context.Background(), consts.PgOrderBookTopic, dtos.DeadLetterReplayReq{})
+		assert.ErrorIs(t, err, ErrInvalidReplay)
+	})
+}
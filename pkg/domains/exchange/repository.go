@@ -2,7 +2,9 @@ package exchange
 
 import (
 	"context"
+	"errors"
 
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
 	"gorm.io/gorm"
@@ -29,7 +31,12 @@ func NewRepo(db *gorm.DB) Repository {
 func (r *repository) AddExchange(ctx context.Context, req dtos.AddExchangeReq) (dtos.AddExchangeRes, error) {
 	var exchange entities.Exchange
 	exchange.FromDto(req)
-	err := r.db.WithContext(ctx).Create(&exchange).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&exchange).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditExchange, EntityID: exchange.ID.String(), Action: entities.AuditCreate, After: exchange})
+	})
 	if err != nil {
 		return exchange.ToDto(), err
 	}
@@ -42,16 +49,38 @@ func (r *repository) UpdateExchange(ctx context.Context, req dtos.UpdateExchange
 		return dtos.UpdateExchangeRes{}, err
 	}
 
+	before := exchange
 	exchange.FromDtoUpdate(req)
-	if err := r.db.WithContext(ctx).Where("id = ?", req.ID).Updates(&exchange).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", req.ID).Updates(&exchange).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditExchange, EntityID: req.ID, Action: entities.AuditUpdate, Before: before, After: exchange})
+	})
+	if err != nil {
 		return dtos.UpdateExchangeRes{}, err
 	}
 
 	return exchange.ToDtoUpdate(), nil
 }
 
+// DeleteExchange soft deletes the exchange, deleting a missing exchange is a no-op
 func (r *repository) DeleteExchange(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entities.Exchange{}).Error
+	var exchange entities.Exchange
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&exchange).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Delete(&entities.Exchange{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditExchange, EntityID: id, Action: entities.AuditDelete, Before: exchange})
+	})
 }
 
 func (r *repository) GetExchangeById(ctx context.Context, id string) (dtos.GetExchangeRes, error) {
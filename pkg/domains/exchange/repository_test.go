@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit/audittest"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/exchange"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
@@ -74,6 +75,7 @@ func TestAddExchange(t *testing.T) {
 			"", // RestUrl
 			1,  // IsActive
 		).WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditExchange, entities.AuditCreate, sqlmock.AnyArg())
 	mock.ExpectCommit()
 
 	res, err := repo.AddExchange(context.Background(), req)
@@ -103,6 +105,7 @@ func TestUpdateExchange(t *testing.T) {
 	mock.ExpectExec(`UPDATE "exchanges" SET`).
 		WithArgs(sqlmock.AnyArg(), req.Name, req.WsUrl, sqlmock.AnyArg(), req.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditExchange, entities.AuditUpdate, exchangeID)
 
 	mock.ExpectCommit()
 
@@ -184,11 +187,15 @@ func TestGetAllExchanges(t *testing.T) {
 func TestDeleteExchange(t *testing.T) {
 	db, mock := setupMockDB(t)
 	repo := exchange.NewRepo(db)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "exchanges" WHERE id = $1 AND "exchanges"."deleted_at" IS NULL ORDER BY "exchanges"."id" LIMIT $2`)).
+		WithArgs("550e8400-e29b-41d4-a716-446655440000", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("550e8400-e29b-41d4-a716-446655440000", "Binance"))
 	mock.ExpectBegin()
 	query := regexp.QuoteMeta(`UPDATE "exchanges" SET "deleted_at"=$1 WHERE id = $2 AND "exchanges"."deleted_at" IS NULL`)
 	mock.ExpectExec(query).
 		WithArgs(sqlmock.AnyArg(), "550e8400-e29b-41d4-a716-446655440000").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditExchange, entities.AuditDelete, "550e8400-e29b-41d4-a716-446655440000")
 	mock.ExpectCommit()
 
 	err := repo.DeleteExchange(context.Background(), "550e8400-e29b-41d4-a716-446655440000")
@@ -196,3 +203,16 @@ func TestDeleteExchange(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDeleteMissingExchange(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := exchange.NewRepo(db)
+	mock.ExpectQuery(`SELECT \* FROM "exchanges" WHERE id = \$1`).
+		WithArgs("550e8400-e29b-41d4-a716-446655440000", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	err := repo.DeleteExchange(context.Background(), "550e8400-e29b-41d4-a716-446655440000")
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
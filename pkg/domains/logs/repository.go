@@ -0,0 +1,100 @@
+package logs
+
+import (
+	"context"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+const (
+	// searchDocument is the expression of the idx_logs_search index, a query has to use it as is to hit the index
+	searchDocument = `to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(message, ''))`
+
+	// normalizedMessage replaces the ids, quoted values and numbers of the message so the same error groups
+	// together whatever the symbol, order or address it was logged for
+	normalizedMessage = `regexp_replace(regexp_replace(regexp_replace(coalesce(message, ''),
+		'[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<id>', 'g'),
+		'"[^"]*"|''[^'']*''', '<value>', 'g'),
+		'[0-9]+', '<n>', 'g')`
+)
+
+// Repository only reads the logs, rows are written by the postgres sink of ctlog
+type Repository interface {
+	GetLogs(ctx context.Context, req dtos.LogReq) ([]entities.Log, int64, error)
+	GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error)
+	GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetLogs returns a page of the matching rows, newest first, and the count of all matching rows
+func (r *repository) GetLogs(ctx context.Context, req dtos.LogReq) ([]entities.Log, int64, error) {
+	query := r.filter(ctx, req.Type, req.Entity, req.Symbol, req.From, req.To)
+	if req.Query != "" {
+		query = query.Where(searchDocument+" @@ plainto_tsquery('simple', ?)", req.Query)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []entities.Log
+	err := query.Order("created_at DESC").Offset(int((req.Page - 1) * req.PerPage)).Limit(int(req.PerPage)).Find(&logs).Error
+	return logs, total, err
+}
+
+// GetErrorRate counts the logs of each entity per minute, oldest minute first
+func (r *repository) GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error) {
+	var rates []dtos.ErrorRateRes
+	err := r.filter(ctx, req.Type, req.Entity, req.Symbol, req.From, req.To).
+		Select("entity, date_trunc('minute', created_at) AS minute, count(*) AS count").
+		Group("entity, minute").
+		Order("minute, entity").
+		Scan(&rates).Error
+	return rates, err
+}
+
+// GetTopErrors groups the logs of each entity by normalized message, most frequent first
+func (r *repository) GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error) {
+	var top []dtos.TopErrorRes
+	err := r.filter(ctx, req.Type, req.Entity, req.Symbol, req.From, req.To).
+		Select("entity, " + normalizedMessage + " AS pattern, count(*) AS count, min(created_at) AS first_seen, " +
+			"max(created_at) AS last_seen, max(message) AS sample").
+		Group("entity, pattern").
+		Order("count DESC, last_seen DESC").
+		Limit(req.Limit).
+		Scan(&top).Error
+	return top, err
+}
+
+func (r *repository) filter(ctx context.Context, logType, entity, symbol string, from, to time.Time) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&entities.Log{})
+	if logType != "" {
+		query = query.Where("type = ?", logType)
+	}
+	if entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+	if symbol != "" {
+		query = query.Where("fields->>'symbol' = ?", symbol)
+	}
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	return query
+}
@@ -0,0 +1,109 @@
+package logs_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/logs"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+func TestGetLogsRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := logs.NewRepo(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fields := `{"symbol":"BTCUSDT"}`
+
+	where := `WHERE type = $1 AND entity = $2 AND fields->>'symbol' = $3 AND created_at >= $4 AND ` +
+		`to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(message, '')) @@ plainto_tsquery('simple', $5) AND "logs"."deleted_at" IS NULL`
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "logs" `+where)).
+		WithArgs("error", "stream", "BTCUSDT", from, "websocket read").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "logs" `+where+` ORDER BY created_at DESC LIMIT $6 OFFSET $7`)).
+		WithArgs("error", "stream", "BTCUSDT", from, "websocket read", 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "type", "entity", "fields"}).
+			AddRow(uuid.New(), "WebSocket read error", "error", "stream", fields))
+
+	rows, total, err := repo.GetLogs(context.Background(), dtos.LogReq{
+		Type:    "error",
+		Entity:  "stream",
+		Symbol:  "BTCUSDT",
+		Query:   "websocket read",
+		From:    from,
+		Page:    2,
+		PerPage: 2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, fields, *rows[0].Fields)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetErrorRateRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := logs.NewRepo(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT entity, date_trunc('minute', created_at) AS minute, count(*) AS count FROM "logs" `+
+		`WHERE type = $1 AND created_at >= $2 AND created_at < $3 AND "logs"."deleted_at" IS NULL GROUP BY entity, minute ORDER BY minute, entity`)).
+		WithArgs("error", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"entity", "minute", "count"}).
+			AddRow("stream", from, 12).
+			AddRow("signal", from.Add(time.Minute), 1))
+
+	rates, err := repo.GetErrorRate(context.Background(), dtos.LogStatsReq{Type: "error", From: from, To: to})
+	assert.NoError(t, err)
+	assert.Equal(t, []dtos.ErrorRateRes{
+		{Entity: "stream", Minute: from, Count: 12},
+		{Entity: "signal", Minute: from.Add(time.Minute), Count: 1},
+	}, rates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTopErrorsRepo(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := logs.NewRepo(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	mock.ExpectQuery(`SELECT entity, regexp_replace\(.+\) AS pattern, count\(\*\) AS count, min\(created_at\) AS first_seen, max\(created_at\) AS last_seen, max\(message\) AS sample FROM "logs" `+
+		regexp.QuoteMeta(`WHERE type = $1 AND entity = $2 AND created_at >= $3 AND created_at < $4 AND "logs"."deleted_at" IS NULL GROUP BY entity, pattern ORDER BY count DESC, last_seen DESC LIMIT $5`)).
+		WithArgs("error", "stream", from, to, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"entity", "pattern", "count", "first_seen", "last_seen", "sample"}).
+			AddRow("stream", "WebSocket read error: read tcp <n>.<n>.<n>.<n>:<n>: i/o timeout", 40, from, to, "WebSocket read error: read tcp 10.0.0.1:443: i/o timeout"))
+
+	top, err := repo.GetTopErrors(context.Background(), dtos.LogStatsReq{Type: "error", Entity: "stream", From: from, To: to, Limit: 20})
+	assert.NoError(t, err)
+	assert.Len(t, top, 1)
+	assert.Equal(t, int64(40), top[0].Count)
+	assert.Equal(t, "WebSocket read error: read tcp 10.0.0.1:443: i/o timeout", top[0].Sample)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
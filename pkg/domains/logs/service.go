@@ -0,0 +1,131 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+var ErrInvalidRange = errors.New(consts.LogInvalidRange)
+
+const (
+	defaultPerPage   = 50
+	maxPerPage       = 500
+	defaultStatsType = "error"
+	defaultWindow    = time.Hour
+	defaultLogWindow = 24 * time.Hour
+	maxWindow        = 7 * 24 * time.Hour
+	defaultTopErrors = 20
+	maxTopErrors     = 100
+)
+
+// now is swapped in tests
+var now = time.Now
+
+type Service interface {
+	GetLogs(ctx context.Context, req dtos.LogReq) (dtos.PaginatedData, error)
+	GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error)
+	GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error)
+}
+
+type service struct {
+	repository Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{
+		repository: r,
+	}
+}
+
+// GetLogs searches the last day unless asked otherwise, a range is at most 7 days so a search can not scan every
+// partition of the table
+func (s *service) GetLogs(ctx context.Context, req dtos.LogReq) (dtos.PaginatedData, error) {
+	if req.To.IsZero() {
+		req.To = now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-defaultLogWindow)
+	}
+	if !req.To.After(req.From) || req.To.Sub(req.From) > maxWindow {
+		return dtos.PaginatedData{}, ErrInvalidRange
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PerPage < 1 {
+		req.PerPage = defaultPerPage
+	}
+	req.PerPage = min(req.PerPage, maxPerPage)
+
+	logs, total, err := s.repository.GetLogs(ctx, req)
+	if err != nil {
+		return dtos.PaginatedData{}, err
+	}
+	rows := make([]dtos.LogRes, 0, len(logs))
+	for _, l := range logs {
+		rows = append(rows, l.ToDto())
+	}
+	return dtos.PaginatedData{
+		Page:       req.Page,
+		PerPage:    req.PerPage,
+		Total:      total,
+		TotalPages: int((total + req.PerPage - 1) / req.PerPage),
+		Rows:       rows,
+	}, nil
+}
+
+func (s *service) GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error) {
+	req, err := statsDefaults(req)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.repository.GetErrorRate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if rates == nil {
+		rates = []dtos.ErrorRateRes{}
+	}
+	return rates, nil
+}
+
+func (s *service) GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error) {
+	req, err := statsDefaults(req)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.repository.GetTopErrors(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if top == nil {
+		top = []dtos.TopErrorRes{}
+	}
+	return top, nil
+}
+
+// statsDefaults counts the errors of the last hour unless asked otherwise, a range is at most 7 days so an aggregation
+// can not scan every partition of the table
+func statsDefaults(req dtos.LogStatsReq) (dtos.LogStatsReq, error) {
+	if req.Type == "" {
+		req.Type = defaultStatsType
+	}
+	if req.To.IsZero() {
+		req.To = now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-defaultWindow)
+	}
+	if !req.To.After(req.From) || req.To.Sub(req.From) > maxWindow {
+		return req, ErrInvalidRange
+	}
+	if req.Limit < 1 {
+		req.Limit = defaultTopErrors
+	}
+	req.Limit = min(req.Limit, maxTopErrors)
+	return req, nil
+}
@@ -0,0 +1,106 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetLogs(ctx context.Context, req dtos.LogReq) ([]entities.Log, int64, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]entities.Log), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRepository) GetErrorRate(ctx context.Context, req dtos.LogStatsReq) ([]dtos.ErrorRateRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]dtos.ErrorRateRes), args.Error(1)
+}
+
+func (m *MockRepository) GetTopErrors(ctx context.Context, req dtos.LogStatsReq) ([]dtos.TopErrorRes, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).([]dtos.TopErrorRes), args.Error(1)
+}
+
+func TestGetLogs(t *testing.T) {
+	fields := `{"symbol":"BTCUSDT"}`
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return at }
+	defer func() { now = time.Now }()
+
+	t.Run("Defaults", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+		mockRepo.On("GetLogs", mock.Anything, dtos.LogReq{Query: "timeout", From: at.Add(-24 * time.Hour), To: at, Page: 1, PerPage: 50}).
+			Return([]entities.Log{{Title: "WebSocket read error", Type: "error", Fields: &fields}}, int64(51), nil)
+
+		res, err := service.GetLogs(context.Background(), dtos.LogReq{Query: "timeout"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, res.TotalPages)
+
+		rows := res.Rows.([]dtos.LogRes)
+		assert.Len(t, rows, 1)
+		assert.JSONEq(t, fields, string(rows[0].Fields))
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		service := NewService(new(MockRepository))
+
+		_, err := service.GetLogs(context.Background(), dtos.LogReq{From: at, To: at})
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+
+	t.Run("Range is capped", func(t *testing.T) {
+		service := NewService(new(MockRepository))
+
+		_, err := service.GetLogs(context.Background(), dtos.LogReq{From: at.Add(-8 * 24 * time.Hour)})
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
+
+func TestGetErrorRate(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return at }
+	defer func() { now = time.Now }()
+
+	t.Run("Last hour of errors by default", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+		mockRepo.On("GetErrorRate", mock.Anything, dtos.LogStatsReq{Type: "error", Entity: "stream", From: at.Add(-time.Hour), To: at, Limit: 20}).
+			Return([]dtos.ErrorRateRes(nil), nil)
+
+		rates, err := service.GetErrorRate(context.Background(), dtos.LogStatsReq{Entity: "stream"})
+		assert.NoError(t, err)
+		assert.NotNil(t, rates, "an empty range is an empty list")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Range is capped", func(t *testing.T) {
+		service := NewService(new(MockRepository))
+
+		_, err := service.GetErrorRate(context.Background(), dtos.LogStatsReq{From: at.Add(-8 * 24 * time.Hour)})
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+}
+
+func TestGetTopErrors(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo)
+	mockRepo.On("GetTopErrors", mock.Anything, dtos.LogStatsReq{Type: "warn", From: from, To: to, Limit: 100}).
+		Return([]dtos.TopErrorRes{{Entity: "stream", Pattern: "Reconnecting in <n>s", Count: 9}}, nil)
+
+	top, err := service.GetTopErrors(context.Background(), dtos.LogStatsReq{Type: "warn", From: from, To: to, Limit: 1000})
+	assert.NoError(t, err)
+	assert.Len(t, top, 1)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,89 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/shopspring/decimal"
+)
+
+// Book is the price -> amount state of both sides rebuilt from snapshots and level changes
+type Book struct {
+	bids map[string]string
+	asks map[string]string
+}
+
+func NewBook(bids, asks [][]string) *Book {
+	b := &Book{
+		bids: make(map[string]string, len(bids)),
+		asks: make(map[string]string, len(asks)),
+	}
+	for _, level := range bids {
+		b.set(b.bids, level[0], level[1])
+	}
+	for _, level := range asks {
+		b.set(b.asks, level[0], level[1])
+	}
+	return b
+}
+
+// Apply replays a level change, closed or empty levels leave the book
+func (b *Book) Apply(change entities.OrderBookHistory) {
+	side := b.asks
+	if change.Side == "bid" {
+		side = b.bids
+	}
+
+	if change.Status == consts.ClosedOrder {
+		delete(side, change.Price)
+		return
+	}
+	b.set(side, change.Price, change.Amount)
+}
+
+func (b *Book) set(side map[string]string, price, amount string) {
+	if a, err := decimal.NewFromString(amount); err != nil || a.IsZero() {
+		delete(side, price)
+		return
+	}
+	side[price] = amount
+}
+
+// Levels returns up to depth best levels of both sides, bids descending and asks ascending by price
+func (b *Book) Levels(depth int) (bids, asks [][]string) {
+	return top(b.bids, depth, true), top(b.asks, depth, false)
+}
+
+func top(side map[string]string, depth int, desc bool) [][]string {
+	type level struct {
+		price  decimal.Decimal
+		raw    string
+		amount string
+	}
+
+	levels := make([]level, 0, len(side))
+	for price, amount := range side {
+		p, err := decimal.NewFromString(price)
+		if err != nil {
+			continue
+		}
+		levels = append(levels, level{price: p, raw: price, amount: amount})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if desc {
+			return levels[i].price.GreaterThan(levels[j].price)
+		}
+		return levels[i].price.LessThan(levels[j].price)
+	})
+
+	if depth > 0 && len(levels) > depth {
+		levels = levels[:depth]
+	}
+
+	res := make([][]string, 0, len(levels))
+	for _, l := range levels {
+		res = append(res, []string{l.raw, l.amount})
+	}
+	return res
+}
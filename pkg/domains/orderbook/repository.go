@@ -0,0 +1,90 @@
+package orderbook
+
+import (
+	"context"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Market is an exchange symbol pair having book history
+type Market struct {
+	ExchangeId string
+	Symbol     string
+}
+
+type Repository interface {
+	GetMarkets(ctx context.Context, from, to time.Time) ([]Market, error)
+	GetHistory(ctx context.Context, exchangeID, symbol string, from, to time.Time) ([]entities.OrderBookHistory, error)
+	GetLatestSnapshot(ctx context.Context, exchangeID, symbol, resolution string) (entities.OrderBookSnapshot, error)
+	GetSnapshotAt(ctx context.Context, exchangeID, symbol string, at time.Time) (entities.OrderBookSnapshot, error)
+	SaveSnapshots(ctx context.Context, snapshots []entities.OrderBookSnapshot) error
+	DeleteSnapshots(ctx context.Context, resolution string, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetMarkets returns the markets having level changes between from and to
+func (r *repository) GetMarkets(ctx context.Context, from, to time.Time) ([]Market, error) {
+	var markets []Market
+	err := r.db.WithContext(ctx).Model(&entities.OrderBookHistory{}).
+		Distinct("exchange_id", "symbol").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Find(&markets).Error
+	return markets, err
+}
+
+// GetHistory returns the level changes made in [from, to) in the order they happened
+func (r *repository) GetHistory(ctx context.Context, exchangeID, symbol string, from, to time.Time) ([]entities.OrderBookHistory, error) {
+	var changes []entities.OrderBookHistory
+	err := r.db.WithContext(ctx).
+		Where("exchange_id = ? AND symbol = ? AND created_at >= ? AND created_at < ?", exchangeID, symbol, from, to).
+		Order("created_at asc").
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *repository) GetLatestSnapshot(ctx context.Context, exchangeID, symbol, resolution string) (entities.OrderBookSnapshot, error) {
+	var snapshot entities.OrderBookSnapshot
+	err := r.db.WithContext(ctx).
+		Where("exchange_id = ? AND symbol = ? AND resolution = ?", exchangeID, symbol, resolution).
+		Order("taken_at desc").
+		First(&snapshot).Error
+	return snapshot, err
+}
+
+// GetSnapshotAt returns the latest snapshot of any resolution taken at or before at
+func (r *repository) GetSnapshotAt(ctx context.Context, exchangeID, symbol string, at time.Time) (entities.OrderBookSnapshot, error) {
+	var snapshot entities.OrderBookSnapshot
+	err := r.db.WithContext(ctx).
+		Where("exchange_id = ? AND symbol = ? AND taken_at <= ?", exchangeID, symbol, at).
+		Order("taken_at desc").
+		First(&snapshot).Error
+	return snapshot, err
+}
+
+func (r *repository) SaveSnapshots(ctx context.Context, snapshots []entities.OrderBookSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: entities.OrderBookSnapshotKey, DoNothing: true}).
+		CreateInBatches(&snapshots, 500).Error
+}
+
+func (r *repository) DeleteSnapshots(ctx context.Context, resolution string, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Unscoped().
+		Where("resolution = ? AND taken_at < ?", resolution, before).
+		Delete(&entities.OrderBookSnapshot{})
+	return res.RowsAffected, res.Error
+}
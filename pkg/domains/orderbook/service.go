@@ -0,0 +1,250 @@
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDepth = 50
+	// compactionLookback is how far back a market without snapshots and a book request without a snapshot are replayed
+	compactionLookback = 24 * time.Hour
+	// compactionWindow bounds the history loaded at once, it is a multiple of the sub hour resolutions
+	compactionWindow = 10 * time.Minute
+)
+
+var (
+	ErrBookNotFound   = errors.New(consts.OrderBookNotFound)
+	ErrInvalidRequest = errors.New(consts.OrderBookInvalidReq)
+)
+
+type Service interface {
+	Compact(ctx context.Context, now time.Time) (dtos.CompactionRes, error)
+	GetBookAt(ctx context.Context, req dtos.OrderBookAtReq) (dtos.OrderBookAtRes, error)
+}
+
+type service struct {
+	repository  Repository
+	depth       int // levels per side a book request returns by default, snapshots keep the whole book
+	resolutions []config.SnapshotResolution
+}
+
+func NewService(r Repository, cfg config.Compaction) Service {
+	depth := cfg.Depth
+	if depth <= 0 {
+		depth = defaultDepth
+	}
+	return &service{
+		repository:  r,
+		depth:       depth,
+		resolutions: cfg.Resolutions,
+	}
+}
+
+// StartCompaction compacts the book history every interval until ctx is done
+func StartCompaction(ctx context.Context, s Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := s.Compact(ctx, time.Now())
+		if err != nil {
+			log.Printf("Order book compaction finished with errors: %v", err)
+		}
+		log.Printf("Order book compaction wrote %v snapshots, pruned %v", res.Snapshots, res.Pruned)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact turns the level changes of every complete bucket into snapshots of each resolution, then prunes the expired snapshots
+func (s *service) Compact(ctx context.Context, now time.Time) (dtos.CompactionRes, error) {
+	res := dtos.CompactionRes{
+		Snapshots: make(map[string]int),
+		Pruned:    make(map[string]int64),
+	}
+
+	var errs []error
+	for _, resolution := range s.resolutions {
+		step, err := candlestick.IntervalDuration(resolution.Resolution)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// the bucket in progress is left for the next run
+		cutoff := now.Truncate(step)
+		markets, err := s.repository.GetMarkets(ctx, cutoff.Add(-compactionLookback), cutoff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, market := range markets {
+			written, err := s.compact(ctx, market, resolution.Resolution, step, cutoff)
+			res.Snapshots[resolution.Resolution] += written
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s %s: %w", market.ExchangeId, market.Symbol, resolution.Resolution, err))
+			}
+		}
+
+		if resolution.Keep > 0 {
+			pruned, err := s.repository.DeleteSnapshots(ctx, resolution.Resolution, now.Add(-time.Duration(resolution.Keep)*time.Hour))
+			if err != nil {
+				errs = append(errs, err)
+			}
+			res.Pruned[resolution.Resolution] = pruned
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// compact continues from the latest snapshot of the resolution, without one the book is rebuilt from the lookback
+func (s *service) compact(ctx context.Context, market Market, resolution string, step time.Duration, cutoff time.Time) (int, error) {
+	book := NewBook(nil, nil)
+	from := cutoff.Add(-compactionLookback)
+
+	last, err := s.repository.GetLatestSnapshot(ctx, market.ExchangeId, market.Symbol, resolution)
+	switch {
+	case err == nil:
+		bids, asks, err := last.Levels()
+		if err != nil {
+			return 0, err
+		}
+		book = NewBook(bids, asks)
+		from = last.TakenAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	window := compactionWindow
+	if step > window {
+		window = step
+	}
+
+	written := 0
+	for start := from; start.Before(cutoff); {
+		end := start.Truncate(window).Add(window)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+
+		changes, err := s.repository.GetHistory(ctx, market.ExchangeId, market.Symbol, start, end)
+		if err != nil {
+			return written, err
+		}
+
+		snapshots := s.replay(market, resolution, step, book, changes)
+		if err := s.repository.SaveSnapshots(ctx, snapshots); err != nil {
+			return written, err
+		}
+		written += len(snapshots)
+		start = end
+	}
+	return written, nil
+}
+
+// replay applies the changes to the book and snapshots it at the end of every bucket having changes
+func (s *service) replay(market Market, resolution string, step time.Duration, book *Book, changes []entities.OrderBookHistory) []entities.OrderBookSnapshot {
+	var (
+		snapshots []entities.OrderBookSnapshot
+		bucket    time.Time
+	)
+	for _, change := range changes {
+		end := change.CreatedAt.Truncate(step).Add(step)
+		if !bucket.IsZero() && !end.Equal(bucket) {
+			snapshots = append(snapshots, s.snapshot(market, resolution, bucket, book))
+		}
+		bucket = end
+		book.Apply(change)
+	}
+	if !bucket.IsZero() {
+		snapshots = append(snapshots, s.snapshot(market, resolution, bucket, book))
+	}
+	return snapshots
+}
+
+func (s *service) snapshot(market Market, resolution string, takenAt time.Time, book *Book) entities.OrderBookSnapshot {
+	snapshot := entities.OrderBookSnapshot{
+		ExchangeId: market.ExchangeId,
+		Symbol:     market.Symbol,
+		Resolution: resolution,
+		TakenAt:    takenAt.UTC(),
+	}
+	// the next run replays from the snapshot, levels cut from it would never come back once they move to the top
+	snapshot.SetLevels(book.Levels(0))
+	return snapshot
+}
+
+// GetBookAt rebuilds the book at the requested time from the nearest earlier snapshot and the level changes made since
+func (s *service) GetBookAt(ctx context.Context, req dtos.OrderBookAtReq) (dtos.OrderBookAtRes, error) {
+	if req.ExchangeID == "" || req.Symbol == "" {
+		return dtos.OrderBookAtRes{}, ErrInvalidRequest
+	}
+
+	at := req.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	depth := req.Depth
+	if depth <= 0 {
+		depth = s.depth
+	}
+
+	// depth stream frames carry the symbol in upper case
+	symbol := strings.ToUpper(req.Symbol)
+	res := dtos.OrderBookAtRes{
+		ExchangeID: req.ExchangeID,
+		Symbol:     symbol,
+		At:         at,
+	}
+
+	book := NewBook(nil, nil)
+	from := at.Add(-compactionLookback)
+
+	snapshot, err := s.repository.GetSnapshotAt(ctx, req.ExchangeID, symbol, at)
+	switch {
+	case err == nil:
+		bids, asks, err := snapshot.Levels()
+		if err != nil {
+			return res, err
+		}
+		book = NewBook(bids, asks)
+		from = snapshot.TakenAt
+		res.SnapshotAt = &snapshot.TakenAt
+		res.SnapshotResolution = snapshot.Resolution
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return res, err
+	}
+
+	// changes made at the requested time are part of the book
+	changes, err := s.repository.GetHistory(ctx, req.ExchangeID, symbol, from, at.Add(time.Microsecond))
+	if err != nil {
+		return res, err
+	}
+	if res.SnapshotAt == nil && len(changes) == 0 {
+		return res, ErrBookNotFound
+	}
+
+	for _, change := range changes {
+		book.Apply(change)
+	}
+	res.Diffs = len(changes)
+	res.Bids, res.Asks = book.Levels(depth)
+	return res, nil
+}
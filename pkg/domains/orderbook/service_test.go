@@ -0,0 +1,297 @@
+package orderbook
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryRepository keeps the history and snapshots of a single test in memory
+type memoryRepository struct {
+	history   []entities.OrderBookHistory
+	snapshots []entities.OrderBookSnapshot
+}
+
+func (r *memoryRepository) GetMarkets(ctx context.Context, from, to time.Time) ([]Market, error) {
+	seen := make(map[Market]bool)
+	var markets []Market
+	for _, change := range r.history {
+		market := Market{ExchangeId: change.ExchangeId, Symbol: change.Symbol}
+		if !change.CreatedAt.Before(from) && change.CreatedAt.Before(to) && !seen[market] {
+			seen[market] = true
+			markets = append(markets, market)
+		}
+	}
+	return markets, nil
+}
+
+func (r *memoryRepository) GetHistory(ctx context.Context, exchangeID, symbol string, from, to time.Time) ([]entities.OrderBookHistory, error) {
+	var changes []entities.OrderBookHistory
+	for _, change := range r.history {
+		if change.ExchangeId == exchangeID && change.Symbol == symbol && !change.CreatedAt.Before(from) && change.CreatedAt.Before(to) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (r *memoryRepository) GetLatestSnapshot(ctx context.Context, exchangeID, symbol, resolution string) (entities.OrderBookSnapshot, error) {
+	return r.latest(func(s entities.OrderBookSnapshot) bool {
+		return s.ExchangeId == exchangeID && s.Symbol == symbol && s.Resolution == resolution
+	})
+}
+
+func (r *memoryRepository) GetSnapshotAt(ctx context.Context, exchangeID, symbol string, at time.Time) (entities.OrderBookSnapshot, error) {
+	return r.latest(func(s entities.OrderBookSnapshot) bool {
+		return s.ExchangeId == exchangeID && s.Symbol == symbol && !s.TakenAt.After(at)
+	})
+}
+
+func (r *memoryRepository) latest(match func(entities.OrderBookSnapshot) bool) (entities.OrderBookSnapshot, error) {
+	var (
+		found    entities.OrderBookSnapshot
+		hasFound bool
+	)
+	for _, s := range r.snapshots {
+		if match(s) && (!hasFound || s.TakenAt.After(found.TakenAt)) {
+			found, hasFound = s, true
+		}
+	}
+	if !hasFound {
+		return found, gorm.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+func (r *memoryRepository) SaveSnapshots(ctx context.Context, snapshots []entities.OrderBookSnapshot) error {
+	r.snapshots = append(r.snapshots, snapshots...)
+	return nil
+}
+
+func (r *memoryRepository) DeleteSnapshots(ctx context.Context, resolution string, before time.Time) (int64, error) {
+	var (
+		kept    []entities.OrderBookSnapshot
+		deleted int64
+	)
+	for _, s := range r.snapshots {
+		if s.Resolution == resolution && s.TakenAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, s)
+	}
+	r.snapshots = kept
+	return deleted, nil
+}
+
+func change(at time.Time, side, price, amount string) entities.OrderBookHistory {
+	status := consts.ActiveOrder
+	if amount == "0" {
+		status = consts.ClosedOrder
+	}
+	c := entities.OrderBookHistory{ExchangeId: "binance", Symbol: "BTCUSDT", Side: side, Price: price, Amount: amount, Status: status}
+	c.CreatedAt = at
+	return c
+}
+
+func snapshotsOf(r *memoryRepository, resolution string) []entities.OrderBookSnapshot {
+	var res []entities.OrderBookSnapshot
+	for _, s := range r.snapshots {
+		if s.Resolution == resolution {
+			res = append(res, s)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].TakenAt.Before(res[j].TakenAt) })
+	return res
+}
+
+func TestCompact(t *testing.T) {
+	base := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{history: []entities.OrderBookHistory{
+		change(base.Add(100*time.Millisecond), "bid", "100.00", "1"),
+		change(base.Add(200*time.Millisecond), "ask", "101.00", "2"),
+		change(base.Add(1500*time.Millisecond), "bid", "99.50", "3"),
+		change(base.Add(1700*time.Millisecond), "bid", "100.00", "0"),
+		change(base.Add(70*time.Second), "ask", "101.00", "5"),
+		// the bucket in progress is not compacted
+		change(base.Add(90*time.Second), "ask", "102.00", "1"),
+	}}
+
+	s := NewService(repo, config.Compaction{
+		Depth: 50,
+		Resolutions: []config.SnapshotResolution{
+			{Resolution: "1s"},
+			{Resolution: "1m"},
+		},
+	})
+
+	now := base.Add(90*time.Second + 500*time.Millisecond)
+	res, err := s.Compact(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res.Snapshots["1s"])
+	assert.Equal(t, 1, res.Snapshots["1m"])
+
+	seconds := snapshotsOf(repo, "1s")
+	assert.Len(t, seconds, 3)
+	assert.Equal(t, base.Add(time.Second), seconds[0].TakenAt)
+	bids, asks, err := seconds[0].Levels()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"100.00", "1"}}, bids)
+	assert.Equal(t, [][]string{{"101.00", "2"}}, asks)
+
+	assert.Equal(t, base.Add(2*time.Second), seconds[1].TakenAt)
+	bids, _, _ = seconds[1].Levels()
+	assert.Equal(t, [][]string{{"99.50", "3"}}, bids)
+
+	assert.Equal(t, base.Add(71*time.Second), seconds[2].TakenAt)
+	_, asks, _ = seconds[2].Levels()
+	assert.Equal(t, [][]string{{"101.00", "5"}}, asks)
+
+	minutes := snapshotsOf(repo, "1m")
+	assert.Equal(t, base.Add(time.Minute), minutes[0].TakenAt)
+
+	// the next run continues from the latest snapshots
+	res, err = s.Compact(context.Background(), base.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Snapshots["1s"])
+	assert.Equal(t, 1, res.Snapshots["1m"])
+
+	minutes = snapshotsOf(repo, "1m")
+	assert.Len(t, minutes, 2)
+	bids, asks, _ = minutes[1].Levels()
+	assert.Equal(t, [][]string{{"99.50", "3"}}, bids)
+	assert.Equal(t, [][]string{{"101.00", "5"}, {"102.00", "1"}}, asks)
+}
+
+func TestCompactKeepsDeepLevels(t *testing.T) {
+	base := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{history: []entities.OrderBookHistory{
+		change(base.Add(100*time.Millisecond), "bid", "100.00", "1"),
+		change(base.Add(200*time.Millisecond), "bid", "99.00", "2"),
+		change(base.Add(300*time.Millisecond), "bid", "98.00", "3"),
+	}}
+	s := NewService(repo, config.Compaction{
+		Depth:       1,
+		Resolutions: []config.SnapshotResolution{{Resolution: "1s"}},
+	})
+
+	_, err := s.Compact(context.Background(), base.Add(time.Second))
+	assert.NoError(t, err)
+
+	// the best levels close after the first run, the next one replays from its snapshot
+	repo.history = append(repo.history,
+		change(base.Add(1100*time.Millisecond), "bid", "100.00", "0"),
+		change(base.Add(1200*time.Millisecond), "bid", "99.00", "0"),
+	)
+	_, err = s.Compact(context.Background(), base.Add(2*time.Second))
+	assert.NoError(t, err)
+
+	seconds := snapshotsOf(repo, "1s")
+	assert.Len(t, seconds, 2)
+	bids, _, err := seconds[1].Levels()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"98.00", "3"}}, bids)
+
+	// the served book is cut to the depth
+	res, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{ExchangeID: "binance", Symbol: "BTCUSDT", At: base.Add(time.Second)})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"100.00", "1"}}, res.Bids)
+}
+
+func TestCompactPrunes(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	repo := &memoryRepository{snapshots: []entities.OrderBookSnapshot{
+		{Resolution: "1s", TakenAt: now.Add(-7 * time.Hour)},
+		{Resolution: "1s", TakenAt: now.Add(-time.Hour)},
+		{Resolution: "1m", TakenAt: now.Add(-7 * time.Hour)},
+	}}
+
+	s := NewService(repo, config.Compaction{
+		Resolutions: []config.SnapshotResolution{
+			{Resolution: "1s", Keep: 6},
+			{Resolution: "1m", Keep: 168},
+		},
+	})
+
+	res, err := s.Compact(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Pruned["1s"])
+	assert.Equal(t, int64(0), res.Pruned["1m"])
+	assert.Len(t, repo.snapshots, 2)
+}
+
+func TestGetBookAt(t *testing.T) {
+	base := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	snapshot := entities.OrderBookSnapshot{ExchangeId: "binance", Symbol: "BTCUSDT", Resolution: "1m", TakenAt: base}
+	snapshot.SetLevels([][]string{{"100.00", "1"}, {"99.00", "2"}}, [][]string{{"101.00", "1"}})
+
+	repo := &memoryRepository{
+		snapshots: []entities.OrderBookSnapshot{snapshot},
+		history: []entities.OrderBookHistory{
+			change(base.Add(-time.Second), "bid", "98.00", "4"),
+			change(base.Add(10*time.Second), "bid", "100.00", "0"),
+			change(base.Add(20*time.Second), "bid", "99.50", "1"),
+			change(base.Add(40*time.Second), "ask", "100.50", "1"),
+		},
+	}
+	s := NewService(repo, config.Compaction{Depth: 50})
+
+	t.Run("Snapshot plus diffs", func(t *testing.T) {
+		res, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{
+			ExchangeID: "binance",
+			Symbol:     "btcusdt",
+			At:         base.Add(20 * time.Second),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, base, *res.SnapshotAt)
+		assert.Equal(t, "1m", res.SnapshotResolution)
+		assert.Equal(t, 2, res.Diffs)
+		assert.Equal(t, [][]string{{"99.50", "1"}, {"99.00", "2"}}, res.Bids)
+		assert.Equal(t, [][]string{{"101.00", "1"}}, res.Asks)
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		res, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{
+			ExchangeID: "binance",
+			Symbol:     "BTCUSDT",
+			At:         base.Add(time.Minute),
+			Depth:      1,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"99.50", "1"}}, res.Bids)
+		assert.Equal(t, [][]string{{"100.50", "1"}}, res.Asks)
+	})
+
+	t.Run("Diffs only", func(t *testing.T) {
+		res, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{
+			ExchangeID: "binance",
+			Symbol:     "BTCUSDT",
+			At:         base.Add(-time.Millisecond),
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, res.SnapshotAt)
+		assert.Equal(t, [][]string{{"98.00", "4"}}, res.Bids)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{
+			ExchangeID: "binance",
+			Symbol:     "ETHUSDT",
+			At:         base,
+		})
+		assert.ErrorIs(t, err, ErrBookNotFound)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := s.GetBookAt(context.Background(), dtos.OrderBookAtReq{Symbol: "BTCUSDT"})
+		assert.ErrorIs(t, err, ErrInvalidRequest)
+	})
+}
@@ -2,7 +2,9 @@ package signal
 
 import (
 	"context"
+	"errors"
 
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
 	"gorm.io/gorm"
@@ -29,7 +31,12 @@ func NewRepo(db *gorm.DB) Repository {
 func (r *repository) AddSignalIntervals(ctx context.Context, req dtos.AddSignalIntervalReq) (dtos.AddSignalIntervalRes, error) {
 	var signal entities.SignalInterval
 	signal.FromDto(&req)
-	err := r.db.WithContext(ctx).Create(&signal).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&signal).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSignalInterval, EntityID: signal.ID.String(), Action: entities.AuditCreate, After: signal})
+	})
 	if err != nil {
 		return signal.ToDto(), err
 	}
@@ -58,8 +65,23 @@ func (r *repository) GetAllSignalIntervals(ctx context.Context) ([]dtos.GetSigna
 	return result, nil
 }
 
+// DeleteSignalInterval soft deletes the interval, deleting a missing interval is a no-op
 func (r *repository) DeleteSignalInterval(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entities.SignalInterval{}).Error
+	var signal entities.SignalInterval
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&signal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Delete(&entities.SignalInterval{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSignalInterval, EntityID: id, Action: entities.AuditDelete, Before: signal})
+	})
 }
 
 func (r *repository) UpdateSignalInterval(ctx context.Context, req dtos.UpdateSignalIntervalReq) (dtos.UpdateSignalIntervalRes, error) {
@@ -69,8 +91,14 @@ func (r *repository) UpdateSignalInterval(ctx context.Context, req dtos.UpdateSi
 		return dtos.UpdateSignalIntervalRes{}, err
 	}
 
+	before := signal
 	signal.UpdateFromDto(req)
-	err = r.db.WithContext(ctx).Where("id = ?", req.ID).Updates(&signal).Error
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", req.ID).Updates(&signal).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSignalInterval, EntityID: req.ID, Action: entities.AuditUpdate, Before: before, After: signal})
+	})
 	if err != nil {
 		return dtos.UpdateSignalIntervalRes{}, err
 	}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit/audittest"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/signal"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
@@ -63,6 +64,7 @@ func TestAddSignalIntervals(t *testing.T) {
 			"550e8400-e29b-41d4-a716-446655440000", // ExchangeID (uuid)
 			1,                                      // IsActive
 		).WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSignalInterval, entities.AuditCreate, sqlmock.AnyArg())
 	mock.ExpectCommit()
 
 	res, err := repo.AddSignalIntervals(context.Background(), req)
@@ -98,6 +100,7 @@ func TestUpdateSignalInterval(t *testing.T) {
 	mock.ExpectExec(`UPDATE "signal_intervals" SET`).
 		WithArgs(sqlmock.AnyArg(), "ethusdt", "5m", req.ExchangeId, req.ID, req.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSignalInterval, entities.AuditUpdate, req.ID)
 
 	mock.ExpectCommit()
 
@@ -115,11 +118,15 @@ func TestDeleteSignalInterval(t *testing.T) {
 	db, mock := setupMockDB(t)
 	repo := signal.NewRepo(db)
 
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "signal_intervals" WHERE id = $1 AND "signal_intervals"."deleted_at" IS NULL ORDER BY "signal_intervals"."id" LIMIT $2`)).
+		WithArgs("750e8400-e29b-41d4-a716-446655440000", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "interval"}).AddRow("750e8400-e29b-41d4-a716-446655440000", "1m"))
 	mock.ExpectBegin()
 	query := regexp.QuoteMeta(`UPDATE "signal_intervals" SET "deleted_at"=$1 WHERE id = $2 AND "signal_intervals"."deleted_at" IS NULL`)
 	mock.ExpectExec(query).
 		WithArgs(sqlmock.AnyArg(), "750e8400-e29b-41d4-a716-446655440000").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSignalInterval, entities.AuditDelete, "750e8400-e29b-41d4-a716-446655440000")
 	mock.ExpectCommit()
 
 	err := repo.DeleteSignalInterval(context.Background(), "750e8400-e29b-41d4-a716-446655440000")
@@ -2,14 +2,21 @@ package symbol
 
 import (
 	"context"
+	"errors"
+	"strings"
 
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Repository interface {
-	AddSymbol(ctx context.Context, req dtos.AddSymbolReq) (dtos.AddSymbolRes, error)
+	AddSymbol(ctx context.Context, req dtos.AddSymbolReq, info dtos.SymbolInfo) (dtos.AddSymbolRes, error)
+	ImportSymbols(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) ([]dtos.AddSymbolRes, error)
+	UpdateMetadata(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) error
 	GetByID(ctx context.Context, id string) (dtos.GetSymbolRes, error)
 	GetAll(ctx context.Context) ([]dtos.GetSymbolRes, error)
 	Delete(ctx context.Context, id string) error
@@ -26,17 +33,116 @@ func NewRepo(db *gorm.DB) Repository {
 	}
 }
 
-func (r *repository) AddSymbol(ctx context.Context, req dtos.AddSymbolReq) (dtos.AddSymbolRes, error) {
+func (r *repository) AddSymbol(ctx context.Context, req dtos.AddSymbolReq, info dtos.SymbolInfo) (dtos.AddSymbolRes, error) {
 	var symbol entities.Symbol
-	symbol.FromDto(&req)
-	err := r.db.WithContext(ctx).Create(&symbol).Error
+	if err := symbol.FromDto(&req); err != nil {
+		return symbol.ToDto(), err
+	}
+	symbol.ApplyInfo(info)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&symbol).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: symbol.ID.String(), Action: entities.AuditCreate, After: symbol})
+	})
 	if err != nil {
 		return symbol.ToDto(), err
 	}
-	
+
 	return symbol.ToDto(), nil
 }
 
+// ImportSymbols creates the missing symbols of the exchange and refreshes the metadata of the existing ones
+func (r *repository) ImportSymbols(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) ([]dtos.AddSymbolRes, error) {
+	exchangeUUID, err := uuid.Parse(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []dtos.AddSymbolRes
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := symbolsByName(tx, exchangeID)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			symbol, ok := existing[strings.ToLower(info.Symbol)]
+			if ok {
+				before := symbol
+				symbol.ApplyInfo(info)
+				if err := tx.Save(&symbol).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: symbol.ID.String(), Action: entities.AuditUpdate, Before: before, After: symbol}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			symbol.FromInfo(exchangeUUID, info)
+			if err := tx.Create(&symbol).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: symbol.ID.String(), Action: entities.AuditCreate, After: symbol}); err != nil {
+				return err
+			}
+			response = append(response, symbol.ToDto())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpdateMetadata refreshes the metadata of every symbol of the exchange, symbols missing from the exchange are marked unknown
+func (r *repository) UpdateMetadata(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) error {
+	byName := make(map[string]dtos.SymbolInfo, len(infos))
+	for _, info := range infos {
+		byName[strings.ToLower(info.Symbol)] = info
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := symbolsByName(tx, exchangeID)
+		if err != nil {
+			return err
+		}
+
+		for name, symbol := range existing {
+			before := symbol
+			if info, ok := byName[name]; ok {
+				symbol.ApplyInfo(info)
+			} else {
+				symbol.Status = consts.SymbolStatusUnknown
+			}
+			if err := tx.Save(&symbol).Error; err != nil {
+				return err
+			}
+			// unchanged symbols leave no audit row
+			if err := audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: symbol.ID.String(), Action: entities.AuditUpdate, Before: before, After: symbol}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func symbolsByName(tx *gorm.DB, exchangeID string) (map[string]entities.Symbol, error) {
+	var symbols []entities.Symbol
+	if err := tx.Where("exchange_id = ?", exchangeID).Find(&symbols).Error; err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]entities.Symbol, len(symbols))
+	for _, symbol := range symbols {
+		byName[strings.ToLower(symbol.Symbol)] = symbol
+	}
+	return byName, nil
+}
+
 func (r *repository) GetByID(ctx context.Context, id string) (dtos.GetSymbolRes, error) {
 	var symbol entities.Symbol
 	err := r.db.WithContext(ctx).Where("id = ?", id).First(&symbol).Error
@@ -59,8 +165,23 @@ func (r *repository) GetAll(ctx context.Context) ([]dtos.GetSymbolRes, error) {
 	return response, nil
 }
 
+// Delete soft deletes the symbol, deleting a missing symbol is a no-op
 func (r *repository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entities.Symbol{}).Error
+	var symbol entities.Symbol
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&symbol).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).Delete(&entities.Symbol{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: id, Action: entities.AuditDelete, Before: symbol})
+	})
 }
 
 func (r *repository) Update(ctx context.Context, req dtos.UpdateSymbolReq) (dtos.UpdateSymbolRes, error) {
@@ -69,8 +190,14 @@ func (r *repository) Update(ctx context.Context, req dtos.UpdateSymbolReq) (dtos
 	if err != nil {
 		return dtos.UpdateSymbolRes{}, err
 	}
+	before := symbol
 	symbol.UpdateFromDto(req)
-	err = r.db.WithContext(ctx).Where("id = ?", req.ID).Updates(&symbol).Error
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", req.ID).Updates(&symbol).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditSymbol, EntityID: req.ID, Action: entities.AuditUpdate, Before: before, After: symbol})
+	})
 	if err != nil {
 		return dtos.UpdateSymbolRes{}, err
 	}
@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit/audittest"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/symbol"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -61,10 +63,30 @@ func TestAddSymbolRepo(t *testing.T) {
 			"btcusdt",
 			"540e8400-e29b-41d4-a716-446655440000",
 			1,
+			"BTC",
+			"USDT",
+			"TRADING",
+			sqlmock.AnyArg(), // TickSize
+			sqlmock.AnyArg(), // StepSize
+			sqlmock.AnyArg(), // MinNotional
+			8,
+			8,
 		).WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSymbol, entities.AuditCreate, sqlmock.AnyArg())
 	mock.ExpectCommit()
 
-	res, err := repo.AddSymbol(context.Background(), req)
+	info := dtos.SymbolInfo{
+		Symbol:              "BTCUSDT",
+		BaseAsset:           "BTC",
+		QuoteAsset:          "USDT",
+		Status:              "TRADING",
+		TickSize:            decimal.RequireFromString("0.01"),
+		StepSize:            decimal.RequireFromString("0.00001"),
+		MinNotional:         decimal.RequireFromString("5"),
+		BaseAssetPrecision:  8,
+		QuoteAssetPrecision: 8,
+	}
+	res, err := repo.AddSymbol(context.Background(), req, info)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -120,10 +142,14 @@ func TestDelete(t *testing.T) {
 
 	query := regexp.QuoteMeta(`UPDATE "symbols" SET "deleted_at"=$1 WHERE id = $2 AND "symbols"."deleted_at" IS NULL`)
 
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "symbols" WHERE id = $1 AND "symbols"."deleted_at" IS NULL ORDER BY "symbols"."id" LIMIT $2`)).
+		WithArgs("550e8400-e29b-41d4-a716-446655440000", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "symbol"}).AddRow("550e8400-e29b-41d4-a716-446655440000", "btcusdt"))
 	mock.ExpectBegin()
 	mock.ExpectExec(query).
 		WithArgs(sqlmock.AnyArg(), "550e8400-e29b-41d4-a716-446655440000").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSymbol, entities.AuditDelete, "550e8400-e29b-41d4-a716-446655440000")
 	mock.ExpectCommit()
 
 	err := repo.Delete(context.Background(), "550e8400-e29b-41d4-a716-446655440000")
@@ -153,6 +179,7 @@ func TestUpdate(t *testing.T) {
 	mock.ExpectExec(`UPDATE "symbols" SET`).
 		WithArgs("ethusdt", sqlmock.AnyArg(), sqlmock.AnyArg(), "550e8400-e29b-41d4-a716-446655440000").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	audittest.Expect(mock, entities.AuditSymbol, entities.AuditUpdate, "550e8400-e29b-41d4-a716-446655440000")
 	mock.ExpectCommit()
 
 	res, err := repo.Update(context.Background(), req)
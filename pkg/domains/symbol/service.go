@@ -2,12 +2,27 @@ package symbol
 
 import (
 	"context"
+	"errors"
+	"strings"
 
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 )
 
+var (
+	ErrUnknownSymbol    = errors.New(consts.UnknownSymbol)
+	ErrSymbolNotTrading = errors.New(consts.SymbolNotTrading)
+)
+
+// InfoProvider returns the trading metadata of every symbol listed on an exchange
+type InfoProvider interface {
+	GetSymbolInfos(ctx context.Context, exchangeID string) ([]dtos.SymbolInfo, error)
+}
+
 type Service interface {
 	AddSymbol(ctx context.Context, req dtos.AddSymbolReq) (dtos.AddSymbolRes, error)
+	ImportSymbols(ctx context.Context, req dtos.ImportSymbolsReq) (dtos.ImportSymbolsRes, error)
+	SyncMetadata(ctx context.Context, exchangeID string) error
 	GetSymbol(ctx context.Context, id string) (dtos.GetSymbolRes, error)
 	GetAllSymbols(ctx context.Context) ([]dtos.GetSymbolRes, error)
 	DeleteSymbol(ctx context.Context, id string) error
@@ -16,16 +31,65 @@ type Service interface {
 
 type service struct {
 	repository Repository
+	provider   InfoProvider
 }
 
-func NewService(r Repository) Service {
+func NewService(r Repository, p InfoProvider) Service {
 	return &service{
 		repository: r,
+		provider:   p,
 	}
 }
 
 func (s *service) AddSymbol(ctx context.Context, req dtos.AddSymbolReq) (dtos.AddSymbolRes, error) {
-	return s.repository.AddSymbol(ctx, req)
+	infos, err := s.provider.GetSymbolInfos(ctx, req.ExchangeID)
+	if err != nil {
+		return dtos.AddSymbolRes{}, err
+	}
+
+	info, ok := findSymbolInfo(infos, req.Symbol)
+	if !ok {
+		return dtos.AddSymbolRes{}, ErrUnknownSymbol
+	}
+	if info.Status != consts.SymbolStatusTrading {
+		return dtos.AddSymbolRes{}, ErrSymbolNotTrading
+	}
+
+	return s.repository.AddSymbol(ctx, req, info)
+}
+
+// ImportSymbols adds every trading symbol of the exchange quoted in the requested asset
+func (s *service) ImportSymbols(ctx context.Context, req dtos.ImportSymbolsReq) (dtos.ImportSymbolsRes, error) {
+	infos, err := s.provider.GetSymbolInfos(ctx, req.ExchangeID)
+	if err != nil {
+		return dtos.ImportSymbolsRes{}, err
+	}
+
+	var matched []dtos.SymbolInfo
+	for _, info := range infos {
+		if info.Status == consts.SymbolStatusTrading && strings.EqualFold(info.QuoteAsset, req.QuoteAsset) {
+			matched = append(matched, info)
+		}
+	}
+
+	symbols, err := s.repository.ImportSymbols(ctx, req.ExchangeID, matched)
+	if err != nil {
+		return dtos.ImportSymbolsRes{}, err
+	}
+
+	return dtos.ImportSymbolsRes{
+		Imported: len(symbols),
+		Symbols:  symbols,
+	}, nil
+}
+
+// SyncMetadata refreshes the stored metadata of the exchange symbols from the exchange info
+func (s *service) SyncMetadata(ctx context.Context, exchangeID string) error {
+	infos, err := s.provider.GetSymbolInfos(ctx, exchangeID)
+	if err != nil {
+		return err
+	}
+	return s.repository.UpdateMetadata(ctx, exchangeID, infos)
 }
 
 func (s *service) GetSymbol(ctx context.Context, id string) (dtos.GetSymbolRes, error) {
@@ -43,3 +107,12 @@ func (s *service) DeleteSymbol(ctx context.Context, id string) error {
 func (s *service) UpdateSymbol(ctx context.Context, req dtos.UpdateSymbolReq) (dtos.UpdateSymbolRes, error) {
 	return s.repository.Update(ctx, req)
 }
+
+func findSymbolInfo(infos []dtos.SymbolInfo, symbol string) (dtos.SymbolInfo, bool) {
+	for _, info := range infos {
+		if strings.EqualFold(info.Symbol, symbol) {
+			return info, true
+		}
+	}
+	return dtos.SymbolInfo{}, false
+}
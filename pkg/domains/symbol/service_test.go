@@ -13,11 +13,21 @@ type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) AddSymbol(ctx context.Context, req dtos.AddSymbolReq) (dtos.AddSymbolRes, error) {
-	args := m.Called(ctx, req)
+func (m *MockRepository) AddSymbol(ctx context.Context, req dtos.AddSymbolReq, info dtos.SymbolInfo) (dtos.AddSymbolRes, error) {
+	args := m.Called(ctx, req, info)
 	return args.Get(0).(dtos.AddSymbolRes), args.Error(1)
 }
 
+func (m *MockRepository) ImportSymbols(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) ([]dtos.AddSymbolRes, error) {
+	args := m.Called(ctx, exchangeID, infos)
+	return args.Get(0).([]dtos.AddSymbolRes), args.Error(1)
+}
+
+func (m *MockRepository) UpdateMetadata(ctx context.Context, exchangeID string, infos []dtos.SymbolInfo) error {
+	args := m.Called(ctx, exchangeID, infos)
+	return args.Error(0)
+}
+
 func (m *MockRepository) GetByID(ctx context.Context, id string) (dtos.GetSymbolRes, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(dtos.GetSymbolRes), args.Error(1)
@@ -38,14 +48,32 @@ func (m *MockRepository) Update(ctx context.Context, req dtos.UpdateSymbolReq) (
 	return args.Get(0).(dtos.UpdateSymbolRes), args.Error(1)
 }
 
+type MockInfoProvider struct {
+	mock.Mock
+}
+
+func (m *MockInfoProvider) GetSymbolInfos(ctx context.Context, exchangeID string) ([]dtos.SymbolInfo, error) {
+	args := m.Called(ctx, exchangeID)
+	return args.Get(0).([]dtos.SymbolInfo), args.Error(1)
+}
+
+var testInfos = []dtos.SymbolInfo{
+	{Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT", Status: "TRADING"},
+	{Symbol: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT", Status: "TRADING"},
+	{Symbol: "LUNAUSDT", BaseAsset: "LUNA", QuoteAsset: "USDT", Status: "BREAK"},
+	{Symbol: "ETHBTC", BaseAsset: "ETH", QuoteAsset: "BTC", Status: "TRADING"},
+}
+
 func TestAddSymbol(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	mockProvider := new(MockInfoProvider)
+	service := NewService(mockRepo, mockProvider)
 
-	req := dtos.AddSymbolReq{Symbol: "BTC/USDT"}
-	expected := dtos.AddSymbolRes{ID: "1", Symbol: "BTC/USDT"}
+	req := dtos.AddSymbolReq{Symbol: "btcusdt", ExchangeID: "1"}
+	expected := dtos.AddSymbolRes{ID: "1", Symbol: "btcusdt"}
 
-	mockRepo.On("AddSymbol", mock.Anything, req).Return(expected, nil)
+	mockProvider.On("GetSymbolInfos", mock.Anything, "1").Return(testInfos, nil)
+	mockRepo.On("AddSymbol", mock.Anything, req, testInfos[0]).Return(expected, nil)
 
 	result, err := service.AddSymbol(t.Context(), req)
 
@@ -54,9 +82,67 @@ func TestAddSymbol(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAddSymbolRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		symbol  string
+		wantErr error
+	}{
+		{name: "unknown symbol", symbol: "FOOUSDT", wantErr: ErrUnknownSymbol},
+		{name: "halted symbol", symbol: "lunausdt", wantErr: ErrSymbolNotTrading},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockProvider := new(MockInfoProvider)
+			service := NewService(mockRepo, mockProvider)
+
+			mockProvider.On("GetSymbolInfos", mock.Anything, "1").Return(testInfos, nil)
+
+			_, err := service.AddSymbol(t.Context(), dtos.AddSymbolReq{Symbol: tt.symbol, ExchangeID: "1"})
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "AddSymbol", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestImportSymbols(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockProvider := new(MockInfoProvider)
+	service := NewService(mockRepo, mockProvider)
+
+	created := []dtos.AddSymbolRes{{ID: "1", Symbol: "btcusdt"}, {ID: "2", Symbol: "ethusdt"}}
+
+	mockProvider.On("GetSymbolInfos", mock.Anything, "1").Return(testInfos, nil)
+	mockRepo.On("ImportSymbols", mock.Anything, "1", testInfos[:2]).Return(created, nil)
+
+	result, err := service.ImportSymbols(t.Context(), dtos.ImportSymbolsReq{ExchangeID: "1", QuoteAsset: "usdt"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, created, result.Symbols)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSyncMetadata(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockProvider := new(MockInfoProvider)
+	service := NewService(mockRepo, mockProvider)
+
+	mockProvider.On("GetSymbolInfos", mock.Anything, "1").Return(testInfos, nil)
+	mockRepo.On("UpdateMetadata", mock.Anything, "1", testInfos).Return(nil)
+
+	err := service.SyncMetadata(t.Context(), "1")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetSymbol(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, new(MockInfoProvider))
 
 	expected := dtos.GetSymbolRes{ID: "1", Symbol: "BTC/USDT"}
 
@@ -71,7 +157,7 @@ func TestGetSymbol(t *testing.T) {
 
 func TestGetAllSymbols(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, new(MockInfoProvider))
 
 	expected := []dtos.GetSymbolRes{
 		{ID: "1", Symbol: "BTC/USDT"},
@@ -89,7 +175,7 @@ func TestGetAllSymbols(t *testing.T) {
 
 func TestDeleteSymbol(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, new(MockInfoProvider))
 
 	mockRepo.On("Delete", mock.Anything, "1").Return(nil)
 
@@ -101,7 +187,7 @@ func TestDeleteSymbol(t *testing.T) {
 
 func TestUpdateSymbol(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := NewService(mockRepo)
+	service := NewService(mockRepo, new(MockInfoProvider))
 
 	req := dtos.UpdateSymbolReq{ID: "1", Symbol: "Updated BTC/USDT"}
 	expected := dtos.UpdateSymbolRes{ID: "1", Symbol: "Updated BTC/USDT"}
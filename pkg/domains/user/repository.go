@@ -0,0 +1,116 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserExists     = errors.New(consts.UserExists)
+	ErrAPIKeyNotFound = errors.New(consts.APIKeyNotFound)
+)
+
+// uniqueViolation is the postgres code for a duplicate key
+const uniqueViolation = "23505"
+
+type Repository interface {
+	AddUser(ctx context.Context, user *entities.User) error
+	GetAllUsers(ctx context.Context) ([]entities.User, error)
+	AddAPIKey(ctx context.Context, key *entities.APIKey) error
+	GetAllAPIKeys(ctx context.Context) ([]entities.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepo(db *gorm.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) AddUser(ctx context.Context, user *entities.User) error {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&entities.User{}).Where("username = ?", user.Username).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditUser, EntityID: user.ID.String(), Action: entities.AuditCreate, After: user})
+	})
+	// the count above races with concurrent signups, the unique index decides the loser
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (r *repository) GetAllUsers(ctx context.Context) ([]entities.User, error) {
+	var users []entities.User
+	err := r.db.WithContext(ctx).Order("username").Find(&users).Error
+	return users, err
+}
+
+func (r *repository) AddAPIKey(ctx context.Context, key *entities.APIKey) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditAPIKey, EntityID: key.ID.String(), Action: entities.AuditCreate, After: key})
+	})
+}
+
+func (r *repository) GetAllAPIKeys(ctx context.Context) ([]entities.APIKey, error) {
+	var keys []entities.APIKey
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error) {
+	var key entities.APIKey
+	err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// RevokeAPIKey stamps the key as revoked, revoking it again keeps the first time, an id that is not a uuid is not found
+func (r *repository) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrAPIKeyNotFound
+	}
+	var key entities.APIKey
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil || key.RevokedAt != nil {
+		return err
+	}
+
+	before := key
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&key).Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, audit.Change{EntityType: entities.AuditAPIKey, EntityID: id, Action: entities.AuditUpdate, Before: before, After: key})
+	})
+}
@@ -0,0 +1,167 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/pkg/audit/audittest"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/user"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       db,
+		DriverName: "postgres",
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm db: %v", err)
+	}
+	return gormDB, mock
+}
+
+func TestAddUser(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users"`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "alice", "hash", consts.RoleTrader, consts.Active).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		audittest.Expect(mock, entities.AuditUser, entities.AuditCreate, sqlmock.AnyArg())
+		mock.ExpectCommit()
+
+		err := repo.AddUser(context.Background(), &entities.User{Username: "alice", PasswordHash: "hash", Role: consts.RoleTrader, IsActive: consts.Active})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Taken username", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		err := repo.AddUser(context.Background(), &entities.User{Username: "alice"})
+		assert.ErrorIs(t, err, user.ErrUserExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Username taken concurrently", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users"`).
+			WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_username"})
+		mock.ExpectRollback()
+
+		err := repo.AddUser(context.Background(), &entities.User{Username: "alice", PasswordHash: "hash", Role: consts.RoleTrader, IsActive: consts.Active})
+		assert.ErrorIs(t, err, user.ErrUserExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	db, mock := setupMockDB(t)
+	repo := user.NewRepo(db)
+	id := uuid.New()
+
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE prefix = \$1`).
+		WithArgs("0011223344556677", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "secret_hash", "role"}).
+			AddRow(id, "collector", "0011223344556677", "hash", consts.RoleViewer))
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE prefix = \$1`).
+		WithArgs("ffffffffffffffff", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	key, err := repo.GetAPIKeyByPrefix(context.Background(), "0011223344556677")
+	assert.NoError(t, err)
+	assert.Equal(t, id, key.ID)
+	assert.Equal(t, "hash", key.SecretHash)
+
+	_, err = repo.GetAPIKeyByPrefix(context.Background(), "ffffffffffffffff")
+	assert.ErrorIs(t, err, user.ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	t.Run("Revokes", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+		id := uuid.New()
+		at := time.Now()
+
+		mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE id = \$1`).
+			WithArgs(id.String(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(id, "collector"))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "api_keys" SET "revoked_at"=\$1,"updated_at"=\$2 WHERE "api_keys"."deleted_at" IS NULL AND "id" = \$3`).
+			WithArgs(at, sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		audittest.ExpectColumns(mock, entities.AuditAPIKey, entities.AuditUpdate, id.String(), sqlmock.AnyArg(), sqlmock.AnyArg(), audittest.DiffOf("revoked_at"))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.RevokeAPIKey(context.Background(), id.String(), at))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already revoked", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+		id := uuid.New()
+
+		mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE id = \$1`).
+			WithArgs(id.String(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "revoked_at"}).AddRow(id, time.Now().Add(-time.Hour)))
+
+		assert.NoError(t, repo.RevokeAPIKey(context.Background(), id.String(), time.Now()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+
+		id := uuid.New().String()
+		mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE id = \$1`).
+			WithArgs(id, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		assert.ErrorIs(t, repo.RevokeAPIKey(context.Background(), id, time.Now()), user.ErrAPIKeyNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid id", func(t *testing.T) {
+		db, mock := setupMockDB(t)
+		repo := user.NewRepo(db)
+
+		assert.ErrorIs(t, repo.RevokeAPIKey(context.Background(), "missing", time.Now()), user.ErrAPIKeyNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidUserReq   = errors.New(consts.UserInvalidReq)
+	ErrInvalidAPIKeyReq = errors.New(consts.APIKeyInvalidReq)
+)
+
+type Service interface {
+	AddUser(ctx context.Context, req dtos.AddUserReq) (dtos.UserRes, error)
+	GetAllUsers(ctx context.Context) ([]dtos.UserRes, error)
+	AddAPIKey(ctx context.Context, req dtos.AddAPIKeyReq) (dtos.AddAPIKeyRes, error)
+	GetAllAPIKeys(ctx context.Context) ([]dtos.APIKeyRes, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	VerifyAPIKey(ctx context.Context, key string) (dtos.APIKeyRes, error)
+}
+
+type service struct {
+	repository Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{
+		repository: r,
+	}
+}
+
+func (s *service) AddUser(ctx context.Context, req dtos.AddUserReq) (dtos.UserRes, error) {
+	if req.Username == "" || req.Password == "" || !entities.ValidRole(req.Role) {
+		return dtos.UserRes{}, ErrInvalidUserReq
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return dtos.UserRes{}, err
+	}
+
+	user := entities.User{
+		Username:     req.Username,
+		PasswordHash: string(hash),
+		Role:         req.Role,
+		IsActive:     consts.Active,
+	}
+	if err := s.repository.AddUser(ctx, &user); err != nil {
+		return dtos.UserRes{}, err
+	}
+	return user.ToDto(), nil
+}
+
+func (s *service) GetAllUsers(ctx context.Context) ([]dtos.UserRes, error) {
+	users, err := s.repository.GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dtos.UserRes, 0, len(users))
+	for _, u := range users {
+		res = append(res, u.ToDto())
+	}
+	return res, nil
+}
+
+// AddAPIKey creates a key for the role, the plain key is only in the response
+func (s *service) AddAPIKey(ctx context.Context, req dtos.AddAPIKeyReq) (dtos.AddAPIKeyRes, error) {
+	if req.Name == "" || !entities.ValidRole(req.Role) || (req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now())) {
+		return dtos.AddAPIKeyRes{}, ErrInvalidAPIKeyReq
+	}
+	plain, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		return dtos.AddAPIKeyRes{}, err
+	}
+
+	key := entities.APIKey{
+		Name:       req.Name,
+		Prefix:     prefix,
+		SecretHash: hash,
+		Role:       req.Role,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if userID, err := uuid.Parse(req.UserID); err == nil {
+		key.UserID = &userID
+	}
+	if err := s.repository.AddAPIKey(ctx, &key); err != nil {
+		return dtos.AddAPIKeyRes{}, err
+	}
+	return dtos.AddAPIKeyRes{
+		APIKeyRes: key.ToDto(),
+		Key:       plain,
+	}, nil
+}
+
+func (s *service) GetAllAPIKeys(ctx context.Context) ([]dtos.APIKeyRes, error) {
+	keys, err := s.repository.GetAllAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dtos.APIKeyRes, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, k.ToDto())
+	}
+	return res, nil
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.repository.RevokeAPIKey(ctx, id, time.Now())
+}
+
+// VerifyAPIKey returns the key when it is known, not revoked and not expired, otherwise utils.ErrAPIKeyInvalid
+func (s *service) VerifyAPIKey(ctx context.Context, plain string) (dtos.APIKeyRes, error) {
+	prefix, ok := utils.APIKeyPrefix(plain)
+	if !ok {
+		return dtos.APIKeyRes{}, utils.ErrAPIKeyInvalid
+	}
+	key, err := s.repository.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return dtos.APIKeyRes{}, utils.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return dtos.APIKeyRes{}, err
+	}
+	if !utils.CheckAPIKey(plain, key.SecretHash) || !key.Usable(time.Now()) {
+		return dtos.APIKeyRes{}, utils.ErrAPIKeyInvalid
+	}
+	return key.ToDto(), nil
+}
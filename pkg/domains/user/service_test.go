@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) AddUser(ctx context.Context, user *entities.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAllUsers(ctx context.Context) ([]entities.User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entities.User), args.Error(1)
+}
+
+func (m *MockRepository) AddAPIKey(ctx context.Context, key *entities.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAllAPIKeys(ctx context.Context) ([]entities.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entities.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (entities.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	return args.Get(0).(entities.APIKey), args.Error(1)
+}
+
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func TestAddUser(t *testing.T) {
+	t.Run("Hashes the password", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+
+		var saved *entities.User
+		mockRepo.On("AddUser", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(1).(*entities.User)
+		}).Return(nil)
+
+		res, err := service.AddUser(context.Background(), dtos.AddUserReq{Username: "alice", Password: "secret", Role: consts.RoleTrader})
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", res.Username)
+		assert.Equal(t, consts.RoleTrader, res.Role)
+		assert.NotEqual(t, "secret", saved.PasswordHash)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(saved.PasswordHash), []byte("secret")))
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+
+		_, err := service.AddUser(context.Background(), dtos.AddUserReq{Username: "alice", Password: "secret", Role: "root"})
+		assert.ErrorIs(t, err, ErrInvalidUserReq)
+		mockRepo.AssertNotCalled(t, "AddUser", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Taken username", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := NewService(mockRepo)
+		mockRepo.On("AddUser", mock.Anything, mock.Anything).Return(ErrUserExists)
+
+		_, err := service.AddUser(context.Background(), dtos.AddUserReq{Username: "alice", Password: "secret", Role: consts.RoleViewer})
+		assert.ErrorIs(t, err, ErrUserExists)
+	})
+}
+
+func TestAddAPIKey(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewService(mockRepo)
+	userID := uuid.New()
+
+	var saved *entities.APIKey
+	mockRepo.On("AddAPIKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*entities.APIKey)
+	}).Return(nil)
+
+	res, err := service.AddAPIKey(context.Background(), dtos.AddAPIKeyReq{Name: "collector", Role: consts.RoleViewer, UserID: userID.String()})
+	assert.NoError(t, err)
+
+	prefix, ok := utils.APIKeyPrefix(res.Key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, res.Prefix)
+	assert.Equal(t, prefix, saved.Prefix)
+	assert.Equal(t, userID, *saved.UserID)
+	assert.NotEqual(t, res.Key, saved.SecretHash)
+	assert.True(t, utils.CheckAPIKey(res.Key, saved.SecretHash))
+
+	past := time.Now().Add(-time.Hour)
+	_, err = service.AddAPIKey(context.Background(), dtos.AddAPIKeyReq{Name: "collector", Role: consts.RoleViewer, ExpiresAt: &past})
+	assert.ErrorIs(t, err, ErrInvalidAPIKeyReq)
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	key, prefix, hash, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+	other, _, _, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	stored := entities.APIKey{Name: "collector", Prefix: prefix, SecretHash: hash, Role: consts.RoleViewer, ExpiresAt: &future}
+
+	tests := []struct {
+		name    string
+		key     string
+		stored  entities.APIKey
+		repoErr error
+		wantErr error
+	}{
+		{name: "Valid", key: key, stored: stored},
+		{name: "Malformed", key: "not-a-key", wantErr: utils.ErrAPIKeyInvalid},
+		{name: "Unknown prefix", key: other, repoErr: ErrAPIKeyNotFound, wantErr: utils.ErrAPIKeyInvalid},
+		{name: "Wrong secret", key: key[:len(key)-4] + "0000", stored: stored, wantErr: utils.ErrAPIKeyInvalid},
+		{name: "Expired", key: key, stored: entities.APIKey{Prefix: prefix, SecretHash: hash, ExpiresAt: &past}, wantErr: utils.ErrAPIKeyInvalid},
+		{name: "Revoked", key: key, stored: entities.APIKey{Prefix: prefix, SecretHash: hash, RevokedAt: &past}, wantErr: utils.ErrAPIKeyInvalid},
+		{name: "Lookup error", key: key, repoErr: errors.New("connection refused"), wantErr: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			service := NewService(mockRepo)
+			mockRepo.On("GetAPIKeyByPrefix", mock.Anything, mock.Anything).Return(tt.stored, tt.repoErr)
+
+			res, err := service.VerifyAPIKey(context.Background(), tt.key)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "collector", res.Name)
+			assert.Equal(t, consts.RoleViewer, res.Role)
+		})
+	}
+}
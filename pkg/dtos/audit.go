@@ -0,0 +1,31 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLogReq struct {
+	EntityType string    `form:"entity_type"` // exchanges, symbols, signal_intervals, users, api_keys
+	EntityID   string    `form:"entity_id"`
+	ActorID    string    `form:"actor_id"`
+	Action     string    `form:"action"` // create, update, delete
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page       int64     `form:"page"`     // defaults to 1
+	PerPage    int64     `form:"per_page"` // defaults to 50, at most 500
+}
+
+type AuditLogRes struct {
+	ID         string          `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    string          `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	AuthMethod string          `json:"auth_method"` // token, api_key or system
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"` // {"field": {"before": ..., "after": ...}}
+}
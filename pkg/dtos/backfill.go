@@ -0,0 +1,51 @@
+package dtos
+
+import "time"
+
+type CandleGap struct {
+	From  int64 `json:"from"`  // first missing open time
+	To    int64 `json:"to"`    // last missing open time
+	Count int64 `json:"count"` // missing candles
+}
+
+type BackfillReq struct {
+	ExchangeID string    `json:"exchange_id"`
+	Symbol     string    `json:"symbol"`   // BTCUSDT
+	Interval   string    `json:"interval"` // 1m, 5m, 15m, 1h, 4h, 1d
+	From       time.Time `json:"from"`     // 2024-01-01T00:00:00Z
+	To         time.Time `json:"to"`       // defaults to now
+}
+
+type BackfillJobRes struct {
+	ID         string     `json:"id"`
+	ExchangeID string     `json:"exchange_id"`
+	Symbol     string     `json:"symbol"`
+	Interval   string     `json:"interval"`
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Status     string     `json:"status"` // pending, running, done, failed
+	Gaps       int        `json:"gaps"`
+	Missing    int64      `json:"missing"`
+	Fetched    int64      `json:"fetched"`
+	Inserted   int64      `json:"inserted"`
+	Requests   int64      `json:"requests"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type RollupReq struct {
+	ExchangeID string    `json:"exchange_id"`
+	Symbol     string    `json:"symbol"`    // BTCUSDT
+	Intervals  []string  `json:"intervals"` // defaults to every derived interval
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"` // defaults to now
+}
+
+type RollupRes struct {
+	ExchangeID string         `json:"exchange_id"`
+	Symbol     string         `json:"symbol"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Bars       map[string]int `json:"bars"` // recomputed bars per interval
+}
@@ -0,0 +1,48 @@
+package dtos
+
+import "time"
+
+type DeadLetterListReq struct {
+	Limit int `form:"limit"` // latest messages returned, defaults to 50
+}
+
+type DeadLetterRes struct {
+	Topic           string            `json:"topic"`
+	Partition       int32             `json:"partition"`
+	Offset          int64             `json:"offset"`
+	Key             string            `json:"key"`
+	Value           string            `json:"value"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Error           string            `json:"error"`
+	ErrorType       string            `json:"error_type"` // permanent or retryable
+	Attempts        int               `json:"attempts"`
+	Group           string            `json:"group"` // consumer group the message failed in
+	SourceTopic     string            `json:"source_topic"`
+	SourcePartition int32             `json:"source_partition"`
+	SourceOffset    int64             `json:"source_offset"`
+	FailedAt        time.Time         `json:"failed_at"`
+	Headers         map[string]string `json:"headers"` // original headers of the message
+}
+
+type DeadLetterRef struct {
+	Partition int32 `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+type DeadLetterReplayReq struct {
+	Messages []DeadLetterRef `json:"messages"`
+}
+
+type DeadLetterReplayed struct {
+	DeadLetterRef
+	SourceTopic string `json:"source_topic"`
+	// the only group handling the replayed message again
+	Group string `json:"group"`
+	// position of the replayed message in the source topic
+	ReplayPartition int32 `json:"replay_partition"`
+	ReplayOffset    int64 `json:"replay_offset"`
+}
+
+type DeadLetterReplayRes struct {
+	Replayed []DeadLetterReplayed `json:"replayed"`
+}
@@ -0,0 +1,31 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+)
+
+// AddCredentialReq carries the account secrets, they print as [REDACTED] so the request is safe to log
+type AddCredentialReq struct {
+	ExchangeID string         `json:"exchange_id"`
+	Label      string         `json:"label"` // main
+	APIKey     secrets.Secret `json:"api_key" swaggertype:"string"`
+	APISecret  secrets.Secret `json:"api_secret" swaggertype:"string"`
+}
+
+// CredentialRes never has the secrets, only the last characters of the api key
+type CredentialRes struct {
+	ID          string    `json:"id"`
+	ExchangeID  string    `json:"exchange_id"`
+	Label       string    `json:"label"`
+	APIKeyHint  string    `json:"api_key_hint"` // ****abcd
+	MasterKeyID string    `json:"master_key_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RotateCredentialsRes struct {
+	MasterKeyID string `json:"master_key_id"` // key every credential is wrapped with now
+	Rotated     int    `json:"rotated"`
+}
@@ -0,0 +1,39 @@
+package dtos
+
+/*
+	{
+	  "symbol": "BTCUSDT",
+	  "status": "TRADING",
+	  "baseAsset": "BTC",
+	  "baseAssetPrecision": 8,
+	  "quoteAsset": "USDT",
+	  "quoteAssetPrecision": 8,
+	  "filters": [
+	    {"filterType": "PRICE_FILTER", "minPrice": "0.01", "maxPrice": "1000000.00", "tickSize": "0.01"},
+	    {"filterType": "LOT_SIZE", "minQty": "0.00001", "maxQty": "9000.00", "stepSize": "0.00001"},
+	    {"filterType": "NOTIONAL", "minNotional": "5.00", "maxNotional": "9000000.00"}
+	  ]
+	}
+*/
+type BinanceExchangeInfo struct {
+	Timezone   string              `json:"timezone"`
+	ServerTime int64               `json:"serverTime"`
+	Symbols    []BinanceSymbolInfo `json:"symbols"`
+}
+
+type BinanceSymbolInfo struct {
+	Symbol              string                `json:"symbol"`
+	Status              string                `json:"status"`
+	BaseAsset           string                `json:"baseAsset"`
+	BaseAssetPrecision  int                   `json:"baseAssetPrecision"`
+	QuoteAsset          string                `json:"quoteAsset"`
+	QuoteAssetPrecision int                   `json:"quoteAssetPrecision"`
+	Filters             []BinanceSymbolFilter `json:"filters"`
+}
+
+type BinanceSymbolFilter struct {
+	FilterType  string `json:"filterType"`  // PRICE_FILTER, LOT_SIZE, NOTIONAL, MIN_NOTIONAL
+	TickSize    string `json:"tickSize"`    // PRICE_FILTER
+	StepSize    string `json:"stepSize"`    // LOT_SIZE
+	MinNotional string `json:"minNotional"` // NOTIONAL, MIN_NOTIONAL
+}
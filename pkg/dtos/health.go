@@ -0,0 +1,23 @@
+package dtos
+
+import "time"
+
+type HealthRes struct {
+	Status    string                    `json:"status"` // ok, degraded when a non critical check fails, down when a critical one does
+	CheckedAt time.Time                 `json:"checked_at"`
+	Checks    map[string]HealthCheckRes `json:"checks"`
+}
+
+type HealthCheckRes struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Details   any    `json:"details,omitempty"` // state of the parts of the dependency, like every subscribed feed
+}
+
+type FeedRes struct {
+	Status      string     `json:"status"` // ok or degraded once the stream is stale
+	LastFrameAt *time.Time `json:"last_frame_at,omitempty"`
+	AgeMs       int64      `json:"age_ms"` // since the last frame, or since the subscription before the first one
+}
@@ -0,0 +1,56 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type LogReq struct {
+	Type    string    `form:"type"` // debug, info, warn, error
+	Entity  string    `form:"entity"`
+	Symbol  string    `form:"symbol"`
+	Query   string    `form:"q"` // words searched in the title and message
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page    int64     `form:"page"`     // defaults to 1
+	PerPage int64     `form:"per_page"` // defaults to 50, at most 500
+}
+
+type LogRes struct {
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	Entity    string          `json:"entity"`
+	Title     string          `json:"title"`
+	Message   string          `json:"message"`
+	Data      string          `json:"data,omitempty"`
+	TraceID   string          `json:"trace_id,omitempty"`
+	Fields    json.RawMessage `json:"fields,omitempty"`
+}
+
+// LogStatsReq filters the logs counted by the error rate and top errors, the range defaults to the last hour
+type LogStatsReq struct {
+	Type   string    `form:"type"` // defaults to error
+	Entity string    `form:"entity"`
+	Symbol string    `form:"symbol"`
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit"` // of the top errors, defaults to 20, at most 100
+}
+
+type ErrorRateRes struct {
+	Entity string    `json:"entity"`
+	Minute time.Time `json:"minute"`
+	Count  int64     `json:"count"`
+}
+
+// TopErrorRes is a message logged again and again, numbers, ids and quoted values of the messages are replaced so
+// they group together
+type TopErrorRes struct {
+	Entity    string    `json:"entity"`
+	Pattern   string    `json:"pattern" example:"WebSocket read error: read tcp <n>.<n>.<n>.<n>:<n>: i/o timeout"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sample    string    `json:"sample"` // one of the messages as it was logged
+}
@@ -0,0 +1,26 @@
+package dtos
+
+import "time"
+
+type OrderBookAtReq struct {
+	ExchangeID string    `form:"exchange_id"`
+	Symbol     string    `form:"symbol"`
+	At         time.Time `form:"-"`     // defaults to now
+	Depth      int       `form:"depth"` // levels per side, defaults to the compaction depth
+}
+
+type OrderBookAtRes struct {
+	ExchangeID         string     `json:"exchange_id"`
+	Symbol             string     `json:"symbol"`
+	At                 time.Time  `json:"at"`
+	SnapshotAt         *time.Time `json:"snapshot_at"`         // nearest snapshot the book was rebuilt from, null when rebuilt from diffs only
+	SnapshotResolution string     `json:"snapshot_resolution"` // 1s, 1m, 1h
+	Diffs              int        `json:"diffs"`               // level changes applied on top of the snapshot
+	Bids               [][]string `json:"bids"`                // [price, amount], best first
+	Asks               [][]string `json:"asks"`                // [price, amount], best first
+}
+
+type CompactionRes struct {
+	Snapshots map[string]int   `json:"snapshots"` // snapshots written per resolution
+	Pruned    map[string]int64 `json:"pruned"`    // snapshots deleted per resolution
+}
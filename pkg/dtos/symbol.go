@@ -1,5 +1,7 @@
 package dtos
 
+import "github.com/shopspring/decimal"
+
 type AddSymbolReq struct {
 	Symbol     string `json:"symbol"` //BTCUSDT
 	ExchangeID string `json:"exchange_id"`
@@ -28,8 +30,39 @@ type GetSymbolReq struct {
 }
 
 type GetSymbolRes struct {
-	ID         string `json:"id"`
-	Symbol     string `json:"symbol"`
+	ID                  string `json:"id"`
+	Symbol              string `json:"symbol"`
+	ExchangeID          string `json:"exchange_id"`
+	IsActive            uint   `json:"is_active"` //1 active, 2 passive
+	BaseAsset           string `json:"base_asset"`
+	QuoteAsset          string `json:"quote_asset"`
+	Status              string `json:"status"`
+	TickSize            string `json:"tick_size"`
+	StepSize            string `json:"step_size"`
+	MinNotional         string `json:"min_notional"`
+	BaseAssetPrecision  int    `json:"base_asset_precision"`
+	QuoteAssetPrecision int    `json:"quote_asset_precision"`
+}
+
+type ImportSymbolsReq struct {
 	ExchangeID string `json:"exchange_id"`
-	IsActive   uint   `json:"is_active"` //1 active, 2 passive
+	QuoteAsset string `json:"quote_asset"` //USDT
+}
+
+type ImportSymbolsRes struct {
+	Imported int            `json:"imported"`
+	Symbols  []AddSymbolRes `json:"symbols"`
+}
+
+// SymbolInfo is the exchange agnostic trading metadata of a symbol
+type SymbolInfo struct {
+	Symbol              string          `json:"symbol"`                // BTCUSDT
+	BaseAsset           string          `json:"base_asset"`            // BTC
+	QuoteAsset          string          `json:"quote_asset"`           // USDT
+	Status              string          `json:"status"`                // TRADING, HALT, BREAK
+	TickSize            decimal.Decimal `json:"tick_size"`             // 0.01
+	StepSize            decimal.Decimal `json:"step_size"`             // 0.00001
+	MinNotional         decimal.Decimal `json:"min_notional"`          // 5
+	BaseAssetPrecision  int             `json:"base_asset_precision"`  // 8
+	QuoteAssetPrecision int             `json:"quote_asset_precision"` // 8
 }
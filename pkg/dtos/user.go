@@ -0,0 +1,41 @@
+package dtos
+
+import "time"
+
+type AddUserReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"` // admin, trader, viewer
+}
+
+type UserRes struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	IsActive  uint      `json:"is_active"` // 1 active, 2 passive
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AddAPIKeyReq struct {
+	Name      string     `json:"name"`       // market-data-service
+	Role      string     `json:"role"`       // admin, trader, viewer
+	ExpiresAt *time.Time `json:"expires_at"` // never expires when empty
+	UserID    string     `json:"-"`          // set from the caller
+}
+
+type APIKeyRes struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Role      string     `json:"role"`
+	UserID    string     `json:"user_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AddAPIKeyRes is the only response that carries the key, it can not be read again
+type AddAPIKeyRes struct {
+	APIKeyRes
+	Key string `json:"key"` // ct_<prefix>_<secret>, send it in the data-api-key header
+}
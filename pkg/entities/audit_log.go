@@ -0,0 +1,71 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditReplay = "replay"
+)
+
+const ( // audited entities, named after their tables
+	AuditExchange       = "exchanges"
+	AuditSymbol         = "symbols"
+	AuditSignalInterval = "signal_intervals"
+	AuditUser           = "users"
+	AuditAPIKey         = "api_keys"
+	AuditCredential     = "exchange_credentials"
+	AuditDeadLetter     = "dead_letters"  // kafka, the id is topic/partition/offset
+	AuditBackfillJob    = "backfill_jobs" // kept in memory by the candle service
+)
+
+// AuditLog is a change of a configuration entity, rows are only ever inserted so it has no update or delete time
+type AuditLog struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ActorID    string    `json:"actor_id"`    // user or api key id, empty for the system
+	ActorName  string    `json:"actor_name"`  // username, api key name or system
+	AuthMethod string    `json:"auth_method"` // token, api_key or system
+	EntityType string    `json:"entity_type"` // table of the entity
+	EntityID   string    `json:"entity_id"`
+	Action     string    `json:"action"`                   // create, update, delete
+	Before     *string   `json:"before" gorm:"type:jsonb"` // nil for a create
+	After      *string   `json:"after" gorm:"type:jsonb"`  // nil for a delete
+	Diff       string    `json:"diff" gorm:"type:jsonb"`   // changed fields with their before and after values
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return nil
+}
+
+func (a *AuditLog) ToDto() dtos.AuditLogRes {
+	return dtos.AuditLogRes{
+		ID:         a.ID.String(),
+		CreatedAt:  a.CreatedAt,
+		ActorID:    a.ActorID,
+		ActorName:  a.ActorName,
+		AuthMethod: a.AuthMethod,
+		EntityType: a.EntityType,
+		EntityID:   a.EntityID,
+		Action:     a.Action,
+		Before:     rawJSON(a.Before),
+		After:      rawJSON(a.After),
+		Diff:       rawJSON(&a.Diff),
+	}
+}
+
+func rawJSON(s *string) json.RawMessage {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return []byte(*s)
+}
@@ -3,25 +3,40 @@ package entities
 import (
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm/clause"
 )
 
+// CandlestickKey is the unique key of a candlestick row
+var CandlestickKey = []clause.Column{{Name: "exchange_id"}, {Name: "symbol"}, {Name: "interval"}, {Name: "open_time"}}
+
+// CandlestickUpsert overwrites the stored candlestick having the same key
+func CandlestickUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns: CandlestickKey,
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "deleted_at", "open", "high", "low", "close", "volume", "close_time", "quote_volume",
+			"number_of_trades", "taker_buy_base_volume", "taker_buy_quote_volume", "ignore",
+		}),
+	}
+}
+
 type Candlestick struct {
 	Base
-	Symbol              string          `json:"symbol"`                       // Symbol
-	ExchangeId          string          `json:"exchange_id"`                  // Exchange
-	Interval            string          `json:"interval"`                     // Interval
-	OpenTime            int64           `json:"open_time"`                    // Open time
-	Open                decimal.Decimal `json:"open"`                         // Open
-	High                decimal.Decimal `json:"high"`                         // High
-	Low                 decimal.Decimal `json:"low"`                          // Low
-	Close               decimal.Decimal `json:"close"`                        // Close
-	Volume              decimal.Decimal `json:"volume"`                       // Volume
-	CloseTime           int64           `json:"close_time"`                   // Close time
-	QuoteVolume         decimal.Decimal `json:"quote_asset_volume"`           // Quote asset volume
-	NumberOfTrades      int64           `json:"number_of_trades"`             // Number of trades
-	TakerBuyBaseVolume  decimal.Decimal `json:"taker_buy_base_asset_volume"`  // Taker buy base asset volume
-	TakerBuyQuoteVolume decimal.Decimal `json:"taker_buy_quote_asset_volume"` // Taker buy quote asset volume
-	Ignore              decimal.Decimal `json:"ignore"`                       // Ignore
+	Symbol              string          `json:"symbol" gorm:"uniqueIndex:idx_candlestick_key,priority:2"`               // Symbol
+	ExchangeId          string          `json:"exchange_id" gorm:"not null;uniqueIndex:idx_candlestick_key,priority:1"` // Exchange
+	Interval            string          `json:"interval" gorm:"uniqueIndex:idx_candlestick_key,priority:3"`             // Interval
+	OpenTime            int64           `json:"open_time" gorm:"uniqueIndex:idx_candlestick_key,priority:4"`            // Open time
+	Open                decimal.Decimal `json:"open"`                                                                   // Open
+	High                decimal.Decimal `json:"high"`                                                                   // High
+	Low                 decimal.Decimal `json:"low"`                                                                    // Low
+	Close               decimal.Decimal `json:"close"`                                                                  // Close
+	Volume              decimal.Decimal `json:"volume"`                                                                 // Volume
+	CloseTime           int64           `json:"close_time"`                                                             // Close time
+	QuoteVolume         decimal.Decimal `json:"quote_asset_volume"`                                                     // Quote asset volume
+	NumberOfTrades      int64           `json:"number_of_trades"`                                                       // Number of trades
+	TakerBuyBaseVolume  decimal.Decimal `json:"taker_buy_base_asset_volume"`                                            // Taker buy base asset volume
+	TakerBuyQuoteVolume decimal.Decimal `json:"taker_buy_quote_asset_volume"`                                           // Taker buy quote asset volume
+	Ignore              decimal.Decimal `json:"ignore"`                                                                 // Ignore
 }
 
 func (c *Candlestick) FromDto(req *dtos.CandlestickRest) {
@@ -59,3 +74,29 @@ func (c *Candlestick) FromDtoWs(req *dtos.CandlestickWs) {
 	c.TakerBuyQuoteVolume, _ = decimal.NewFromString(req.Kline.TakerBuyQuoteVolume)
 	c.Ignore, _ = decimal.NewFromString(req.Kline.Ignore)
 }
+
+// ToWs converts the candlestick to the kline stream payload
+func (c *Candlestick) ToWs(closed bool) dtos.CandlestickWs {
+	return dtos.CandlestickWs{
+		ExchangeId: c.ExchangeId,
+		EventType:  "kline",
+		Symbol:     c.Symbol,
+		Kline: dtos.Kline{
+			StartTime:           c.OpenTime,
+			CloseTime:           c.CloseTime,
+			Symbol:              c.Symbol,
+			Interval:            c.Interval,
+			OpenPrice:           c.Open.String(),
+			ClosePrice:          c.Close.String(),
+			HighPrice:           c.High.String(),
+			LowPrice:            c.Low.String(),
+			BaseAssetVolume:     c.Volume.String(),
+			NumberOfTrades:      c.NumberOfTrades,
+			IsKlineClosed:       closed,
+			QuoteAssetVolume:    c.QuoteVolume.String(),
+			TakerBuyBaseVolume:  c.TakerBuyBaseVolume.String(),
+			TakerBuyQuoteVolume: c.TakerBuyQuoteVolume.String(),
+			Ignore:              c.Ignore.String(),
+		},
+	}
+}
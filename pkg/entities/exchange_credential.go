@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+	"github.com/google/uuid"
+)
+
+// ExchangeCredential is the api key and secret of an exchange account, both are sealed in Ciphertext with a data key
+// that is wrapped by the master key MasterKeyID, only a hint of the api key is kept in clear
+type ExchangeCredential struct {
+	Base
+	ExchangeID  uuid.UUID `json:"exchange_id"`
+	Label       string    `json:"label"`        // main, hedge, etc
+	APIKeyHint  string    `json:"api_key_hint"` // ****abcd
+	MasterKeyID string    `json:"master_key_id"`
+	WrappedKey  []byte    `json:"-"`
+	Ciphertext  []byte    `json:"-"`
+}
+
+func (c *ExchangeCredential) Envelope() secrets.Envelope {
+	return secrets.Envelope{
+		MasterKeyID: c.MasterKeyID,
+		WrappedKey:  c.WrappedKey,
+		Ciphertext:  c.Ciphertext,
+	}
+}
+
+func (c *ExchangeCredential) SetEnvelope(e secrets.Envelope) {
+	c.MasterKeyID = e.MasterKeyID
+	c.WrappedKey = e.WrappedKey
+	c.Ciphertext = e.Ciphertext
+}
+
+func (c *ExchangeCredential) ToDto() dtos.CredentialRes {
+	return dtos.CredentialRes{
+		ID:          c.ID.String(),
+		ExchangeID:  c.ExchangeID.String(),
+		Label:       c.Label,
+		APIKeyHint:  c.APIKeyHint,
+		MasterKeyID: c.MasterKeyID,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+}
@@ -1,12 +1,37 @@
 package entities
 
+import (
+	"encoding/json"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
 type Log struct {
 	Base
-	Title   string `json:"title" example:"example title"`
-	Message string `json:"message" example:"order created"`
-	Entity  string `json:"entity" example:"order"`
-	Type    string `json:"type" example:"info"`  // -----> info, error
-	Proto   string `json:"proto" example:"http"` // -----> http, grpc
-	Ip      string `json:"ip" example:"127.0.0.1"`
-	Data    string `json:"data" example:"{}"`
+	Title   string  `json:"title" example:"example title"`
+	Message string  `json:"message" example:"order created"`
+	Entity  string  `json:"entity" example:"order"`
+	Type    string  `json:"type" example:"info"`  // -----> debug, info, warn, error
+	Proto   string  `json:"proto" example:"http"` // -----> http, grpc
+	Ip      string  `json:"ip" example:"127.0.0.1"`
+	Data    string  `json:"data" example:"{}"`
+	TraceID string  `json:"trace_id"`
+	Fields  *string `json:"fields" gorm:"type:jsonb"` // symbol, topic, exchange and the other attributes of the entry
+}
+
+func (l *Log) ToDto() dtos.LogRes {
+	res := dtos.LogRes{
+		ID:        l.ID.String(),
+		CreatedAt: l.CreatedAt,
+		Type:      l.Type,
+		Entity:    l.Entity,
+		Title:     l.Title,
+		Message:   l.Message,
+		Data:      l.Data,
+		TraceID:   l.TraceID,
+	}
+	if l.Fields != nil && json.Valid([]byte(*l.Fields)) {
+		res.Fields = json.RawMessage(*l.Fields)
+	}
+	return res
 }
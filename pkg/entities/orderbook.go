@@ -1,11 +1,72 @@
 package entities
 
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
 type OrderBook struct {
 	Base
-	Symbol     string `json:"symbol"`
+	Symbol     string `json:"symbol" gorm:"uniqueIndex:idx_order_book_key,priority:2"`
+	ExchangeId string `json:"exchange_id" gorm:"not null;uniqueIndex:idx_order_book_key,priority:1"`
+	Price      string `json:"price" gorm:"uniqueIndex:idx_order_book_key,priority:4"`
+	Amount     string `json:"amount"`
+	Side       string `json:"side" gorm:"uniqueIndex:idx_order_book_key,priority:3"` // bid or ask
+	Status     string `json:"status"`                                                // open, closed
+}
+
+// OrderBookUpsert updates the amount and status of the stored price level having the same key
+func OrderBookUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "exchange_id"}, {Name: "symbol"}, {Name: "side"}, {Name: "price"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "deleted_at", "amount", "status"}),
+	}
+}
+
+// OrderBookHistory is an append only record of a price level change, partitioned daily on created_at
+type OrderBookHistory struct {
+	Base
 	ExchangeId string `json:"exchange_id"`
+	Symbol     string `json:"symbol"`
+	Side       string `json:"side"`
 	Price      string `json:"price"`
 	Amount     string `json:"amount"`
-	Side       string `json:"side"`   // bid or ask
-	Status     string `json:"status"` // open, closed
+	Status     string `json:"status"`
 }
+
+func (OrderBookHistory) TableName() string {
+	return "order_book_history"
+}
+
+// OrderBookSnapshot holds the top levels of the book at the end of a resolution bucket, levels are [price, amount] pairs
+type OrderBookSnapshot struct {
+	Base
+	ExchangeId string    `json:"exchange_id"`
+	Symbol     string    `json:"symbol"`
+	Resolution string    `json:"resolution"` // 1s, 1m, 1h
+	TakenAt    time.Time `json:"taken_at"`
+	Bids       string    `json:"bids" gorm:"type:jsonb"`
+	Asks       string    `json:"asks" gorm:"type:jsonb"`
+}
+
+func (s *OrderBookSnapshot) SetLevels(bids, asks [][]string) {
+	b, _ := json.Marshal(bids)
+	a, _ := json.Marshal(asks)
+	s.Bids = string(b)
+	s.Asks = string(a)
+}
+
+func (s *OrderBookSnapshot) Levels() (bids, asks [][]string, err error) {
+	if err = json.Unmarshal([]byte(s.Bids), &bids); err != nil {
+		return nil, nil, err
+	}
+	if err = json.Unmarshal([]byte(s.Asks), &asks); err != nil {
+		return nil, nil, err
+	}
+	return bids, asks, nil
+}
+
+// OrderBookSnapshotKey is the unique key of a snapshot row
+var OrderBookSnapshotKey = []clause.Column{{Name: "exchange_id"}, {Name: "symbol"}, {Name: "resolution"}, {Name: "taken_at"}}
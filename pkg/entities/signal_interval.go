@@ -7,6 +7,11 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	SignalIntervalActive   = 1
+	SignalIntervalInactive = 2
+)
+
 type SignalInterval struct {
 	Base
 	Symbol     string    `json:"symbol"`   //BTCUSDT
@@ -21,7 +26,7 @@ func (s *SignalInterval) FromDto(dto *dtos.AddSignalIntervalReq) error {
 	s.Symbol = symbol
 	s.Interval = dto.Interval
 	s.ExchangeID = uuid.MustParse(dto.ExchangeId)
-	s.IsActive = 1
+	s.IsActive = SignalIntervalActive
 	return nil
 }
 
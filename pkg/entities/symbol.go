@@ -1,8 +1,11 @@
 package entities
 
 import (
+	"strings"
+
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const (
@@ -12,9 +15,17 @@ const (
 
 type Symbol struct {
 	Base
-	Symbol     string    `json:"symbol"` //BTCUSDT
-	ExchangeID uuid.UUID `json:"exchange_id"`
-	IsActive   uint      `json:"is_active"` //1 active, 2 passive
+	Symbol              string          `json:"symbol"` //BTCUSDT
+	ExchangeID          uuid.UUID       `json:"exchange_id"`
+	IsActive            uint            `json:"is_active"`             //1 active, 2 passive
+	BaseAsset           string          `json:"base_asset"`            //BTC
+	QuoteAsset          string          `json:"quote_asset"`           //USDT
+	Status              string          `json:"status"`                //TRADING, HALT, BREAK
+	TickSize            decimal.Decimal `json:"tick_size"`             //0.01
+	StepSize            decimal.Decimal `json:"step_size"`             //0.00001
+	MinNotional         decimal.Decimal `json:"min_notional"`          //5
+	BaseAssetPrecision  int             `json:"base_asset_precision"`  //8
+	QuoteAssetPrecision int             `json:"quote_asset_precision"` //8
 }
 
 func (s *Symbol) FromDto(dto *dtos.AddSymbolReq) error {
@@ -47,10 +58,18 @@ func (s *Symbol) UpdateFromDto(dto dtos.UpdateSymbolReq) error {
 
 func (s *Symbol) ToGetDto() dtos.GetSymbolRes {
 	return dtos.GetSymbolRes{
-		ID:         s.ID.String(),
-		Symbol:     s.Symbol,
-		ExchangeID: s.ExchangeID.String(),
-		IsActive:   s.IsActive,
+		ID:                  s.ID.String(),
+		Symbol:              s.Symbol,
+		ExchangeID:          s.ExchangeID.String(),
+		IsActive:            s.IsActive,
+		BaseAsset:           s.BaseAsset,
+		QuoteAsset:          s.QuoteAsset,
+		Status:              s.Status,
+		TickSize:            s.TickSize.String(),
+		StepSize:            s.StepSize.String(),
+		MinNotional:         s.MinNotional.String(),
+		BaseAssetPrecision:  s.BaseAssetPrecision,
+		QuoteAssetPrecision: s.QuoteAssetPrecision,
 	}
 }
 func (s *Symbol) ToDtoUpdate() dtos.UpdateSymbolRes {
@@ -60,3 +79,23 @@ func (s *Symbol) ToDtoUpdate() dtos.UpdateSymbolRes {
 		ExchangeID: s.ExchangeID.String(),
 	}
 }
+
+// FromInfo creates an active symbol from the exchange metadata, symbols are stored lowercase like the streams use them
+func (s *Symbol) FromInfo(exchangeID uuid.UUID, info dtos.SymbolInfo) {
+	s.Symbol = strings.ToLower(info.Symbol)
+	s.ExchangeID = exchangeID
+	s.IsActive = SymbolActive
+	s.ApplyInfo(info)
+}
+
+// ApplyInfo copies the exchange metadata onto the symbol
+func (s *Symbol) ApplyInfo(info dtos.SymbolInfo) {
+	s.BaseAsset = info.BaseAsset
+	s.QuoteAsset = info.QuoteAsset
+	s.Status = info.Status
+	s.TickSize = info.TickSize
+	s.StepSize = info.StepSize
+	s.MinNotional = info.MinNotional
+	s.BaseAssetPrecision = info.BaseAssetPrecision
+	s.QuoteAssetPrecision = info.QuoteAssetPrecision
+}
@@ -0,0 +1,81 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/google/uuid"
+)
+
+var roleRanks = map[string]int{
+	consts.RoleViewer: 1,
+	consts.RoleTrader: 2,
+	consts.RoleAdmin:  3,
+}
+
+// ValidRole reports whether role is one of admin, trader or viewer
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAllows reports whether role can do what required can, an admin can do anything a trader or a viewer can
+func RoleAllows(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
+type User struct {
+	Base
+	Username     string `json:"username" gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"`         // bcrypt
+	Role         string `json:"role"`      // admin, trader, viewer
+	IsActive     uint   `json:"is_active"` // 1 active, 2 passive
+}
+
+func (u *User) ToDto() dtos.UserRes {
+	return dtos.UserRes{
+		ID:        u.ID.String(),
+		Username:  u.Username,
+		Role:      u.Role,
+		IsActive:  u.IsActive,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// APIKey authenticates service to service calls, only the sha256 of the secret is kept
+type APIKey struct {
+	Base
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex"` // public part of the key, used to look it up
+	SecretHash string     `json:"-"`                         // hex sha256 of the whole key
+	Role       string     `json:"role"`
+	UserID     *uuid.UUID `json:"user_id"` // user that created the key
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Usable reports whether the key is neither revoked nor expired at now
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+func (k *APIKey) ToDto() dtos.APIKeyRes {
+	res := dtos.APIKeyRes{
+		ID:        k.ID.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Role:      k.Role,
+		ExpiresAt: k.ExpiresAt,
+		RevokedAt: k.RevokedAt,
+		CreatedAt: k.CreatedAt,
+	}
+	if k.UserID != nil {
+		res.UserID = k.UserID.String()
+	}
+	return res
+}
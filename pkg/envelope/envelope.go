@@ -0,0 +1,194 @@
+package envelope
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Subject is the registry subject of the envelope schema and Version the schema version this code writes
+const (
+	Subject = "envelope"
+	Version = 1
+)
+
+// field numbers of the envelope, they are never reused, removed fields move to the reserved numbers of the schema
+const (
+	fieldVersion    protowire.Number = 1
+	fieldExchangeID protowire.Number = 2
+	fieldSymbol     protowire.Number = 3
+	fieldStreamType protowire.Number = 4
+	fieldReceivedAt protowire.Number = 5
+	fieldSequence   protowire.Number = 6
+	fieldPayload    protowire.Number = 7
+	fieldDocumentID protowire.Number = 8
+)
+
+// Schema describes the envelope this code writes, it must stay compatible with every version in the registry
+var Schema = Definition{
+	Name: Subject,
+	Fields: []Field{
+		{Number: int(fieldVersion), Name: "version", Type: TypeUint32},
+		{Number: int(fieldExchangeID), Name: "exchange_id", Type: TypeString},
+		{Number: int(fieldSymbol), Name: "symbol", Type: TypeString},
+		{Number: int(fieldStreamType), Name: "stream_type", Type: TypeString},
+		{Number: int(fieldReceivedAt), Name: "received_at", Type: TypeInt64},
+		{Number: int(fieldSequence), Name: "sequence", Type: TypeUint64},
+		{Number: int(fieldPayload), Name: "payload", Type: TypeBytes},
+		{Number: int(fieldDocumentID), Name: "document_id", Type: TypeString},
+	},
+}
+
+var (
+	ErrMissingVersion = errors.New("envelope has no version")
+	ErrMissingPayload = errors.New("envelope has no payload")
+)
+
+// Envelope wraps a raw exchange frame with where and when it was received. Version 0 marks a legacy message
+// produced before the envelope, its payload is the raw message value.
+type Envelope struct {
+	Version    uint32
+	ExchangeID string
+	Symbol     string
+	StreamType string // consts.StreamKline, consts.StreamOrderBook or consts.StreamAggTrade
+	ReceivedAt int64  // unix milliseconds
+	Sequence   uint64 // order of the frame within its symbol stream
+	Payload    []byte // the exchange frame as received
+	DocumentID string // id of the mongo document, set once the frame is stored
+}
+
+// Marshal encodes the envelope in the protobuf wire format of the schema
+func (e Envelope) Marshal() []byte {
+	version := e.Version
+	if version == 0 {
+		version = Version
+	}
+
+	b := make([]byte, 0, len(e.Payload)+len(e.ExchangeID)+len(e.Symbol)+len(e.DocumentID)+48)
+	b = protowire.AppendTag(b, fieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(version))
+	b = appendString(b, fieldExchangeID, e.ExchangeID)
+	b = appendString(b, fieldSymbol, e.Symbol)
+	b = appendString(b, fieldStreamType, e.StreamType)
+	if e.ReceivedAt != 0 {
+		b = protowire.AppendTag(b, fieldReceivedAt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(e.ReceivedAt))
+	}
+	if e.Sequence != 0 {
+		b = protowire.AppendTag(b, fieldSequence, protowire.VarintType)
+		b = protowire.AppendVarint(b, e.Sequence)
+	}
+	b = protowire.AppendTag(b, fieldPayload, protowire.BytesType)
+	b = protowire.AppendBytes(b, e.Payload)
+	b = appendString(b, fieldDocumentID, e.DocumentID)
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// Unmarshal decodes an envelope, fields added by newer versions are skipped so older consumers keep working
+func Unmarshal(b []byte) (Envelope, error) {
+	var e Envelope
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return e, fmt.Errorf("invalid envelope tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			switch num {
+			case fieldVersion:
+				e.Version = uint32(v)
+			case fieldReceivedAt:
+				e.ReceivedAt = int64(v)
+			case fieldSequence:
+				e.Sequence = v
+			}
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			switch num {
+			case fieldExchangeID:
+				e.ExchangeID = string(v)
+			case fieldSymbol:
+				e.Symbol = string(v)
+			case fieldStreamType:
+				e.StreamType = string(v)
+			case fieldPayload:
+				e.Payload = append([]byte{}, v...)
+			case fieldDocumentID:
+				e.DocumentID = string(v)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return e, fmt.Errorf("invalid envelope field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+
+	if e.Version == 0 {
+		return e, ErrMissingVersion
+	}
+	if e.Payload == nil {
+		return e, ErrMissingPayload
+	}
+	return e, nil
+}
+
+// Headers are the kafka headers naming the schema of an encoded envelope
+func Headers() []sarama.RecordHeader {
+	return []sarama.RecordHeader{
+		{Key: []byte(consts.SchemaSubjectHeader), Value: []byte(Subject)},
+		{Key: []byte(consts.SchemaVersionHeader), Value: []byte(strconv.Itoa(Version))},
+	}
+}
+
+// FromMessage decodes the envelope of a consumed message. Messages without the schema header were produced before
+// the envelope, they come back as a legacy envelope holding the raw value and the message key as the symbol.
+func FromMessage(msg *sarama.ConsumerMessage) (Envelope, error) {
+	subject := ""
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == consts.SchemaSubjectHeader {
+			subject = string(h.Value)
+		}
+	}
+
+	switch subject {
+	case "":
+		return Envelope{Symbol: string(msg.Key), Payload: msg.Value}, nil
+	case Subject:
+		return Unmarshal(msg.Value)
+	default:
+		return Envelope{}, fmt.Errorf("unknown schema subject: %s", subject)
+	}
+}
+
+// StreamType returns the stream type of the frames produced on a raw topic
+func StreamType(topic string) string {
+	switch topic {
+	case consts.CandleStickTopic:
+		return consts.StreamKline
+	case consts.OrderBookTopic:
+		return consts.StreamOrderBook
+	case consts.AggTradeTopic:
+		return consts.StreamAggTrade
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,88 @@
+package envelope
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	env := Envelope{
+		ExchangeID: "550e8400-e29b-41d4-a716-446655440000",
+		Symbol:     "BTCUSDT",
+		StreamType: consts.StreamKline,
+		ReceivedAt: 1714000000123,
+		Sequence:   42,
+		Payload:    []byte(`{"e":"kline","s":"BTCUSDT"}`),
+		DocumentID: "6630f1d2e4b0a1b2c3d4e5f6",
+	}
+
+	got, err := Unmarshal(env.Marshal())
+	assert.NoError(t, err)
+	env.Version = Version
+	assert.Equal(t, env, got)
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	// a newer writer added fields 9 and 10, this reader keeps what it knows
+	b := Envelope{Symbol: "ETHUSDT", Payload: []byte("{}")}.Marshal()
+	b = protowire.AppendTag(b, 9, protowire.BytesType)
+	b = protowire.AppendString(b, "spot")
+	b = protowire.AppendTag(b, 10, protowire.VarintType)
+	b = protowire.AppendVarint(b, 7)
+
+	got, err := Unmarshal(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "ETHUSDT", got.Symbol)
+	assert.Equal(t, []byte("{}"), got.Payload)
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"e":"kline"}`))
+	assert.Error(t, err)
+
+	b := protowire.AppendTag(nil, fieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	_, err = Unmarshal(b)
+	assert.ErrorIs(t, err, ErrMissingPayload)
+
+	_, err = Unmarshal(protowire.AppendString(protowire.AppendTag(nil, fieldPayload, protowire.BytesType), "{}"))
+	assert.ErrorIs(t, err, ErrMissingVersion)
+}
+
+func TestFromMessage(t *testing.T) {
+	headers := func() []*sarama.RecordHeader {
+		var hs []*sarama.RecordHeader
+		for _, h := range Headers() {
+			hs = append(hs, &h)
+		}
+		return hs
+	}
+
+	t.Run("Envelope", func(t *testing.T) {
+		env := Envelope{ExchangeID: "binance", Symbol: "BTCUSDT", Payload: []byte("{}")}
+		got, err := FromMessage(&sarama.ConsumerMessage{Value: env.Marshal(), Headers: headers()})
+		assert.NoError(t, err)
+		assert.Equal(t, "binance", got.ExchangeID)
+		assert.EqualValues(t, Version, got.Version)
+	})
+
+	t.Run("Legacy message", func(t *testing.T) {
+		got, err := FromMessage(&sarama.ConsumerMessage{Key: []byte("BTCUSDT"), Value: []byte(`{"e":"depthUpdate"}`)})
+		assert.NoError(t, err)
+		assert.Zero(t, got.Version)
+		assert.Equal(t, "BTCUSDT", got.Symbol)
+		assert.Equal(t, []byte(`{"e":"depthUpdate"}`), got.Payload)
+	})
+
+	t.Run("Unknown subject", func(t *testing.T) {
+		_, err := FromMessage(&sarama.ConsumerMessage{
+			Value:   []byte("{}"),
+			Headers: []*sarama.RecordHeader{{Key: []byte(consts.SchemaSubjectHeader), Value: []byte("trade")}},
+		})
+		assert.Error(t, err)
+	})
+}
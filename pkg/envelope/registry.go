@@ -0,0 +1,142 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// FileRegistry is a schema registry kept in a directory, every version of a subject is a json file at
+// <dir>/<subject>/v<version>.json. It stands in for a registry service in tests and local runs.
+type FileRegistry struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileRegistry(dir string) *FileRegistry {
+	return &FileRegistry{
+		dir: dir,
+	}
+}
+
+// Versions returns the registered versions of the subject in ascending order
+func (r *FileRegistry) Versions(subject string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(r.dir, subject))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".json"))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// Get reads a version of the subject
+func (r *FileRegistry) Get(subject string, version int) (Definition, error) {
+	var d Definition
+	data, err := os.ReadFile(r.path(subject, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, fmt.Errorf("%w: %s v%d", ErrSchemaNotFound, subject, version)
+	}
+	if err != nil {
+		return d, err
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, fmt.Errorf("invalid schema %s v%d: %w", subject, version, err)
+	}
+	d.Version = version
+	return d, nil
+}
+
+// Latest reads the newest version of the subject
+func (r *FileRegistry) Latest(subject string) (Definition, error) {
+	versions, err := r.Versions(subject)
+	if err != nil {
+		return Definition{}, err
+	}
+	if len(versions) == 0 {
+		return Definition{}, fmt.Errorf("%w: %s", ErrSchemaNotFound, subject)
+	}
+	return r.Get(subject, versions[len(versions)-1])
+}
+
+// Check returns an error when the definition is not compatible with every registered version of the subject
+func (r *FileRegistry) Check(subject string, d Definition) error {
+	versions, err := r.Versions(subject)
+	if err != nil {
+		return err
+	}
+	if err := d.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompatible, err)
+	}
+
+	for _, version := range versions {
+		prev, err := r.Get(subject, version)
+		if err != nil {
+			return err
+		}
+		if err := Compatible(prev, d); err != nil {
+			return fmt.Errorf("%s v%d: %w", subject, version, err)
+		}
+	}
+	return nil
+}
+
+// Register stores the definition as the next version of the subject once it is compatible with the registered ones,
+// a definition equal to the latest version returns that version
+func (r *FileRegistry) Register(subject string, d Definition) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.Check(subject, d); err != nil {
+		return 0, err
+	}
+
+	latest, err := r.Latest(subject)
+	if err == nil && slices.Equal(latest.Fields, d.Fields) && slices.Equal(latest.Reserved, d.Reserved) {
+		return latest.Version, nil
+	}
+	if err != nil && !errors.Is(err, ErrSchemaNotFound) {
+		return 0, err
+	}
+
+	d.Name = subject
+	d.Version = latest.Version + 1
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Join(r.dir, subject), 0o755); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(r.path(subject, d.Version), append(data, '\n'), 0o644); err != nil {
+		return 0, err
+	}
+	return d.Version, nil
+}
+
+func (r *FileRegistry) path(subject string, version int) string {
+	return filepath.Join(r.dir, subject, fmt.Sprintf("v%d.json", version))
+}
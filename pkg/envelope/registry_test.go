@@ -0,0 +1,86 @@
+package envelope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeSchemaIsRegistered(t *testing.T) {
+	// the registry in the repository holds every published version, the code must be able to talk to all of them
+	registry := NewFileRegistry("../../schemas")
+	assert.NoError(t, registry.Check(Subject, Schema))
+
+	latest, err := registry.Latest(Subject)
+	assert.NoError(t, err)
+	assert.Equal(t, Version, latest.Version)
+	assert.Equal(t, Schema.Fields, latest.Fields, "register the changed envelope as a new version")
+}
+
+func TestFileRegistryRegister(t *testing.T) {
+	registry := NewFileRegistry(t.TempDir())
+
+	_, err := registry.Latest(Subject)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	version, err := registry.Register(Subject, Schema)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	// registering the same schema again keeps its version
+	version, err = registry.Register(Subject, Schema)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	added := Schema
+	added.Fields = append(append([]Field{}, Schema.Fields...), Field{Number: 9, Name: "market", Type: TypeString})
+	version, err = registry.Register(Subject, added)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	// a removed field reserves its number, later versions can not take it back
+	removed := Definition{Name: Subject, Fields: added.Fields[:8], Reserved: []int{9}}
+	version, err = registry.Register(Subject, removed)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	reused := Definition{Name: Subject, Fields: append(append([]Field{}, removed.Fields...), Field{Number: 9, Name: "venue", Type: TypeBytes})}
+	_, err = registry.Register(Subject, reused)
+	assert.ErrorIs(t, err, ErrIncompatible)
+
+	versions, err := registry.Versions(Subject)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions)
+}
+
+func TestCompatible(t *testing.T) {
+	prev := Definition{Fields: []Field{
+		{Number: 1, Name: "version", Type: TypeUint32},
+		{Number: 2, Name: "symbol", Type: TypeString},
+	}}
+
+	tests := []struct {
+		name    string
+		next    Definition
+		wantErr bool
+	}{
+		{name: "Same", next: prev},
+		{name: "Renamed field", next: Definition{Fields: []Field{{Number: 1, Name: "version", Type: TypeUint32}, {Number: 2, Name: "pair", Type: TypeString}}}},
+		{name: "Added field", next: Definition{Fields: append(append([]Field{}, prev.Fields...), Field{Number: 3, Name: "sequence", Type: TypeUint64})}},
+		{name: "Removed and reserved", next: Definition{Fields: prev.Fields[:1], Reserved: []int{2}}},
+		{name: "Removed without reserving", next: Definition{Fields: prev.Fields[:1]}, wantErr: true},
+		{name: "Changed type", next: Definition{Fields: []Field{{Number: 1, Name: "version", Type: TypeUint32}, {Number: 2, Name: "symbol", Type: TypeBytes}}}, wantErr: true},
+		{name: "Duplicate number", next: Definition{Fields: append(append([]Field{}, prev.Fields...), Field{Number: 2, Name: "pair", Type: TypeString})}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compatible(prev, tt.next)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrIncompatible)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package envelope
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// field types of a schema, they match the protobuf scalar types used on the wire
+const (
+	TypeString = "string"
+	TypeBytes  = "bytes"
+	TypeInt64  = "int64"
+	TypeUint32 = "uint32"
+	TypeUint64 = "uint64"
+	TypeBool   = "bool"
+)
+
+var ErrIncompatible = errors.New("schema is not compatible")
+
+// Field is a numbered field of a schema
+type Field struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+// Definition is a versioned message schema as kept in the registry
+type Definition struct {
+	Name     string  `json:"name"`
+	Version  int     `json:"version"`
+	Fields   []Field `json:"fields"`
+	Reserved []int   `json:"reserved,omitempty"` // numbers of removed fields, they are never used again
+}
+
+func (d Definition) field(number int) (Field, bool) {
+	for _, f := range d.Fields {
+		if f.Number == number {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Validate checks the definition on its own, field numbers are positive and used once
+func (d Definition) Validate() error {
+	seen := map[int]bool{}
+	for _, f := range d.Fields {
+		if f.Number <= 0 {
+			return fmt.Errorf("field %s has an invalid number %d", f.Name, f.Number)
+		}
+		if seen[f.Number] {
+			return fmt.Errorf("field number %d is used twice", f.Number)
+		}
+		if slices.Contains(d.Reserved, f.Number) {
+			return fmt.Errorf("field %s uses the reserved number %d", f.Name, f.Number)
+		}
+		seen[f.Number] = true
+	}
+	return nil
+}
+
+// Compatible checks that readers and writers of both definitions understand each other. A field keeps its type, a
+// removed field has its number reserved and a reserved number is not brought back, new fields are skipped by older
+// readers.
+func Compatible(prev, next Definition) error {
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompatible, err)
+	}
+
+	var errs []error
+	for _, f := range prev.Fields {
+		nf, ok := next.field(f.Number)
+		if !ok {
+			if !slices.Contains(next.Reserved, f.Number) {
+				errs = append(errs, fmt.Errorf("field %s (%d) is removed without reserving its number", f.Name, f.Number))
+			}
+			continue
+		}
+		if nf.Type != f.Type {
+			errs = append(errs, fmt.Errorf("field %s (%d) changes type from %s to %s", f.Name, f.Number, f.Type, nf.Type))
+		}
+	}
+	for _, number := range prev.Reserved {
+		if f, ok := next.field(number); ok {
+			errs = append(errs, fmt.Errorf("field %s reuses the reserved number %d", f.Name, number))
+		}
+		if !slices.Contains(next.Reserved, number) {
+			errs = append(errs, fmt.Errorf("reserved number %d is released", number))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrIncompatible, errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+)
+
+// pgEnvelope decodes a message of the postgres topics. Messages forwarded before the envelope hold the frame as a
+// string inside dtos.MongoData, they are unwrapped into a legacy envelope.
+func pgEnvelope(msg *sarama.ConsumerMessage) (envelope.Envelope, error) {
+	env, err := envelope.FromMessage(msg)
+	if err != nil || env.Version != 0 {
+		return env, err
+	}
+
+	var mongoData dtos.MongoData
+	if err := json.Unmarshal(env.Payload, &mongoData); err != nil {
+		return env, err
+	}
+	env.DocumentID = mongoData.MongoID
+	env.Payload = []byte(mongoData.Value)
+	return env, nil
+}
+
+// errNoExchange fails a frame without its exchange, a symbol can be listed on several exchanges so it is not guessed
+var errNoExchange = errors.New("frame has no exchange id")
+
+// exchangeID returns the exchange the stream of the frame was started for
+func exchangeID(env envelope.Envelope) (string, error) {
+	if env.ExchangeID == "" {
+		return "", kafka.Permanent(errNoExchange)
+	}
+	return env.ExchangeID, nil
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MemoryStore keeps the documents in memory for tests and local runs
+type MemoryStore struct {
+	mu        sync.Mutex
+	frames    map[string]map[string]memoryFrame // collection -> id
+	orderBook map[string]entities.OrderBook     // symbol|side|price
+	signals   []entities.Signal
+}
+
+type memoryFrame struct {
+	doc    bson.M
+	record OutboxRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		frames:    map[string]map[string]memoryFrame{},
+		orderBook: map[string]entities.OrderBook{},
+	}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, collection, id string, doc bson.M, entry OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.frames[collection] == nil {
+		s.frames[collection] = map[string]memoryFrame{}
+	}
+	if _, ok := s.frames[collection][id]; !ok {
+		s.frames[collection][id] = memoryFrame{doc: maps.Clone(doc), record: OutboxRecord{ID: id, Entry: entry}}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Pending(ctx context.Context, collection string, limit int) ([]OutboxRecord, error) {
+	var pending []OutboxRecord
+	for _, record := range s.Outbox(collection) {
+		if !record.Entry.Published {
+			pending = append(pending, record)
+		}
+	}
+	return pending[:min(limit, len(pending))], nil
+}
+
+func (s *MemoryStore) MarkPublished(ctx context.Context, collection string, ids []string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		frame, ok := s.frames[collection][id]
+		if !ok {
+			continue
+		}
+		frame.record.Entry.Published = true
+		frame.record.Entry.PublishedAt = &at
+		frame.record.Entry.Value = nil
+		frame.record.Entry.Trace = nil
+		s.frames[collection][id] = frame
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpsertOrderBookLevels(ctx context.Context, symbol string, levels []entities.OrderBook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, level := range levels {
+		key := symbol + "|" + level.Side + "|" + level.Price
+		stored, ok := s.orderBook[key]
+		if level.Status == consts.ClosedOrder {
+			if ok {
+				stored.Status = level.Status
+				s.orderBook[key] = stored
+			}
+			continue
+		}
+		level.Symbol = symbol
+		s.orderBook[key] = level
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertSignal(ctx context.Context, signal entities.Signal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signals = append(s.signals, signal)
+	return nil
+}
+
+// Document returns the stored frame without its outbox entry
+func (s *MemoryStore) Document(collection, id string) (bson.M, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame, ok := s.frames[collection][id]
+	return maps.Clone(frame.doc), ok
+}
+
+// Outbox returns the outbox entries of the collection oldest first, published ones included
+func (s *MemoryStore) Outbox(collection string) []OutboxRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]OutboxRecord, 0, len(s.frames[collection]))
+	for _, frame := range s.frames[collection] {
+		records = append(records, frame.record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Entry.CreatedAt.Equal(records[j].Entry.CreatedAt) {
+			return records[i].Entry.CreatedAt.Before(records[j].Entry.CreatedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// OrderBook returns the mirrored levels of the symbol
+func (s *MemoryStore) OrderBook(symbol string) []entities.OrderBook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var levels []entities.OrderBook
+	for _, level := range s.orderBook {
+		if level.Symbol == symbol {
+			levels = append(levels, level)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return OrderBookLevelKey(levels[i]) < OrderBookLevelKey(levels[j]) })
+	return levels
+}
+
+// Signals returns the inserted signals in order
+func (s *MemoryStore) Signals() []entities.Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]entities.Signal(nil), s.signals...)
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	outboxField            = "outbox"
+	defaultOutboxBatchSize = 500
+)
+
+var errMongoNotInitialized = errors.New("mongo is not initialized")
+
+// OutboxEntry is the postgres fan-out of a stored frame. It is kept in the frame document, so the frame and its
+// fan-out are written by one atomic insert and neither exists without the other.
+type OutboxEntry struct {
+	Topic       string            `bson:"topic"`
+	Key         string            `bson:"key"`
+	Value       []byte            `bson:"value,omitempty"` // the envelope to produce, dropped once published
+	Trace       map[string]string `bson:"trace,omitempty"` // trace context of the stored frame, the fan-out continues its trace, dropped once published
+	CreatedAt   time.Time         `bson:"created_at"`
+	Published   bool              `bson:"published"`
+	PublishedAt *time.Time        `bson:"published_at,omitempty"`
+}
+
+// OutboxRecord is a pending entry and the id of its document
+type OutboxRecord struct {
+	ID    string      `bson:"_id"`
+	Entry OutboxEntry `bson:"outbox"`
+}
+
+// OutboxStore keeps the frames with their outbox entries
+type OutboxStore interface {
+	// Save inserts the frame document with its entry, saving an id that is already stored does nothing
+	Save(ctx context.Context, collection, id string, doc bson.M, entry OutboxEntry) error
+	// Pending returns the unpublished entries of the collection oldest first
+	Pending(ctx context.Context, collection string, limit int) ([]OutboxRecord, error)
+	// MarkPublished marks the entries as published
+	MarkPublished(ctx context.Context, collection string, ids []string, at time.Time) error
+}
+
+// OutboxProducer sends a batch of messages and returns once the broker has them all
+type OutboxProducer interface {
+	ProduceMessages(msgs []*sarama.ProducerMessage) error
+}
+
+// frameID identifies a frame however often it is delivered. Enveloped frames are identified by their stream position,
+// so a replay from the dead letter topic maps to the same document, legacy frames by their source offset.
+func frameID(msg *sarama.ConsumerMessage, env envelope.Envelope) string {
+	var source string
+	if env.Version != 0 {
+		source = fmt.Sprintf("%s|%s|%s|%d|%d", env.ExchangeID, env.Symbol, env.StreamType, env.ReceivedAt, env.Sequence)
+	} else {
+		source = fmt.Sprintf("%s|%d|%d", msg.Topic, msg.Partition, msg.Offset)
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:16])
+}
+
+// OutboxRelay produces the pending outbox entries to their topics. An entry is marked once the broker acknowledged
+// it, a relay stopped in between produces it again with the same key and document id, the postgres handlers upsert
+// by that so the replay does not duplicate rows.
+type OutboxRelay struct {
+	Store       OutboxStore
+	Producer    OutboxProducer
+	Collections []string
+	BatchSize   int
+}
+
+// Relay publishes the pending entries of every collection once and returns how many were published
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	limit := r.BatchSize
+	if limit <= 0 {
+		limit = defaultOutboxBatchSize
+	}
+
+	var (
+		published int
+		errs      []error
+	)
+	for _, collection := range r.Collections {
+		for {
+			n, err := r.relay(ctx, collection, limit)
+			published += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", collection, err))
+				break
+			}
+			if n < limit {
+				break
+			}
+		}
+	}
+	return published, errors.Join(errs...)
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, collection string, limit int) (int, error) {
+	records, err := r.Store.Pending(ctx, collection, limit)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+
+	msgs := make([]*sarama.ProducerMessage, 0, len(records))
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic:   record.Entry.Topic,
+			Key:     sarama.StringEncoder(record.Entry.Key),
+			Value:   sarama.ByteEncoder(record.Entry.Value),
+			Headers: tracing.KafkaHeaders(tracing.FromCarrier(record.Entry.Trace), envelope.Headers()),
+		})
+		ids = append(ids, record.ID)
+	}
+
+	// nothing is marked when a message fails, the ones that went out are produced again on the next run
+	if err := r.Producer.ProduceMessages(msgs); err != nil {
+		return 0, err
+	}
+	if err := r.Store.MarkPublished(ctx, collection, ids, time.Now()); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// StartOutboxRelay relays the outbox every interval until ctx is done
+func StartOutboxRelay(ctx context.Context, relay *OutboxRelay, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := relay.Relay(ctx); err != nil {
+			log.Printf("Outbox relay finished with errors: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// OutboxInterval returns the time between relay runs
+func OutboxInterval(cfg config.Outbox) time.Duration {
+	if cfg.Interval > 0 {
+		return time.Duration(cfg.Interval) * time.Millisecond
+	}
+	return 100 * time.Millisecond
+}
+
+// outboxKey is the message key of a fan-out, the source key keeps the updates of a symbol on one partition and in order
+func outboxKey(msg *sarama.ConsumerMessage, id string) string {
+	if len(msg.Key) > 0 {
+		return string(msg.Key)
+	}
+	return id
+}
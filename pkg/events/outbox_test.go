@@ -0,0 +1,222 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// memoryOutbox is a memory store with failures, lostAcks saves are stored but fail, like a handler killed right after
+// the insert, failedMarks marks fail, like a relay killed after producing.
+type memoryOutbox struct {
+	*MemoryStore
+	lostAcks    int
+	failedMarks int
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{MemoryStore: NewMemoryStore()}
+}
+
+func (o *memoryOutbox) Save(ctx context.Context, collection, id string, doc bson.M, entry OutboxEntry) error {
+	if err := o.MemoryStore.Save(ctx, collection, id, doc, entry); err != nil {
+		return err
+	}
+	if o.lostAcks > 0 {
+		o.lostAcks--
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (o *memoryOutbox) MarkPublished(ctx context.Context, collection string, ids []string, at time.Time) error {
+	if o.failedMarks > 0 {
+		o.failedMarks--
+		return errors.New("context canceled")
+	}
+	return o.MemoryStore.MarkPublished(ctx, collection, ids, at)
+}
+
+// memoryBroker keeps the produced messages, err fails every batch
+type memoryBroker struct {
+	msgs []*sarama.ProducerMessage
+	err  error
+}
+
+func (b *memoryBroker) ProduceMessages(msgs []*sarama.ProducerMessage) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.msgs = append(b.msgs, msgs...)
+	return nil
+}
+
+// consumed turns a produced message into the message its consumer receives
+func consumed(msg *sarama.ProducerMessage, offset int64) *sarama.ConsumerMessage {
+	key, _ := msg.Key.Encode()
+	value, _ := msg.Value.Encode()
+	var headers []*sarama.RecordHeader
+	for _, h := range msg.Headers {
+		headers = append(headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return &sarama.ConsumerMessage{Topic: msg.Topic, Offset: offset, Key: key, Value: value, Headers: headers}
+}
+
+func klineMessage(offset int64) *sarama.ConsumerMessage {
+	env := envelope.Envelope{
+		ExchangeID: "550e8400-e29b-41d4-a716-446655440000",
+		Symbol:     "BTCUSDT",
+		StreamType: consts.StreamKline,
+		ReceivedAt: 1714000060000,
+		Sequence:   7,
+		Payload:    []byte(`{"e":"kline","s":"BTCUSDT","k":{"t":1714000000000,"T":1714000059999,"s":"BTCUSDT","i":"1m","o":"64000.1","c":"64010.5","h":"64020","l":"63990","v":"12.5","x":true}}`),
+	}
+	return consumed(&sarama.ProducerMessage{
+		Topic:   consts.CandleStickTopic,
+		Key:     sarama.StringEncoder("BTCUSDT"),
+		Value:   sarama.ByteEncoder(env.Marshal()),
+		Headers: envelope.Headers(),
+	}, offset)
+}
+
+func newTestRelay(store OutboxStore, broker OutboxProducer) *OutboxRelay {
+	return &OutboxRelay{Store: store, Producer: broker, Collections: []string{consts.CollectionNameCandleStick}}
+}
+
+func TestFrameID(t *testing.T) {
+	// a frame replayed from another offset keeps its id
+	assert.Equal(t, frameID(klineMessage(4), envelope.Envelope{Version: 1, Symbol: "BTCUSDT", Sequence: 7}),
+		frameID(klineMessage(90), envelope.Envelope{Version: 1, Symbol: "BTCUSDT", Sequence: 7}))
+	assert.NotEqual(t, frameID(klineMessage(4), envelope.Envelope{Version: 1, Symbol: "BTCUSDT", Sequence: 7}),
+		frameID(klineMessage(4), envelope.Envelope{Version: 1, Symbol: "BTCUSDT", Sequence: 8}))
+
+	// legacy frames only have their offset
+	assert.NotEqual(t, frameID(&sarama.ConsumerMessage{Offset: 4}, envelope.Envelope{}), frameID(&sarama.ConsumerMessage{Offset: 5}, envelope.Envelope{}))
+}
+
+func TestMongoHandlerStoresFanOut(t *testing.T) {
+	store := newMemoryOutbox()
+	handler := &MongoHandler{Outbox: store}
+
+	assert.NoError(t, handler.HandleMessage(klineMessage(4)))
+
+	records := store.Outbox(consts.CollectionNameCandleStick)
+	assert.Len(t, records, 1)
+	for _, record := range records {
+		assert.Equal(t, consts.PgCandleStickTopic, record.Entry.Topic)
+		assert.Equal(t, "BTCUSDT", record.Entry.Key)
+		assert.False(t, record.Entry.Published)
+
+		env, err := envelope.Unmarshal(record.Entry.Value)
+		assert.NoError(t, err)
+		assert.Equal(t, record.ID, env.DocumentID)
+
+		doc, ok := store.Document(consts.CollectionNameCandleStick, record.ID)
+		assert.True(t, ok)
+		assert.Equal(t, "kline", doc["e"])
+		assert.Equal(t, "550e8400-e29b-41d4-a716-446655440000", env.ExchangeID)
+	}
+}
+
+func TestMongoHandlerKilledAfterInsert(t *testing.T) {
+	// the insert lands but the handler dies before it is acknowledged, the redelivered frame finds its document
+	store := newMemoryOutbox()
+	store.lostAcks = 1
+	handler := &MongoHandler{Outbox: store}
+
+	err := handler.HandleMessage(klineMessage(4))
+	assert.Error(t, err)
+	assert.False(t, kafka.IsPermanent(err))
+	assert.NoError(t, handler.HandleMessage(klineMessage(4)))
+	assert.Len(t, store.Outbox(consts.CollectionNameCandleStick), 1)
+
+	broker := &memoryBroker{}
+	published, err := newTestRelay(store, broker).Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Len(t, broker.msgs, 1)
+}
+
+func TestOutboxRelayProduceFails(t *testing.T) {
+	store := newMemoryOutbox()
+	assert.NoError(t, (&MongoHandler{Outbox: store}).HandleMessage(klineMessage(4)))
+
+	broker := &memoryBroker{err: errors.New("broker down")}
+	relay := newTestRelay(store, broker)
+	_, err := relay.Relay(context.Background())
+	assert.Error(t, err)
+
+	// the entry stays pending until the broker is back
+	broker.err = nil
+	published, err := relay.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	published, err = relay.Relay(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+}
+
+func TestOutboxRelayKilledBeforeMark(t *testing.T) {
+	store := newMemoryOutbox()
+	assert.NoError(t, (&MongoHandler{Outbox: store}).HandleMessage(klineMessage(4)))
+
+	// the relay dies after producing, the restarted relay produces the entry again
+	store.failedMarks = 1
+	broker := &memoryBroker{}
+	relay := newTestRelay(store, broker)
+	_, err := relay.Relay(context.Background())
+	assert.Error(t, err)
+	_, err = relay.Relay(context.Background())
+	assert.NoError(t, err)
+	_, err = relay.Relay(context.Background())
+	assert.NoError(t, err)
+
+	assert.Len(t, broker.msgs, 2)
+	first, second := consumed(broker.msgs[0], 0), consumed(broker.msgs[1], 1)
+	assert.Equal(t, first.Key, second.Key)
+	assert.Equal(t, first.Value, second.Value)
+
+	// both copies upsert the same candle
+	sqlDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.NoError(t, err)
+
+	rows := map[string]entities.Candlestick{}
+	writer := database.NewBatchWriter("candlesticks", database.BatchConfig{}, func() *gorm.DB { return db }, func(db *gorm.DB, candles []entities.Candlestick) error {
+		for _, c := range candles {
+			rows[CandlestickKey(c)] = c
+		}
+		return nil
+	}, CandlestickKey)
+	handler := &PgCandleStickHandler{Writer: writer}
+
+	var marked int
+	assert.NoError(t, handler.HandleMessageDeferred(first, func(error) { marked++ }))
+	assert.NoError(t, writer.Flush(context.Background()))
+	assert.NoError(t, handler.HandleMessageDeferred(second, func(error) { marked++ }))
+	assert.NoError(t, writer.Flush(context.Background()))
+
+	assert.Equal(t, 2, marked)
+	assert.Len(t, rows, 1)
+	for _, row := range rows {
+		assert.Equal(t, "550e8400-e29b-41d4-a716-446655440000", row.ExchangeId)
+		assert.Equal(t, "64010.5", row.Close.String())
+	}
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// exchangeServer streams the frames to every websocket client and hangs up
+func exchangeServer(t *testing.T, frames ...string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrading websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+		for _, frame := range frames {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				t.Errorf("writing frame: %v", err)
+				return
+			}
+		}
+	}))
+}
+
+// recordSpans installs a provider recording every span until the test ends
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), "test", 1)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	_, err := tracing.Init(context.Background(), config.Tracing{}, "test")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func repeat(value string, n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func TestPipelineWebsocketToSignal(t *testing.T) {
+	const exchangeID = "550e8400-e29b-41d4-a716-446655440000"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spans := recordSpans(t)
+
+	sqlDB, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+	sqlMock.ExpectQuery(`FROM "signal_intervals"`).
+		WithArgs("btcusdt", "1m", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "symbol", "interval", "exchange_id", "is_active"}).
+			AddRow("7c9e6679-7425-40de-944b-e07fc1f90ae7", "btcusdt", "1m", exchangeID, 1))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`INSERT INTO "signals"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	// the averages are warm, the streamed close lifts the short one above the long one
+	memCache := cache.NewMemoryStore()
+	assert.NoError(t, memCache.PushList(ctx, "BTCUSDT:1m:ma50", 0, repeat("100", 50)...))
+	assert.NoError(t, memCache.PushList(ctx, "BTCUSDT:1m:ma200", 0, repeat("100", 200)...))
+
+	var (
+		mu   sync.Mutex
+		rows = map[string]entities.Candlestick{}
+	)
+	writer := database.NewBatchWriter("candlesticks", database.BatchConfig{Size: 1, FlushInterval: 5 * time.Millisecond}, func() *gorm.DB { return db },
+		func(db *gorm.DB, candles []entities.Candlestick) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, c := range candles {
+				rows[CandlestickKey(c)] = c
+			}
+			return nil
+		}, CandlestickKey)
+	go writer.Run(ctx)
+
+	broker := kafka.NewMemoryBroker()
+	store := NewMemoryStore()
+	deps := Deps{DB: func() *gorm.DB { return db }, Cache: memCache, Store: store}
+	consumer := func(group, topic string, handler kafka.MessageHandler) *kafka.Consumer {
+		return &kafka.Consumer{GroupID: group, Topic: topic, Handler: handler, Group: broker.Group(group)}
+	}
+	consumers := kafka.NewRuntime(
+		consumer(consts.MongoCandleStickGroup, consts.CandleStickTopic, &MongoHandler{Outbox: store}),
+		consumer(consts.SignalCandleStickGroup, consts.CandleStickTopic, &SignalHandlerCandleStick{Deps: deps}),
+		consumer(consts.PgCandleStickGroup, consts.PgCandleStickTopic, &PgCandleStickHandler{Deps: deps, Writer: writer}),
+	)
+	assert.NoError(t, consumers.Start(ctx))
+	go StartOutboxRelay(ctx, &OutboxRelay{Store: store, Producer: broker, Collections: []string{consts.CollectionNameCandleStick}}, 5*time.Millisecond)
+
+	eventTime := time.Now().UnixMilli()
+	server := exchangeServer(t, fmt.Sprintf(`{"e":"kline","E":%d,"s":"BTCUSDT","k":{"t":1714000000000,"T":1714000059999,"s":"BTCUSDT","i":"1m","o":"64000.1","c":"64010.5","h":"64020","l":"63990","v":"12.5","x":true}}`, eventTime))
+	defer server.Close()
+	stream := NewStream(nil, broker)
+	assert.NoError(t, stream.startSymbolStream("ws"+strings.TrimPrefix(server.URL, "http"), exchangeID, "BTCUSDT", consts.CandleStickTopic, nil))
+	assert.Len(t, broker.Messages(consts.CandleStickTopic), 1)
+
+	assert.Eventually(t, func() bool {
+		return broker.Committed(consts.PgCandleStickGroup, consts.PgCandleStickTopic) == 1 &&
+			broker.Committed(consts.SignalCandleStickGroup, consts.CandleStickTopic) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, consumers.Shutdown(time.Second))
+
+	// the frame is stored in mongo and its fan-out published
+	records := store.Outbox(consts.CollectionNameCandleStick)
+	assert.Len(t, records, 1)
+	assert.True(t, records[0].Entry.Published)
+
+	// the closed candle is written to postgres
+	mu.Lock()
+	assert.Len(t, rows, 1)
+	for _, row := range rows {
+		assert.Equal(t, exchangeID, row.ExchangeId)
+		assert.Equal(t, "64010.5", row.Close.String())
+	}
+	mu.Unlock()
+
+	// and it crossed the averages into a buy
+	signals := store.Signals()
+	assert.Len(t, signals, 1)
+	assert.Equal(t, consts.BuySignal, signals[0].Signal)
+	lastSignal, err := memCache.Get(ctx, "BTCUSDT:1m:lastSignal")
+	assert.NoError(t, err)
+	assert.Equal(t, consts.BuySignal, lastSignal)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+
+	// every hop continues the trace of the frame, the postgres fan-out through the outbox too
+	names := map[string]bool{}
+	traceID := spans.GetSpans()[0].SpanContext.TraceID()
+	for _, span := range spans.GetSpans() {
+		assert.Equal(t, traceID, span.SpanContext.TraceID(), span.Name)
+		names[span.Name] = true
+	}
+	for _, name := range []string{
+		consts.CandleStickTopic + " frame",
+		consts.CandleStickTopic + " process",
+		consts.PgCandleStickTopic + " process",
+		"postgres.select",
+		"postgres.create",
+	} {
+		assert.True(t, names[name], name)
+	}
+}
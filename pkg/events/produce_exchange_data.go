@@ -1,28 +1,43 @@
 package events
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"path"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/SametAvcii/crypto-trade/pkg/health"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
 type Stream struct {
 	DB    *gorm.DB
-	Kafka *kafka.KafkaClient
+	Kafka kafka.Broker
+	Feeds *health.Feeds // tracks the freshness of every subscribed stream, nil tracks nothing
 }
 
-func NewStream(db *gorm.DB, kafkaClient *kafka.KafkaClient) *Stream {
+func NewStream(db *gorm.DB, broker kafka.Broker) *Stream {
 	return &Stream{
 		DB:    db,
-		Kafka: kafkaClient,
+		Kafka: broker,
 	}
 }
 
@@ -30,13 +45,7 @@ func (s *Stream) GetExchanges() []entities.Exchange {
 	var exchanges []entities.Exchange
 	err := s.DB.Where("is_active = ?", entities.ExchangeActive).Find(&exchanges).Error
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching exchanges from Postgres",
-			Message: fmt.Sprintf("Error fetching exchanges from Postgres: %v", err),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    "Error fetching exchanges from Postgres",
-		})
+		ctlog.For("stream").Error("Error fetching exchanges from Postgres", ctlog.Err(err))
 		return exchanges
 	}
 	return exchanges
@@ -46,13 +55,7 @@ func (s *Stream) GetStreamWS(exchangeID string) string {
 	var exchange entities.Exchange
 	err := s.DB.Where("id = ?", exchangeID).First(&exchange).Error
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching exchange from Postgres",
-			Message: fmt.Sprintf("Error fetching exchange from Postgres for exchange ID %s: %v", exchangeID, err),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    fmt.Sprintf("Exchange ID: %s", exchangeID),
-		})
+		ctlog.For("stream").Error("Error fetching exchange from Postgres", ctlog.Exchange(exchangeID), ctlog.Err(err))
 
 		return ""
 	}
@@ -63,13 +66,7 @@ func (s *Stream) GetStreamSymbols(exchangeID string) ([]entities.Symbol, error)
 	var symbols []entities.Symbol
 	err := s.DB.Where("exchange_id = ?", exchangeID).Find(&symbols).Error
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching symbols from Postgres",
-			Message: fmt.Sprintf("Error fetching symbols from Postgres for exchange ID %s: %v", exchangeID, err),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    fmt.Sprintf("Exchange ID: %s", exchangeID),
-		})
+		ctlog.For("stream").Error("Error fetching symbols from Postgres", ctlog.Exchange(exchangeID), ctlog.Err(err))
 		return nil, err
 	}
 	return symbols, nil
@@ -80,13 +77,7 @@ func (s *Stream) GetSymbolIntervals(exchangeID, symbol string) ([]entities.Signa
 	var intervals []entities.SignalInterval
 	err := s.DB.Where("exchange_id = ? AND symbol = ?", exchangeID, strings.ToLower(symbol)).Find(&intervals).Error
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching intervals from Postgres",
-			Message: fmt.Sprintf("Error fetching intervals from Postgres for symbol %s: %v", symbol, err),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    fmt.Sprintf("Exchange ID: %s, Symbol: %s", exchangeID, symbol),
-		})
+		ctlog.For("stream").Error("Error fetching intervals from Postgres", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Err(err))
 
 		return intervals, err
 	}
@@ -99,25 +90,13 @@ func (s *Stream) GetSymbolIntervals(exchangeID, symbol string) ([]entities.Signa
 func (s *Stream) StartAllStreams(exchangeID, topic string) error {
 	wsBase := s.GetStreamWS(exchangeID)
 	if wsBase == "" {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "WebSocket URL Not Found",
-			Message: fmt.Sprintf("WebSocket URL not found for exchange ID %s", exchangeID),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    fmt.Sprintf("Exchange ID: %s", exchangeID),
-		})
+		ctlog.For("stream").Error("WebSocket URL Not Found", ctlog.Exchange(exchangeID))
 		return fmt.Errorf("WebSocket URL not found for exchange ID: %s", exchangeID)
 	}
 
 	symbols, err := s.GetStreamSymbols(exchangeID)
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Get Stream Symbols Error",
-			Message: fmt.Sprintf("Error getting stream symbols for exchange ID %s: %v", exchangeID, err),
-			Type:    "error",
-			Entity:  "stream",
-			Data:    fmt.Sprintf("Exchange ID: %s", exchangeID),
-		})
+		ctlog.For("stream").Error("Get Stream Symbols Error", ctlog.Exchange(exchangeID), ctlog.Err(err))
 		return err
 	}
 
@@ -128,18 +107,11 @@ func (s *Stream) StartAllStreams(exchangeID, topic string) error {
 		case consts.OrderBookTopic:
 			go func() {
 				wsURL := fmt.Sprintf("%s/ws/%s@%s", wsBase, strings.ToLower(symbol.Symbol), consts.StreamOrderBook)
-				log.Printf("Connecting to WS for %s symbol: %s", consts.StreamOrderBook, symbol.Symbol)
+				ctlog.For("stream").Info("Connecting to WebSocket", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL))
 
-				err := s.startSymbolStream(wsURL, symbol.Symbol, topic)
+				err := s.startSymbolStream(wsURL, exchangeID, symbol.Symbol, topic, nil)
 				if err != nil {
-					ctlog.CreateLog(&entities.Log{
-						Title:   "WebSocket Connection Error",
-						Message: fmt.Sprintf("WebSocket connection error for %s: %v", symbol.Symbol, err),
-						Type:    "error",
-						Entity:  "stream",
-						Data:    fmt.Sprintf("WebSocket URL: %s", wsURL),
-					})
-					log.Printf("Error in stream for %s: %v", symbol.Symbol, err)
+					ctlog.For("stream").Error("WebSocket Connection Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL), ctlog.Err(err))
 				}
 			}()
 
@@ -147,72 +119,61 @@ func (s *Stream) StartAllStreams(exchangeID, topic string) error {
 			go func() {
 
 				wsURL := fmt.Sprintf("%s/ws/%s@%s", wsBase, strings.ToLower(symbol.Symbol), consts.StreamAggTrade)
-				log.Printf("Connecting to WS for %s  symbol: %s", consts.StreamAggTrade, symbol.Symbol)
+				ctlog.For("stream").Info("Connecting to WebSocket", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL))
 
-				err := s.startSymbolStream(wsURL, symbol.Symbol, topic)
+				err := s.startSymbolStream(wsURL, exchangeID, symbol.Symbol, topic, nil)
 				if err != nil {
-					log.Printf("Error in stream for %s: %v", symbol.Symbol, err)
+					ctlog.For("stream").Error("WebSocket Connection Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL), ctlog.Err(err))
 				}
 			}()
 
 		case consts.CandleStickTopic:
 
-			log.Printf("Connecting to WS for intervals symbol: %s", symbol.Symbol)
-
 			intervals, err := s.GetSymbolIntervals(exchangeID, symbol.Symbol)
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error fetching intervals from Postgres",
-					Message: fmt.Sprintf("Error fetching intervals from Postgres for symbol %s: %v", symbol.Symbol, err),
-					Type:    "error",
-					Entity:  "stream",
-					Data:    fmt.Sprintf("Symbol: %s", symbol.Symbol),
-				})
-				log.Printf("Error getting intervals for %s: %v", symbol.Symbol, err)
+				ctlog.For("stream").Error("Error fetching intervals from Postgres", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Err(err))
 				continue
 			}
 
 			if len(intervals) == 0 {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "No intervals found",
-					Message: fmt.Sprintf("No intervals found for symbol %s", symbol.Symbol),
-					Type:    "error",
-					Entity:  "stream",
-					Data:    fmt.Sprintf("Symbol: %s", symbol.Symbol),
-				})
-				log.Printf("No intervals found for %s", symbol.Symbol)
+				ctlog.For("stream").Error("No intervals found", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol))
+				continue
+			}
+
+			aggregator, err := candlestick.NewAggregator(consts.DerivedIntervals)
+			if err != nil {
+				ctlog.For("stream").Error("Error creating candlestick aggregator", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Err(err))
 				continue
 			}
 
+			// the base interval is streamed once and the derived intervals are aggregated from it,
+			// intervals that can not be derived keep their own stream
+			streamIntervals := []string{consts.BaseInterval}
 			for _, interval := range intervals {
+				if interval.Interval != consts.BaseInterval && !slices.Contains(consts.DerivedIntervals, interval.Interval) && !slices.Contains(streamIntervals, interval.Interval) {
+					streamIntervals = append(streamIntervals, interval.Interval)
+				}
+			}
+
+			for _, interval := range streamIntervals {
+				var derive func(ctx context.Context, message []byte)
+				if interval == consts.BaseInterval {
+					derive = s.deriveCandles(exchangeID, symbol.Symbol, aggregator)
+				}
+
 				go func() {
-					wsURL := fmt.Sprintf("%s/ws/%s@%s", wsBase, strings.ToLower(symbol.Symbol), fmt.Sprintf(consts.StreamCandleStick, interval.Interval))
-					log.Printf("Connecting to WS for interval: %s symbol: %s", fmt.Sprintf(consts.StreamCandleStick, interval.Interval), symbol.Symbol)
-					err := s.startSymbolStream(wsURL, symbol.Symbol, topic)
+					wsURL := fmt.Sprintf("%s/ws/%s@%s", wsBase, strings.ToLower(symbol.Symbol), fmt.Sprintf(consts.StreamCandleStick, interval))
+					ctlog.For("stream").Info("Connecting to WebSocket", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL))
+					err := s.startSymbolStream(wsURL, exchangeID, symbol.Symbol, topic, derive)
 					if err != nil {
-						ctlog.CreateLog(&entities.Log{
-							Title:   "WebSocket Connection Error",
-							Message: fmt.Sprintf("WebSocket connection error for %s: %v", symbol.Symbol, err),
-							Type:    "error",
-							Entity:  "stream",
-							Data:    fmt.Sprintf("WebSocket URL: %s", wsURL),
-						})
-
-						log.Printf("Error in stream for %s: %v", symbol.Symbol, err)
+						ctlog.For("stream").Error("WebSocket Connection Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol.Symbol), ctlog.Topic(topic), ctlog.Data(wsURL), ctlog.Err(err))
 					}
 
 				}()
 			}
 
 		default:
-			log.Printf("Unknown topic: %s", topic)
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Unknown Topic",
-				Message: fmt.Sprintf("Unknown topic: %s", topic),
-				Type:    "error",
-				Entity:  "stream",
-				Data:    fmt.Sprintf("Topic: %s", topic),
-			})
+			ctlog.For("stream").Error("Unknown Topic", ctlog.Exchange(exchangeID), ctlog.Topic(topic))
 			continue
 		}
 	}
@@ -220,60 +181,64 @@ func (s *Stream) StartAllStreams(exchangeID, topic string) error {
 	return nil
 }
 
-func (s *Stream) startSymbolStream(wsURL, symbol, topic string) error {
+// startSymbolStream produces every frame of the websocket to the topic in an envelope, onMessage is called with each frame
+// after it is produced when set. Each frame starts a trace, the consumers of the frame and of the bars derived from it
+// continue it.
+func (s *Stream) startSymbolStream(wsURL, exchangeID, symbol, topic string, onMessage func(ctx context.Context, message []byte)) error {
 	maxRetries := consts.MaxRetries
 	retryDelay := consts.RetryDelay * time.Second
+	var sequence uint64
+
+	// the stream stays tracked after the retries are exhausted so it shows up stale
+	feed := path.Base(wsURL)
+	s.Feeds.Subscribe(feed)
 
 	var lastErr error
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 		if err != nil {
 			lastErr = fmt.Errorf("WebSocket dial failed for %s (attempt %d/%d): %v", symbol, attempt+1, maxRetries, err)
-			ctlog.CreateLog(&entities.Log{
-				Title:   "WebSocket Connection Error",
-				Message: lastErr.Error(),
-				Type:    "error",
-				Entity:  "stream",
-				Data:    fmt.Sprintf("WebSocket URL: %s", wsURL),
-			})
-
-			log.Println(lastErr.Error())
+			ctlog.For("stream").Error("WebSocket Connection Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Topic(topic), ctlog.Data(wsURL), ctlog.Err(lastErr))
 			time.Sleep(retryDelay)
 			continue
 		}
 
 		// Successfully connected, start reading messages
-		log.Printf("[%s] WebSocket connected, starting to read messages", symbol)
+		ctlog.For("stream").Info("WebSocket connected", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Topic(topic))
 		defer c.Close()
 
 		for {
 			_, message, err := c.ReadMessage()
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "WebSocket Read Error",
-					Message: fmt.Sprintf("WebSocket read error for %s: %v", symbol, err),
-					Type:    "error",
-					Entity:  "stream",
-					Data:    fmt.Sprintf("WebSocket URL: %s", wsURL),
-				})
-
-				log.Printf("[%s] WebSocket read error: %v", symbol, err)
+				ctlog.For("stream").Error("WebSocket Read Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Topic(topic), ctlog.Data(wsURL), ctlog.Err(err))
 				break
 			}
 
-			_, _, err = s.Kafka.Produce(topic, symbol, []byte(message))
-			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Kafka Write Error",
-					Message: fmt.Sprintf("Kafka write error for %s: %v", symbol, err),
-					Type:    "error",
-					Entity:  "stream",
-					Data:    fmt.Sprintf("Topic: %s, Symbol: %s", topic, symbol),
-				})
-				log.Printf("[%s] Kafka write error: %v", symbol, err)
+			// the frame is only queued, a slow broker does not hold up the socket, dropped frames are counted in the producer metrics
+			s.Feeds.Seen(feed)
+			sequence++
+			ctx, span := tracing.Tracer().Start(context.Background(), topic+" frame",
+				trace.WithNewRoot(),
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(
+					semconv.MessagingSystemKafka,
+					semconv.MessagingOperationTypePublish,
+					semconv.MessagingDestinationName(topic),
+					semconv.MessagingKafkaMessageKey(symbol),
+					attribute.String("exchange.id", exchangeID),
+					attribute.Int64("stream.sequence", int64(sequence)),
+				))
+			err = s.produceFrame(ctx, topic, exchangeID, symbol, sequence, message)
+			if err != nil && !errors.Is(err, kafka.ErrBufferFull) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				ctlog.For("stream").ErrorContext(ctx, "Kafka Write Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Topic(topic), ctlog.Err(err))
 			}
 
-			log.Printf("[%s] Message sent to Kafka for %s", symbol, topic)
+			if onMessage != nil {
+				onMessage(ctx, message)
+			}
+			span.End()
 		}
 
 		return nil
@@ -281,3 +246,46 @@ func (s *Stream) startSymbolStream(wsURL, symbol, topic string) error {
 
 	return lastErr
 }
+
+// deriveCandles produces the bars closed by each base interval kline of the symbol on the candlestick topic
+func (s *Stream) deriveCandles(exchangeID, symbol string, aggregator *candlestick.Aggregator) func(ctx context.Context, message []byte) {
+	var sequence uint64
+	return func(ctx context.Context, message []byte) {
+		var payload dtos.CandlestickWs
+		if err := json.Unmarshal(message, &payload); err != nil {
+			ctlog.For("stream").ErrorContext(ctx, "Error unmarshalling kline for aggregation", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Err(err))
+			return
+		}
+
+		for _, bar := range aggregator.Add(payload) {
+			bar.ExchangeId = exchangeID
+			value, err := json.Marshal(bar)
+			if err != nil {
+				ctlog.For("stream").ErrorContext(ctx, "Error marshalling derived bar", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), slog.String("interval", bar.Kline.Interval), ctlog.Err(err))
+				continue
+			}
+
+			sequence++
+			err = s.produceFrame(ctx, consts.CandleStickTopic, exchangeID, symbol, sequence, value)
+			if err != nil {
+				ctlog.For("stream").ErrorContext(ctx, "Kafka Write Error", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), ctlog.Topic(consts.CandleStickTopic), slog.String("interval", bar.Kline.Interval), ctlog.Err(err))
+				continue
+			}
+			ctlog.For("stream").DebugContext(ctx, "Derived bar queued for Kafka", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), slog.String("interval", bar.Kline.Interval))
+		}
+	}
+}
+
+// produceFrame queues the frame on the topic wrapped in an envelope, the symbol stays the message key and the span of
+// ctx is carried in the headers
+func (s *Stream) produceFrame(ctx context.Context, topic, exchangeID, symbol string, sequence uint64, frame []byte) error {
+	env := envelope.Envelope{
+		ExchangeID: exchangeID,
+		Symbol:     symbol,
+		StreamType: envelope.StreamType(topic),
+		ReceivedAt: time.Now().UnixMilli(),
+		Sequence:   sequence,
+		Payload:    frame,
+	}
+	return s.Kafka.ProduceAsync(topic, symbol, env.Marshal(), tracing.KafkaHeaders(ctx, envelope.Headers())...)
+}
@@ -5,174 +5,144 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/IBM/sarama"
 	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
-	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
 	"github.com/SametAvcii/crypto-trade/pkg/candlestick"
-	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
-	"github.com/redis/go-redis/v9"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
-type SignalHandlerCandleStick struct{}
+type SignalHandlerCandleStick struct {
+	Deps
+}
 
-func (s *SignalHandlerCandleStick) HandleMessage(msg *sarama.ConsumerMessage) {
+func (s *SignalHandlerCandleStick) HandleMessage(msg *sarama.ConsumerMessage) error {
+	ctx := tracing.MessageContext(msg)
+	env, err := envelope.FromMessage(msg)
+	if err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error decoding message for candlestick signal", ctlog.Topic(msg.Topic), slog.Int64("offset", msg.Offset), ctlog.Err(err))
+		return kafka.Permanent(err)
+	}
 
 	var payload dtos.CandlestickWs
-	if err := json.Unmarshal(msg.Value, &payload); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling message for candlestick signal",
-			Message: "Error unmarshalling message into BSON: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(msg.Value),
-		})
-		return
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error unmarshalling message for candlestick signal", ctlog.Topic(msg.Topic), ctlog.Data(string(env.Payload)), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
 	// Check if the candlestick is closed
 	if !payload.Kline.IsKlineClosed {
-		log.Println("Candlestick is not closed, skipping...")
-		return
+		ctlog.For("candlestick").DebugContext(ctx, "Candlestick is not closed, skipping", ctlog.Symbol(payload.Symbol))
+		return nil
 	}
 
-	var pgDb = database.PgClient()
+	pgDb := s.db()
+	if pgDb == nil {
+		return errors.New("postgres is not initialized")
+	}
+	pgDb = pgDb.WithContext(ctx)
 	var interval entities.SignalInterval
 
-	err := pgDb.Where("symbol = ? and interval = ?", strings.ToLower(payload.Symbol), payload.Kline.Interval).First(&interval).Error
+	err = pgDb.Where("symbol = ? and interval = ?", strings.ToLower(payload.Symbol), payload.Kline.Interval).First(&interval).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// every derived interval is streamed, only the ones with a signal interval are tracked
+		return nil
+	}
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching intervals from Postgres",
-			Message: "Error fetching intervals from Postgres: " + err.Error(),
-			Type:    "error",
-			Entity:  "aggTrade",
-			Data:    string(msg.Value),
-		})
-		return
+		ctlog.For("candlestick").ErrorContext(ctx, "Error fetching intervals from Postgres", ctlog.Symbol(payload.Symbol), slog.String("interval", payload.Kline.Interval), ctlog.Err(err))
+		return err
 	}
 
 	key50 := fmt.Sprintf("%s:%s:ma50", payload.Symbol, interval.Interval)
 	key200 := fmt.Sprintf("%s:%s:ma200", payload.Symbol, interval.Interval)
 
-	rdb := cache.RedisClient()
-	pipe := rdb.Pipeline()
+	store := s.cache()
 	price, _ := decimal.NewFromString(payload.Kline.ClosePrice)
 
-	if exists, _ := rdb.Exists(context.Background(), key50).Result(); exists == 0 {
-		if exists, _ := rdb.Exists(context.Background(), key200).Result(); exists > 0 {
-			pipe.Del(context.Background(), key200)
-		}
-
+	if exists, _ := store.Exists(ctx, key50); !exists {
 		var candleSticks []entities.Candlestick
-		err = pgDb.Where("symbol = ? and interval = ?", payload.Symbol, payload.Kline.Interval).
+		end := payload.Kline.StartTime
+		err = pgDb.Where("symbol = ? and interval = ? and open_time BETWEEN ? AND ?", payload.Symbol, payload.Kline.Interval,
+			candlestick.WindowStart(payload.Kline.Interval, 200, end), end).
 			Order("open_time desc").Limit(200).Find(&candleSticks).Error
 
 		if err != nil || len(candleSticks) < 200 {
-			ctlog.CreateLog(&entities.Log{
-				Title:   "Error fetching candlesticks from Postgres",
-				Message: "Error fetching candlesticks from Postgres:",
-				Type:    "error",
-				Entity:  "candlestick",
-				Data:    string(msg.Value),
-			})
-
-			candleSticks, err = candlestick.GetCandleSticksAndUpdate(context.Background(), interval.ExchangeID.String(), payload.Symbol, interval.Interval, 200)
+			ctlog.For("candlestick").WarnContext(ctx, "Candlesticks missing in Postgres, fetching from API", ctlog.Symbol(payload.Symbol), slog.String("interval", interval.Interval), slog.Int("found", len(candleSticks)), ctlog.Err(err))
+
+			candleSticks, err = candlestick.GetCandleSticksAndUpdate(ctx, interval.ExchangeID.String(), payload.Symbol, interval.Interval, 200)
 			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error fetching candlesticks from API",
-					Message: "Error fetching candlesticks from API: " + err.Error(),
-					Type:    "error",
-					Entity:  "candlestick",
-					Data:    string(msg.Value),
-				})
-				return
+				ctlog.For("candlestick").ErrorContext(ctx, "Error fetching candlesticks from API", ctlog.Symbol(payload.Symbol), slog.String("interval", interval.Interval), ctlog.Err(err))
+				return err
 			}
 
 		}
 
-		var cnt = 0
+		closes := make([]string, 0, len(candleSticks))
 		for _, candle := range candleSticks {
-			if cnt < 50 {
-				pipe.RPush(context.Background(), key50, candle.Close.String())
-				cnt++
-			}
-			pipe.RPush(context.Background(), key200, candle.Close.String())
+			closes = append(closes, candle.Close.String())
 		}
 
+		// the averages are seeded together, a stale long window is dropped with the short one
+		err = store.Del(ctx, key200)
+		if err == nil {
+			err = store.PushList(ctx, key50, 0, closes[:min(50, len(closes))]...)
+		}
+		if err == nil {
+			err = store.PushList(ctx, key200, 0, closes...)
+		}
 	} else {
-		pipe.RPush(context.Background(), key50, price.String())
-		pipe.LTrim(context.Background(), key50, -50, -1)
-
-		pipe.RPush(context.Background(), key200, price.String())
-		pipe.LTrim(context.Background(), key200, -200, -1)
+		err = store.PushList(ctx, key50, 50, price.String())
+		if err == nil {
+			err = store.PushList(ctx, key200, 200, price.String())
+		}
 	}
 
-	_, err = pipe.Exec(context.Background())
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error executing Redis pipeline",
-			Message: "Error executing Redis pipeline: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(msg.Value),
-		})
+		ctlog.For("candlestick").ErrorContext(ctx, "Error updating moving averages in cache", ctlog.Symbol(payload.Symbol), slog.String("interval", interval.Interval), ctlog.Err(err))
+		return err
 	}
 
-	signal, err := checkForSignal(rdb, payload.Symbol, interval.Interval)
+	// the price is pushed now, a retry would push it twice so signal errors are only logged
+	signal, err := s.checkForSignal(ctx, store, payload.Symbol, interval.Interval)
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error checking for signal",
-			Message: "Error checking for signal: " + err.Error(),
-			Type:    "error",
-			Entity:  "signal",
-			Data:    string(msg.Value),
-		})
-		return
+		ctlog.For("signal").ErrorContext(ctx, "Error checking for signal", ctlog.Symbol(payload.Symbol), slog.String("interval", interval.Interval), ctlog.Err(err))
+		return nil
 	}
-	log.Printf("[%s][%s] Signal: %s", payload.Symbol, interval.Interval, signal)
-
+	ctlog.For("signal").InfoContext(ctx, "Signal checked", ctlog.Symbol(payload.Symbol), slog.String("interval", interval.Interval), slog.String("signal", signal.Signal))
+	return nil
 }
 
-func checkForSignal(rdb *redis.Client, symbol, timeframe string) (dtos.MaData, error) {
+func (s *SignalHandlerCandleStick) checkForSignal(ctx context.Context, store cache.Store, symbol, timeframe string) (dtos.MaData, error) {
 	var res dtos.MaData
 	key50 := fmt.Sprintf("%s:%s:ma50", symbol, timeframe)
 	key200 := fmt.Sprintf("%s:%s:ma200", symbol, timeframe)
 	lastSignalKey := fmt.Sprintf("%s:%s:lastSignal", symbol, timeframe)
 
-	pipe := rdb.Pipeline()
-	res50 := pipe.LRange(context.Background(), key50, 0, -1)
-	res200 := pipe.LRange(context.Background(), key200, 0, -1)
-
-	_, err := pipe.Exec(context.Background())
+	vals50, err := store.List(ctx, key50)
+	var vals200 []string
+	if err == nil {
+		vals200, err = store.List(ctx, key200)
+	}
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error executing Redis pipeline",
-			Message: "Error executing Redis pipeline: " + err.Error(),
-			Type:    "error",
-			Entity:  "signal",
-			Data:    fmt.Sprintf("Symbol: %s, Timeframe: %s", symbol, timeframe),
-		})
+		ctlog.For("signal").ErrorContext(ctx, "Error reading moving averages from cache", ctlog.Symbol(symbol), slog.String("interval", timeframe), ctlog.Err(err))
 		return res, fmt.Errorf("redis read error: %v", err)
 	}
 
-	var lastSignal string
-	lastSignalRes := rdb.Get(context.Background(), lastSignalKey)
-	if lastSignalRes.Err() != nil {
+	lastSignal, err := store.Get(ctx, lastSignalKey)
+	if err != nil {
 		lastSignal = consts.HoldSignal
-	} else {
-		lastSignal = lastSignalRes.Val()
 	}
 
-	vals50, _ := res50.Result()
-	vals200, _ := res200.Result()
-
 	if len(vals50) < 50 || len(vals200) < 200 {
 		return res, nil
 	}
@@ -204,43 +174,24 @@ func checkForSignal(rdb *redis.Client, symbol, timeframe string) (dtos.MaData, e
 	}
 
 	if ma50.GreaterThan(ma200) {
-		rdb.Set(context.Background(), lastSignalKey, consts.BuySignal, 0)
+		store.Set(ctx, lastSignalKey, consts.BuySignal)
 		res.Signal = consts.BuySignal
 		signal.Signal = consts.BuySignal
 	}
 
 	if ma50.LessThan(ma200) {
-		rdb.Set(context.Background(), lastSignalKey, consts.SellSignal, 0)
+		store.Set(ctx, lastSignalKey, consts.SellSignal)
 		res.Signal = consts.SellSignal
 		signal.Signal = consts.SellSignal
 	}
 
-	db := database.PgClient()
-	if err := db.Create(&signal).Error; err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error inserting signal into Postgres",
-			Message: "Error inserting signal into Postgres: " + err.Error(),
-			Type:    "error",
-			Entity:  "signal",
-			Data:    fmt.Sprintf("Symbol: %s, Signal: %s", signal.Symbol, signal.Signal),
-		})
-		log.Printf("Error inserting signal into Postgres: %v", err)
+	if err := s.db().WithContext(ctx).Create(&signal).Error; err != nil {
+		ctlog.For("signal").ErrorContext(ctx, "Error inserting signal into Postgres", ctlog.Symbol(signal.Symbol), slog.String("signal", signal.Signal), ctlog.Err(err))
 	}
 
 	//add to mongo signal data
-	mongoDb := database.MongoClient()
-	collName := consts.CollectionNameSignal
-	collection := mongoDb.Database(config.ReadValue().Mongo.Database).Collection(collName)
-	_, err = collection.InsertOne(context.Background(), signal)
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error inserting signal into MongoDB",
-			Message: "Error inserting signal into MongoDB: " + err.Error(),
-			Type:    "error",
-			Entity:  "signal",
-			Data:    fmt.Sprintf("Symbol: %s, Signal: %s", signal.Symbol, signal.Signal),
-		})
-		log.Printf("Error inserting signal into MongoDB: %v", err)
+	if err := s.store().InsertSignal(ctx, signal); err != nil {
+		ctlog.For("signal").ErrorContext(ctx, "Error inserting signal into MongoDB", ctlog.Symbol(signal.Symbol), slog.String("signal", signal.Signal), ctlog.Err(err))
 	}
 
 	return res, errors.New(consts.AlreadyInHold)
@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// DocumentStore keeps the documents of the pipeline, the frames with their outbox entries, the order book mirror and
+// the signals. MongoStore and MemoryStore implement it.
+type DocumentStore interface {
+	OutboxStore
+	// UpsertOrderBookLevels mirrors the levels of the symbol, closed levels only update a mirrored level
+	UpsertOrderBookLevels(ctx context.Context, symbol string, levels []entities.OrderBook) error
+	InsertSignal(ctx context.Context, signal entities.Signal) error
+}
+
+var (
+	_ DocumentStore = (*MongoStore)(nil)
+	_ DocumentStore = (*MemoryStore)(nil)
+)
+
+// Deps are the clients a handler works with, a nil client falls back to the initialized global one
+type Deps struct {
+	DB    func() *gorm.DB
+	Cache cache.Store
+	Store DocumentStore
+}
+
+func (d Deps) db() *gorm.DB {
+	if d.DB != nil {
+		return d.DB()
+	}
+	return database.PgClient()
+}
+
+func (d Deps) cache() cache.Store {
+	if d.Cache != nil {
+		return d.Cache
+	}
+	return cache.DefaultStore()
+}
+
+func (d Deps) store() DocumentStore {
+	if d.Store != nil {
+		return d.Store
+	}
+	return NewMongoStore()
+}
+
+// MongoStore keeps the documents in mongo, the outbox entries are kept in the frame collections
+type MongoStore struct{}
+
+func NewMongoStore() *MongoStore {
+	return &MongoStore{}
+}
+
+func (o *MongoStore) collection(name string) (*mongo.Collection, error) {
+	client := database.MongoClient()
+	if client == nil {
+		return nil, errMongoNotInitialized
+	}
+	return client.Database(config.ReadValue().Mongo.Database).Collection(name), nil
+}
+
+// EnsureIndexes creates the index the relay reads the pending entries with
+func (o *MongoStore) EnsureIndexes(ctx context.Context, collections ...string) error {
+	for _, name := range collections {
+		coll, err := o.collection(name)
+		if err != nil {
+			return err
+		}
+		_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: outboxField + ".published", Value: 1}, {Key: outboxField + ".created_at", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				outboxField + ".published": false,
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o *MongoStore) Save(ctx context.Context, collection, id string, doc bson.M, entry OutboxEntry) error {
+	coll, err := o.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	doc["_id"] = id
+	doc[outboxField] = entry
+	_, err = coll.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (o *MongoStore) Pending(ctx context.Context, collection string, limit int) ([]OutboxRecord, error) {
+	coll, err := o.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: outboxField + ".created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{outboxField: 1})
+	cursor, err := coll.Find(ctx, bson.M{outboxField + ".published": false}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []OutboxRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (o *MongoStore) MarkPublished(ctx context.Context, collection string, ids []string, at time.Time) error {
+	coll, err := o.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	_, err = coll.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{
+		"$set":   bson.M{outboxField + ".published": true, outboxField + ".published_at": at},
+		"$unset": bson.M{outboxField + ".value": "", outboxField + ".trace": ""},
+	})
+	return err
+}
+
+// UpsertOrderBookLevels mirrors the levels in the updated order book collection with one bulk write
+func (o *MongoStore) UpsertOrderBookLevels(ctx context.Context, symbol string, levels []entities.OrderBook) error {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(levels))
+	for _, level := range levels {
+		filter := bson.M{"symbol": symbol, "price": level.Price, "side": level.Side}
+		if level.Status == consts.ClosedOrder {
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{
+				"$set": bson.M{
+					"status":     level.Status,
+					"updated_at": time.Now(),
+				},
+			}))
+			continue
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpsert(true).SetUpdate(bson.M{
+			"$set": bson.M{
+				"symbol":    symbol,
+				"price":     level.Price,
+				"amount":    level.Amount,
+				"side":      level.Side,
+				"status":    level.Status,
+				"updatedAt": time.Now(),
+			},
+		}))
+	}
+
+	coll, err := o.collection(consts.CollectionNameUpdatedOrder)
+	if err != nil {
+		return err
+	}
+	_, err = coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (o *MongoStore) InsertSignal(ctx context.Context, signal entities.Signal) error {
+	coll, err := o.collection(consts.CollectionNameSignal)
+	if err != nil {
+		return err
+	}
+	_, err = coll.InsertOne(ctx, signal)
+	return err
+}
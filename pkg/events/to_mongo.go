@@ -1,126 +1,73 @@
 package events
 
 import (
-	"context"
-	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/IBM/sarama"
-	"github.com/SametAvcii/crypto-trade/internal/clients/database"
 	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
-	"github.com/SametAvcii/crypto-trade/pkg/config"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
-	"github.com/SametAvcii/crypto-trade/pkg/dtos"
-	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type MongoHandler struct{}
-
-func (d *MongoHandler) HandleMessage(msg *sarama.ConsumerMessage) {
+// MongoHandler stores the raw frames in mongo, the postgres fan-out is stored with the frame and produced by the
+// outbox relay, so a frame is never stored without its fan-out or fanned out twice under different ids
+type MongoHandler struct {
+	Outbox OutboxStore // nil keeps the outbox in mongo
+}
 
-	mongoData, err := insertMessageToMongo(msg)
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error inserting message into MongoDB",
-			Message: "Error inserting message into MongoDB: " + err.Error(),
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error inserting message into MongoDB: %v", err)
-		return
-	}
-	mongoID := mongoData.InsertedID.(primitive.ObjectID).Hex()
-	aggTradeMongo := dtos.MongoData{
-		MongoID: mongoID,
-		Value:   string(msg.Value),
+func (d *MongoHandler) outbox() OutboxStore {
+	if d.Outbox != nil {
+		return d.Outbox
 	}
-	jsonBytes, err := json.Marshal(aggTradeMongo)
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error marshalling Kafka message",
-			Message: "Error marshalling Kafka message: " + err.Error(),
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
+	return NewMongoStore()
+}
 
-		log.Printf("Error marshaling Kafka message: %v", err)
-		return
+func (d *MongoHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	ctx := tracing.MessageContext(msg)
+	env, err := envelope.FromMessage(msg)
+	if err != nil {
+		ctlog.For("trade").ErrorContext(ctx, "Error decoding message envelope", ctlog.Topic(msg.Topic), slog.Int64("offset", msg.Offset), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
-	client := kafka.KafkaClientNew()
 	pgTopic := getPgTopic(msg.Topic)
 	if pgTopic == "" {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error getting Postgres topic",
-			Message: "Error getting Postgres topic for Kafka message: ",
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error getting Postgres topic for Kafka message: %v", err)
-		return
+		ctlog.For("trade").ErrorContext(ctx, "Error getting Postgres topic", ctlog.Topic(msg.Topic), ctlog.Symbol(env.Symbol), ctlog.Data(string(env.Payload)))
+		return kafka.Permanent(fmt.Errorf("no postgres topic for %s", msg.Topic))
 	}
 
-	_, _, err = client.Produce(pgTopic, mongoID, jsonBytes)
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error sending message to Kafka",
-			Message: "Error sending message to Kafka: " + err.Error(),
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error sending message to postgres-topic: %v", err)
-	}
-	ctlog.CreateLog(&entities.Log{
-		Title:   "Message sent to Kafka",
-		Message: "Message sent to Kafka successfully",
-		Type:    "success",
-		Entity:  "trade",
-		Data:    string(msg.Value),
-	})
-	log.Printf("Message sent to postgres-topic successfully: %s", pgTopic)
-
-}
-
-func insertMessageToMongo(msg *sarama.ConsumerMessage) (*mongo.InsertOneResult, error) {
 	var doc bson.M
-	if err := bson.UnmarshalExtJSON(msg.Value, true, &doc); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling message",
-			Message: "Error unmarshalling message into BSON: " + err.Error(),
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error unmarshalling message into BSON: %v", err)
-		return nil, err
+	if err := bson.UnmarshalExtJSON(env.Payload, true, &doc); err != nil {
+		ctlog.For("trade").ErrorContext(ctx, "Error unmarshalling message", ctlog.Topic(msg.Topic), ctlog.Symbol(env.Symbol), ctlog.Data(string(env.Payload)), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
-	mongoClient := database.MongoClient()
-	collName := getCollectionName(msg.Topic)
-	collection := mongoClient.Database(config.ReadValue().Mongo.Database).Collection(collName)
+	// the id is derived from the frame, a redelivered frame finds its document and is not stored or fanned out again
+	id := frameID(msg, env)
+	env.DocumentID = id
+	if env.StreamType == "" {
+		env.StreamType = envelope.StreamType(msg.Topic)
+	}
 
-	mongoData, err := collection.InsertOne(context.Background(), doc)
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error inserting message into MongoDB",
-			Message: "Error inserting message into MongoDB: " + err.Error(),
-			Type:    "error",
-			Entity:  "trade",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error inserting message into MongoDB: %v", err)
-		return nil, err
+	entry := OutboxEntry{
+		Topic:     pgTopic,
+		Key:       outboxKey(msg, id),
+		Value:     env.Marshal(),
+		Trace:     tracing.Carrier(ctx),
+		CreatedAt: time.Now(),
+	}
+	if err := d.outbox().Save(ctx, getCollectionName(msg.Topic), id, doc, entry); err != nil {
+		ctlog.For("trade").ErrorContext(ctx, "Error inserting message into MongoDB", ctlog.Topic(msg.Topic), ctlog.Symbol(env.Symbol), ctlog.Data(string(env.Payload)), ctlog.Err(err))
+		return err
 	}
 
-	return mongoData, nil
+	ctlog.For("trade").InfoContext(ctx, "Message stored", ctlog.Topic(pgTopic), ctlog.Symbol(env.Symbol), ctlog.Exchange(env.ExchangeID), slog.String("id", id))
+	return nil
 }
 
 func getPgTopic(topic string) string {
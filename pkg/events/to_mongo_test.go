@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/stretchr/testify/assert"
 )
@@ -95,7 +96,8 @@ func TestMongoHandlerHandleMessage(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			handler.HandleMessage(tc.message)
+			err := handler.HandleMessage(tc.message)
+			assert.True(t, kafka.IsPermanent(err))
 		})
 	}
 }
\ No newline at end of file
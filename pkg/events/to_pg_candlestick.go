@@ -2,109 +2,94 @@ package events
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 
 	"github.com/IBM/sarama"
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
-type PgCandleStickHandler struct{}
-
-func (d *PgCandleStickHandler) HandleMessage(msg *sarama.ConsumerMessage) {
-
-	var mongoData dtos.MongoData
-	if err := json.Unmarshal([]byte(msg.Value), &mongoData); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling message for candlestick signal",
-			Message: "Error unmarshalling outer message structure: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error unmarshalling outer message structure: %v", err)
-		return
+type PgCandleStickHandler struct {
+	Deps
+	Writer *database.BatchWriter[entities.Candlestick] // nil writes every candle on its own
+}
+
+func (d *PgCandleStickHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	return d.HandleMessageDeferred(msg, nil)
+}
+
+// HandleMessageDeferred buffers the closed candle in the writer, done runs once it is stored or the message is skipped
+func (d *PgCandleStickHandler) HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error {
+	ctx := tracing.MessageContext(msg)
+	env, err := pgEnvelope(msg)
+	if err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error unmarshalling message for candlestick signal", ctlog.Topic(msg.Topic), slog.Int64("offset", msg.Offset), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
 	var payload dtos.CandlestickWs
-	if err := json.Unmarshal([]byte(mongoData.Value), &payload); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling candlestick data",
-			Message: "Error unmarshalling value field into CandlestickWs: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    mongoData.Value,
-		})
-		log.Printf("Error unmarshalling value field into CandlestickWs: %v", err)
-		return
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error unmarshalling candlestick data", ctlog.Topic(msg.Topic), ctlog.Symbol(env.Symbol), ctlog.Data(string(env.Payload)), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
 	if !payload.Kline.IsKlineClosed {
-		log.Println("Candlestick is not closed for write pg, skipping...")
-		return
+		ctlog.For("candlestick").DebugContext(ctx, "Candlestick is not closed, skipping", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Kline.Symbol))
+		markDone(done)
+		return nil
 	}
 
-	// Check if the candlestick is already in the database
-	if checkCandlestickExists(payload.Kline.Symbol, payload.Kline.StartTime) {
-		log.Println("Candlestick already exists, skipping...")
-		return
+	// derived bars carry their exchange in the payload, streamed klines in the envelope
+	if payload.ExchangeId == "" {
+		if payload.ExchangeId, err = exchangeID(env); err != nil {
+			ctlog.For("candlestick").ErrorContext(ctx, "Error resolving exchange of candlestick", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Kline.Symbol), ctlog.Err(err))
+			return err
+		}
 	}
+	done = observeLatency("candlesticks", payload.EventTime, done)
 
-	/*var symbol entities.Symbol
-	err := database.PgClient().Where("symbol = ?", payload.Kline.Symbol).First(&symbol).Error
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching symbol from Postgres",
-			Message: "Error fetching symbol from Postgres: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(mongoData.Value),
-		})
-		return
+	if d.Writer != nil {
+		d.Writer.Add(candlestickFromWs(payload), done)
+		return nil
 	}
-	var exchange entities.Exchange
-	err = database.PgClient().Where("id = ?", symbol.ExchangeID).First(&exchange).Error
-	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error fetching exchange from Postgres",
-			Message: "Error fetching exchange from Postgres: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(mongoData.Value),
-		})
-		return
-	}*/
-
-	//payload.ExchangeId = exchange.ID.String()
-
-	if err := insertCandlestick(payload); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error inserting candlestick into Postgres",
-			Message: "Error inserting candlestick into Postgres: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(mongoData.Value),
-		})
-		return
+
+	if err := insertCandlestick(d.db(), payload); err != nil {
+		ctlog.For("candlestick").ErrorContext(ctx, "Error inserting candlestick into Postgres", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Kline.Symbol), ctlog.Exchange(payload.ExchangeId), ctlog.Err(err))
+		return err
 	}
-	log.Println("Candlestick inserted into Postgres successfully")
+	ctlog.For("candlestick").InfoContext(ctx, "Candlestick upserted into Postgres", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Kline.Symbol), ctlog.Exchange(payload.ExchangeId))
+	markDone(done)
+	return nil
 }
 
-func checkCandlestickExists(symbol string, startTime int64) bool {
-	db := database.PgClient()
-	var candlestick entities.Candlestick
-	err := db.Model(&entities.Candlestick{}).Where("symbol = ? AND open_time = ?", symbol, startTime).First(&candlestick).Error
-	if err != nil {
-		return false
+func insertCandlestick(db *gorm.DB, payload dtos.CandlestickWs) error {
+	if db == nil {
+		return fmt.Errorf("postgres is not initialized")
 	}
-	return true
+	candlestick := candlestickFromWs(payload)
+
+	// replayed and re-derived klines overwrite the stored row
+	return db.Clauses(entities.CandlestickUpsert()).Create(&candlestick).Error
+}
+
+// WriteCandlesticks upserts a batch of candlesticks
+func WriteCandlesticks(db *gorm.DB, candles []entities.Candlestick) error {
+	return db.Clauses(entities.CandlestickUpsert()).Create(&candles).Error
 }
 
-func insertCandlestick(payload dtos.CandlestickWs) error {
-	db := database.PgClient()
+// CandlestickKey identifies the row a candlestick upserts
+func CandlestickKey(c entities.Candlestick) string {
+	return fmt.Sprintf("%s|%s|%s|%d", c.ExchangeId, c.Symbol, c.Interval, c.OpenTime)
+}
+
+func candlestickFromWs(payload dtos.CandlestickWs) entities.Candlestick {
 	openPrice, _ := decimal.NewFromString(payload.Kline.OpenPrice)
 	closePrice, _ := decimal.NewFromString(payload.Kline.ClosePrice)
 	highPrice, _ := decimal.NewFromString(payload.Kline.HighPrice)
@@ -115,7 +100,7 @@ func insertCandlestick(payload dtos.CandlestickWs) error {
 	takerBuyQuoteVolume, _ := decimal.NewFromString(payload.Kline.TakerBuyQuoteVolume)
 	ignore, _ := decimal.NewFromString(payload.Kline.Ignore)
 
-	candlestick := entities.Candlestick{
+	return entities.Candlestick{
 		Symbol:              payload.Kline.Symbol,
 		ExchangeId:          payload.ExchangeId,
 		OpenTime:            payload.Kline.StartTime,
@@ -132,6 +117,4 @@ func insertCandlestick(payload dtos.CandlestickWs) error {
 		TakerBuyQuoteVolume: takerBuyQuoteVolume,
 		Ignore:              ignore,
 	}
-
-	return db.Create(&candlestick).Error
 }
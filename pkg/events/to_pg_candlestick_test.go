@@ -0,0 +1,65 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	"github.com/SametAvcii/crypto-trade/pkg/envelope"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPgCandleStickHandlerHandleMessageDeferred(t *testing.T) {
+	handler := &PgCandleStickHandler{}
+
+	t.Run("Invalid message", func(t *testing.T) {
+		done := false
+		err := handler.HandleMessageDeferred(&sarama.ConsumerMessage{Value: []byte("invalid json")}, func(error) { done = true })
+		assert.True(t, kafka.IsPermanent(err))
+		assert.False(t, done)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		done := false
+		err := handler.HandleMessageDeferred(&sarama.ConsumerMessage{Value: []byte(`{"id":"1","value":"{"}`)}, func(error) { done = true })
+		assert.True(t, kafka.IsPermanent(err))
+		assert.False(t, done)
+	})
+
+	t.Run("Open candle is skipped", func(t *testing.T) {
+		done := false
+		err := handler.HandleMessageDeferred(&sarama.ConsumerMessage{Value: []byte(`{"id":"1","value":"{\"k\":{\"x\":false}}"}`)}, func(error) { done = true })
+		assert.NoError(t, err)
+		assert.True(t, done)
+	})
+}
+
+func TestPgEnvelope(t *testing.T) {
+	t.Run("Envelope", func(t *testing.T) {
+		env := envelope.Envelope{ExchangeID: "binance", Symbol: "BTCUSDT", Payload: []byte(`{"k":{"x":false}}`), DocumentID: "1"}
+		var headers []*sarama.RecordHeader
+		for _, h := range envelope.Headers() {
+			headers = append(headers, &h)
+		}
+
+		got, err := pgEnvelope(&sarama.ConsumerMessage{Value: env.Marshal(), Headers: headers})
+		assert.NoError(t, err)
+		assert.Equal(t, "binance", got.ExchangeID)
+		exchange, err := exchangeID(got)
+		assert.NoError(t, err)
+		assert.Equal(t, "binance", exchange)
+		assert.Equal(t, `{"k":{"x":false}}`, string(got.Payload))
+	})
+
+	t.Run("Legacy mongo data", func(t *testing.T) {
+		got, err := pgEnvelope(&sarama.ConsumerMessage{Key: []byte("BTCUSDT"), Value: []byte(`{"id":"1","value":"{\"k\":{\"x\":false}}"}`)})
+		assert.NoError(t, err)
+		assert.Zero(t, got.Version)
+		assert.Equal(t, "1", got.DocumentID)
+		assert.Equal(t, `{"k":{"x":false}}`, string(got.Payload))
+
+		// the exchange is not guessed from the symbol, the frame is dead-lettered
+		_, err = exchangeID(got)
+		assert.True(t, kafka.IsPermanent(err))
+	})
+}
@@ -3,236 +3,291 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
+	"sync"
 
 	"github.com/IBM/sarama"
 	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
-	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
 	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
 	"github.com/SametAvcii/crypto-trade/pkg/dtos"
 	"github.com/SametAvcii/crypto-trade/pkg/entities"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
 	"gorm.io/gorm"
 )
 
-type PgOrderBookHandler struct{}
+type PgOrderBookHandler struct {
+	Deps
+	// nil writers store every depth update on its own
+	Levels  *database.BatchWriter[entities.OrderBook]
+	History *database.BatchWriter[entities.OrderBookHistory]
 
-func (d *PgOrderBookHandler) HandleMessage(msg *sarama.ConsumerMessage) {
-	log.Printf("Received message from topic %s", msg.Topic)
-	var mongoData dtos.MongoData
-	if err := json.Unmarshal([]byte(msg.Value), &mongoData); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling message for candlestick signal",
-			Message: "Error unmarshalling outer message structure: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    string(msg.Value),
-		})
-		log.Printf("Error unmarshalling outer message structure: %v", err)
-		return
+	mu      sync.Mutex
+	pending map[string]*pendingBook // books handed to the writers and not stored yet, by symbol
+}
+
+// pendingBook is the latest book of a symbol while its writes are buffered, the next update is diffed against it
+// since the cached book only moves once the rows are stored
+type pendingBook struct {
+	bids, asks map[string]string
+	inflight   int
+}
+
+func (d *PgOrderBookHandler) HandleMessage(msg *sarama.ConsumerMessage) error {
+	return d.HandleMessageDeferred(msg, nil)
+}
+
+// HandleMessageDeferred buffers the level changes in the writers, done runs once they are stored
+func (d *PgOrderBookHandler) HandleMessageDeferred(msg *sarama.ConsumerMessage, done func(error)) error {
+	ctx := tracing.MessageContext(msg)
+	env, err := pgEnvelope(msg)
+	if err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error unmarshalling message for order book", ctlog.Topic(msg.Topic), slog.Int64("offset", msg.Offset), ctlog.Err(err))
+		return kafka.Permanent(err)
 	}
 
 	var payload dtos.OrderBook
-	if err := json.Unmarshal([]byte(mongoData.Value), &payload); err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error unmarshalling candlestick data",
-			Message: "Error unmarshalling value field into CandlestickWs: " + err.Error(),
-			Type:    "error",
-			Entity:  "candlestick",
-			Data:    mongoData.Value,
-		})
-		log.Printf("Error unmarshalling value field into CandlestickWs: %v", err)
-		return
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error unmarshalling order book data", ctlog.Topic(msg.Topic), ctlog.Symbol(env.Symbol), ctlog.Data(string(env.Payload)), ctlog.Err(err))
+		return kafka.Permanent(err)
+	}
+	if payload.Symbol == "" {
+		payload.Symbol = env.Symbol
 	}
 
-	if err := UpdateOrderBookData(payload.Symbol, payload.Bids, payload.Asks); err != nil {
-		log.Printf("Error updating order book data: %v", err)
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error updating order book data",
-			Message: "Error updating order book data: " + err.Error(),
-			Type:    "error",
-			Entity:  "order-book",
-			Data:    string(msg.Value),
-		})
+	exchange, err := exchangeID(env)
+	if err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error resolving exchange of order book", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Symbol), ctlog.Err(err))
+		return err
 	}
-	log.Printf("Order book data updated for symbol %s", payload.Symbol)
-	return
 
+	completion := database.NewCompletion(observeLatency("order_books", payload.EventTime, done))
+	if err := d.UpdateOrderBookData(exchange, payload.Symbol, payload.Bids, payload.Asks, completion); err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error updating order book data", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Symbol), ctlog.Exchange(env.ExchangeID), ctlog.Err(err))
+		// the completion is not released so done never runs for a failed update
+		return err
+	}
+	completion.Release()
+	ctlog.For("order-book").InfoContext(ctx, "Order book data updated", ctlog.Topic(msg.Topic), ctlog.Symbol(payload.Symbol), ctlog.Exchange(env.ExchangeID))
+	return nil
 }
 
-func UpdateOrderBookData(symbol string, bids, asks [][]string) error {
-
-	rdb := cache.RedisClient()
+// UpdateOrderBookData diffs the depth update against the latest book and stores the changed levels. The cached book
+// only moves once every row of the update is stored, so a failed or redelivered update is diffed the same way again.
+func (d *PgOrderBookHandler) UpdateOrderBookData(exchangeID, symbol string, bids, asks [][]string, completion *database.Completion) error {
+	store := d.cache()
 	bidKey := fmt.Sprintf("order-book-depth:%s:bids", symbol)
 	askKey := fmt.Sprintf("order-book-depth:%s:asks", symbol)
 
 	ctx := context.Background()
 
-	oldBids, _ := rdb.HGetAll(ctx, bidKey).Result()
-	oldAsks, _ := rdb.HGetAll(ctx, askKey).Result()
-
-	err := compareAndUpdate("bid", oldBids, bids, symbol)
+	oldBids, oldAsks, err := d.book(ctx, store, symbol, bidKey, askKey)
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error comparing and updating bids",
-			Message: fmt.Sprintf("Error comparing and updating bids for symbol %s: %v", symbol, err),
-			Type:    "error",
-			Entity:  "order-book",
-			Data:    fmt.Sprintf("Symbol: %s, Bids: %v", symbol, bids),
-		})
+		return err
 	}
 
-	err = compareAndUpdate("ask", oldAsks, asks, symbol)
+	var changes orderBookChanges
+	changes.diff(exchangeID, symbol, "bid", oldBids, bids)
+	changes.diff(exchangeID, symbol, "ask", oldAsks, asks)
+
+	// the mirror is written first, buffered rows can not be taken back once the writers have them
+	if err := d.store().UpsertOrderBookLevels(ctx, symbol, changes.levels); err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error updating order book levels in MongoDB", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), slog.Int("levels", len(changes.levels)), ctlog.Err(err))
+		return err
+	}
+
+	// closed levels leave the cached book so they are closed and recorded only once
+	done := completion.Add()
+	d.hold(symbol, bids, asks)
+	stored := database.NewCompletion(func(err error) {
+		if err == nil {
+			replaceLevels(ctx, store, bidKey, oldBids, bids)
+			replaceLevels(ctx, store, askKey, oldAsks, asks)
+		}
+		d.settle(symbol)
+		done(err)
+	})
+
+	if err := d.write(changes, stored); err != nil {
+		ctlog.For("order-book").ErrorContext(ctx, "Error writing order book levels", ctlog.Exchange(exchangeID), ctlog.Symbol(symbol), slog.Int("levels", len(changes.levels)), slog.Int("changes", len(changes.history)), ctlog.Err(err))
+		d.settle(symbol)
+		return err
+	}
+	stored.Release()
+	return nil
+}
+
+// book returns the book the update is diffed against, the pending one while earlier updates wait in the writers
+func (d *PgOrderBookHandler) book(ctx context.Context, store cache.Store, symbol, bidKey, askKey string) (map[string]string, map[string]string, error) {
+	d.mu.Lock()
+	if book, ok := d.pending[symbol]; ok {
+		d.mu.Unlock()
+		return book.bids, book.asks, nil
+	}
+	d.mu.Unlock()
+
+	bids, err := store.HGetAll(ctx, bidKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	asks, err := store.HGetAll(ctx, askKey)
 	if err != nil {
-		ctlog.CreateLog(&entities.Log{
-			Title:   "Error comparing and updating asks",
-			Message: fmt.Sprintf("Error comparing and updating asks for symbol %s: %v", symbol, err),
-			Type:    "error",
-			Entity:  "order-book",
-			Data:    fmt.Sprintf("Symbol: %s, Asks: %v", symbol, asks),
-		})
+		return nil, nil, err
 	}
+	return bids, asks, nil
+}
 
-	newBidMap := make(map[string]string)
-	for _, bid := range bids {
-		newBidMap[bid[0]] = bid[1]
+// hold makes the update the pending book of the symbol until its rows are stored
+func (d *PgOrderBookHandler) hold(symbol string, bids, asks [][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending == nil {
+		d.pending = make(map[string]*pendingBook)
+	}
+	book, ok := d.pending[symbol]
+	if !ok {
+		book = &pendingBook{}
+		d.pending[symbol] = book
 	}
-	rdb.HMSet(ctx, bidKey, newBidMap)
+	book.bids, book.asks = bookLevels(bids), bookLevels(asks)
+	book.inflight++
+}
 
-	newAskMap := make(map[string]string)
-	for _, ask := range asks {
-		newAskMap[ask[0]] = ask[1]
+// settle drops the pending book once the last update holding it is stored or failed, the cached book is read again
+func (d *PgOrderBookHandler) settle(symbol string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if book, ok := d.pending[symbol]; ok {
+		if book.inflight--; book.inflight <= 0 {
+			delete(d.pending, symbol)
+		}
 	}
-	rdb.HMSet(ctx, askKey, newAskMap)
+}
 
-	return nil
+// orderBookChanges are the writes of one depth update: every level to upsert and the levels that changed
+type orderBookChanges struct {
+	levels  []entities.OrderBook
+	history []entities.OrderBookHistory
 }
 
-func compareAndUpdate(side string, oldData map[string]string, newData [][]string, symbol string) error {
+func (c *orderBookChanges) diff(exchangeID, symbol, side string, oldData map[string]string, newData [][]string) {
 	newMap := make(map[string]string)
 	for _, entry := range newData {
 		newMap[entry[0]] = entry[1]
 	}
 
-	for price := range oldData {
+	for price, amount := range oldData {
 		newAmount, exists := newMap[price]
 		if !exists || newAmount == "0.00000000" {
-			err := UpdateStatusInDB(symbol, price, side, consts.ClosedOrder)
-			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error updating status in DB",
-					Message: fmt.Sprintf("Error updating status in DB for price %s: %v", price, err),
-					Type:    "error",
-					Entity:  "order-book",
-					Data:    fmt.Sprintf("Price: %s, Side: %s", price, side),
-				})
-				return err
-			}
+			c.levels = append(c.levels, orderBookLevel(exchangeID, symbol, side, price, amount, consts.ClosedOrder))
+			c.history = append(c.history, orderBookChange(exchangeID, symbol, side, price, "0", consts.ClosedOrder))
 		}
 	}
 
 	for _, entry := range newData {
 		price, amount := entry[0], entry[1]
-		if amount != "0.00000000" {
-			err := UpsertToDB(symbol, price, amount, side, consts.ActiveOrder)
-			if err != nil {
-				ctlog.CreateLog(&entities.Log{
-					Title:   "Error upserting to DB",
-					Message: fmt.Sprintf("Error upserting to DB for price %s: %v", price, err),
-					Type:    "error",
-					Entity:  "order-book",
-					Data:    fmt.Sprintf("Price: %s, Side: %s", price, side),
-				})
-				return err
-			}
+		if amount == "0.00000000" {
+			continue
+		}
+		c.levels = append(c.levels, orderBookLevel(exchangeID, symbol, side, price, amount, consts.ActiveOrder))
+		if oldData[price] != amount {
+			c.history = append(c.history, orderBookChange(exchangeID, symbol, side, price, amount, consts.ActiveOrder))
 		}
 	}
-	return nil
 }
 
-func UpdateStatusInDB(symbol, price, side, status string) error {
-	// PostgreSQL update
-	db := database.PgClient()
-	err := db.Model(&entities.OrderBook{}).Where("symbol = ? AND price = ? AND side = ?", symbol, price, side).
-		Updates(map[string]interface{}{
-			"status":     status,
-			"updated_at": time.Now(),
-		}).Error
-	if err != nil {
-		return err
+func (d *PgOrderBookHandler) write(changes orderBookChanges, completion *database.Completion) error {
+	if d.Levels != nil && d.History != nil {
+		for _, level := range changes.levels {
+			d.Levels.Add(level, completion.Add())
+		}
+		for _, change := range changes.history {
+			d.History.Add(change, completion.Add())
+		}
+		return nil
 	}
 
-	// MongoDB update
-	mongo := database.MongoClient()
-	collection := mongo.Database(config.ReadValue().Mongo.Database).Collection(consts.CollectionNameUpdatedOrder)
-	filter := bson.M{"symbol": symbol, "price": price, "side": side}
-	update := bson.M{
-		"$set": bson.M{
-			"status":     status,
-			"updated_at": time.Now(),
-		},
+	db := d.db()
+	if db == nil {
+		return fmt.Errorf("postgres is not initialized")
 	}
-	_, err = collection.UpdateOne(context.Background(), filter, update)
-	if err != nil {
+	if err := WriteOrderBookLevels(db, changes.levels); err != nil {
 		return err
 	}
-	return nil
+	return WriteOrderBookHistory(db, changes.history)
+}
+
+// WriteOrderBookLevels upserts the price levels with one statement, a level may appear once per call
+func WriteOrderBookLevels(db *gorm.DB, levels []entities.OrderBook) error {
+	if len(levels) == 0 {
+		return nil
+	}
+	return db.Clauses(entities.OrderBookUpsert()).Create(&levels).Error
 }
 
-func UpsertToDB(symbol, price, amount, side, status string) error {
-	db := database.PgClient()
-	var existing entities.OrderBook
-	err := db.Where("symbol = ? AND price = ? AND side = ?", symbol, price, side).First(&existing).Error
+// WriteOrderBookHistory records the price level changes in the daily partitioned history
+func WriteOrderBookHistory(db *gorm.DB, changes []entities.OrderBookHistory) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return db.Create(&changes).Error
+}
 
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			newOrder := entities.OrderBook{
-				Symbol: symbol,
-				Price:  price,
-				Amount: amount,
-				Side:   side,
-				Status: status,
-			}
-			err = db.Create(&newOrder).Error
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
+// OrderBookLevelKey identifies the row a price level upserts
+func OrderBookLevelKey(level entities.OrderBook) string {
+	return level.ExchangeId + "|" + level.Symbol + "|" + level.Side + "|" + level.Price
+}
+
+func orderBookLevel(exchangeID, symbol, side, price, amount, status string) entities.OrderBook {
+	return entities.OrderBook{
+		Symbol:     symbol,
+		ExchangeId: exchangeID,
+		Price:      price,
+		Amount:     amount,
+		Side:       side,
+		Status:     status,
+	}
+}
+
+func orderBookChange(exchangeID, symbol, side, price, amount, status string) entities.OrderBookHistory {
+	return entities.OrderBookHistory{
+		ExchangeId: exchangeID,
+		Symbol:     symbol,
+		Side:       side,
+		Price:      price,
+		Amount:     amount,
+		Status:     status,
+	}
+}
+
+// bookLevels returns the open levels of a depth update by price
+func bookLevels(data [][]string) map[string]string {
+	levels := make(map[string]string)
+	for _, entry := range data {
+		if entry[1] != "0.00000000" {
+			levels[entry[0]] = entry[1]
 		}
-	} else {
-		existing.Amount = amount
-		existing.Status = status
-		err = db.Save(&existing).Error
-		if err != nil {
-			return err
+	}
+	return levels
+}
+
+func replaceLevels(ctx context.Context, store cache.Store, key string, oldData map[string]string, newData [][]string) {
+	levels := bookLevels(newData)
+
+	var closed []string
+	for price := range oldData {
+		if _, ok := levels[price]; !ok {
+			closed = append(closed, price)
 		}
 	}
 
-	// MongoDB upsert
-	mongo := database.MongoClient()
-	collection := mongo.Database(config.ReadValue().Mongo.Database).Collection(consts.CollectionNameUpdatedOrder)
-	filter := bson.M{"symbol": symbol, "price": price, "side": side}
-	update := bson.M{
-		"$set": bson.M{
-			"symbol":    symbol,
-			"price":     price,
-			"amount":    amount,
-			"side":      side,
-			"status":    status,
-			"updatedAt": time.Now(),
-		},
-	}
-	opts := options.Update().SetUpsert(true)
-	_, err = collection.UpdateOne(context.Background(), filter, update, opts)
-	if err != nil {
-		return err
+	if len(closed) > 0 {
+		store.HDel(ctx, key, closed...)
+	}
+	if len(levels) > 0 {
+		store.HSet(ctx, key, levels)
 	}
-	return nil
 }
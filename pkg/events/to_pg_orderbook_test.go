@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestOrderBookChangesDiff(t *testing.T) {
+	old := map[string]string{
+		"100.00": "1.00000000",
+		"99.00":  "2.00000000",
+		"98.00":  "3.00000000",
+	}
+	update := [][]string{
+		{"100.00", "1.00000000"}, // unchanged
+		{"99.00", "0.00000000"},  // removed by the exchange
+		{"97.00", "4.00000000"},  // new level
+	}
+
+	var changes orderBookChanges
+	changes.diff("ex-1", "BTCUSDT", "bid", old, update)
+
+	levels := make(map[string]entities.OrderBook)
+	for _, level := range changes.levels {
+		levels[level.Price] = level
+	}
+	assert.Len(t, levels, 4)
+	assert.Equal(t, consts.ClosedOrder, levels["99.00"].Status)
+	assert.Equal(t, "2.00000000", levels["99.00"].Amount)
+	assert.Equal(t, consts.ClosedOrder, levels["98.00"].Status)
+	assert.Equal(t, consts.ActiveOrder, levels["100.00"].Status)
+	assert.Equal(t, consts.ActiveOrder, levels["97.00"].Status)
+
+	history := make(map[string]entities.OrderBookHistory)
+	for _, change := range changes.history {
+		history[change.Price] = change
+	}
+	assert.Len(t, history, 3, "the unchanged level is not recorded")
+	assert.Equal(t, "0", history["99.00"].Amount)
+	assert.Equal(t, "4.00000000", history["97.00"].Amount)
+}
+
+func TestOrderBookLevelKey(t *testing.T) {
+	a := entities.OrderBook{ExchangeId: "ex-1", Symbol: "BTCUSDT", Side: "bid", Price: "100.00", Amount: "1"}
+	b := entities.OrderBook{ExchangeId: "ex-1", Symbol: "BTCUSDT", Side: "bid", Price: "100.00", Amount: "2"}
+	c := entities.OrderBook{ExchangeId: "ex-1", Symbol: "BTCUSDT", Side: "ask", Price: "100.00", Amount: "2"}
+
+	assert.Equal(t, OrderBookLevelKey(a), OrderBookLevelKey(b))
+	assert.NotEqual(t, OrderBookLevelKey(a), OrderBookLevelKey(c))
+}
+
+func TestUpdateOrderBookDataCachesStoredBook(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.NoError(t, err)
+	pg := func() *gorm.DB { return db }
+
+	reject := false
+	var history []entities.OrderBookHistory
+	levels := database.NewBatchWriter("order_books", database.BatchConfig{}, pg, func(*gorm.DB, []entities.OrderBook) error {
+		return nil
+	}, OrderBookLevelKey)
+	changes := database.NewBatchWriter("order_book_history", database.BatchConfig{}, pg, func(_ *gorm.DB, rows []entities.OrderBookHistory) error {
+		if reject {
+			return errors.New("value too long")
+		}
+		history = append(history, rows...)
+		return nil
+	}, nil)
+
+	store := cache.NewMemoryStore()
+	handler := &PgOrderBookHandler{Deps: Deps{Cache: store, Store: NewMemoryStore()}, Levels: levels, History: changes}
+	update := func(bids [][]string) error {
+		var result error
+		completion := database.NewCompletion(func(err error) { result = err })
+		assert.NoError(t, handler.UpdateOrderBookData("ex-1", "BTCUSDT", bids, nil, completion))
+		completion.Release()
+		assert.NoError(t, levels.Flush(context.Background()))
+		assert.NoError(t, changes.Flush(context.Background()))
+		return result
+	}
+	cached := func() map[string]string {
+		book, err := store.HGetAll(context.Background(), "order-book-depth:BTCUSDT:bids")
+		assert.NoError(t, err)
+		return book
+	}
+
+	assert.NoError(t, update([][]string{{"100.00", "1.00000000"}}))
+	assert.Equal(t, map[string]string{"100.00": "1.00000000"}, cached())
+
+	// the buffered update is not cached yet, the next one is diffed against it
+	assert.NoError(t, handler.UpdateOrderBookData("ex-1", "BTCUSDT", [][]string{{"99.00", "2.00000000"}}, nil, database.NewCompletion(nil)))
+	assert.Equal(t, map[string]string{"100.00": "1.00000000"}, cached())
+	assert.NoError(t, handler.UpdateOrderBookData("ex-1", "BTCUSDT", [][]string{{"99.00", "3.00000000"}}, nil, database.NewCompletion(nil)))
+	history = nil
+	assert.NoError(t, levels.Flush(context.Background()))
+	assert.NoError(t, changes.Flush(context.Background()))
+	assert.Len(t, history, 3, "100.00 closes once, 99.00 opens and changes")
+	assert.Equal(t, map[string]string{"99.00": "3.00000000"}, cached())
+
+	// a rejected update leaves the cached book so it is diffed the same way when redelivered
+	reject = true
+	mock.ExpectPing()
+	assert.EqualError(t, update([][]string{{"98.00", "1.00000000"}}), "value too long")
+	assert.Equal(t, map[string]string{"99.00": "3.00000000"}, cached())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
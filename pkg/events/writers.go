@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+)
+
+// Writers are the batched Postgres writers shared by the consumer handlers
+type Writers struct {
+	Candles          *database.BatchWriter[entities.Candlestick]
+	OrderBooks       *database.BatchWriter[entities.OrderBook]
+	OrderBookHistory *database.BatchWriter[entities.OrderBookHistory]
+
+	wg sync.WaitGroup
+}
+
+func NewWriters(cfg database.BatchConfig) *Writers {
+	return &Writers{
+		Candles:          database.NewBatchWriter("candlesticks", cfg, database.PgClient, WriteCandlesticks, CandlestickKey),
+		OrderBooks:       database.NewBatchWriter("order_books", cfg, database.PgClient, WriteOrderBookLevels, OrderBookLevelKey),
+		OrderBookHistory: database.NewBatchWriter("order_book_history", cfg, database.PgClient, WriteOrderBookHistory, nil),
+	}
+}
+
+// Run flushes the writers until ctx is done
+func (w *Writers) Run(ctx context.Context) {
+	w.run(ctx, w.Candles.Run)
+	w.run(ctx, w.OrderBooks.Run)
+	w.run(ctx, w.OrderBookHistory.Run)
+}
+
+// Wait blocks until the writers made their final flush after ctx is done
+func (w *Writers) Wait() {
+	w.wg.Wait()
+}
+
+func (w *Writers) run(ctx context.Context, run func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		run(ctx)
+	}()
+}
+
+// markDone runs the done callback of a message stored without a writer, done is nil outside of a consumer
+func markDone(done func(error)) {
+	if done != nil {
+		done(nil)
+	}
+}
+
+// observeLatency wraps done to record the time from the exchange event to the stored row, frames without an event
+// time and failed rows are not measured
+func observeLatency(table string, eventTime int64, done func(error)) func(error) {
+	if eventTime <= 0 {
+		return done
+	}
+	return func(err error) {
+		if err == nil {
+			metrics.PipelineLatency.WithLabelValues(table).Observe(time.Since(time.UnixMilli(eventTime)).Seconds())
+		}
+		if done != nil {
+			done(err)
+		}
+	}
+}
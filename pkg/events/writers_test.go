@@ -0,0 +1,39 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func latencySamples(t *testing.T, table string) (uint64, float64) {
+	var m dto.Metric
+	assert.NoError(t, metrics.PipelineLatency.WithLabelValues(table).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestObserveLatency(t *testing.T) {
+	var marked int
+	observeLatency("latency_test", time.Now().Add(-2*time.Second).UnixMilli(), func(error) { marked++ })(nil)
+	assert.Equal(t, 1, marked)
+	count, sum := latencySamples(t, "latency_test")
+	assert.Equal(t, uint64(1), count)
+	assert.GreaterOrEqual(t, sum, 2.0)
+
+	// frames without an event time are stored but not measured
+	observeLatency("latency_test", 0, func(error) { marked++ })(nil)
+	assert.Equal(t, 2, marked)
+	count, _ = latencySamples(t, "latency_test")
+	assert.Equal(t, uint64(1), count)
+
+	// failed rows are not measured
+	observeLatency("latency_test", time.Now().UnixMilli(), func(error) { marked++ })(errors.New("rejected"))
+	assert.Equal(t, 3, marked)
+	count, _ = latencySamples(t, "latency_test")
+	assert.Equal(t, uint64(1), count)
+}
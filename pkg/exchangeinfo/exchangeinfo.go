@@ -0,0 +1,109 @@
+package exchangeinfo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/ctlog"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type Provider struct {
+	db *gorm.DB
+}
+
+func NewProvider(db *gorm.DB) *Provider {
+	return &Provider{
+		db: db,
+	}
+}
+
+// GetSymbolInfos pulls the exchange info of the exchange and returns the metadata of every listed symbol
+func (p *Provider) GetSymbolInfos(ctx context.Context, exchangeID string) ([]dtos.SymbolInfo, error) {
+	var exchange entities.Exchange
+	if err := p.db.WithContext(ctx).Where("id = ?", exchangeID).First(&exchange).Error; err != nil {
+		return nil, fmt.Errorf("exchange %s not found: %w", exchangeID, err)
+	}
+
+	api := utils.NewAPI(exchange.RestUrl)
+
+	switch exchange.Name {
+	case consts.Binance:
+		var info dtos.BinanceExchangeInfo
+		if err := api.Get(ctx, "/exchangeInfo", nil, &info); err != nil {
+			return nil, err
+		}
+		return ParseBinanceSymbols(info), nil
+	default:
+		return nil, fmt.Errorf("exchange %s not supported", exchange.Name)
+	}
+}
+
+func ParseBinanceSymbols(info dtos.BinanceExchangeInfo) []dtos.SymbolInfo {
+	symbols := make([]dtos.SymbolInfo, 0, len(info.Symbols))
+	for _, s := range info.Symbols {
+		symbol := dtos.SymbolInfo{
+			Symbol:              s.Symbol,
+			BaseAsset:           s.BaseAsset,
+			QuoteAsset:          s.QuoteAsset,
+			Status:              s.Status,
+			BaseAssetPrecision:  s.BaseAssetPrecision,
+			QuoteAssetPrecision: s.QuoteAssetPrecision,
+		}
+
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				symbol.TickSize, _ = decimal.NewFromString(f.TickSize)
+			case "LOT_SIZE":
+				symbol.StepSize, _ = decimal.NewFromString(f.StepSize)
+			case "NOTIONAL", "MIN_NOTIONAL":
+				symbol.MinNotional, _ = decimal.NewFromString(f.MinNotional)
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+type MetadataSyncer interface {
+	SyncMetadata(ctx context.Context, exchangeID string) error
+}
+
+// StartSync refreshes the symbol metadata of every active exchange on each tick until the context is done
+func StartSync(ctx context.Context, db *gorm.DB, syncer MetadataSyncer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		syncAll(ctx, db, syncer)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func syncAll(ctx context.Context, db *gorm.DB, syncer MetadataSyncer) {
+	var exchanges []entities.Exchange
+	if err := db.WithContext(ctx).Where("is_active = ?", entities.ExchangeActive).Find(&exchanges).Error; err != nil {
+		ctlog.For("symbol").ErrorContext(ctx, "Error fetching exchanges for symbol sync", ctlog.Err(err))
+		return
+	}
+
+	for _, exchange := range exchanges {
+		if err := syncer.SyncMetadata(ctx, exchange.ID.String()); err != nil {
+			ctlog.For("symbol").ErrorContext(ctx, "Error syncing symbol metadata", ctlog.Exchange(exchange.Name), ctlog.Err(err))
+			continue
+		}
+		ctlog.For("symbol").InfoContext(ctx, "Symbol metadata synced", ctlog.Exchange(exchange.Name))
+	}
+}
@@ -0,0 +1,69 @@
+package exchangeinfo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const binanceExchangeInfo = `{
+	"timezone": "UTC",
+	"serverTime": 1713000000000,
+	"rateLimits": [],
+	"symbols": [
+		{
+			"symbol": "BTCUSDT",
+			"status": "TRADING",
+			"baseAsset": "BTC",
+			"baseAssetPrecision": 8,
+			"quoteAsset": "USDT",
+			"quotePrecision": 8,
+			"quoteAssetPrecision": 8,
+			"orderTypes": ["LIMIT", "MARKET"],
+			"filters": [
+				{"filterType": "PRICE_FILTER", "minPrice": "0.01", "maxPrice": "1000000.00", "tickSize": "0.01"},
+				{"filterType": "LOT_SIZE", "minQty": "0.00001", "maxQty": "9000.00", "stepSize": "0.00001"},
+				{"filterType": "NOTIONAL", "minNotional": "5.00", "maxNotional": "9000000.00"}
+			]
+		},
+		{
+			"symbol": "OLDBTC",
+			"status": "HALT",
+			"baseAsset": "OLD",
+			"baseAssetPrecision": 8,
+			"quoteAsset": "BTC",
+			"quoteAssetPrecision": 8,
+			"filters": [
+				{"filterType": "MIN_NOTIONAL", "minNotional": "0.0001"}
+			]
+		}
+	]
+}`
+
+func TestParseBinanceSymbols(t *testing.T) {
+	var info dtos.BinanceExchangeInfo
+	assert.NoError(t, json.Unmarshal([]byte(binanceExchangeInfo), &info))
+
+	symbols := ParseBinanceSymbols(info)
+
+	assert.Len(t, symbols, 2)
+
+	btc := symbols[0]
+	assert.Equal(t, "BTCUSDT", btc.Symbol)
+	assert.Equal(t, "BTC", btc.BaseAsset)
+	assert.Equal(t, "USDT", btc.QuoteAsset)
+	assert.Equal(t, "TRADING", btc.Status)
+	assert.True(t, decimal.RequireFromString("0.01").Equal(btc.TickSize))
+	assert.True(t, decimal.RequireFromString("0.00001").Equal(btc.StepSize))
+	assert.True(t, decimal.RequireFromString("5").Equal(btc.MinNotional))
+	assert.Equal(t, 8, btc.BaseAssetPrecision)
+	assert.Equal(t, 8, btc.QuoteAssetPrecision)
+
+	old := symbols[1]
+	assert.Equal(t, "HALT", old.Status)
+	assert.True(t, decimal.RequireFromString("0.0001").Equal(old.MinNotional))
+	assert.True(t, old.TickSize.IsZero())
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+// Feeds tracks the last frame of every subscribed stream, a stream without a frame for longer than the max age is
+// stale. A nil Feeds tracks nothing.
+type Feeds struct {
+	maxAge  time.Duration
+	mu      sync.RWMutex
+	streams map[string]*feed
+}
+
+type feed struct {
+	subscribedAt time.Time
+	lastFrame    atomic.Int64 // unix nanoseconds, zero before the first frame
+}
+
+func NewFeeds(maxAge time.Duration) *Feeds {
+	if maxAge <= 0 {
+		maxAge = defaultFeedMaxAge
+	}
+	return &Feeds{maxAge: maxAge, streams: map[string]*feed{}}
+}
+
+// Subscribe starts tracking the stream, it is stale when no frame comes in within the max age. Subscribing a
+// stream again, like after a reconnect, keeps its last frame.
+func (f *Feeds) Subscribe(stream string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.streams[stream]; !ok {
+		f.streams[stream] = &feed{subscribedAt: now()}
+	}
+}
+
+// Seen records a frame of the stream, it only takes the read lock so the websocket readers do not contend
+func (f *Feeds) Seen(stream string) {
+	if f == nil {
+		return
+	}
+	f.mu.RLock()
+	s, ok := f.streams[stream]
+	f.mu.RUnlock()
+	if !ok {
+		f.Subscribe(stream)
+		f.mu.RLock()
+		s = f.streams[stream]
+		f.mu.RUnlock()
+	}
+	s.lastFrame.Store(now().UnixNano())
+}
+
+// Check fails with the stale streams
+func (f *Feeds) Check(context.Context) error {
+	var stale []string
+	for stream, res := range f.states() {
+		if res.Status != consts.HealthOK {
+			stale = append(stale, stream)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(stale)
+	return fmt.Errorf("%d stale feeds: %s", len(stale), strings.Join(stale, ", "))
+}
+
+// Details returns the freshness of every stream
+func (f *Feeds) Details() any {
+	return f.states()
+}
+
+func (f *Feeds) states() map[string]dtos.FeedRes {
+	if f == nil {
+		return nil
+	}
+	at := now()
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	states := make(map[string]dtos.FeedRes, len(f.streams))
+	for stream, s := range f.streams {
+		res := dtos.FeedRes{Status: consts.HealthOK}
+		since := s.subscribedAt
+		if nanos := s.lastFrame.Load(); nanos != 0 {
+			last := time.Unix(0, nanos)
+			res.LastFrameAt = &last
+			since = last
+		}
+		age := at.Sub(since)
+		res.AgeMs = age.Milliseconds()
+		if age > f.maxAge {
+			res.Status = consts.HealthDegraded
+		}
+		states[stream] = res
+	}
+	return states
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return at }
+	defer func() { now = time.Now }()
+
+	feeds := NewFeeds(time.Minute)
+	feeds.Subscribe("btcusdt@depth")
+	feeds.Subscribe("ethusdt@kline_1m")
+	feeds.Seen("btcusdt@depth")
+	assert.NoError(t, feeds.Check(context.Background()))
+
+	// the order book keeps streaming, the kline stream never sent a frame
+	at = at.Add(90 * time.Second)
+	feeds.Seen("btcusdt@depth")
+	at = at.Add(time.Second)
+
+	err := feeds.Check(context.Background())
+	assert.EqualError(t, err, "1 stale feeds: ethusdt@kline_1m")
+
+	states := feeds.Details().(map[string]dtos.FeedRes)
+	assert.Equal(t, consts.HealthOK, states["btcusdt@depth"].Status)
+	assert.Equal(t, int64(1000), states["btcusdt@depth"].AgeMs)
+	assert.Equal(t, consts.HealthDegraded, states["ethusdt@kline_1m"].Status)
+	assert.Nil(t, states["ethusdt@kline_1m"].LastFrameAt)
+	assert.Equal(t, int64(91000), states["ethusdt@kline_1m"].AgeMs)
+
+	// a reconnect keeps the last frame
+	feeds.Subscribe("btcusdt@depth")
+	assert.NotNil(t, feeds.Details().(map[string]dtos.FeedRes)["btcusdt@depth"].LastFrameAt)
+}
+
+func TestFeedsNil(t *testing.T) {
+	var feeds *Feeds
+	feeds.Subscribe("btcusdt@depth")
+	feeds.Seen("btcusdt@depth")
+	assert.NoError(t, feeds.Check(context.Background()))
+}
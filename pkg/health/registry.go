@@ -0,0 +1,164 @@
+// Package health keeps the readiness checks of a service. Every dependency registers a checker with its timeout,
+// a failing critical check takes the service down and any other failure degrades it.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+)
+
+const (
+	defaultTimeout    = 2 * time.Second
+	defaultFeedMaxAge = time.Minute
+)
+
+var now = time.Now
+
+// Checker checks one dependency, it should return once ctx is done
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to a Checker
+type CheckFunc func(ctx context.Context) error
+
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Detailer is a checker also reporting the state of its parts, like the freshness of every feed
+type Detailer interface {
+	Details() any
+}
+
+type Options struct {
+	Timeout  time.Duration // zero uses the timeout of the registry
+	Critical bool          // a failing critical check takes the service down, the others degrade it
+}
+
+type registered struct {
+	name    string
+	checker Checker
+	opts    Options
+}
+
+type Registry struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  []registered
+}
+
+// NewRegistry returns a registry whose checks time out after timeout unless they set their own
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Registry{timeout: timeout}
+}
+
+// Register adds the checker, registering a name again replaces its checker
+func (r *Registry) Register(name string, checker Checker, opts Options) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = r.timeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, check := range r.checks {
+		if check.name == name {
+			r.checks[i] = registered{name: name, checker: checker, opts: opts}
+			return
+		}
+	}
+	r.checks = append(r.checks, registered{name: name, checker: checker, opts: opts})
+}
+
+// Check runs every check in parallel, a check still running at its timeout fails without being waited for
+func (r *Registry) Check(ctx context.Context) dtos.HealthRes {
+	r.mu.RLock()
+	checks := append([]registered(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]dtos.HealthCheckRes, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	res := dtos.HealthRes{Status: consts.HealthOK, CheckedAt: now(), Checks: make(map[string]dtos.HealthCheckRes, len(checks))}
+	for i, check := range checks {
+		res.Checks[check.name] = results[i]
+		res.Status = worst(res.Status, results[i].Status)
+	}
+	return res
+}
+
+func run(ctx context.Context, check registered) dtos.HealthCheckRes {
+	ctx, cancel := context.WithTimeout(ctx, check.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- check.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", check.opts.Timeout)
+	}
+
+	res := dtos.HealthCheckRes{
+		Status:    consts.HealthOK,
+		Critical:  check.opts.Critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		res.Status = consts.HealthDegraded
+		if check.opts.Critical {
+			res.Status = consts.HealthDown
+		}
+		res.Error = err.Error()
+	}
+	if detailer, ok := check.checker.(Detailer); ok {
+		res.Details = detailer.Details()
+	}
+	return res
+}
+
+func worst(a, b string) string {
+	rank := map[string]int{consts.HealthOK: 0, consts.HealthDegraded: 1, consts.HealthDown: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Timeout is the configured timeout of a dependency check
+func Timeout(cfg config.Health) time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+// FeedMaxAge is the configured time a stream may go without a frame
+func FeedMaxAge(cfg config.Health) time.Duration {
+	if cfg.FeedMaxAge > 0 {
+		return time.Duration(cfg.FeedMaxAge) * time.Second
+	}
+	return defaultFeedMaxAge
+}
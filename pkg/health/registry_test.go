@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func ok(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused") }
+
+// detailed is a checker reporting its parts
+type detailed struct{}
+
+func (detailed) Check(context.Context) error { return nil }
+func (detailed) Details() any                { return map[string]string{"btcusdt@depth": consts.HealthOK} }
+
+func TestRegistryCheck(t *testing.T) {
+	t.Run("Ok", func(t *testing.T) {
+		registry := NewRegistry(time.Second)
+		registry.Register("postgres", CheckFunc(ok), Options{Critical: true})
+		registry.Register("feeds", detailed{}, Options{})
+
+		res := registry.Check(context.Background())
+		assert.Equal(t, consts.HealthOK, res.Status)
+		assert.Len(t, res.Checks, 2)
+		assert.True(t, res.Checks["postgres"].Critical)
+		assert.Equal(t, map[string]string{"btcusdt@depth": consts.HealthOK}, res.Checks["feeds"].Details)
+	})
+
+	t.Run("Non critical failure degrades", func(t *testing.T) {
+		registry := NewRegistry(time.Second)
+		registry.Register("postgres", CheckFunc(ok), Options{Critical: true})
+		registry.Register("redis", CheckFunc(failing), Options{})
+
+		res := registry.Check(context.Background())
+		assert.Equal(t, consts.HealthDegraded, res.Status)
+		assert.Equal(t, consts.HealthDegraded, res.Checks["redis"].Status)
+		assert.Equal(t, "connection refused", res.Checks["redis"].Error)
+	})
+
+	t.Run("Critical failure is down", func(t *testing.T) {
+		registry := NewRegistry(time.Second)
+		registry.Register("postgres", CheckFunc(failing), Options{Critical: true})
+		registry.Register("redis", CheckFunc(failing), Options{})
+
+		res := registry.Check(context.Background())
+		assert.Equal(t, consts.HealthDown, res.Status)
+		assert.Equal(t, consts.HealthDown, res.Checks["postgres"].Status)
+	})
+
+	t.Run("Hanging check times out", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		registry := NewRegistry(time.Second)
+		registry.Register("kafka", CheckFunc(func(context.Context) error {
+			// ignores its context like the sarama metadata refresh
+			<-release
+			return nil
+		}), Options{Critical: true, Timeout: 20 * time.Millisecond})
+
+		start := time.Now()
+		res := registry.Check(context.Background())
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, consts.HealthDown, res.Status)
+		assert.Equal(t, "timed out after 20ms", res.Checks["kafka"].Error)
+	})
+
+	t.Run("Register replaces", func(t *testing.T) {
+		registry := NewRegistry(0)
+		registry.Register("redis", CheckFunc(failing), Options{})
+		registry.Register("redis", CheckFunc(ok), Options{})
+
+		res := registry.Check(context.Background())
+		assert.Equal(t, consts.HealthOK, res.Status)
+		assert.Len(t, res.Checks, 1)
+	})
+}
@@ -76,6 +76,96 @@ var (
 		},
 		[]string{"path"},
 	)
+
+	ProducerMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_producer_messages_total",
+			Help: "Messages handed to the async Kafka producer by delivery status.",
+		},
+		[]string{"topic", "status"},
+	)
+
+	ProducerBuffered = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "kafka_producer_buffered_messages",
+			Help: "Messages waiting in the async Kafka producer buffer.",
+		},
+	)
+
+	ProducerLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kafka_producer_delivery_seconds",
+			Help:    "Time from sending a message until the broker acknowledged it.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"topic"},
+	)
+
+	ConsumerProcessed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_messages_processed_total",
+			Help: "Messages a consumer group finished, handled or dead lettered.",
+		},
+		[]string{"group", "topic", "partition"},
+	)
+
+	ConsumerDeadLettered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_messages_dead_lettered_total",
+			Help: "Messages a consumer group moved to the dead letter topic.",
+		},
+		[]string{"group", "topic"},
+	)
+
+	ConsumerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_handler_errors_total",
+			Help: "Failed handler attempts by error type.",
+		},
+		[]string{"group", "topic", "type"},
+	)
+
+	ConsumerHandleDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kafka_consumer_handler_duration_seconds",
+			Help:    "Duration of a handler attempt.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+		[]string{"group", "topic"},
+	)
+
+	ConsumerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "High water mark of a partition minus the offset the group committed.",
+		},
+		[]string{"group", "topic", "partition"},
+	)
+
+	ExchangeUsedWeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "exchange_used_request_weight",
+			Help: "Request weight the exchange reports it counted against this ip per interval.",
+		},
+		[]string{"host", "interval"},
+	)
+
+	LogEntriesDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_entries_dropped_total",
+			Help: "Log entries a sink dropped because its queue was full or the write failed.",
+		},
+		[]string{"sink", "reason"},
+	)
+
+	PipelineLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pipeline_latency_seconds",
+			Help:    "Time from the exchange event until its rows are written to Postgres.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		},
+		[]string{"table"},
+	)
 )
 
 func Register() {
@@ -89,5 +179,22 @@ func Register() {
 		StatusCodeCounter,
 		RequestMethodCounter,
 		RequestPathCounter,
+		ProducerMessages,
+		ProducerBuffered,
+		ProducerLatency,
+		ExchangeUsedWeight,
+		LogEntriesDropped,
+	)
+}
+
+// RegisterConsumer registers the metrics of the consumer process
+func RegisterConsumer() {
+	prometheus.MustRegister(
+		ConsumerProcessed,
+		ConsumerDeadLettered,
+		ConsumerErrors,
+		ConsumerHandleDuration,
+		ConsumerLag,
+		PipelineLatency,
 	)
 }
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/entities"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Principal is the caller of an authenticated request, a user with a token or a service with an api key
+type Principal struct {
+	ID     string // user id or api key id
+	Name   string // username or api key name
+	Role   string // admin, trader, viewer
+	Method string // token or api_key
+}
+
+// APIKeyVerifier resolves the key of the data-api-key header, unknown, revoked and expired keys return
+// utils.ErrAPIKeyInvalid
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (dtos.APIKeyRes, error)
+}
+
+// Auth lets through the requests with a valid access token in the Authorization header or, when keys is set, a valid
+// key in the data-api-key header and keeps the caller in the context, the others are rejected with 401
+func Auth(j *utils.JwtWrapper, keys APIKeyVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(consts.APIKeyHeader); key != "" && keys != nil {
+			res, err := keys.VerifyAPIKey(c.Request.Context(), key)
+			if errors.Is(err, utils.ErrAPIKeyInvalid) {
+				unauthorized(c, consts.AuthInvalidAPIKey)
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error":  err.Error(),
+					"status": http.StatusInternalServerError,
+				})
+				return
+			}
+			setCaller(c, Principal{ID: res.ID, Name: res.Name, Role: res.Role, Method: consts.AuthMethodAPIKey})
+			c.Next()
+			return
+		}
+
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			unauthorized(c, consts.AuthMissingToken)
+			return
+		}
+
+		claims, err := j.ParseClaims(token)
+		if errors.Is(err, utils.ErrTokenExpired) {
+			unauthorized(c, consts.AuthExpiredToken)
+			return
+		}
+		// refresh tokens only buy new tokens, they do not open the api
+		if err != nil || claims.TokenType != consts.TokenTypeAccess {
+			unauthorized(c, consts.AuthInvalidToken)
+			return
+		}
+
+		setCaller(c, Principal{ID: claims.UserId, Name: claims.UserName, Role: claims.Role, Method: consts.AuthMethodToken})
+		c.Next()
+	}
+}
+
+// setCaller keeps the principal in the gin context and as the audit actor in the request context, so the changes the
+// request makes are recorded with it
+func setCaller(c *gin.Context, p Principal) {
+	c.Set(consts.ClaimsKey, p)
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.Actor{ID: p.ID, Name: p.Name, Method: p.Method}))
+}
+
+// Caller returns the principal of the authenticated request
+func Caller(c *gin.Context) (Principal, bool) {
+	principal, ok := c.Get(consts.ClaimsKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := principal.(Principal)
+	return p, ok
+}
+
+// Authorize needs the read role for GET and HEAD requests and the write role for the others, it runs after Auth and
+// rejects the callers without the role with 403
+func Authorize(read, write string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		required := write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			required = read
+		}
+
+		p, ok := Caller(c)
+		if !ok {
+			unauthorized(c, consts.AuthMissingToken)
+			return
+		}
+		if !entities.RoleAllows(p.Role, required) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":  consts.AuthForbidden,
+				"status": http.StatusForbidden,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole needs the role for every method
+func RequireRole(role string) gin.HandlerFunc {
+	return Authorize(role, role)
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, consts.BearerScheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", consts.BearerScheme)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error":  msg,
+		"status": http.StatusUnauthorized,
+	})
+}
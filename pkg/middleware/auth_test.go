@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/dtos"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwt := &utils.JwtWrapper{SecretKey: "secret", Issuer: "crypto-trade"}
+
+	router := gin.New()
+	router.GET("/api/v1/symbol", Auth(jwt, nil), func(c *gin.Context) {
+		p, ok := Caller(c)
+		assert.True(t, ok)
+		assert.Equal(t, consts.AuthMethodToken, p.Method)
+		assert.Equal(t, audit.Actor{ID: "user-1", Name: "trader", Method: consts.AuthMethodToken}, audit.ActorFrom(c.Request.Context()))
+		c.String(http.StatusOK, p.Name)
+	})
+
+	token := func(j *utils.JwtWrapper, tokenType string, expire time.Duration) string {
+		signed, err := j.GenerateToken("trader", "user-1", consts.RoleTrader, tokenType, expire)
+		assert.NoError(t, err)
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{name: "Valid token", header: "Bearer " + token(jwt, consts.TokenTypeAccess, time.Hour), status: http.StatusOK, body: "trader"},
+		{name: "Missing token", header: "", status: http.StatusUnauthorized, body: consts.AuthMissingToken},
+		{name: "Not bearer", header: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized, body: consts.AuthMissingToken},
+		{name: "Expired token", header: "Bearer " + token(jwt, consts.TokenTypeAccess, -time.Minute), status: http.StatusUnauthorized, body: consts.AuthExpiredToken},
+		{name: "Refresh token", header: "Bearer " + token(jwt, consts.TokenTypeRefresh, time.Hour), status: http.StatusUnauthorized, body: consts.AuthInvalidToken},
+		{name: "Other secret", header: "Bearer " + token(&utils.JwtWrapper{SecretKey: "other", Issuer: "crypto-trade"}, consts.TokenTypeAccess, time.Hour), status: http.StatusUnauthorized, body: consts.AuthInvalidToken},
+		{name: "Other issuer", header: "Bearer " + token(&utils.JwtWrapper{SecretKey: "secret", Issuer: "other"}, consts.TokenTypeAccess, time.Hour), status: http.StatusUnauthorized, body: consts.AuthInvalidToken},
+		{name: "Malformed token", header: "Bearer abc.def.ghi", status: http.StatusUnauthorized, body: consts.AuthInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/symbol", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+		})
+	}
+}
+
+type fakeKeys map[string]dtos.APIKeyRes
+
+func (f fakeKeys) VerifyAPIKey(ctx context.Context, key string) (dtos.APIKeyRes, error) {
+	if key == "broken" {
+		return dtos.APIKeyRes{}, errors.New("connection refused")
+	}
+	res, ok := f[key]
+	if !ok {
+		return dtos.APIKeyRes{}, utils.ErrAPIKeyInvalid
+	}
+	return res, nil
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwt := &utils.JwtWrapper{SecretKey: "secret", Issuer: "crypto-trade"}
+	keys := fakeKeys{"ct_valid": {ID: "key-1", Name: "collector", Role: consts.RoleViewer}}
+
+	router := gin.New()
+	router.GET("/api/v1/orderbook", Auth(jwt, keys), func(c *gin.Context) {
+		p, ok := Caller(c)
+		assert.True(t, ok)
+		assert.Equal(t, consts.AuthMethodAPIKey, p.Method)
+		c.String(http.StatusOK, p.Name+":"+p.Role)
+	})
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+		body   string
+	}{
+		{name: "Valid key", key: "ct_valid", status: http.StatusOK, body: "collector:viewer"},
+		{name: "Unknown key", key: "ct_other", status: http.StatusUnauthorized, body: consts.AuthInvalidAPIKey},
+		{name: "Lookup error", key: "broken", status: http.StatusInternalServerError, body: "connection refused"},
+		{name: "No key nor token", key: "", status: http.StatusUnauthorized, body: consts.AuthMissingToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/orderbook", nil)
+			if tt.key != "" {
+				req.Header.Set(consts.APIKeyHeader, tt.key)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	group := router.Group("/api/v1/exchange", func(c *gin.Context) {
+		if role := c.GetHeader("X-Role"); role != "" {
+			c.Set(consts.ClaimsKey, Principal{ID: "user-1", Name: "user", Role: role, Method: consts.AuthMethodToken})
+		}
+	}, Authorize(consts.RoleViewer, consts.RoleAdmin))
+	group.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	group.POST("/", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	tests := []struct {
+		name   string
+		method string
+		role   string
+		status int
+	}{
+		{name: "Viewer reads", method: http.MethodGet, role: consts.RoleViewer, status: http.StatusOK},
+		{name: "Viewer writes", method: http.MethodPost, role: consts.RoleViewer, status: http.StatusForbidden},
+		{name: "Trader writes", method: http.MethodPost, role: consts.RoleTrader, status: http.StatusForbidden},
+		{name: "Admin writes", method: http.MethodPost, role: consts.RoleAdmin, status: http.StatusCreated},
+		{name: "Unknown role", method: http.MethodGet, role: "guest", status: http.StatusForbidden},
+		{name: "No caller", method: http.MethodGet, role: "", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/api/v1/exchange/", nil)
+			req.Header.Set("X-Role", tt.role)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -38,6 +38,4 @@ func PrometheusMiddleware() gin.HandlerFunc {
 		}
 	}
 }
-
-//maybe write jwt middleware for routes
  
\ No newline at end of file
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits the requests of every caller in the route group to the rule, callers are told apart by api key,
+// user or ip in that order so it runs after Auth, a limiter failure lets the request through
+func RateLimit(l cache.Limiter, group string, rule config.RateLimitRule) gin.HandlerFunc {
+	return rateLimit(l, group, rule, rateLimitKey)
+}
+
+// IPRateLimit limits the requests of every client ip in the route group to the rule, it runs before Auth so invalid
+// tokens and api keys are limited before they cost a lookup
+func IPRateLimit(l cache.Limiter, group string, rule config.RateLimitRule) gin.HandlerFunc {
+	return rateLimit(l, group, rule, func(c *gin.Context) string { return "ip:" + c.ClientIP() })
+}
+
+func rateLimit(l cache.Limiter, group string, rule config.RateLimitRule, key func(c *gin.Context) string) gin.HandlerFunc {
+	window := time.Duration(rule.Window) * time.Second
+	if rule.Limit <= 0 || window <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		res, err := l.Allow(c.Request.Context(), "ratelimit:"+group+":"+key(c), rule.Limit, window)
+		if err != nil {
+			log.Println("Error checking rate limit: ", err.Error())
+			c.Next()
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(res.Reset.Seconds())))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", reset) // seconds until the window ends
+		if !res.Allowed {
+			c.Header("Retry-After", reset)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":  consts.RateLimitExceeded,
+				"status": http.StatusTooManyRequests,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitKey(c *gin.Context) string {
+	p, ok := Caller(c)
+	switch {
+	case ok && p.Method == consts.AuthMethodAPIKey:
+		return "key:" + p.ID
+	case ok:
+		return "user:" + p.ID
+	default:
+		return "ip:" + c.ClientIP()
+	}
+}
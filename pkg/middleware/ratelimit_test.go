@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := cache.NewMemoryLimiter()
+
+	router := gin.New()
+	group := router.Group("/api/v1/candles", func(c *gin.Context) {
+		if key := c.GetHeader("X-Key"); key != "" {
+			c.Set(consts.ClaimsKey, Principal{ID: key, Role: consts.RoleViewer, Method: consts.AuthMethodAPIKey})
+		}
+	}, RateLimit(limiter, "candles", config.RateLimitRule{Limit: 2, Window: 60}))
+	group.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/v1/open", RateLimit(limiter, "open", config.RateLimitRule{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	call := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		if key != "" {
+			req.Header.Set("X-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("/api/v1/candles/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, call("/api/v1/candles/", "").Code)
+	w = call("/api/v1/candles/", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), consts.RateLimitExceeded)
+
+	// an api key from the same ip has its own limit
+	assert.Equal(t, http.StatusOK, call("/api/v1/candles/", "key-1").Code)
+
+	// a group without a limit is not counted
+	for i := 0; i < 5; i++ {
+		w = call("/api/v1/open", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestIPRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := cache.NewMemoryLimiter()
+
+	// the credentials are checked after the ip limit, an invalid key is refused by them
+	lookups := 0
+	router := gin.New()
+	router.GET("/api/v1/symbol", IPRateLimit(limiter, "ip", config.RateLimitRule{Limit: 2, Window: 60}), func(c *gin.Context) {
+		lookups++
+		c.AbortWithStatus(http.StatusUnauthorized)
+	})
+
+	call := func(addr string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/symbol", nil)
+		req.RemoteAddr = addr
+		req.Header.Set(consts.APIKeyHeader, "invalid")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1:4000"))
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1:4001"))
+	assert.Equal(t, http.StatusTooManyRequests, call("10.0.0.1:4002"))
+	assert.Equal(t, 2, lookups)
+
+	// another ip has its own limit
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.2:4000"))
+}
@@ -0,0 +1,172 @@
+// Package secrets seals credentials with envelope encryption, every value gets its own data key which is wrapped by a
+// master key, rotating the master key only rewraps the data keys.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+)
+
+const keySize = 32 // AES-256
+
+var (
+	ErrNoMasterKey      = errors.New("no master key is configured")
+	ErrConfigMasterKey  = errors.New("the active master key comes from the config, set MASTER_KEY outside of development")
+	ErrUnknownMasterKey = errors.New("sealed with an unknown master key")
+	ErrDecrypt          = errors.New("secret can not be decrypted")
+)
+
+// Envelope is a sealed value, the data key it is encrypted with is stored next to it wrapped by the master key
+type Envelope struct {
+	MasterKeyID string
+	WrappedKey  []byte // nonce + data key encrypted with the master key
+	Ciphertext  []byte // nonce + value encrypted with the data key
+}
+
+// Keyring holds the master keys, values are sealed with the active key and opened with the key they were sealed with
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// NewKeyring takes base64 encoded 32 byte keys by id
+func NewKeyring(active string, keys map[string]string) (*Keyring, error) {
+	k := &Keyring{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, encoded := range keys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", id, err)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("master key %s is %d bytes, it must be %d", id, len(raw), keySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[active]; !ok {
+		return nil, ErrNoMasterKey
+	}
+	return k, nil
+}
+
+// NewKeyringFromConfig reads the master keys of the config, a MASTER_KEY env set to id:base64 is added to them and
+// becomes the active key so the key can stay out of config files. Outside of development a config key can only open
+// and rewrap what it sealed, it is refused as the active key.
+func NewKeyringFromConfig(cfg config.Secrets) (*Keyring, error) {
+	keys := make(map[string]string, len(cfg.MasterKeys)+1)
+	for _, k := range cfg.MasterKeys {
+		keys[k.ID] = k.Key
+	}
+	active := cfg.ActiveKey
+	if env := os.Getenv("MASTER_KEY"); env != "" {
+		id, key, ok := strings.Cut(env, ":")
+		if !ok {
+			return nil, errors.New("MASTER_KEY must be id:base64")
+		}
+		keys[id] = key
+		active = id
+	} else if active != "" && !cfg.Development {
+		return nil, ErrConfigMasterKey
+	}
+	return NewKeyring(active, keys)
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Seal encrypts the value with a new data key and wraps the data key with the active master key
+func (k *Keyring) Seal(plaintext []byte) (Envelope, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Envelope{}, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	ciphertext, err := seal(aead, plaintext)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{MasterKeyID: k.active, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open unwraps the data key with the master key the envelope was sealed with and decrypts the value
+func (k *Keyring) Open(e Envelope) ([]byte, error) {
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, e.Ciphertext)
+}
+
+// Rewrap wraps the data key of the envelope with the active master key, the ciphertext is left as it is
+func (k *Keyring) Rewrap(e Envelope) (Envelope, error) {
+	if e.MasterKeyID == k.active {
+		return e, nil
+	}
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{MasterKeyID: k.active, WrappedKey: wrapped, Ciphertext: e.Ciphertext}, nil
+}
+
+func (k *Keyring) unwrap(e Envelope) ([]byte, error) {
+	master, ok := k.keys[e.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, e.MasterKeyID)
+	}
+	return open(master, e.WrappedKey)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,137 @@
+package secrets_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestSealOpen(t *testing.T) {
+	keys, err := secrets.NewKeyring("k1", map[string]string{"k1": newKey(t)})
+	require.NoError(t, err)
+
+	envelope, err := keys.Seal([]byte("api-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "k1", envelope.MasterKeyID)
+	assert.False(t, bytes.Contains(envelope.Ciphertext, []byte("api-secret")))
+
+	plain, err := keys.Open(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "api-secret", string(plain))
+
+	t.Run("Tampered ciphertext", func(t *testing.T) {
+		tampered := envelope
+		tampered.Ciphertext = append([]byte(nil), envelope.Ciphertext...)
+		tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+		_, err := keys.Open(tampered)
+		assert.ErrorIs(t, err, secrets.ErrDecrypt)
+	})
+
+	t.Run("Unknown master key", func(t *testing.T) {
+		other, err := secrets.NewKeyring("k2", map[string]string{"k2": newKey(t)})
+		require.NoError(t, err)
+		_, err = other.Open(envelope)
+		assert.ErrorIs(t, err, secrets.ErrUnknownMasterKey)
+	})
+}
+
+func TestRewrap(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+	old, err := secrets.NewKeyring("k1", map[string]string{"k1": k1})
+	require.NoError(t, err)
+	envelope, err := old.Seal([]byte("api-secret"))
+	require.NoError(t, err)
+
+	rotated, err := secrets.NewKeyring("k2", map[string]string{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+	rewrapped, err := rotated.Rewrap(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", rewrapped.MasterKeyID)
+	assert.Equal(t, envelope.Ciphertext, rewrapped.Ciphertext)
+
+	// the old master key is no longer needed once everything is rewrapped
+	retired, err := secrets.NewKeyring("k2", map[string]string{"k2": k2})
+	require.NoError(t, err)
+	plain, err := retired.Open(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "api-secret", string(plain))
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, err := secrets.NewKeyring("k1", map[string]string{"k2": newKey(t)})
+	assert.ErrorIs(t, err, secrets.ErrNoMasterKey)
+
+	_, err = secrets.NewKeyring("k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))})
+	assert.Error(t, err)
+
+	t.Run("Env key wins", func(t *testing.T) {
+		t.Setenv("MASTER_KEY", "prod-1:"+newKey(t))
+		keys, err := secrets.NewKeyringFromConfig(config.Secrets{
+			ActiveKey:  "dev-1",
+			MasterKeys: []config.MasterKey{{ID: "dev-1", Key: newKey(t)}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "prod-1", keys.ActiveKeyID())
+	})
+
+	t.Run("Config key outside development", func(t *testing.T) {
+		t.Setenv("MASTER_KEY", "")
+		cfg := config.Secrets{
+			ActiveKey:  "dev-1",
+			MasterKeys: []config.MasterKey{{ID: "dev-1", Key: newKey(t)}},
+		}
+		_, err := secrets.NewKeyringFromConfig(cfg)
+		assert.ErrorIs(t, err, secrets.ErrConfigMasterKey)
+
+		cfg.Development = true
+		keys, err := secrets.NewKeyringFromConfig(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "dev-1", keys.ActiveKeyID())
+	})
+
+	t.Run("No key", func(t *testing.T) {
+		t.Setenv("MASTER_KEY", "")
+		_, err := secrets.NewKeyringFromConfig(config.Secrets{})
+		assert.ErrorIs(t, err, secrets.ErrNoMasterKey)
+	})
+}
+
+func TestSecretRedaction(t *testing.T) {
+	s := secrets.Secret("api-secret")
+
+	b, err := json.Marshal(struct {
+		Secret secrets.Secret `json:"secret"`
+	}{s})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"secret":"[REDACTED]"}`, string(b))
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q", "%x"} {
+		assert.NotContains(t, fmt.Sprintf(format, s), "api-secret", format)
+	}
+	assert.Equal(t, secrets.Redacted, s.LogValue().String())
+	assert.Equal(t, "api-secret", s.Reveal())
+
+	var req struct {
+		Secret secrets.Secret `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"secret":"api-secret"}`), &req))
+	assert.Equal(t, "api-secret", req.Secret.Reveal())
+
+	assert.Equal(t, "****cdef", secrets.Hint("0123456789abcdef"))
+	assert.Equal(t, "****", secrets.Hint("short"))
+}
@@ -0,0 +1,45 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+const Redacted = "[REDACTED]"
+
+// Secret is a string that never shows itself when it is printed, logged or marshalled, read it with Reveal
+type Secret string
+
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return Redacted
+}
+
+// Format covers the verbs that do not go through String, like %x and %q
+func (s Secret) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, Redacted)
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(Redacted)
+}
+
+// Hint keeps the last 4 characters of a value so an operator can tell keys apart, shorter values are hidden entirely
+func Hint(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/health"
+	"github.com/gin-gonic/gin"
+)
+
+// registerHealth serves the probes of the service. Liveness only says the process serves requests, so a dependency
+// outage never gets the pod restarted, readiness runs the registry and takes the pod out of rotation once it is down.
+func registerHealth(app *gin.Engine, registry *health.Registry) {
+	app.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": consts.HealthOK})
+	})
+
+	app.GET("/readyz", func(c *gin.Context) {
+		res := registry.Check(c.Request.Context())
+		code := http.StatusOK
+		if res.Status == consts.HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, res)
+	})
+}
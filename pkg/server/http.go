@@ -11,13 +11,29 @@ import (
 
 	"github.com/Depado/ginprom"
 	"github.com/SametAvcii/crypto-trade/cmd/app/api/routes"
+	"github.com/SametAvcii/crypto-trade/internal/clients/cache"
 	"github.com/SametAvcii/crypto-trade/internal/clients/database"
+	"github.com/SametAvcii/crypto-trade/internal/clients/kafka"
+	auditlog "github.com/SametAvcii/crypto-trade/pkg/audit"
 	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/audit"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/auth"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/candle"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/credential"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/deadletter"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/exchange"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/logs"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/orderbook"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/signal"
 	"github.com/SametAvcii/crypto-trade/pkg/domains/symbol"
+	"github.com/SametAvcii/crypto-trade/pkg/domains/user"
+	"github.com/SametAvcii/crypto-trade/pkg/exchangeinfo"
+	"github.com/SametAvcii/crypto-trade/pkg/health"
 	"github.com/SametAvcii/crypto-trade/pkg/metrics"
 	"github.com/SametAvcii/crypto-trade/pkg/middleware"
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+	"github.com/SametAvcii/crypto-trade/pkg/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -35,11 +51,13 @@ func init() {
 	metrics.Register()
 }
 
-func LaunchHttpServer(appc config.App, allows config.Allows) {
+func LaunchHttpServer(appc config.App, allows config.Allows, registry *health.Registry, candleService candle.Service) {
 	log.Println("Starting HTTP Server...")
 	gin.SetMode(gin.ReleaseMode)
 
 	app := gin.New()
+	// services get the gin context, the fallback lets them see the request context with its deadline and audit actor
+	app.ContextWithFallback = true
 	app.Use(middleware.PrometheusMiddleware())
 
 	app.Use(gin.LoggerWithFormatter(func(log gin.LogFormatterParams) string {
@@ -58,27 +76,13 @@ func LaunchHttpServer(appc config.App, allows config.Allows) {
 
 	pgDB := database.PgClient()
 
-	app.GET("/healthz", func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
-
-	app.GET("/readyz", func(c *gin.Context) {
-		sqlDB, err := pgDB.DB()
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Database not ready")
-			return
-		}
-		if err := sqlDB.Ping(); err != nil {
-			c.String(http.StatusInternalServerError, "Database not reachable")
-			return
-		}
-		c.String(http.StatusOK, "READY")
-	})
+	registerHealth(app, registry)
 
 	app.Use(cors.New(cors.Config{
 		AllowMethods:     allows.Methods,
 		AllowHeaders:     allows.Headers,
 		AllowOrigins:     allows.Origins,
+		ExposeHeaders:    []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -98,22 +102,75 @@ func LaunchHttpServer(appc config.App, allows config.Allows) {
 	)
 	app.Use(p.Instrument())
 
-	api := app.Group("/api/v1")
-	symbolRoute := api.Group("/symbol")
+	// anyone knowing the secret can sign a token with any role, the server does not start with a guessable one
+	jwt, err := utils.NewJwtWrapper(appc)
+	if err != nil {
+		log.Fatalf("Error starting HTTP server: %v", err)
+	}
+
+	// callers are limited per route group, redis keeps the counters of every instance and memory while it is down
+	limits := config.ReadValue().RateLimit
+	limiter := cache.NewFallbackLimiter(cache.NewRedisLimiter(cache.RedisClient), cache.NewMemoryLimiter())
+	rateLimit := func(group string) gin.HandlerFunc {
+		return middleware.RateLimit(limiter, group, limits.Rule(group))
+	}
+
+	// the login routes are the only ones open without a token
+	public := app.Group("/api/v1")
+	authRoute := public.Group("/auth", rateLimit("auth"))
+	authService := auth.NewService(auth.NewRepo(pgDB), jwt, appc)
+	routes.AuthRoutes(authRoute, authService)
+
+	userService := user.NewService(user.NewRepo(pgDB))
+
+	// market data is readable by every role, exchanges, symbols and candle jobs are managed by admins and the signal
+	// intervals of the strategies by traders. Client ips are limited before their credentials are checked, callers
+	// after it.
+	api := app.Group("/api/v1", middleware.IPRateLimit(limiter, "ip", limits.Rule("ip")), middleware.Auth(jwt, userService))
+	symbolRoute := api.Group("/symbol", middleware.Authorize(consts.RoleViewer, consts.RoleAdmin), rateLimit("symbol"))
 	symbolRepo := symbol.NewRepo(pgDB)
-	symbolService := symbol.NewService(symbolRepo)
+	symbolService := symbol.NewService(symbolRepo, exchangeinfo.NewProvider(pgDB))
 	routes.SymbolRoutes(symbolRoute, symbolService)
 
-	exchangeRoute := api.Group("/exchange")
+	exchangeRoute := api.Group("/exchange", middleware.Authorize(consts.RoleViewer, consts.RoleAdmin), rateLimit("exchange"))
 	exchangeRepo := exchange.NewRepo(pgDB)
 	exchangeService := exchange.NewService(exchangeRepo)
 	routes.ExchangeRoutes(exchangeRoute, exchangeService)
 
-	signalRoute := api.Group("/signal")
+	signalRoute := api.Group("/signal", middleware.Authorize(consts.RoleViewer, consts.RoleTrader), rateLimit("signal"))
 	signalRepo := signal.NewRepo(pgDB)
 	signalService := signal.NewService(signalRepo)
 	routes.SignalRoutes(signalRoute, signalService)
 
+	candleRoute := api.Group("/candles", middleware.Authorize(consts.RoleViewer, consts.RoleAdmin), rateLimit("candles"))
+	routes.CandleRoutes(candleRoute, candleService)
+
+	orderBookRoute := api.Group("/orderbook", middleware.RequireRole(consts.RoleViewer), rateLimit("orderbook"))
+	orderBookRepo := orderbook.NewRepo(pgDB)
+	orderBookService := orderbook.NewService(orderBookRepo, config.ReadValue().Compaction)
+	routes.OrderBookRoutes(orderBookRoute, orderBookService)
+
+	adminRoute := api.Group("/admin", middleware.RequireRole(consts.RoleAdmin), rateLimit("admin"))
+	routes.UserRoutes(adminRoute, userService)
+
+	// without a master key the credentials can still be listed and deleted, adding and rotating them is refused
+	keyring, err := secrets.NewKeyringFromConfig(config.ReadValue().Secrets)
+	if err != nil {
+		log.Printf("exchange credentials can not be sealed: %v", err)
+	}
+	credentialRoute := adminRoute.Group("/credentials")
+	routes.CredentialRoutes(credentialRoute, credential.NewService(credential.NewRepo(pgDB), keyring))
+
+	auditRoute := api.Group("/audit", middleware.RequireRole(consts.RoleAdmin), rateLimit("admin"))
+	routes.AuditRoutes(auditRoute, audit.NewService(audit.NewRepo(pgDB)))
+
+	logRoute := api.Group("/logs", middleware.RequireRole(consts.RoleAdmin), rateLimit("admin"))
+	routes.LogRoutes(logRoute, logs.NewService(logs.NewRepo(pgDB)))
+
+	deadLetterRoute := adminRoute.Group("/dlq")
+	deadLetterService := deadletter.NewService(kafka.KafkaClientNew(), auditlog.NewRecorder(pgDB))
+	routes.DeadLetterRoutes(deadLetterRoute, deadLetterService)
+
 	app.GET("/docs", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "docs/index.html")
 	})
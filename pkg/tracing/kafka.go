@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// producerHeaders carries the trace context into the headers of a message to produce
+type producerHeaders struct {
+	headers *[]sarama.RecordHeader
+}
+
+func (c producerHeaders) Get(key string) string {
+	for _, h := range *c.headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerHeaders) Set(key, value string) {
+	for i, h := range *c.headers {
+		if string(h.Key) == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerHeaders) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, string(h.Key))
+	}
+	return keys
+}
+
+// consumerHeaders carries the trace context out of, and back into, the headers of a consumed message
+type consumerHeaders struct {
+	msg *sarama.ConsumerMessage
+}
+
+func (c consumerHeaders) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c consumerHeaders) Set(key, value string) {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			h.Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c consumerHeaders) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
+
+// KafkaHeaders returns the headers with the traceparent of the span of ctx, headers are not modified
+func KafkaHeaders(ctx context.Context, headers []sarama.RecordHeader) []sarama.RecordHeader {
+	out := append([]sarama.RecordHeader(nil), headers...)
+	otel.GetTextMapPropagator().Inject(ctx, producerHeaders{headers: &out})
+	return out
+}
+
+// MessageContext returns a context holding the span the message was handled or produced under, a handler gets the
+// span of its consumer from it
+func MessageContext(msg *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), consumerHeaders{msg: msg})
+}
+
+// SetMessageContext replaces the trace context in the headers of the consumed message by the one of ctx
+func SetMessageContext(ctx context.Context, msg *sarama.ConsumerMessage) {
+	otel.GetTextMapPropagator().Inject(ctx, consumerHeaders{msg: msg})
+}
+
+// Carrier returns the trace context of ctx as a map, to be stored with data that is produced later
+func Carrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// FromCarrier returns a context holding the trace context of a map returned by Carrier
+func FromCarrier(carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+}
@@ -0,0 +1,67 @@
+// Package tracing sets up the OpenTelemetry tracer of the services and instruments the clients, the trace context
+// travels in the http headers and in the kafka message headers so a frame can be followed from the websocket to the
+// signal it produced.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name is the instrumentation scope of the spans started by the services
+const Name = "github.com/SametAvcii/crypto-trade"
+
+// Init installs the W3C trace context propagator and, when enabled, a provider exporting to the collector of cfg.
+// shutdown flushes the spans still queued.
+func Init(ctx context.Context, cfg config.Tracing, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	// the variable is a full url and is read by the exporter itself
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(sdktrace.NewBatchSpanProcessor(exporter), service, cfg.SampleRatio)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a provider sampling cfg.SampleRatio of the new traces, a span with a parent is sampled when its
+// parent is. Tests pass a syncer of an in-memory exporter.
+func NewProvider(processor sdktrace.SpanProcessor, service string, ratio float64) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service)))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+}
+
+// Tracer returns the tracer of the global provider, it is looked up on each call so spans follow a provider
+// installed later
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
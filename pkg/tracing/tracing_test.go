@@ -0,0 +1,121 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), "test", 1)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	_, err := tracing.Init(context.Background(), config.Tracing{}, "test")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func spanContext(ctx context.Context) trace.SpanContext {
+	return trace.SpanContextFromContext(ctx)
+}
+
+func TestInitDisabled(t *testing.T) {
+	shutdown, err := tracing.Init(context.Background(), config.Tracing{Endpoint: "localhost:4318"}, "test")
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, otel.GetTextMapPropagator().Fields())
+}
+
+func TestKafkaHeaders(t *testing.T) {
+	spans := recordSpans(t)
+	ctx, span := tracing.Tracer().Start(context.Background(), "frame")
+	span.End()
+
+	schema := []sarama.RecordHeader{{Key: []byte("schema"), Value: []byte("envelope")}}
+	headers := tracing.KafkaHeaders(ctx, schema)
+	assert.Len(t, schema, 1)
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "schema", string(headers[0].Key))
+
+	// a stale traceparent is replaced and not duplicated
+	other, otherSpan := tracing.Tracer().Start(context.Background(), "other")
+	otherSpan.End()
+	headers = tracing.KafkaHeaders(other, headers)
+	assert.Len(t, headers, 2)
+
+	msg := &sarama.ConsumerMessage{}
+	for _, h := range headers {
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	assert.Equal(t, spans.GetSpans()[1].SpanContext.TraceID(), spanContext(tracing.MessageContext(msg)).TraceID())
+
+	// the consumer puts its own span in the message
+	consumerCtx, consumerSpan := tracing.Tracer().Start(tracing.MessageContext(msg), "process")
+	consumerSpan.End()
+	tracing.SetMessageContext(consumerCtx, msg)
+	assert.Len(t, msg.Headers, 2)
+	assert.Equal(t, spanContext(consumerCtx).SpanID(), spanContext(tracing.MessageContext(msg)).SpanID())
+}
+
+func TestCarrier(t *testing.T) {
+	recordSpans(t)
+	assert.Nil(t, tracing.Carrier(context.Background()))
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "frame")
+	span.End()
+	carrier := tracing.Carrier(ctx)
+	assert.Contains(t, carrier, "traceparent")
+	assert.Equal(t, spanContext(ctx).TraceID(), spanContext(tracing.FromCarrier(carrier)).TraceID())
+}
+
+func TestGormPlugin(t *testing.T) {
+	spans := recordSpans(t)
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+
+	mock.ExpectQuery(`SELECT \* FROM "symbols"`).WillReturnRows(sqlmock.NewRows([]string{"symbol"}))
+	mock.ExpectQuery(`SELECT \* FROM "symbols"`).WillReturnRows(sqlmock.NewRows([]string{"symbol"}))
+
+	var rows []map[string]any
+	// statements outside a trace are not traced
+	assert.NoError(t, db.Table("symbols").Find(&rows).Error)
+	assert.Empty(t, spans.GetSpans())
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "process")
+	assert.NoError(t, db.WithContext(ctx).Table("symbols").Find(&rows).Error)
+	parent.End()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	recorded := spans.GetSpans()
+	assert.Len(t, recorded, 2)
+	assert.Equal(t, "postgres.select", recorded[0].Name)
+	assert.Equal(t, spanContext(ctx).SpanID(), recorded[0].Parent.SpanID())
+	attrs := map[string]string{}
+	for _, attr := range recorded[0].Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	assert.Equal(t, "postgresql", attrs["db.system"])
+	assert.Equal(t, "symbols", attrs["db.collection.name"])
+	assert.Contains(t, attrs["db.query.text"], `SELECT * FROM "symbols"`)
+}
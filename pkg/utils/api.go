@@ -1,41 +1,169 @@
 package utils
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	ErrNoSigner = errors.New("api has no signer for signed endpoints")
+	ErrDecode   = errors.New("exchange response can not be decoded")
+)
+
+const (
+	defaultMaxRetries = 3
+	maxErrorBody      = 4 << 10
+)
+
+// retryBackoff is the first wait between attempts when the exchange sent no Retry-After, it doubles on each attempt
+var retryBackoff = 500 * time.Millisecond
+
+// httpClient is shared by every API so connections to an exchange are reused, the deadline of a call comes from its
+// context
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// APIError is a response with an error status, Code and Msg are filled from the {code,msg} body binance returns
+type APIError struct {
+	StatusCode int           `json:"-"`
+	Code       int           `json:"code"` // -1121 invalid symbol, -1021 timestamp outside of recvWindow, etc
+	Msg        string        `json:"msg"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("exchange api error %d: code %d: %s", e.StatusCode, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("exchange api error %d: %s", e.StatusCode, e.Msg)
+}
+
+// RateLimited reports whether the exchange refused the request because of its limits, 418 is an ip ban after
+// ignoring a 429
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusTeapot
+}
+
 type API struct {
-	EndPoint string `json:"end_point"`
+	EndPoint   string         `json:"end_point"`
+	Signer     Signer         `json:"-"` // signs the requests of the Signed methods
+	MaxRetries int            `json:"-"` // retries of a request the exchange rate limited or failed with a 5xx
+	Client     *http.Client   `json:"-"`
+	Weight     *WeightTracker `json:"-"` // shared by the apis of the same endpoint
 }
 
 func NewAPI(endpoint string) *API {
 	return &API{
-		EndPoint: endpoint,
+		EndPoint:   endpoint,
+		MaxRetries: defaultMaxRetries,
+		Client:     httpClient,
+		Weight:     WeightFor(endpoint),
 	}
 }
 
-func (t *API) Get(path string, payload interface{}, response interface{}, headers ...map[string]string) error {
-	url := t.EndPoint + path
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err
+// Request is a call to the exchange, GET and DELETE send the params in the query and POST and PUT as a form body
+type Request struct {
+	Method string
+	Path   string
+	Params url.Values
+	Signed bool
+}
+
+func (t *API) Get(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodGet, Path: path, Params: params}, response)
+}
+
+func (t *API) Post(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodPost, Path: path, Params: params}, response)
+}
+
+func (t *API) Delete(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodDelete, Path: path, Params: params}, response)
+}
+
+// GetSigned calls an endpoint that needs the account credentials, the params are signed by the signer of the api
+func (t *API) GetSigned(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodGet, Path: path, Params: params, Signed: true}, response)
+}
+
+func (t *API) PostSigned(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodPost, Path: path, Params: params, Signed: true}, response)
+}
+
+func (t *API) DeleteSigned(ctx context.Context, path string, params url.Values, response interface{}) error {
+	return t.Do(ctx, Request{Method: http.MethodDelete, Path: path, Params: params, Signed: true}, response)
+}
+
+// Do sends the request and decodes a successful response into response, rate limited requests are retried after
+// the Retry-After of the exchange, 5xx and network errors only for GET and DELETE since a POST may have been applied
+func (t *API) Do(ctx context.Context, r Request, response interface{}) error {
+	if r.Signed && t.Signer == nil {
+		return ErrNoSigner
 	}
-	req.Header.Set("Content-Type", "application/json")
-	if len(headers) > 0 {
-		for k, v := range headers[0] {
-			req.Header.Set(k, v)
+
+	for attempt := 0; ; attempt++ {
+		if t.Weight != nil {
+			if err := sleep(ctx, time.Until(t.Weight.BlockedUntil())); err != nil {
+				return err
+			}
+		}
+
+		err := t.send(ctx, r, response)
+		if err == nil || attempt >= t.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		wait, retry := retryAfter(err, r.Method, attempt)
+		if !retry {
+			return err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
 		}
 	}
-	return t.do(req, response)
 }
 
-func (t *API) do(req *http.Request, response interface{}) error {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// send makes a single attempt, a signed request is signed again on every attempt so its timestamp stays fresh
+func (t *API) send(ctx context.Context, r Request, response interface{}) error {
+	encoded := r.Params.Encode()
+	headers := map[string]string{}
+	if r.Signed {
+		encoded, headers = t.Signer.Sign(r.Params)
+	}
+
+	target := t.EndPoint + r.Path
+	var body io.Reader
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		body = strings.NewReader(encoded)
+		headers["Content-Type"] = "application/x-www-form-urlencoded"
+	default:
+		if encoded != "" {
+			target += "?" + encoded
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := t.Client
+	if client == nil {
+		client = httpClient
 	}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -43,20 +171,76 @@ func (t *API) do(req *http.Request, response interface{}) error {
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-
-	decode := json.NewDecoder(bytes.NewReader(body))
-	decode.DisallowUnknownFields()
-	decode.UseNumber()
-	if err := decode.Decode(response); err != nil {
-		return err
+	if t.Weight != nil {
+		t.Weight.Update(resp.Header)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := decodeError(resp)
+		if apiErr.RateLimited() && t.Weight != nil {
+			t.Weight.Block(apiErr.RetryAfter)
+		}
+		return apiErr
+	}
+	if response == nil {
+		return nil
 	}
 
-	decode = json.NewDecoder(bytes.NewReader(body))
-	decode.DisallowUnknownFields()
+	decode := json.NewDecoder(resp.Body)
 	decode.UseNumber()
 	if err := decode.Decode(response); err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrDecode, err)
 	}
 	return nil
 }
+
+func decodeError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Msg == "" {
+		apiErr.Msg = strings.TrimSpace(string(body))
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// retryAfter returns how long to wait before the next attempt and whether the error is worth another attempt
+func retryAfter(err error, method string, attempt int) (time.Duration, bool) {
+	backoff := retryBackoff << attempt
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+
+	if errors.Is(err, ErrDecode) {
+		return 0, false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// the request may not have reached the exchange, only idempotent ones are sent again
+		return backoff, idempotent
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+		return backoff, true
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return backoff, idempotent
+	default:
+		return 0, false
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
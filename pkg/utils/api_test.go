@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+// replay answers the calls with the handlers in order, the last one answers every call after them
+func replay(calls *atomic.Int32, handlers ...http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		handlers[min(i, len(handlers)-1)](w, r)
+	}))
+}
+
+func status(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		io.WriteString(w, body)
+	}
+}
+
+func TestAPIGet(t *testing.T) {
+	var calls atomic.Int32
+	srv := replay(&calls, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "BTCUSDT", r.URL.Query().Get("symbol"))
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "42")
+		io.WriteString(w, `{"symbol":"BTCUSDT","price":"1.5","newField":true}`)
+	})
+	defer srv.Close()
+
+	api := NewAPI(srv.URL)
+	var res struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	require.NoError(t, api.Get(context.Background(), "/ticker/price", url.Values{"symbol": {"BTCUSDT"}}, &res))
+	assert.Equal(t, "1.5", res.Price)
+	assert.Equal(t, int32(1), calls.Load())
+
+	used, _ := api.Weight.Used("1m")
+	assert.Equal(t, 42, used)
+	assert.Same(t, api.Weight, NewAPI(srv.URL+"/api/v3").Weight, "apis of an exchange share its weight")
+}
+
+func TestAPIError(t *testing.T) {
+	var calls atomic.Int32
+	srv := replay(&calls, status(http.StatusBadRequest, `{"code":-1121,"msg":"Invalid symbol."}`))
+	defer srv.Close()
+
+	err := NewAPI(srv.URL).Get(context.Background(), "/ticker/price", url.Values{"symbol": {"NOPE"}}, nil)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, -1121, apiErr.Code)
+	assert.Equal(t, "Invalid symbol.", apiErr.Msg)
+	assert.Equal(t, int32(1), calls.Load(), "client errors are not retried")
+}
+
+func TestAPIRetry(t *testing.T) {
+	t.Run("GET is retried on 5xx", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := replay(&calls, status(http.StatusServiceUnavailable, "busy"), status(http.StatusBadGateway, ""), status(http.StatusOK, `{}`))
+		defer srv.Close()
+
+		assert.NoError(t, NewAPI(srv.URL).Get(context.Background(), "/time", nil, &struct{}{}))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("POST is not retried on 5xx", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := replay(&calls, status(http.StatusInternalServerError, `{"code":-1000,"msg":"unknown"}`))
+		defer srv.Close()
+
+		err := NewAPI(srv.URL).Post(context.Background(), "/order", url.Values{"symbol": {"BTCUSDT"}}, nil)
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Gives up after the retries", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := replay(&calls, status(http.StatusServiceUnavailable, ""))
+		defer srv.Close()
+
+		api := NewAPI(srv.URL)
+		api.MaxRetries = 2
+		assert.Error(t, api.Get(context.Background(), "/time", nil, nil))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+}
+
+func TestAPIRateLimited(t *testing.T) {
+	var calls atomic.Int32
+	srv := replay(&calls, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		status(http.StatusTooManyRequests, `{"code":-1003,"msg":"Too many requests"}`)(w, r)
+	}, status(http.StatusOK, `{}`))
+	defer srv.Close()
+
+	api := NewAPI(srv.URL)
+	started := time.Now()
+	require.NoError(t, api.Post(context.Background(), "/order", nil, &struct{}{}))
+	assert.Equal(t, int32(2), calls.Load(), "a rate limited POST was not applied and is sent again")
+	assert.GreaterOrEqual(t, time.Since(started), time.Second)
+
+	t.Run("Cancelled while waiting", func(t *testing.T) {
+		api.Weight.Block(time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, api.Get(ctx, "/time", nil, nil), context.DeadlineExceeded)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestAPISigned(t *testing.T) {
+	var got url.Values
+	var calls atomic.Int32
+	srv := replay(&calls, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		got = r.PostForm
+		assert.Equal(t, binanceExampleKey, r.Header.Get(BinanceAPIKeyHeader))
+		io.WriteString(w, `{"orderId":1}`)
+	})
+	defer srv.Close()
+
+	api := NewAPI(srv.URL)
+	assert.ErrorIs(t, api.PostSigned(context.Background(), "/order", nil, nil), ErrNoSigner)
+
+	api.Signer = NewHMACSigner(binanceExampleKey, binanceExampleSecret)
+	require.NoError(t, api.PostSigned(context.Background(), "/order", url.Values{"symbol": {"BTCUSDT"}, "side": {"BUY"}}, nil))
+	assert.Equal(t, "BTCUSDT", got.Get("symbol"))
+	assert.NotEmpty(t, got.Get("timestamp"))
+	assert.NotEmpty(t, got.Get("signature"))
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
+)
+
+var ErrAPIKeyInvalid = errors.New(consts.AuthInvalidAPIKey)
+
+const (
+	apiKeyPrefixBytes = 8
+	apiKeySecretBytes = 32
+)
+
+// GenerateAPIKey returns a new key in the ct_<prefix>_<secret> form, the prefix to look it up and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(buf[:apiKeyPrefixBytes])
+	key = consts.APIKeyPrefix + "_" + prefix + "_" + hex.EncodeToString(buf[apiKeyPrefixBytes:])
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// APIKeyPrefix returns the prefix of a key made by GenerateAPIKey
+func APIKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != consts.APIKeyPrefix || len(parts[1]) != 2*apiKeyPrefixBytes || len(parts[2]) != 2*apiKeySecretBytes {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// HashAPIKey is the hex sha256 of the key, keys are random so they do not need a slow hash like passwords
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey compares the key with a stored hash in constant time
+func CheckAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -1,13 +1,25 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/SametAvcii/crypto-trade/pkg/consts"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// defaultJwtSecret is the secret the configs used to ship with, tokens signed with it can be forged by anyone
+const defaultJwtSecret = "secret"
+
+var (
+	ErrTokenInvalid = errors.New(consts.AuthInvalidToken)
+	ErrTokenExpired = errors.New(consts.AuthExpiredToken)
+	ErrJwtSecret    = errors.New("JWT_SECRET must be set, the default secret is only accepted in development")
+)
+
 type JwtCustomClaim struct {
 	UserID         string
 	DeviceID       string
@@ -24,10 +36,25 @@ type JwtWrapper struct {
 	Expire    int
 }
 
+// NewJwtWrapper signs with the JWT_SECRET env, or jwt_secret of the config without it. An empty secret is refused and
+// the default one outside of development.
+func NewJwtWrapper(appc config.App) (*JwtWrapper, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = appc.JwtSecret
+	}
+	if secret == "" || (secret == defaultJwtSecret && !appc.Development) {
+		return nil, ErrJwtSecret
+	}
+	return &JwtWrapper{SecretKey: secret, Issuer: appc.JwtIssuer, Expire: appc.JwtExpire}, nil
+}
+
 type JwtClaim struct {
-	ID       string
-	UserName string
-	UserId   string
+	ID        string
+	UserName  string
+	UserId    string
+	Role      string // admin, trader, viewer
+	TokenType string // access or refresh
 	jwt.RegisteredClaims
 }
 
@@ -75,11 +102,22 @@ func (j *JwtWrapper) ValidateToken(tokenString string) bool {
 }
 
 func (j *JwtWrapper) GenerateJWT(userName, userId string) (string, error) {
+	return j.GenerateToken(userName, userId, consts.RoleViewer, consts.TokenTypeAccess, time.Hour*time.Duration(config.ReadValue().App.JwtExpire))
+}
+
+// GenerateToken signs a token of the type for the user with the role, it expires after expire
+func (j *JwtWrapper) GenerateToken(userName, userId, role, tokenType string, expire time.Duration) (string, error) {
+	now := time.Now()
 	claims := &JwtClaim{
-		UserName: userName,
-		UserId:   userId,
+		UserName:  userName,
+		UserId:    userId,
+		Role:      role,
+		TokenType: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Local().Add(time.Hour * time.Duration(config.ReadValue().App.JwtExpire))),
+			Issuer:    j.Issuer,
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expire)),
 		},
 	}
 
@@ -91,3 +129,27 @@ func (j *JwtWrapper) GenerateJWT(userName, userId string) (string, error) {
 	}
 	return signedToken, nil
 }
+
+// ParseClaims checks the signature, issuer and expiry of a token signed by GenerateToken and returns its claims,
+// expired tokens return ErrTokenExpired and every other failure ErrTokenInvalid
+func (j *JwtWrapper) ParseClaims(tokenString string) (*JwtClaim, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if j.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.Issuer))
+	}
+
+	claims := &JwtClaim{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(j.SecretKey), nil
+	}, opts...)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+	return claims, nil
+}
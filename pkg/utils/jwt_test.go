@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/SametAvcii/crypto-trade/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJwtWrapper(t *testing.T) {
+	appc := config.App{JwtIssuer: "crypto-trade", JwtExpire: 1}
+
+	t.Setenv("JWT_SECRET", "")
+	_, err := NewJwtWrapper(appc)
+	assert.ErrorIs(t, err, ErrJwtSecret)
+
+	appc.JwtSecret = defaultJwtSecret
+	_, err = NewJwtWrapper(appc)
+	assert.ErrorIs(t, err, ErrJwtSecret)
+
+	appc.Development = true
+	j, err := NewJwtWrapper(appc)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultJwtSecret, j.SecretKey)
+
+	// the env wins over the config
+	appc.Development = false
+	t.Setenv("JWT_SECRET", "8Rk2m0WqvTn3yZ5c")
+	j, err = NewJwtWrapper(appc)
+	assert.NoError(t, err)
+	assert.Equal(t, "8Rk2m0WqvTn3yZ5c", j.SecretKey)
+	assert.Equal(t, "crypto-trade", j.Issuer)
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/secrets"
+)
+
+const BinanceAPIKeyHeader = "X-MBX-APIKEY"
+
+// Signer signs the parameters of a request to an authenticated exchange endpoint, it returns the query to send and
+// the headers that identify the account
+type Signer interface {
+	Sign(params url.Values) (query string, headers map[string]string)
+}
+
+// HMACSigner signs binance SIGNED endpoints, the query gets a timestamp and the hex HMAC-SHA256 of itself keyed by
+// the api secret
+type HMACSigner struct {
+	APIKey     secrets.Secret
+	Secret     secrets.Secret
+	RecvWindow time.Duration // how long binance accepts the request after the timestamp, its default 5s when 0
+	Now        func() time.Time
+}
+
+func NewHMACSigner(apiKey, secret secrets.Secret) *HMACSigner {
+	return &HMACSigner{
+		APIKey: apiKey,
+		Secret: secret,
+		Now:    time.Now,
+	}
+}
+
+func (s *HMACSigner) Sign(params url.Values) (string, map[string]string) {
+	signed := url.Values{}
+	for k, v := range params {
+		signed[k] = append([]string(nil), v...)
+	}
+	if s.RecvWindow > 0 {
+		signed.Set("recvWindow", strconv.FormatInt(s.RecvWindow.Milliseconds(), 10))
+	}
+	signed.Set("timestamp", strconv.FormatInt(s.Now().UnixMilli(), 10))
+
+	// the signature has to be the last parameter, so it is appended to the encoded query instead of the values
+	query := signed.Encode()
+	query += "&signature=" + SignHMAC(s.Secret, query)
+	return query, map[string]string{BinanceAPIKeyHeader: s.APIKey.Reveal()}
+}
+
+// SignHMAC returns the hex HMAC-SHA256 of payload
+func SignHMAC(secret secrets.Secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret.Reveal()))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
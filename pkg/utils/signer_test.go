@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the example of the binance docs for SIGNED endpoints
+const (
+	binanceExampleKey    = "vmPUZE6mv9SD5VNHk4HlWFsOr6aKE2zvsw0MuIgwCIPy6utIco14y7Ju91duEh8A"
+	binanceExampleSecret = "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"
+)
+
+func TestSignHMAC(t *testing.T) {
+	query := "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559"
+	assert.Equal(t, "c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71", SignHMAC(binanceExampleSecret, query))
+}
+
+func TestHMACSigner(t *testing.T) {
+	signer := NewHMACSigner(binanceExampleKey, binanceExampleSecret)
+	signer.RecvWindow = 5 * time.Second
+	signer.Now = func() time.Time { return time.UnixMilli(1499827319559) }
+
+	params := url.Values{"symbol": {"LTCBTC"}}
+	query, headers := signer.Sign(params)
+
+	unsigned := "recvWindow=5000&symbol=LTCBTC&timestamp=1499827319559"
+	assert.Equal(t, unsigned+"&signature="+SignHMAC(binanceExampleSecret, unsigned), query)
+	assert.Equal(t, binanceExampleKey, headers[BinanceAPIKeyHeader])
+	assert.Len(t, params, 1, "the params of the caller are not changed")
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SametAvcii/crypto-trade/pkg/metrics"
+)
+
+const usedWeightHeader = "X-Mbx-Used-Weight"
+
+var (
+	weightsMu sync.Mutex
+	weights   = map[string]*WeightTracker{}
+)
+
+// WeightTracker keeps the request weight an exchange reports it has counted against this ip and the time until
+// which the exchange asked to be left alone
+type WeightTracker struct {
+	host         string
+	mu           sync.Mutex
+	used         map[string]int // by interval, 1m, 1d, etc
+	updatedAt    time.Time
+	blockedUntil time.Time
+}
+
+// WeightFor returns the tracker of the host of the endpoint, every api of an exchange shares it
+func WeightFor(endpoint string) *WeightTracker {
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	weightsMu.Lock()
+	defer weightsMu.Unlock()
+	w, ok := weights[host]
+	if !ok {
+		w = &WeightTracker{host: host, used: map[string]int{}}
+		weights[host] = w
+	}
+	return w
+}
+
+// Update reads the X-MBX-USED-WEIGHT-<interval> headers of a response
+func (w *WeightTracker) Update(h http.Header) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, values := range h {
+		if !strings.HasPrefix(key, usedWeightHeader) || len(values) == 0 {
+			continue
+		}
+		used, err := strconv.Atoi(values[0])
+		if err != nil {
+			continue
+		}
+		interval := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(key, usedWeightHeader), "-"))
+		if interval == "" {
+			interval = "default"
+		}
+		w.used[interval] = used
+		w.updatedAt = time.Now()
+		metrics.ExchangeUsedWeight.WithLabelValues(w.host, interval).Set(float64(used))
+	}
+}
+
+// Used returns the last weight the exchange reported for the interval and when it was reported
+func (w *WeightTracker) Used(interval string) (int, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.used[interval], w.updatedAt
+}
+
+// Block holds back the requests to the exchange for d, a 429 or 418 carries how long in its Retry-After
+func (w *WeightTracker) Block(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if until := time.Now().Add(d); until.After(w.blockedUntil) {
+		w.blockedUntil = until
+	}
+}
+
+func (w *WeightTracker) BlockedUntil() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.blockedUntil
+}